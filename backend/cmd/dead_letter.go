@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	gqlmodels "github.com/ringecosystem/degov-square/graph/models"
+)
+
+const deadLetterCommandName = "dead-letter"
+
+const deadLetterUsage = `Usage: degov-server dead-letter <list|replay|discard> [flags]
+
+Inspect notification events and records that ran out of retries, replay them
+with a fresh retry budget or discard them. replay and discard need at least one
+of -id, -dao, -type, -since or -until.
+
+Flags:
+`
+
+type deadLetterService interface {
+	List(input gqlmodels.NotificationDeadLettersInput) (*gqlmodels.NotificationDeadLetterPage, error)
+	Replay(input gqlmodels.NotificationDeadLetterActionInput) (int32, error)
+	Discard(input gqlmodels.NotificationDeadLetterActionInput) (int32, error)
+}
+
+type deadLetterCommand struct {
+	action string
+	list   gqlmodels.NotificationDeadLettersInput
+	bulk   gqlmodels.NotificationDeadLetterActionInput
+}
+
+// parseDeadLetterCommand reads the action and its flags, times are RFC 3339 or a duration before now such as 24h
+func parseDeadLetterCommand(args []string, now time.Time, output io.Writer) (*deadLetterCommand, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprint(output, deadLetterUsage)
+		return nil, errors.New("missing action, use list, replay or discard")
+	}
+	command := &deadLetterCommand{action: args[0]}
+	if command.action != "list" && command.action != "replay" && command.action != "discard" {
+		return nil, fmt.Errorf("unknown action %q, use list, replay or discard", command.action)
+	}
+
+	flags := flag.NewFlagSet(deadLetterCommandName+" "+command.action, flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.Usage = func() {
+		fmt.Fprint(output, deadLetterUsage)
+		flags.PrintDefaults()
+	}
+	kind := flags.String("kind", "record", "event or record")
+	ids := flags.String("id", "", "comma separated ids")
+	daoCode := flags.String("dao", "", "DAO code")
+	features := flags.String("type", "", "comma separated notification types, e.g. PROPOSAL_NEW,VOTE_EMITTED")
+	since := flags.String("since", "", "last attempt at or after, RFC 3339 or a duration ago")
+	until := flags.String("until", "", "last attempt before, RFC 3339 or a duration ago")
+	limit := flags.Int("limit", 50, "page size of list")
+	offset := flags.Int("offset", 0, "offset of list")
+	if err := flags.Parse(args[1:]); err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments %v", flags.Args())
+	}
+
+	command.bulk.Kind = gqlmodels.NotificationDeadLetterKind(strings.ToUpper(*kind))
+	if !command.bulk.Kind.IsValid() {
+		return nil, fmt.Errorf("unknown kind %q, use event or record", *kind)
+	}
+	command.bulk.Ids = splitFlagList(*ids)
+	if *daoCode != "" {
+		command.bulk.DaoCode = daoCode
+	}
+	for _, feature := range splitFlagList(*features) {
+		name := gqlmodels.FeatureName(strings.ToUpper(feature))
+		if !name.IsValid() {
+			return nil, fmt.Errorf("unknown notification type %q", feature)
+		}
+		command.bulk.Types = append(command.bulk.Types, name)
+	}
+	var err error
+	if command.bulk.Since, err = parseFlagTime(*since, now); err != nil {
+		return nil, fmt.Errorf("invalid -since: %w", err)
+	}
+	if command.bulk.Until, err = parseFlagTime(*until, now); err != nil {
+		return nil, fmt.Errorf("invalid -until: %w", err)
+	}
+
+	if command.action == "list" {
+		if len(command.bulk.Ids) > 0 {
+			return nil, errors.New("-id is only supported by replay and discard")
+		}
+		pageSize, pageOffset := int32(*limit), int32(*offset)
+		command.list = gqlmodels.NotificationDeadLettersInput{
+			Kind:    command.bulk.Kind,
+			DaoCode: command.bulk.DaoCode,
+			Types:   command.bulk.Types,
+			Since:   command.bulk.Since,
+			Until:   command.bulk.Until,
+			Limit:   &pageSize,
+			Offset:  &pageOffset,
+		}
+	}
+	return command, nil
+}
+
+func runDeadLetterCommand(service deadLetterService, args []string, output io.Writer) error {
+	command, err := parseDeadLetterCommand(args, time.Now(), output)
+	if err != nil {
+		return err
+	}
+
+	switch command.action {
+	case "list":
+		page, err := service.List(command.list)
+		if err != nil {
+			return err
+		}
+		printDeadLetters(output, page, int(*command.list.Offset))
+	case "replay":
+		replayed, err := service.Replay(command.bulk)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(output, "Replayed %d %s dead letters\n", replayed, strings.ToLower(string(command.bulk.Kind)))
+	case "discard":
+		discarded, err := service.Discard(command.bulk)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(output, "Discarded %d %s dead letters\n", discarded, strings.ToLower(string(command.bulk.Kind)))
+	}
+	return nil
+}
+
+func printDeadLetters(output io.Writer, page *gqlmodels.NotificationDeadLetterPage, offset int) {
+	for _, item := range page.Items {
+		fmt.Fprintf(output, "%s %s dao=%s type=%s proposal=%s", item.Kind, item.ID, item.DaoCode, item.Type, item.ProposalID)
+		if item.VoteID != nil {
+			fmt.Fprintf(output, " vote=%s", *item.VoteID)
+		}
+		if item.UserAddress != nil {
+			fmt.Fprintf(output, " user=%s", *item.UserAddress)
+		}
+		fmt.Fprintf(output, " retries=%d last_attempt=%s\n", item.TimesRetry, item.Utime.UTC().Format(time.RFC3339))
+		if item.Message != nil {
+			for _, line := range strings.Split(strings.TrimSpace(*item.Message), "\n") {
+				fmt.Fprintf(output, "    %s\n", line)
+			}
+		}
+		fmt.Fprintln(output)
+	}
+	if len(page.Items) == 0 {
+		if page.Total > 0 {
+			fmt.Fprintf(output, "No dead letters after offset %d, %d in total\n", offset, page.Total)
+		} else {
+			fmt.Fprintln(output, "No dead letters")
+		}
+		return
+	}
+	fmt.Fprintf(output, "Showing %d-%d of %d\n", offset+1, offset+len(page.Items), page.Total)
+}
+
+func splitFlagList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func parseFlagTime(value string, now time.Time) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if ago, err := time.ParseDuration(value); err == nil {
+		t := now.Add(-ago)
+		return &t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	gqlmodels "github.com/ringecosystem/degov-square/graph/models"
+)
+
+type fakeDeadLetterService struct {
+	replayed []gqlmodels.NotificationDeadLetterActionInput
+}
+
+func (f *fakeDeadLetterService) List(input gqlmodels.NotificationDeadLettersInput) (*gqlmodels.NotificationDeadLetterPage, error) {
+	message := "[1] Failed to build notification record: timeout\n\n-------\n[2] Failed to build notification record: timeout"
+	return &gqlmodels.NotificationDeadLetterPage{
+		Items: []*gqlmodels.NotificationDeadLetter{{
+			ID:         "r1",
+			Kind:       input.Kind,
+			DaoCode:    "demo",
+			Type:       gqlmodels.FeatureNameProposalNew,
+			ProposalID: "0x01",
+			TimesRetry: 2,
+			Message:    &message,
+		}},
+		Total: 3,
+	}, nil
+}
+
+func (f *fakeDeadLetterService) Replay(input gqlmodels.NotificationDeadLetterActionInput) (int32, error) {
+	f.replayed = append(f.replayed, input)
+	return int32(len(input.Ids)), nil
+}
+
+func (f *fakeDeadLetterService) Discard(input gqlmodels.NotificationDeadLetterActionInput) (int32, error) {
+	return 0, nil
+}
+
+func TestParseDeadLetterCommandFilters(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	command, err := parseDeadLetterCommand([]string{"list", "-kind", "event", "-dao", "demo", "-type", "vote_emitted,PROPOSAL_NEW", "-since", "24h", "-until", "2026-05-01T11:00:00Z"}, now, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	input := command.list
+	if input.Kind != gqlmodels.NotificationDeadLetterKindEvent || *input.DaoCode != "demo" || len(input.Types) != 2 || input.Types[0] != gqlmodels.FeatureNameVoteEmitted {
+		t.Fatalf("unexpected input %+v", input)
+	}
+	if !input.Since.Equal(now.Add(-24*time.Hour)) || !input.Until.Equal(now.Add(-time.Hour)) {
+		t.Fatalf("unexpected time range %v - %v", input.Since, input.Until)
+	}
+
+	for _, args := range [][]string{{}, {"purge"}, {"list", "-kind", "delivery"}, {"list", "-type", "UNKNOWN"}, {"list", "-id", "r1"}} {
+		if _, err := parseDeadLetterCommand(args, now, &bytes.Buffer{}); err == nil {
+			t.Fatalf("expected %v to be refused", args)
+		}
+	}
+}
+
+func TestRunDeadLetterCommand(t *testing.T) {
+	service := &fakeDeadLetterService{}
+	var output bytes.Buffer
+	if err := runDeadLetterCommand(service, []string{"replay", "-id", "r1, r2"}, &output); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if len(service.replayed) != 1 || len(service.replayed[0].Ids) != 2 || service.replayed[0].Kind != gqlmodels.NotificationDeadLetterKindRecord {
+		t.Fatalf("unexpected replay %+v", service.replayed)
+	}
+	if output.String() != "Replayed 2 record dead letters\n" {
+		t.Fatalf("unexpected output %q", output.String())
+	}
+
+	output.Reset()
+	if err := runDeadLetterCommand(service, []string{"list"}, &output); err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if !strings.Contains(output.String(), "    [2] Failed to build notification record: timeout\n") || !strings.HasSuffix(output.String(), "Showing 1-1 of 3\n") {
+		t.Fatalf("unexpected list output %q", output.String())
+	}
+}
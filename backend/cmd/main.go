@@ -16,6 +16,7 @@ import (
 	"github.com/99designs/gqlgen/graphql/handler/lru"
 	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/gorilla/websocket"
 	"github.com/ringecosystem/degov-square/graph"
 	"github.com/ringecosystem/degov-square/internal"
 	"github.com/ringecosystem/degov-square/internal/config"
@@ -23,6 +24,7 @@ import (
 	mcpserver "github.com/ringecosystem/degov-square/internal/mcp"
 	"github.com/ringecosystem/degov-square/internal/middleware"
 	"github.com/ringecosystem/degov-square/routes"
+	"github.com/ringecosystem/degov-square/services"
 	"github.com/ringecosystem/degov-square/tasks"
 	"github.com/rs/cors"
 	"github.com/vektah/gqlparser/v2/ast"
@@ -36,6 +38,15 @@ const (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == deadLetterCommandName {
+		internal.AppInit()
+		if err := runDeadLetterCommand(services.NewNotificationDeadLetterService(), os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		return
+	}
+
 	if Version == "" {
 		fmt.Println("Version: Debug")
 	} else {
@@ -128,6 +139,14 @@ func startServer() {
 
 	gqlSrv := handler.New(graph.NewExecutableSchema(graphqlConfig))
 
+	gqlSrv.AddTransport(transport.Websocket{
+		// browsers send an Origin on websocket upgrades, CORS allows every origin for the API as well
+		Upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+		InitFunc:              middleware.NewAuthMiddleware().WebsocketInit,
+		KeepAlivePingInterval: cfg.GetGraphQLWebsocketKeepAlive(),
+	})
 	gqlSrv.AddTransport(transport.Options{})
 	gqlSrv.AddTransport(transport.GET{})
 	gqlSrv.AddTransport(transport.POST{})
@@ -161,12 +180,17 @@ func startServer() {
 	// Create DAO route handler
 	daoRoute := routes.NewDaoRoute()
 	proposalSimulationRoute := routes.NewProposalSimulationRoute()
+	telegramRoute := routes.NewTelegramRoute()
+	unsubscribeRoute := routes.NewUnsubscribeRoute()
 
 	// Support both patterns: /dao/config and /dao/config/{dao}
 	mux.Handle("/dao/config", middlewareChain.Then(http.HandlerFunc(daoRoute.ConfigHandler)))
 	mux.Handle("/dao/config/{dao}", middlewareChain.Then(http.HandlerFunc(daoRoute.ConfigHandler)))
 	mux.Handle("GET /api/v1/daos/{daoCode}/proposal-simulation/capability", middlewareChain.Then(http.HandlerFunc(proposalSimulationRoute.CapabilityHandler)))
 	mux.Handle("POST /api/v1/daos/{daoCode}/proposals/{proposalId}/simulation", middlewareChain.Then(http.HandlerFunc(proposalSimulationRoute.SimulationHandler)))
+	mux.Handle("POST /api/v1/notifications/telegram/webhook", middlewareChain.Then(http.HandlerFunc(telegramRoute.WebhookHandler)))
+	mux.Handle("GET /api/v1/notifications/unsubscribe", middlewareChain.Then(http.HandlerFunc(unsubscribeRoute.ConfirmHandler)))
+	mux.Handle("POST /api/v1/notifications/unsubscribe", middlewareChain.Then(http.HandlerFunc(unsubscribeRoute.UnsubscribeHandler)))
 
 	registerStytchOAuthRoutes(mux, middlewareChain, cfg, nil)
 
@@ -0,0 +1,28 @@
+package dbmodels
+
+import "time"
+
+// DelegationSnapshot is the last seen delegation of one delegator to a subscribed delegate
+type DelegationSnapshot struct {
+	DaoCode          string    `gorm:"column:dao_code;type:varchar(255);primaryKey" json:"dao_code"`
+	DelegateAddress  string    `gorm:"column:delegate_address;type:varchar(255);primaryKey" json:"delegate_address"`
+	DelegatorAddress string    `gorm:"column:delegator_address;type:varchar(255);primaryKey" json:"delegator_address"`
+	Power            string    `gorm:"column:power;type:varchar(100);not null" json:"power"` // raw voting power
+	CTime            time.Time `gorm:"column:ctime;default:now()" json:"ctime"`
+	UTime            time.Time `gorm:"column:utime;default:now()" json:"utime"`
+}
+
+func (DelegationSnapshot) TableName() string {
+	return "dgv_delegation_snapshot"
+}
+
+// DelegationTracking marks delegates whose delegators have been snapshotted at least once
+type DelegationTracking struct {
+	DaoCode         string    `gorm:"column:dao_code;type:varchar(255);primaryKey" json:"dao_code"`
+	DelegateAddress string    `gorm:"column:delegate_address;type:varchar(255);primaryKey" json:"delegate_address"`
+	TimeSynced      time.Time `gorm:"column:time_synced" json:"time_synced"`
+}
+
+func (DelegationTracking) TableName() string {
+	return "dgv_delegation_tracking"
+}
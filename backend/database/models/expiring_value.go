@@ -0,0 +1,16 @@
+package dbmodels
+
+import "time"
+
+// ExpiringValue is a short lived value shared by every instance, such as a pending OTP code or a rate limit window
+type ExpiringValue struct {
+	ID        string    `gorm:"column:id;type:varchar(255);primaryKey" json:"id"`
+	Value     string    `gorm:"column:value;type:text;not null;default:''" json:"value"`
+	Attempts  int       `gorm:"column:attempts;not null;default:0" json:"attempts"` // counted by ExpiringStore.Increment
+	ExpiresAt time.Time `gorm:"column:expires_at;not null;index:idx_dgv_expiring_value_expires_at" json:"expires_at"`
+	CTime     time.Time `gorm:"column:ctime;default:now()" json:"ctime"`
+}
+
+func (ExpiringValue) TableName() string {
+	return "dgv_expiring_value"
+}
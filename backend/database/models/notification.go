@@ -8,11 +8,22 @@ import (
 type NotificationRecordState string
 type NotificationChannelType string
 type NotificationEventState string
+type NotificationDeliveryState string
 
 const (
 	NotificationRecordStatePending  NotificationRecordState = "PENDING"
 	NotificationRecordStateSentOk   NotificationRecordState = "SENT_OK"
 	NotificationRecordStateSentFail NotificationRecordState = "SENT_FAIL"
+	// discarded by an operator after failing, it is kept for the inbox and is not retried
+	NotificationRecordStateDiscarded NotificationRecordState = "DISCARDED"
+)
+
+const (
+	NotificationDeliveryStatePending  NotificationDeliveryState = "PENDING"
+	NotificationDeliveryStateSentOk   NotificationDeliveryState = "SENT_OK"
+	NotificationDeliveryStateSentFail NotificationDeliveryState = "SENT_FAIL"
+	// the channel was removed, unverified or opted out of the feature before the delivery was sent, it is not a failure
+	NotificationDeliveryStateCancelled NotificationDeliveryState = "CANCELLED"
 )
 
 const (
@@ -20,6 +31,7 @@ const (
 	NotificationEventStateProgress  NotificationEventState = "PROGRESS"
 	NotificationEventStateCompleted NotificationEventState = "COMPLETED"
 	NotificationEventStateFailed    NotificationEventState = "FAILED"
+	NotificationEventStateDiscarded NotificationEventState = "DISCARDED"
 )
 
 type NotificationRecord struct {
@@ -36,6 +48,11 @@ type NotificationRecord struct {
 	State           NotificationRecordState `gorm:"column:state;type:varchar(50);not null" json:"state"`
 	Message         *string                 `gorm:"column:message;type:text" json:"message,omitempty"`
 	Payload         *string                 `gorm:"column:payload;type:text" json:"payload,omitempty"`
+	Digest          *string                 `gorm:"column:digest;type:varchar(50)" json:"digest,omitempty"` // digest strategy, nil when delivered instantly
+	Title           *string                 `gorm:"column:title;type:varchar(255)" json:"title,omitempty"`  // rendered for the in-app inbox
+	Content         *string                 `gorm:"column:content;type:text" json:"content,omitempty"`      // rendered plain text for the in-app inbox
+	TimeRead        *time.Time              `gorm:"column:time_read" json:"time_read,omitempty"`
+	TimeArchived    *time.Time              `gorm:"column:time_archived" json:"time_archived,omitempty"`
 	TimesRetry      int                     `gorm:"column:times_retry;not null;default:0" json:"times_retry"`
 	TimeNextExecute time.Time               `gorm:"column:time_next_execute;" json:"time_next_execute"`
 	CTime           time.Time               `gorm:"column:ctime;default:now()" json:"ctime"`
@@ -53,6 +70,8 @@ type NotificationEvent struct {
 	Type            SubscribeFeatureName   `gorm:"column:type;type:varchar(50);not null" json:"type"`
 	ProposalID      string                 `gorm:"column:proposal_id;type:varchar(255);not null" json:"proposal_id"`
 	VoteID          *string                `gorm:"column:vote_id;type:varchar(255)" json:"vote_id,omitempty"`
+	TargetAddress   *string                `gorm:"column:target_address;type:varchar(255)" json:"target_address,omitempty"` // only this subscriber is notified
+	DedupeKey       *string                `gorm:"column:dedupe_key;type:text;uniqueIndex:uq_dgv_notification_event_dedupe_key" json:"dedupe_key,omitempty"`
 	Reached         int                    `gorm:"column:reached;not null;default:0" json:"reached"`
 	State           NotificationEventState `gorm:"column:state;type:varchar(50);not null" json:"state"`
 	Payload         *string                `gorm:"column:payload;type:text" json:"payload"`
@@ -68,9 +87,32 @@ func (NotificationEvent) TableName() string {
 	return "dgv_notification_event"
 }
 
+// NotificationDelivery tracks the delivery of one record to one channel, the record state is aggregated from them
+type NotificationDelivery struct {
+	ID              string                    `gorm:"column:id;type:varchar(50);primaryKey" json:"id"`
+	RecordID        string                    `gorm:"column:record_id;type:varchar(50);not null;uniqueIndex:uq_dgv_notification_delivery_record_channel" json:"record_id"`
+	ChannelID       string                    `gorm:"column:channel_id;type:varchar(50);not null;uniqueIndex:uq_dgv_notification_delivery_record_channel" json:"channel_id"`
+	ChannelType     NotificationChannelType   `gorm:"column:channel_type;type:varchar(50);not null" json:"channel_type"`
+	State           NotificationDeliveryState `gorm:"column:state;type:varchar(50);not null" json:"state"`
+	TimesRetry      int                       `gorm:"column:times_retry;not null;default:0" json:"times_retry"`
+	LastError       *string                   `gorm:"column:last_error;type:text" json:"last_error,omitempty"`
+	ResponseCode    *int                      `gorm:"column:response_code" json:"response_code,omitempty"`
+	TimeNextExecute time.Time                 `gorm:"column:time_next_execute" json:"time_next_execute"`
+	CTime           time.Time                 `gorm:"column:ctime;default:now()" json:"ctime"`
+	UTime           *time.Time                `gorm:"column:utime" json:"utime,omitempty"`
+}
+
+func (NotificationDelivery) TableName() string {
+	return "dgv_notification_delivery"
+}
+
 const (
-	NotificationChannelTypeEmail   NotificationChannelType = "EMAIL"
-	NotificationChannelTypeWebhook NotificationChannelType = "WEBHOOK"
+	NotificationChannelTypeEmail    NotificationChannelType = "EMAIL"
+	NotificationChannelTypeWebhook  NotificationChannelType = "WEBHOOK"
+	NotificationChannelTypeTelegram NotificationChannelType = "TELEGRAM"
+	NotificationChannelTypeDiscord  NotificationChannelType = "DISCORD"
+	NotificationChannelTypeSlack    NotificationChannelType = "SLACK"
+	NotificationChannelTypeWebPush  NotificationChannelType = "WEB_PUSH"
 )
 
 type NotificationChannel struct {
@@ -81,6 +123,7 @@ type NotificationChannel struct {
 	ChannelType  NotificationChannelType `gorm:"column:channel_type;type:varchar(50);not null" json:"channel_type"`
 	ChannelValue string                  `gorm:"column:channel_value;type:varchar(500);not null" json:"channel_value"`
 	Payload      *string                 `gorm:"column:payload;type:text" json:"payload,omitempty"`
+	Features     *string                 `gorm:"column:features;type:text" json:"features,omitempty"` // JSON array of SubscribeFeatureName, nil delivers every feature
 	CTime        time.Time               `gorm:"column:ctime;default:now()" json:"ctime"`
 }
 
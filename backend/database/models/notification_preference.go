@@ -0,0 +1,18 @@
+package dbmodels
+
+import "time"
+
+type NotificationPreference struct {
+	UserID          string    `gorm:"column:user_id;type:varchar(50);primaryKey" json:"user_id"`
+	Timezone        string    `gorm:"column:timezone;type:varchar(64);not null;default:UTC" json:"timezone"`         // IANA timezone
+	QuietHoursStart *int      `gorm:"column:quiet_hours_start" json:"quiet_hours_start,omitempty"`                   // minutes after local midnight
+	QuietHoursEnd   *int      `gorm:"column:quiet_hours_end" json:"quiet_hours_end,omitempty"`                       // minutes after local midnight
+	UrgentFeatures  string    `gorm:"column:urgent_features;type:text;not null;default:'[]'" json:"urgent_features"` // JSON array of SubscribeFeatureName
+	Locale          string    `gorm:"column:locale;type:varchar(16);not null;default:en" json:"locale"`              // en or zh-CN
+	CTime           time.Time `gorm:"column:ctime;default:now()" json:"ctime"`
+	UTime           time.Time `gorm:"column:utime;default:now()" json:"utime"`
+}
+
+func (NotificationPreference) TableName() string {
+	return "dgv_notification_preference"
+}
@@ -9,6 +9,12 @@ const (
 	SubscribeFeatureProposalStateChanged SubscribeFeatureName = "PROPOSAL_STATE_CHANGED"
 	SubscribeFeatureVoteEnd              SubscribeFeatureName = "VOTE_END"
 	SubscribeFeatureVoteEmitted          SubscribeFeatureName = "VOTE_EMITTED"
+	SubscribeFeatureDelegationChanged    SubscribeFeatureName = "DELEGATION_CHANGED"
+	SubscribeFeatureExecutionReady       SubscribeFeatureName = "EXECUTION_READY"
+	SubscribeFeatureExecutionExpiring    SubscribeFeatureName = "EXECUTION_EXPIRING"
+	SubscribeFeatureVoteReminder         SubscribeFeatureName = "VOTE_REMINDER"
+	SubscribeFeatureCommentReply         SubscribeFeatureName = "COMMENT_REPLY"
+	SubscribeFeatureCommentMention       SubscribeFeatureName = "COMMENT_MENTION"
 )
 
 type SubscribeState string
@@ -18,6 +24,21 @@ const (
 	SubscribeStateInactive SubscribeState = "INACTIVE"
 )
 
+// Subscribe strategies decide when the records of a feature are delivered
+const (
+	SubscribeStrategyEnabled      = "true" // legacy value, delivered like instant
+	SubscribeStrategyInstant      = "instant"
+	SubscribeStrategyHourlyDigest = "hourly_digest"
+	SubscribeStrategyDailyDigest  = "daily_digest"
+)
+
+// Vote threshold strategies only notify VOTE_EMITTED for large votes, the value follows the prefix,
+// e.g. "min_weight:100000" (tokens) or "min_quorum_percent:5" (percent of the proposal quorum)
+const (
+	SubscribeStrategyMinWeightPrefix        = "min_weight:"
+	SubscribeStrategyMinQuorumPercentPrefix = "min_quorum_percent:"
+)
+
 type UserSubscribedDao struct {
 	ID          string         `gorm:"column:id;type:varchar(50);primaryKey" json:"id"`
 	ChainID     int            `gorm:"column:chain_id;not null" json:"chain_id"`
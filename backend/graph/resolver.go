@@ -14,39 +14,49 @@ import (
 type Resolver struct {
 	authUtils *middleware.AuthUtils
 
-	authService            *services.AuthService
-	daoService             *services.DaoService
-	daoConfigService       *services.DaoConfigService
-	userLikedService       *services.UserLikedDaoService
-	userSubscribedService  *services.UserSubscribedDaoService
-	userInteractionService *services.UserInteractionService
-	ensService             *services.ENSService
-	evmChainService        *services.EvmChainService
-	subscribeService       *services.SubscribeService
-	treasuryService        *services.TreasuryService
-	proposalService        *services.ProposalService
-	proposalSummaryService *services.ProposalSummaryService
-	proposalCommentService *services.ProposalCommentService
-	proposalDraftService   *services.ProposalDraftService
+	authService                   *services.AuthService
+	daoService                    *services.DaoService
+	daoConfigService              *services.DaoConfigService
+	userLikedService              *services.UserLikedDaoService
+	userSubscribedService         *services.UserSubscribedDaoService
+	userInteractionService        *services.UserInteractionService
+	ensService                    *services.ENSService
+	evmChainService               *services.EvmChainService
+	subscribeService              *services.SubscribeService
+	treasuryService               *services.TreasuryService
+	proposalService               *services.ProposalService
+	proposalSummaryService        *services.ProposalSummaryService
+	proposalCommentService        *services.ProposalCommentService
+	proposalDraftService          *services.ProposalDraftService
+	notificationPreferenceService *services.NotificationPreferenceService
+	notificationInboxService      *services.NotificationInboxService
+	notificationPreviewService    *services.NotificationPreviewService
+	notificationDeadLetterService *services.NotificationDeadLetterService
+	notificationEventStream       *services.NotificationEventStream
 }
 
 func NewResolver() *Resolver {
 	return &Resolver{
 		authUtils: middleware.NewAuthUtils(),
 
-		authService:            services.NewAuthService(),
-		daoService:             services.NewDaoService(),
-		daoConfigService:       services.NewDaoConfigService(),
-		userLikedService:       services.NewUserLikedDaoService(),
-		userSubscribedService:  services.NewUserSubscribedDaoService(),
-		userInteractionService: services.NewUserInteractionService(),
-		ensService:             services.NewENSService(),
-		evmChainService:        services.NewEvmChainService(),
-		subscribeService:       services.NewSubscribeService(),
-		treasuryService:        services.NewTreasuryService(),
-		proposalService:        services.NewProposalService(),
-		proposalSummaryService: services.NewProposalSummaryService(),
-		proposalCommentService: services.NewProposalCommentService(),
-		proposalDraftService:   services.NewProposalDraftService(),
+		authService:                   services.NewAuthService(),
+		daoService:                    services.NewDaoService(),
+		daoConfigService:              services.NewDaoConfigService(),
+		userLikedService:              services.NewUserLikedDaoService(),
+		userSubscribedService:         services.NewUserSubscribedDaoService(),
+		userInteractionService:        services.NewUserInteractionService(),
+		ensService:                    services.NewENSService(),
+		evmChainService:               services.NewEvmChainService(),
+		subscribeService:              services.NewSubscribeService(),
+		treasuryService:               services.NewTreasuryService(),
+		proposalService:               services.NewProposalService(),
+		proposalSummaryService:        services.NewProposalSummaryService(),
+		proposalCommentService:        services.NewProposalCommentService(),
+		proposalDraftService:          services.NewProposalDraftService(),
+		notificationPreferenceService: services.NewNotificationPreferenceService(),
+		notificationInboxService:      services.NewNotificationInboxService(),
+		notificationPreviewService:    services.NewNotificationPreviewService(),
+		notificationDeadLetterService: services.NewNotificationDeadLetterService(),
+		notificationEventStream:       services.NewNotificationEventStream(),
 	}
 }
@@ -10,7 +10,6 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/jinzhu/copier"
 	dbmodels "github.com/ringecosystem/degov-square/database/models"
 	gqlmodels "github.com/ringecosystem/degov-square/graph/models"
 	"github.com/ringecosystem/degov-square/services"
@@ -55,6 +54,74 @@ func (r *mutationResolver) ResendOtp(ctx context.Context, input gqlmodels.BaseNo
 	})
 }
 
+// RemoveNotificationChannel is the resolver for the removeNotificationChannel field.
+func (r *mutationResolver) RemoveNotificationChannel(ctx context.Context, input gqlmodels.RemoveNotificationChannelInput) (bool, error) {
+	user, _ := r.authUtils.GetUser(ctx)
+	return r.userInteractionService.RemoveNotificationChannel(types.BasicInput[gqlmodels.RemoveNotificationChannelInput]{
+		User:  user,
+		Input: input,
+	})
+}
+
+// SetChannelFeatures is the resolver for the setChannelFeatures field.
+func (r *mutationResolver) SetChannelFeatures(ctx context.Context, input gqlmodels.SetChannelFeaturesInput) (*gqlmodels.NotificationChannel, error) {
+	user, _ := r.authUtils.GetUser(ctx)
+	channel, err := r.userInteractionService.SetChannelFeatures(types.BasicInput[gqlmodels.SetChannelFeaturesInput]{
+		User:  user,
+		Input: input,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return services.NotificationChannelToGraphQL(channel), nil
+}
+
+// UpdateNotificationPreference is the resolver for the updateNotificationPreference field.
+func (r *mutationResolver) UpdateNotificationPreference(ctx context.Context, input gqlmodels.UpdateNotificationPreferenceInput) (*gqlmodels.NotificationPreference, error) {
+	user, err := r.authUtils.GetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.notificationPreferenceService.Update(user, input)
+}
+
+// MarkNotificationsRead is the resolver for the markNotificationsRead field.
+func (r *mutationResolver) MarkNotificationsRead(ctx context.Context, input gqlmodels.MarkNotificationsReadInput) (int32, error) {
+	user, err := r.authUtils.GetUser(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return r.notificationInboxService.MarkRead(user, input)
+}
+
+// ArchiveNotifications is the resolver for the archiveNotifications field.
+func (r *mutationResolver) ArchiveNotifications(ctx context.Context, input gqlmodels.ArchiveNotificationsInput) (int32, error) {
+	user, err := r.authUtils.GetUser(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return r.notificationInboxService.Archive(user, input)
+}
+
+// SendTestNotification is the resolver for the sendTestNotification field.
+func (r *mutationResolver) SendTestNotification(ctx context.Context, input gqlmodels.SendTestNotificationInput) (*gqlmodels.SendTestNotificationOutput, error) {
+	user, err := r.authUtils.GetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.notificationPreviewService.SendTest(user, input)
+}
+
+// ReplayNotificationDeadLetters is the resolver for the replayNotificationDeadLetters field.
+func (r *mutationResolver) ReplayNotificationDeadLetters(ctx context.Context, input gqlmodels.NotificationDeadLetterActionInput) (int32, error) {
+	return r.notificationDeadLetterService.Replay(input)
+}
+
+// DiscardNotificationDeadLetters is the resolver for the discardNotificationDeadLetters field.
+func (r *mutationResolver) DiscardNotificationDeadLetters(ctx context.Context, input gqlmodels.NotificationDeadLetterActionInput) (int32, error) {
+	return r.notificationDeadLetterService.Discard(input)
+}
+
 // SubscribeDao is the resolver for the subscribeDao field.
 func (r *mutationResolver) SubscribeDao(ctx context.Context, input gqlmodels.SubscribeDaoInput) (*gqlmodels.SubscribedDaoOutput, error) {
 	user, _ := r.authUtils.GetUser(ctx)
@@ -234,11 +301,65 @@ func (r *queryResolver) ListNotificationChannels(ctx context.Context) ([]*gqlmod
 	if err != nil {
 		return nil, err
 	}
-	var result []*gqlmodels.NotificationChannel
-	copier.Copy(&result, &channels)
+	result := make([]*gqlmodels.NotificationChannel, 0, len(channels))
+	for i := range channels {
+		result = append(result, services.NotificationChannelToGraphQL(&channels[i]))
+	}
 	return result, nil
 }
 
+// PendingNotificationChannel is the resolver for the pendingNotificationChannel field.
+func (r *queryResolver) PendingNotificationChannel(ctx context.Context) (*gqlmodels.PendingNotificationChannel, error) {
+	user, _ := r.authUtils.GetUser(ctx)
+	return r.userInteractionService.PendingChannel(user), nil
+}
+
+// WebPushPublicKey is the resolver for the webPushPublicKey field.
+func (r *queryResolver) WebPushPublicKey(ctx context.Context) (*string, error) {
+	return r.userInteractionService.WebPushPublicKey(), nil
+}
+
+// NotificationPreference is the resolver for the notificationPreference field.
+func (r *queryResolver) NotificationPreference(ctx context.Context) (*gqlmodels.NotificationPreference, error) {
+	user, err := r.authUtils.GetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.notificationPreferenceService.Get(user)
+}
+
+// MyNotifications is the resolver for the myNotifications field.
+func (r *queryResolver) MyNotifications(ctx context.Context, input gqlmodels.MyNotificationsInput) (*gqlmodels.NotificationPage, error) {
+	user, err := r.authUtils.GetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.notificationInboxService.List(user, input)
+}
+
+// UnreadNotificationCount is the resolver for the unreadNotificationCount field.
+func (r *queryResolver) UnreadNotificationCount(ctx context.Context) (int32, error) {
+	user, err := r.authUtils.GetUser(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return r.notificationInboxService.UnreadCount(user)
+}
+
+// PreviewNotification is the resolver for the previewNotification field.
+func (r *queryResolver) PreviewNotification(ctx context.Context, input gqlmodels.PreviewNotificationInput) (*gqlmodels.NotificationPreview, error) {
+	user, err := r.authUtils.GetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.notificationPreviewService.Preview(user, input)
+}
+
+// NotificationDeadLetters is the resolver for the notificationDeadLetters field.
+func (r *queryResolver) NotificationDeadLetters(ctx context.Context, input gqlmodels.NotificationDeadLettersInput) (*gqlmodels.NotificationDeadLetterPage, error) {
+	return r.notificationDeadLetterService.List(input)
+}
+
 // SubscribedDaos is the resolver for the subscribedDaos field.
 func (r *queryResolver) SubscribedDaos(ctx context.Context) ([]*gqlmodels.SubscribedDao, error) {
 	user, _ := r.authUtils.GetUser(ctx)
@@ -354,11 +475,30 @@ func (r *queryResolver) ProposalDraft(ctx context.Context, input gqlmodels.Propo
 	return r.proposalDraftService.Get(user, input)
 }
 
+// ProposalStateChanged is the resolver for the proposalStateChanged field.
+func (r *subscriptionResolver) ProposalStateChanged(ctx context.Context, daoCode string) (<-chan *gqlmodels.ProposalStateChangedEvent, error) {
+	return r.notificationEventStream.ProposalStateChanged(ctx, daoCode), nil
+}
+
+// VoteCast is the resolver for the voteCast field.
+func (r *subscriptionResolver) VoteCast(ctx context.Context, daoCode string, proposalID string) (<-chan *gqlmodels.VoteCastEvent, error) {
+	return r.notificationEventStream.VoteCast(ctx, daoCode, proposalID), nil
+}
+
+// DaoMetricsUpdated is the resolver for the daoMetricsUpdated field.
+func (r *subscriptionResolver) DaoMetricsUpdated(ctx context.Context, daoCode *string) (<-chan *gqlmodels.Dao, error) {
+	return r.notificationEventStream.DaoMetricsUpdated(ctx, daoCode), nil
+}
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
+// Subscription returns SubscriptionResolver implementation.
+func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
+
 type mutationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
@@ -138,17 +138,66 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("TASK_VOTE_TRACKING_INTERVAL", "3m")
 	v.SetDefault("TASK_VOTE_END_TRACKING_ENABLED", true)
 	v.SetDefault("TASK_VOTE_END_TRACKING_INTERVAL", "4m")
+	v.SetDefault("TASK_DELEGATION_TRACKING_ENABLED", true)
+	v.SetDefault("TASK_DELEGATION_TRACKING_INTERVAL", "10m")
+	v.SetDefault("TASK_VOTE_REMINDER_TRACKING_ENABLED", true)
+	v.SetDefault("TASK_VOTE_REMINDER_TRACKING_INTERVAL", "5m")
+	v.SetDefault("TASK_EXECUTION_TRACKING_ENABLED", true)
+	v.SetDefault("TASK_EXECUTION_TRACKING_INTERVAL", "5m")
+	v.SetDefault("TASK_EXECUTION_EXPIRING_WINDOW", "48h")
 	v.SetDefault("TASK_PROPOSAL_TRACKING_ENABLED", true)
 	v.SetDefault("TASK_PROPOSAL_TRACKING_INTERVAL", "3m")
 	v.SetDefault("TASK_NOTIFICATION_EVENT_ENABLED", true)
 	v.SetDefault("TASK_NOTIFICATION_EVENT_INTERVAL", "10s")
 	v.SetDefault("TASK_NOTIFICATION_DISPATCHER_ENABLED", true)
 	v.SetDefault("TASK_NOTIFICATION_DISPATCHER_INTERVAL", "5s")
+	v.SetDefault("TASK_NOTIFICATION_DIGEST_ENABLED", true)
+	v.SetDefault("TASK_NOTIFICATION_DIGEST_INTERVAL", "1m")
+	v.SetDefault("TASK_LOCK_ENABLED", true)
+	v.SetDefault("TASK_LOCK_TTL", "1m")
+	v.SetDefault("TASK_MAX_RUN_TIME", "15m")
+
+	// email provider
+	v.SetDefault("NOTIFICATION_EMAIL_PROVIDER", "sendgrid")
 
 	// sendgrid
 	v.SetDefault("SENDGRID_FROM_USER", "DeGov Notifications")
 	v.SetDefault("SENDGRID_FROM_EMAIL", "notifications@degov.ai")
 
+	// smtp
+	v.SetDefault("SMTP_PORT", 587)
+	v.SetDefault("SMTP_TLS_MODE", "starttls")
+	v.SetDefault("SMTP_TIMEOUT", "10s")
+	v.SetDefault("SMTP_FROM_USER", "DeGov Notifications")
+	v.SetDefault("SMTP_FROM_EMAIL", "notifications@degov.ai")
+
+	// notification webhook
+	v.SetDefault("NOTIFICATION_WEBHOOK_TIMEOUT", "10s")
+	v.SetDefault("NOTIFICATION_ALLOW_PRIVATE_NETWORKS", false)
+
+	// telegram bot
+	v.SetDefault("TELEGRAM_API_BASE_URL", "https://api.telegram.org")
+
+	// web push
+	v.SetDefault("WEB_PUSH_SUBJECT", "mailto:notifications@degov.ai")
+	v.SetDefault("WEB_PUSH_TTL", "24h")
+
+	// notification retry
+	v.SetDefault("NOTIFICATION_RETRY_BASE_DELAY", "2m")
+	v.SetDefault("NOTIFICATION_RETRY_MAX_DELAY", "24h")
+	v.SetDefault("NOTIFICATION_RETRY_MAX_ATTEMPTS", 5)
+	v.SetDefault("NOTIFICATION_RETRY_JITTER", 0.2)
+	v.SetDefault("NOTIFICATION_EVENT_LEASE", "5m")
+
+	// notification unsubscribe links
+	v.SetDefault("NOTIFICATION_UNSUBSCRIBE_BASE_URL", "")
+	v.SetDefault("NOTIFICATION_UNSUBSCRIBE_SECRET", "")
+	v.SetDefault("NOTIFICATION_UNSUBSCRIBE_TOKEN_TTL", "720h")
+
+	// graphql subscriptions
+	v.SetDefault("GRAPHQL_SUBSCRIPTION_POLL_INTERVAL", "2s")
+	v.SetDefault("GRAPHQL_WEBSOCKET_KEEPALIVE", "15s")
+
 }
 
 // Server configuration methods
@@ -227,6 +276,15 @@ func (c *Config) GetAppEnv() Environment {
 	return parseEnvironment(strings.ToLower(strings.TrimSpace(env)))
 }
 
+// GetAdminAddresses returns the lowercased wallet addresses allowed to use ADMIN_ONLY operations
+func (c *Config) GetAdminAddresses() []string {
+	addresses := splitCommaSeparated(c.viper.GetString("ADMIN_ADDRESSES"))
+	for i, address := range addresses {
+		addresses[i] = strings.ToLower(address)
+	}
+	return addresses
+}
+
 func (c *Config) GetMCPEnabled() bool {
 	return c.viper.GetBool("MCP_ENABLED")
 }
@@ -332,6 +390,35 @@ func (c *Config) GetTaskVoteEndTrackingInterval() time.Duration {
 	return c.viper.GetDuration("TASK_VOTE_END_TRACKING_INTERVAL")
 }
 
+func (c *Config) GetTaskDelegationTrackingEnabled() bool {
+	return c.viper.GetBool("TASK_DELEGATION_TRACKING_ENABLED")
+}
+
+func (c *Config) GetTaskDelegationTrackingInterval() time.Duration {
+	return c.viper.GetDuration("TASK_DELEGATION_TRACKING_INTERVAL")
+}
+
+func (c *Config) GetTaskVoteReminderTrackingEnabled() bool {
+	return c.viper.GetBool("TASK_VOTE_REMINDER_TRACKING_ENABLED")
+}
+
+func (c *Config) GetTaskVoteReminderTrackingInterval() time.Duration {
+	return c.viper.GetDuration("TASK_VOTE_REMINDER_TRACKING_INTERVAL")
+}
+
+func (c *Config) GetTaskExecutionTrackingEnabled() bool {
+	return c.viper.GetBool("TASK_EXECUTION_TRACKING_ENABLED")
+}
+
+func (c *Config) GetTaskExecutionTrackingInterval() time.Duration {
+	return c.viper.GetDuration("TASK_EXECUTION_TRACKING_INTERVAL")
+}
+
+// GetTaskExecutionExpiringWindow is how long before the timelock grace period ends EXECUTION_EXPIRING is sent
+func (c *Config) GetTaskExecutionExpiringWindow() time.Duration {
+	return c.viper.GetDuration("TASK_EXECUTION_EXPIRING_WINDOW")
+}
+
 func (c *Config) GetTaskProposalTrackingEnabled() bool {
 	return c.viper.GetBool("TASK_PROPOSAL_TRACKING_ENABLED")
 }
@@ -356,6 +443,69 @@ func (c *Config) GetTaskNotificationDispatcherInterval() time.Duration {
 	return c.viper.GetDuration("TASK_NOTIFICATION_DISPATCHER_INTERVAL")
 }
 
+func (c *Config) GetTaskNotificationDigestEnabled() bool {
+	return c.viper.GetBool("TASK_NOTIFICATION_DIGEST_ENABLED")
+}
+
+func (c *Config) GetTaskNotificationDigestInterval() time.Duration {
+	return c.viper.GetDuration("TASK_NOTIFICATION_DIGEST_INTERVAL")
+}
+
+func (c *Config) GetTaskLockEnabled() bool {
+	return c.viper.GetBool("TASK_LOCK_ENABLED")
+}
+
+func (c *Config) GetTaskLockTTL() time.Duration {
+	return c.viper.GetDuration("TASK_LOCK_TTL")
+}
+
+func (c *Config) GetTaskMaxRunTime() time.Duration {
+	return c.viper.GetDuration("TASK_MAX_RUN_TIME")
+}
+
+// Notification retry configuration methods
+func (c *Config) GetNotificationRetryBaseDelay() time.Duration {
+	return c.viper.GetDuration("NOTIFICATION_RETRY_BASE_DELAY")
+}
+
+func (c *Config) GetNotificationRetryMaxDelay() time.Duration {
+	return c.viper.GetDuration("NOTIFICATION_RETRY_MAX_DELAY")
+}
+
+func (c *Config) GetNotificationRetryMaxAttempts() int {
+	return c.viper.GetInt("NOTIFICATION_RETRY_MAX_ATTEMPTS")
+}
+
+func (c *Config) GetNotificationRetryJitter() float64 {
+	return c.viper.GetFloat64("NOTIFICATION_RETRY_JITTER")
+}
+
+func (c *Config) GetNotificationEventLease() time.Duration {
+	return c.viper.GetDuration("NOTIFICATION_EVENT_LEASE")
+}
+
+// Notification unsubscribe configuration methods
+func (c *Config) GetNotificationUnsubscribeBaseURL() string {
+	return c.viper.GetString("NOTIFICATION_UNSUBSCRIBE_BASE_URL")
+}
+
+func (c *Config) GetNotificationUnsubscribeSecret() string {
+	return c.viper.GetString("NOTIFICATION_UNSUBSCRIBE_SECRET")
+}
+
+func (c *Config) GetNotificationUnsubscribeTokenTTL() time.Duration {
+	return c.viper.GetDuration("NOTIFICATION_UNSUBSCRIBE_TOKEN_TTL")
+}
+
+// GraphQL subscription configuration methods
+func (c *Config) GetGraphQLSubscriptionPollInterval() time.Duration {
+	return c.viper.GetDuration("GRAPHQL_SUBSCRIPTION_POLL_INTERVAL")
+}
+
+func (c *Config) GetGraphQLWebsocketKeepAlive() time.Duration {
+	return c.viper.GetDuration("GRAPHQL_WEBSOCKET_KEEPALIVE")
+}
+
 // Generic configuration methods
 func (c *Config) GetString(key string) string {
 	return c.viper.GetString(key)
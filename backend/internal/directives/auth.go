@@ -3,9 +3,11 @@ package directives
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/99designs/gqlgen/graphql"
 	gqlmodels "github.com/ringecosystem/degov-square/graph/models"
+	"github.com/ringecosystem/degov-square/internal/config"
 	"github.com/ringecosystem/degov-square/internal/middleware"
 )
 
@@ -55,7 +57,6 @@ func AuthorizeDirective(ctx context.Context, obj interface{}, next graphql.Resol
 		return next(ctx)
 
 	case gqlmodels.AuthRuleAdminOnly:
-		// Check if user is admin (you'll need to implement this based on your user model)
 		if !isAdmin(user.Address) {
 			return nil, fmt.Errorf("admin privileges required")
 		}
@@ -125,11 +126,13 @@ func AuthorizeDirective(ctx context.Context, obj interface{}, next graphql.Resol
 // 	return nil, fmt.Errorf("permission denied: unable to verify resource ownership")
 // }
 
-// isAdmin checks if the user has admin privileges
-// You should implement this based on your user model and admin logic
+// isAdmin checks if the address is one of the configured ADMIN_ADDRESSES
 func isAdmin(address string) bool {
-	// TODO: Implement admin check logic
-	// This could check a database, smart contract, or configuration
-	// For now, return false (no admins)
+	address = strings.ToLower(address)
+	for _, admin := range config.GetConfig().GetAdminAddresses() {
+		if address != "" && admin == address {
+			return true
+		}
+	}
 	return false
 }
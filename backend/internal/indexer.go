@@ -2,6 +2,7 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -10,6 +11,13 @@ import (
 	"github.com/machinebox/graphql"
 )
 
+var (
+	// ErrVoteNotFound is returned when the voter has not voted on the proposal
+	ErrVoteNotFound = errors.New("no vote found")
+	// ErrContributorNotFound is returned when the address has never held or been delegated voting power
+	ErrContributorNotFound = errors.New("no contributor found")
+)
+
 // DataMetrics represents the data metrics structure from GraphQL response
 type DataMetrics struct {
 	ProposalsCount          *int   `json:"proposalsCount"`
@@ -81,6 +89,38 @@ type Proposal struct {
 	Description                    string  `json:"description"`
 }
 
+// ExecutionWindow returns when a queued proposal becomes executable and when it leaves the timelock grace period.
+// The indexer queue timestamps are preferred, the ETA plus the grace period is used when they are missing.
+func (p Proposal) ExecutionWindow() (readyAt time.Time, expiresAt time.Time, err error) {
+	readyAt, err = parseIndexerTime(p.QueueReadyAt)
+	if err != nil {
+		if readyAt, err = parseIndexerTime(p.ProposalEta); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("proposal %s has no execution eta", p.ProposalID)
+		}
+	}
+	expiresAt, err = parseIndexerTime(p.QueueExpiresAt)
+	if err != nil {
+		gracePeriod, perr := strconv.ParseInt(p.TimelockGracePeriod, 10, 64)
+		if perr != nil || gracePeriod <= 0 {
+			return readyAt, time.Time{}, fmt.Errorf("proposal %s has no timelock grace period", p.ProposalID)
+		}
+		expiresAt = readyAt.Add(time.Duration(gracePeriod) * time.Second)
+	}
+	return readyAt, expiresAt, nil
+}
+
+// parseIndexerTime parses a unix timestamp in milliseconds, or in seconds for values taken from contracts
+func parseIndexerTime(value string) (time.Time, error) {
+	timestamp, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || timestamp <= 0 {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+	}
+	if timestamp < 1e12 {
+		return time.Unix(timestamp, 0), nil
+	}
+	return time.UnixMilli(timestamp), nil
+}
+
 // ProposalsResponse represents the GraphQL response structure for proposals
 type ProposalsResponse struct {
 	Proposals []Proposal `json:"proposals"`
@@ -247,6 +287,34 @@ func (d *DegovIndexer) QueryProposalsCount(scope ProposalScope) (int, error) {
 	return response.ProposalsPage.TotalCount, nil
 }
 
+// QueryTokenDecimals returns the decimals of the governor token recorded on the latest proposal, nil when the DAO has
+// no proposal yet
+func (d *DegovIndexer) QueryTokenDecimals(ctx context.Context, scope ProposalScope) (*int, error) {
+	query := `
+		query QueryTokenDecimals($where: ProposalWhereInput!) {
+			proposals(where: $where, limit: 1, orderBy: [blockNumber_DESC_NULLS_LAST, id_DESC]) {
+				decimals
+			}
+		}
+	`
+
+	req := graphql.NewRequest(query)
+	req.Var("where", scope.withScope(nil))
+
+	var response ProposalsResponse
+	if err := d.client.Run(ctx, req, &response); err != nil {
+		return nil, fmt.Errorf("failed to execute QueryTokenDecimals: %w", err)
+	}
+	if len(response.Proposals) == 0 {
+		return nil, nil
+	}
+	decimals, err := strconv.Atoi(response.Proposals[0].Decimals)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token decimals %q: %w", response.Proposals[0].Decimals, err)
+	}
+	return &decimals, nil
+}
+
 func (d *DegovIndexer) InspectProposal(scope ProposalScope, proposalId string) (*Proposal, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -494,7 +562,7 @@ func (d *DegovIndexer) QueryContributor(ctx context.Context, scope ProposalScope
 		return &response.Contributors[0], nil
 	}
 
-	return nil, fmt.Errorf("no contributor found with address %s", address)
+	return nil, fmt.Errorf("%w with address %s", ErrContributorNotFound, address)
 }
 
 func (d *DegovIndexer) QueryVote(scope ProposalScope, proposalId string, id string) (*VoteCast, error) {
@@ -543,7 +611,7 @@ func (d *DegovIndexer) QueryVote(scope ProposalScope, proposalId string, id stri
 		}
 	}
 
-	return nil, fmt.Errorf("no vote found with id %s", id)
+	return nil, fmt.Errorf("%w with id %s", ErrVoteNotFound, id)
 }
 
 func (d *DegovIndexer) QueryVoteByVoter(scope ProposalScope, proposalId string, voter string) (*VoteCast, error) {
@@ -586,7 +654,7 @@ func (d *DegovIndexer) QueryVoteByVoter(scope ProposalScope, proposalId string,
 		return &vote, nil
 	}
 
-	return nil, fmt.Errorf("no vote found for proposalId %s and voter %s", proposalId, voter)
+	return nil, fmt.Errorf("%w for proposalId %s and voter %s", ErrVoteNotFound, proposalId, voter)
 }
 
 func (d *DegovIndexer) QueryExpiringProposals(scope ProposalScope) ([]Proposal, error) {
@@ -3,6 +3,7 @@ package internal
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -855,3 +856,77 @@ func TestQueryContributorsRequestsBalance(t *testing.T) {
 		t.Fatalf("balance = %#v, want 25", contributors[0].Balance)
 	}
 }
+
+func TestProposalExecutionWindow(t *testing.T) {
+	readyAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	proposal := Proposal{
+		QueueReadyAt:   strconv.FormatInt(readyAt.UnixMilli(), 10),
+		QueueExpiresAt: strconv.FormatInt(readyAt.Add(14*24*time.Hour).UnixMilli(), 10),
+	}
+	ready, expires, err := proposal.ExecutionWindow()
+	if err != nil || !ready.Equal(readyAt) || !expires.Equal(readyAt.Add(14*24*time.Hour)) {
+		t.Fatalf("unexpected window from queue timestamps: %s %s %v", ready, expires, err)
+	}
+
+	// contracts report the eta and grace period in seconds
+	proposal = Proposal{
+		ProposalEta:         strconv.FormatInt(readyAt.Unix(), 10),
+		TimelockGracePeriod: "86400",
+	}
+	ready, expires, err = proposal.ExecutionWindow()
+	if err != nil || !ready.Equal(readyAt) || !expires.Equal(readyAt.Add(24*time.Hour)) {
+		t.Fatalf("unexpected window from eta: %s %s %v", ready, expires, err)
+	}
+
+	if _, _, err := (Proposal{ProposalEta: strconv.FormatInt(readyAt.Unix(), 10)}).ExecutionWindow(); err == nil {
+		t.Fatal("expected an error without an expiry or grace period")
+	}
+	if _, _, err := (Proposal{}).ExecutionWindow(); err == nil {
+		t.Fatal("expected an error for a proposal that is not queued")
+	}
+}
+
+func TestQueryVoteByVoterReportsMissingVote(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":{"proposals":[{"voters":[]}]}}`))
+	}))
+	defer server.Close()
+
+	_, err := NewDegovIndexer(server.URL).QueryVoteByVoter(ProposalScope{DaoCode: "ring-dao"}, "proposal-1", "0xVoter")
+	if !errors.Is(err, ErrVoteNotFound) {
+		t.Fatalf("QueryVoteByVoter() error = %v, want ErrVoteNotFound", err)
+	}
+}
+
+func TestQueryTokenDecimalsReadsLatestProposal(t *testing.T) {
+	var proposals []Proposal
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query string `json:"query"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		if !strings.Contains(req.Query, "limit: 1, orderBy: [blockNumber_DESC_NULLS_LAST, id_DESC]") {
+			t.Fatalf("query = %s, want the latest proposal only", req.Query)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"proposals": proposals}})
+	}))
+	defer server.Close()
+	indexer := NewDegovIndexer(server.URL)
+	scope := ProposalScope{ChainID: 46, DaoCode: "ring-dao", GovernorAddress: "0xAbC123"}
+
+	decimals, err := indexer.QueryTokenDecimals(context.Background(), scope)
+	if err != nil || decimals != nil {
+		t.Fatalf("QueryTokenDecimals() = %v, %v, want nil without proposals", decimals, err)
+	}
+
+	proposals = []Proposal{{ID: "p1", Decimals: "6"}}
+	decimals, err = indexer.QueryTokenDecimals(context.Background(), scope)
+	if err != nil || decimals == nil || *decimals != 6 {
+		t.Fatalf("QueryTokenDecimals() = %v, %v, want 6", decimals, err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/ringecosystem/degov-square/internal/config"
 	"github.com/ringecosystem/degov-square/types"
@@ -49,37 +50,36 @@ func (m *AuthMiddleware) Middleware() Middleware {
 func (m *AuthMiddleware) HTTPMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Extract token from Authorization header
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			// No token provided, continue without authentication
-			next.ServeHTTP(w, r)
-			return
-		}
+		r = r.WithContext(m.authenticate(r.Context(), r.Header.Get("Authorization")))
+		next.ServeHTTP(w, r)
+	})
+}
 
-		// Check if it starts with "Bearer "
-		if !strings.HasPrefix(authHeader, "Bearer ") {
-			// Invalid format, continue without authentication
-			next.ServeHTTP(w, r)
-			return
-		}
+// WebsocketInit authenticates a websocket connection from the Authorization field of its
+// connection_init payload, browsers cannot set headers on the upgrade request.
+func (m *AuthMiddleware) WebsocketInit(ctx context.Context, initPayload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+	return m.authenticate(ctx, initPayload.Authorization()), &initPayload, nil
+}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+// authenticate adds the user claims of a valid "Bearer <token>" value to the context.
+// Missing or invalid tokens leave the request unauthenticated.
+func (m *AuthMiddleware) authenticate(ctx context.Context, authHeader string) context.Context {
+	// Check if it starts with "Bearer "
+	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
+		return ctx
+	}
 
-		// Parse and validate token
-		claims, err := m.validateToken(tokenString)
-		if err != nil {
-			// Invalid token, continue without authentication
-			// You might want to return an error here depending on your requirements
-			next.ServeHTTP(w, r)
-			return
-		}
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 
-		// Add user claims to context
-		ctx := context.WithValue(r.Context(), UserClaimsKey, claims)
-		r = r.WithContext(ctx)
+	// Parse and validate token
+	claims, err := m.validateToken(tokenString)
+	if err != nil {
+		// Invalid token, continue without authentication
+		return ctx
+	}
 
-		next.ServeHTTP(w, r)
-	})
+	// Add user claims to context
+	return context.WithValue(ctx, UserClaimsKey, claims)
 }
 
 // validateToken parses and validates a JWT token
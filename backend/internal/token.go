@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// ERC20 metadata ABI for the decimals function
+const tokenDecimalsABI = `[{
+	"inputs": [],
+	"name": "decimals",
+	"outputs": [{"internalType": "uint8", "name": "", "type": "uint8"}],
+	"stateMutability": "view",
+	"type": "function"
+}]`
+
+// GetTokenDecimals reads the decimals of an ERC20 token from its contract
+func GetTokenDecimals(ctx context.Context, rpcURL, tokenAddress string) (int, error) {
+	contractABI, err := abi.JSON(strings.NewReader(tokenDecimalsABI))
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse token contract ABI: %w", err)
+	}
+	callData, err := contractABI.Pack("decimals")
+	if err != nil {
+		return 0, fmt.Errorf("failed to pack function call data: %w", err)
+	}
+
+	client, err := ethclient.DialContext(ctx, rpcURL)
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to Ethereum client: %w", err)
+	}
+	defer client.Close()
+
+	tokenAddr := common.HexToAddress(tokenAddress)
+	result, err := client.CallContract(ctx, ethereum.CallMsg{
+		To:   &tokenAddr,
+		Data: callData,
+	}, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to call contract: %w", err)
+	}
+
+	var decimals uint8
+	if err := contractABI.UnpackIntoInterface(&decimals, "decimals", result); err != nil {
+		return 0, fmt.Errorf("failed to unpack contract result: %w", err)
+	}
+	return int(decimals), nil
+}
@@ -10,6 +10,26 @@ import (
 	"unicode/utf8"
 )
 
+// Locales notifications are rendered in, English is the fallback for anything that has not been translated
+const (
+	LocaleEnglish = "en"
+	LocaleChinese = "zh-CN"
+)
+
+// NormalizeLocale maps a language tag such as "zh", "zh_CN" or "en-US" to a supported locale,
+// ok is false for unsupported languages, which are rendered in English.
+func NormalizeLocale(locale string) (string, bool) {
+	tag := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+	switch {
+	case tag == "en" || strings.HasPrefix(tag, "en-"):
+		return LocaleEnglish, true
+	case tag == "zh" || tag == "zh-cn" || tag == "zh-sg" || tag == "zh-hans" || strings.HasPrefix(tag, "zh-hans-"):
+		return LocaleChinese, true
+	default:
+		return LocaleEnglish, false
+	}
+}
+
 func TruncateText(s string, maxLength int) string {
 	if utf8.RuneCountInString(s) <= maxLength {
 		return s
@@ -25,14 +45,28 @@ func TruncateText(s string, maxLength int) string {
 
 // formatDate formats a Unix timestamp string into the "Month Day, Year at Hour:Minute PM Timezone" layout.
 func FormatDate(timestampStr string) string {
+	return FormatDateIn("UTC", timestampStr)
+}
+
+// FormatDateIn is FormatDate in the given IANA timezone, unknown timezones fall back to UTC.
+func FormatDateIn(timezone string, timestampStr string) string {
+	return FormatDateLocale(LocaleEnglish, timezone, timestampStr)
+}
+
+// FormatDateLocale is FormatDateIn in the layout of the locale, e.g. "2025年9月2日 16:04 CST" for Chinese.
+func FormatDateLocale(locale string, timezone string, timestampStr string) string {
 	t, err := ParseTimestamp(timestampStr)
 	if err != nil {
 		slog.Warn("Could not parse timestamp string", "timestampStr", timestampStr, "error", err)
 		return timestampStr
 	}
 
-	// 1. Convert the time to the UTC timezone.
-	t = t.UTC()
+	// 1. Convert the time to the requested timezone.
+	location, err := time.LoadLocation(timezone)
+	if err != nil || timezone == "" {
+		location = time.UTC
+	}
+	t = t.In(location)
 
 	// 2. Use the new format layout string.
 	//    Based on Go's reference time: Mon Jan 2 15:04:05 MST 2006
@@ -43,13 +77,21 @@ func FormatDate(timestampStr string) string {
 	//    "3"       -> Hour in 12-hour format without leading zero (e.g., "4")
 	//    "04"      -> Minute with leading zero (e.g., "04")
 	//    "PM"      -> AM/PM marker (e.g., "PM")
-	//    "MST"     -> Timezone abbreviation (will display "UTC" since we converted to UTC)
+	//    "MST"     -> Timezone abbreviation (e.g., "UTC" or "CEST")
+	if locale == LocaleChinese {
+		return t.Format("2006年1月2日 15:04 MST")
+	}
 	return t.Format("January 2, 2006 at 3:04 PM MST")
 }
 
 // formatLargeNumber formats a number string into a human-readable string with k, M, B, T suffixes.
 // It now accepts a string parameter for more flexibility.
 func FormatLargeNumber(numStr string) string {
+	return FormatLargeNumberLocale(LocaleEnglish, numStr)
+}
+
+// FormatLargeNumberLocale is FormatLargeNumber with the units of the locale, Chinese groups by 万 (10^4) and 亿 (10^8).
+func FormatLargeNumberLocale(locale string, numStr string) string {
 	// First, parse the string input into a float64.
 	n, err := strconv.ParseFloat(numStr, 64)
 	if err != nil {
@@ -70,6 +112,17 @@ func FormatLargeNumber(numStr string) string {
 		n = -n
 	}
 
+	if locale == LocaleChinese {
+		if n >= 1e12 {
+			return sign + FormatDecimal(n/1e12) + "万亿"
+		} else if n >= 1e8 {
+			return sign + FormatDecimal(n/1e8) + "亿"
+		} else if n >= 1e4 {
+			return sign + FormatDecimal(n/1e4) + "万"
+		}
+		return sign + FormatDecimal(n)
+	}
+
 	if n >= 1e12 { // Trillion
 		return sign + FormatDecimal(n/1e12) + "T"
 	} else if n >= 1e9 { // Billion
@@ -130,9 +183,42 @@ func FormatPercent(value float64) string {
 }
 
 func FormatDurationShort(d time.Duration) string {
+	return FormatDurationShortLocale(LocaleEnglish, d)
+}
+
+// durationLabels are the words FormatDurationShortLocale uses, units are day, hour, minute and second in singular and plural
+type durationLabels struct {
+	ended     string
+	instant   string
+	separator string
+	units     [4][2]string
+}
+
+var localeDurationLabels = map[string]durationLabels{
+	LocaleEnglish: {
+		ended:     "Ended",
+		instant:   "< 1 second",
+		separator: ", ",
+		units:     [4][2]string{{" day", " days"}, {" hour", " hours"}, {" minute", " minutes"}, {" second", " seconds"}},
+	},
+	LocaleChinese: {
+		ended:     "已结束",
+		instant:   "不到 1 秒",
+		separator: " ",
+		units:     [4][2]string{{" 天", " 天"}, {" 小时", " 小时"}, {" 分钟", " 分钟"}, {" 秒", " 秒"}},
+	},
+}
+
+// FormatDurationShortLocale is FormatDurationShort in the words of the locale.
+func FormatDurationShortLocale(locale string, d time.Duration) string {
+	labels, ok := localeDurationLabels[locale]
+	if !ok {
+		labels = localeDurationLabels[LocaleEnglish]
+	}
+
 	// If the duration is in the past or now, the event has ended.
 	if d <= 0 {
-		return "Ended"
+		return labels.ended
 	}
 
 	// Calculate individual units.
@@ -149,45 +235,24 @@ func FormatDurationShort(d time.Duration) string {
 
 	// Build a slice of the parts of the string.
 	var parts []string
-
-	if days > 0 {
-		if days == 1 {
-			parts = append(parts, "1 day")
-		} else {
-			parts = append(parts, fmt.Sprintf("%d days", days))
+	for i, value := range []time.Duration{days, hours, minutes, seconds} {
+		// minutes and seconds are only added if we have less than 2 parts
+		if value <= 0 || (i >= 2 && len(parts) >= 2) {
+			continue
 		}
-	}
-
-	if hours > 0 {
-		if hours == 1 {
-			parts = append(parts, "1 hour")
-		} else {
-			parts = append(parts, fmt.Sprintf("%d hours", hours))
-		}
-	}
-
-	if minutes > 0 && len(parts) < 2 { // Only add if we have less than 2 parts
-		if minutes == 1 {
-			parts = append(parts, "1 minute")
-		} else {
-			parts = append(parts, fmt.Sprintf("%d minutes", minutes))
-		}
-	}
-
-	if seconds > 0 && len(parts) < 2 { // Only add if we have less than 2 parts
-		if seconds == 1 {
-			parts = append(parts, "1 second")
-		} else {
-			parts = append(parts, fmt.Sprintf("%d seconds", seconds))
+		label := labels.units[i][1]
+		if value == 1 {
+			label = labels.units[i][0]
 		}
+		parts = append(parts, fmt.Sprintf("%d%s", value, label))
 	}
 
 	// If there are no parts (duration is < 1s), return a default.
 	if len(parts) == 0 {
-		return "< 1 second"
+		return labels.instant
 	}
 
-	return strings.Join(parts, ", ")
+	return strings.Join(parts, labels.separator)
 }
 
 func FormatBigIntWithDecimals(amountStr *string, decimals int) (string, error) {
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS dgv_notification_delivery;
@@ -0,0 +1,25 @@
+CREATE TABLE dgv_notification_delivery (
+    id varchar(50) PRIMARY KEY,
+    record_id varchar(50) NOT NULL,
+    channel_id varchar(50) NOT NULL,
+    channel_type varchar(50) NOT NULL,
+    state varchar(50) NOT NULL DEFAULT 'PENDING',
+    times_retry integer NOT NULL DEFAULT 0,
+    last_error text,
+    response_code integer,
+    time_next_execute timestamptz NOT NULL DEFAULT now(),
+    ctime timestamptz NOT NULL DEFAULT now(),
+    utime timestamptz,
+    CONSTRAINT chk_dgv_notification_delivery_state
+        CHECK (state IN ('PENDING', 'SENT_OK', 'SENT_FAIL', 'CANCELLED')),
+    CONSTRAINT uq_dgv_notification_delivery_record_channel
+        UNIQUE (record_id, channel_id)
+);
+
+CREATE INDEX idx_dgv_notification_delivery_record
+    ON dgv_notification_delivery (record_id, state);
+
+COMMENT ON TABLE dgv_notification_delivery IS 'Per-channel delivery attempts of a notification record';
+COMMENT ON COLUMN dgv_notification_delivery.last_error IS 'error of the latest failed attempt';
+COMMENT ON COLUMN dgv_notification_delivery.response_code IS 'response status code of the latest attempt, if the channel returned one';
+COMMENT ON COLUMN dgv_notification_delivery.state IS 'CANCELLED when the channel was removed or opted out of the feature before the delivery was sent';
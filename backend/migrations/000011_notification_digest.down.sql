@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_dgv_notification_record_digest_due;
+
+ALTER TABLE dgv_notification_record
+    DROP COLUMN IF EXISTS digest;
@@ -0,0 +1,8 @@
+ALTER TABLE dgv_notification_record
+    ADD COLUMN digest varchar(50);
+
+CREATE INDEX idx_dgv_notification_record_digest_due
+    ON dgv_notification_record (digest, state, time_next_execute)
+    WHERE digest IS NOT NULL;
+
+COMMENT ON COLUMN dgv_notification_record.digest IS 'digest strategy the record is held for, NULL when it is delivered instantly';
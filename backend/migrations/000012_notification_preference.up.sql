@@ -0,0 +1,21 @@
+CREATE TABLE dgv_notification_preference (
+    user_id varchar(50) PRIMARY KEY,
+    timezone varchar(64) NOT NULL DEFAULT 'UTC',
+    quiet_hours_start smallint,
+    quiet_hours_end smallint,
+    urgent_features text NOT NULL DEFAULT '[]',
+    ctime timestamptz NOT NULL DEFAULT now(),
+    utime timestamptz NOT NULL DEFAULT now(),
+    CONSTRAINT chk_dgv_notification_preference_quiet_hours
+        CHECK (
+            (quiet_hours_start IS NULL AND quiet_hours_end IS NULL)
+            OR (quiet_hours_start BETWEEN 0 AND 1439 AND quiet_hours_end BETWEEN 0 AND 1439
+                AND quiet_hours_start <> quiet_hours_end)
+        )
+);
+
+COMMENT ON TABLE dgv_notification_preference IS 'Per-user notification delivery preferences';
+COMMENT ON COLUMN dgv_notification_preference.timezone IS 'IANA timezone used for quiet hours, digests and rendered dates';
+COMMENT ON COLUMN dgv_notification_preference.quiet_hours_start IS 'start of quiet hours in minutes after local midnight';
+COMMENT ON COLUMN dgv_notification_preference.quiet_hours_end IS 'end of quiet hours in minutes after local midnight, may be before the start for overnight windows';
+COMMENT ON COLUMN dgv_notification_preference.urgent_features IS 'JSON array of features delivered during quiet hours';
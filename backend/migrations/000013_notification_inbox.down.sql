@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_dgv_notification_record_unread;
+DROP INDEX IF EXISTS idx_dgv_notification_record_inbox;
+
+ALTER TABLE dgv_notification_record
+    DROP COLUMN IF EXISTS time_archived,
+    DROP COLUMN IF EXISTS time_read,
+    DROP COLUMN IF EXISTS content,
+    DROP COLUMN IF EXISTS title;
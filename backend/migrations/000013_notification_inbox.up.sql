@@ -0,0 +1,17 @@
+ALTER TABLE dgv_notification_record
+    ADD COLUMN title varchar(255),
+    ADD COLUMN content text,
+    ADD COLUMN time_read timestamptz,
+    ADD COLUMN time_archived timestamptz;
+
+CREATE INDEX idx_dgv_notification_record_inbox
+    ON dgv_notification_record (user_id, ctime DESC, id DESC);
+
+CREATE INDEX idx_dgv_notification_record_unread
+    ON dgv_notification_record (user_id)
+    WHERE time_read IS NULL AND time_archived IS NULL;
+
+COMMENT ON COLUMN dgv_notification_record.title IS 'rendered title shown in the in-app inbox';
+COMMENT ON COLUMN dgv_notification_record.content IS 'rendered plain-text body shown in the in-app inbox';
+COMMENT ON COLUMN dgv_notification_record.time_read IS 'when the user read the notification in the inbox, NULL while unread';
+COMMENT ON COLUMN dgv_notification_record.time_archived IS 'when the user archived the notification, NULL while it is in the inbox';
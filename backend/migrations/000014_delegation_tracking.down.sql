@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS dgv_delegation_tracking;
+DROP TABLE IF EXISTS dgv_delegation_snapshot;
+
+ALTER TABLE dgv_notification_event
+    DROP COLUMN IF EXISTS target_address;
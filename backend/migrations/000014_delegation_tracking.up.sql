@@ -0,0 +1,28 @@
+ALTER TABLE dgv_notification_event
+    ADD COLUMN target_address varchar(255);
+
+COMMENT ON COLUMN dgv_notification_event.target_address IS 'only subscribers with this address are notified, NULL notifies every subscriber';
+
+CREATE TABLE dgv_delegation_snapshot (
+    dao_code varchar(255) NOT NULL,
+    delegate_address varchar(255) NOT NULL,
+    delegator_address varchar(255) NOT NULL,
+    power varchar(100) NOT NULL,
+    ctime timestamptz NOT NULL DEFAULT now(),
+    utime timestamptz NOT NULL DEFAULT now(),
+    CONSTRAINT pk_dgv_delegation_snapshot
+        PRIMARY KEY (dao_code, delegate_address, delegator_address)
+);
+
+COMMENT ON TABLE dgv_delegation_snapshot IS 'Last seen delegators of subscribed delegates, diffed to notify delegation changes';
+COMMENT ON COLUMN dgv_delegation_snapshot.power IS 'raw voting power delegated by the delegator';
+
+CREATE TABLE dgv_delegation_tracking (
+    dao_code varchar(255) NOT NULL,
+    delegate_address varchar(255) NOT NULL,
+    time_synced timestamptz NOT NULL DEFAULT now(),
+    CONSTRAINT pk_dgv_delegation_tracking
+        PRIMARY KEY (dao_code, delegate_address)
+);
+
+COMMENT ON TABLE dgv_delegation_tracking IS 'Delegates whose delegators have been snapshotted, the first snapshot is a baseline and notifies nothing';
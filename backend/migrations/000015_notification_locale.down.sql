@@ -0,0 +1 @@
+ALTER TABLE dgv_notification_preference DROP COLUMN IF EXISTS locale;
@@ -0,0 +1,4 @@
+ALTER TABLE dgv_notification_preference
+    ADD COLUMN locale varchar(16) NOT NULL DEFAULT 'en';
+
+COMMENT ON COLUMN dgv_notification_preference.locale IS 'language notifications are rendered in, en or zh-CN';
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS uq_dgv_notification_channel_value;
+
+ALTER TABLE dgv_notification_channel
+    DROP COLUMN IF EXISTS features;
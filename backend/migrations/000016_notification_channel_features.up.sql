@@ -0,0 +1,15 @@
+ALTER TABLE dgv_notification_channel
+    ADD COLUMN features text;
+
+COMMENT ON COLUMN dgv_notification_channel.features IS 'JSON array of features delivered on the channel, NULL delivers every feature';
+
+-- a user may now verify several channels of a type, but each value only once
+DELETE FROM dgv_notification_channel AS c
+USING dgv_notification_channel AS newer
+WHERE c.user_id = newer.user_id
+    AND c.channel_type = newer.channel_type
+    AND c.channel_value = newer.channel_value
+    AND (c.ctime, c.id) < (newer.ctime, newer.id);
+
+CREATE UNIQUE INDEX uq_dgv_notification_channel_value
+    ON dgv_notification_channel (user_id, channel_type, channel_value);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS uq_dgv_notification_event_dedupe_key;
+
+ALTER TABLE dgv_notification_event
+    DROP COLUMN IF EXISTS dedupe_key;
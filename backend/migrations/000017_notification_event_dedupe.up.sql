@@ -0,0 +1,28 @@
+ALTER TABLE dgv_notification_event
+    ADD COLUMN dedupe_key text;
+
+COMMENT ON COLUMN dgv_notification_event.dedupe_key IS 'natural key of the event (type, dao, proposal and vote, state or target), NULL for events that may repeat';
+
+-- key the events saved so far, only the first of each duplicate gets the key
+WITH keyed AS (
+    SELECT id,
+        type || ':' || dao_code || ':' || proposal_id || CASE
+            WHEN type IN ('PROPOSAL_NEW', 'VOTE_END', 'EXECUTION_READY', 'EXECUTION_EXPIRING') THEN ''
+            WHEN type = 'VOTE_EMITTED' THEN ':' || vote_id
+            WHEN type = 'PROPOSAL_STATE_CHANGED' THEN ':' || (payload::jsonb ->> 'new_state')
+            WHEN type = 'VOTE_REMINDER' THEN ':' || lower(target_address)
+        END AS dedupe_key,
+        ctime
+    FROM dgv_notification_event
+), ranked AS (
+    SELECT id, dedupe_key, ROW_NUMBER() OVER (PARTITION BY dedupe_key ORDER BY ctime ASC, id ASC) AS rn
+    FROM keyed
+    WHERE dedupe_key IS NOT NULL
+)
+UPDATE dgv_notification_event AS e
+SET dedupe_key = ranked.dedupe_key
+FROM ranked
+WHERE e.id = ranked.id AND ranked.rn = 1;
+
+CREATE UNIQUE INDEX uq_dgv_notification_event_dedupe_key
+    ON dgv_notification_event (dedupe_key);
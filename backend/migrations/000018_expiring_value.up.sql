@@ -0,0 +1,14 @@
+CREATE TABLE dgv_expiring_value (
+    id varchar(255) PRIMARY KEY,
+    value text NOT NULL DEFAULT '',
+    attempts integer NOT NULL DEFAULT 0,
+    expires_at timestamptz NOT NULL,
+    ctime timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_dgv_expiring_value_expires_at ON dgv_expiring_value (expires_at);
+
+COMMENT ON TABLE dgv_expiring_value IS 'Short lived values shared by every instance, such as pending OTP codes and rate limit windows';
+COMMENT ON COLUMN dgv_expiring_value.id IS 'prefixed key, e.g. otp:<user id> or telegram_bind:<code>';
+COMMENT ON COLUMN dgv_expiring_value.expires_at IS 'the value is ignored after this time and purged later';
+COMMENT ON COLUMN dgv_expiring_value.attempts IS 'attempts counted in the window that ends at expires_at, e.g. wrong OTP codes';
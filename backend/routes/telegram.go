@@ -0,0 +1,95 @@
+package routes
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/ringecosystem/degov-square/internal/config"
+	"github.com/ringecosystem/degov-square/services"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+const (
+	maxTelegramUpdateBody     = 64 << 10
+	telegramSecretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+)
+
+var telegramBindCodePattern = regexp.MustCompile(`^(?:/start(?:@\w+)?\s+)?(\d{6})$`)
+
+type telegramBindService interface {
+	BindTelegramChat(code string, chatID int64) error
+}
+
+type telegramMessenger interface {
+	SendTelegramMessage(chatID, text string) (*types.NotifyOutput, error)
+}
+
+type TelegramRoute struct {
+	bindService telegramBindService
+	messenger   telegramMessenger
+	secret      string
+}
+
+type telegramUpdate struct {
+	UpdateID int64 `json:"update_id"`
+	Message  *struct {
+		Text string `json:"text"`
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+	} `json:"message"`
+}
+
+func NewTelegramRoute() *TelegramRoute {
+	return &TelegramRoute{
+		bindService: services.NewUserInteractionService(),
+		messenger:   services.NewNotifierService(),
+		secret:      config.GetString("TELEGRAM_WEBHOOK_SECRET"),
+	}
+}
+
+// WebhookHandler receives bot updates from Telegram and completes channel binds started with resendOTP.
+func (route *TelegramRoute) WebhookHandler(w http.ResponseWriter, request *http.Request) {
+	if route.secret == "" || subtle.ConstantTimeCompare([]byte(request.Header.Get(telegramSecretTokenHeader)), []byte(route.secret)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	request.Body = http.MaxBytesReader(w, request.Body, maxTelegramUpdateBody)
+	var update telegramUpdate
+	if err := json.NewDecoder(request.Body).Decode(&update); err != nil {
+		http.Error(w, "invalid update", http.StatusBadRequest)
+		return
+	}
+
+	// Telegram retries non-2xx responses, anything we cannot handle is acknowledged and dropped
+	w.WriteHeader(http.StatusOK)
+	if update.Message == nil {
+		return
+	}
+
+	reply := "Send the 6-digit code shown on DeGov to link this chat."
+	if match := telegramBindCodePattern.FindStringSubmatch(update.Message.Text); match != nil {
+		switch err := route.bindService.BindTelegramChat(match[1], update.Message.Chat.ID); {
+		case err == nil:
+			reply = "Code received. Go back to DeGov and confirm the code to finish linking this chat."
+		case errors.Is(err, services.ErrTelegramBindRateLimited):
+			reply = "Too many invalid codes. Please wait a few minutes before trying again."
+		case errors.Is(err, services.ErrTelegramBindCodeInvalid):
+			reply = "This code is invalid or has expired. Request a new one on DeGov."
+		default:
+			slog.Error("Failed to bind telegram chat", "update_id", update.UpdateID, "error", err)
+			reply = "Something went wrong. Please try again later."
+		}
+	}
+
+	chatID := strconv.FormatInt(update.Message.Chat.ID, 10)
+	if _, err := route.messenger.SendTelegramMessage(chatID, services.EscapeTelegramText(reply)); err != nil {
+		slog.Warn("Failed to reply to telegram update", "update_id", update.UpdateID, "error", err)
+	}
+}
@@ -0,0 +1,71 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ringecosystem/degov-square/services"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+type fakeTelegramBindService struct {
+	codes map[string]int64
+}
+
+func (f *fakeTelegramBindService) BindTelegramChat(code string, chatID int64) error {
+	if _, ok := f.codes[code]; !ok {
+		return services.ErrTelegramBindCodeInvalid
+	}
+	f.codes[code] = chatID
+	return nil
+}
+
+type fakeTelegramMessenger struct {
+	replies map[string]string
+}
+
+func (f *fakeTelegramMessenger) SendTelegramMessage(chatID, text string) (*types.NotifyOutput, error) {
+	f.replies[chatID] = text
+	return &types.NotifyOutput{StatusCode: http.StatusOK}, nil
+}
+
+func TestTelegramWebhookBindsChatFromStartCommand(t *testing.T) {
+	bind := &fakeTelegramBindService{codes: map[string]int64{"123456": 0}}
+	messenger := &fakeTelegramMessenger{replies: map[string]string{}}
+	route := &TelegramRoute{bindService: bind, messenger: messenger, secret: "s3cret"}
+
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/notifications/telegram/webhook", strings.NewReader(
+		`{"update_id":1,"message":{"text":"/start 123456","chat":{"id":42}}}`,
+	))
+	request.Header.Set(telegramSecretTokenHeader, "s3cret")
+	recorder := httptest.NewRecorder()
+	route.WebhookHandler(recorder, request)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d", recorder.Code)
+	}
+	if bind.codes["123456"] != 42 {
+		t.Fatalf("expected chat 42 to be bound, got %d", bind.codes["123456"])
+	}
+	if !strings.Contains(messenger.replies["42"], "Code received") {
+		t.Fatalf("unexpected reply: %q", messenger.replies["42"])
+	}
+}
+
+func TestTelegramWebhookRejectsWrongSecret(t *testing.T) {
+	bind := &fakeTelegramBindService{codes: map[string]int64{"123456": 0}}
+	route := &TelegramRoute{bindService: bind, messenger: &fakeTelegramMessenger{replies: map[string]string{}}, secret: "s3cret"}
+
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/notifications/telegram/webhook", strings.NewReader(
+		`{"update_id":1,"message":{"text":"123456","chat":{"id":42}}}`,
+	))
+	request.Header.Set(telegramSecretTokenHeader, "wrong")
+	recorder := httptest.NewRecorder()
+	route.WebhookHandler(recorder, request)
+
+	if recorder.Code != http.StatusUnauthorized || bind.codes["123456"] != 0 {
+		t.Fatalf("expected unauthorized update to be ignored, status=%d", recorder.Code)
+	}
+}
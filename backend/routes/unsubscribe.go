@@ -0,0 +1,106 @@
+package routes
+
+import (
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+
+	"github.com/ringecosystem/degov-square/services"
+)
+
+const maxUnsubscribeBody = 4 << 10
+
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>DeGov.AI</title></head>
+<body style="font-family: sans-serif; max-width: 480px; margin: 64px auto; padding: 0 16px; text-align: center;">
+{{if .Form}}
+<p>{{.Message}}</p>
+<form method="post" action="?token={{.Token}}">
+<button type="submit" style="padding: 8px 24px;">Unsubscribe</button>
+</form>
+{{else}}
+<p>{{.Message}}</p>
+{{end}}
+<p><a href="https://square.degov.ai/notification/subscription">Manage your subscribe preferences</a></p>
+</body>
+</html>
+`))
+
+type unsubscribeService interface {
+	Parse(token string) (*services.UnsubscribeScope, error)
+	Unsubscribe(token string) (*services.UnsubscribeScope, error)
+}
+
+type UnsubscribeRoute struct {
+	service unsubscribeService
+}
+
+type unsubscribePageData struct {
+	Form    bool
+	Token   string
+	Message string
+}
+
+func NewUnsubscribeRoute() *UnsubscribeRoute {
+	return &UnsubscribeRoute{service: services.NewUnsubscribeService()}
+}
+
+// ConfirmHandler shows what the link unsubscribes from, mail scanners prefetch links so GET never unsubscribes.
+func (route *UnsubscribeRoute) ConfirmHandler(w http.ResponseWriter, request *http.Request) {
+	token := request.URL.Query().Get("token")
+	scope, err := route.service.Parse(token)
+	if err != nil {
+		renderUnsubscribePage(w, http.StatusBadRequest, unsubscribePageData{Message: err.Error()})
+		return
+	}
+	renderUnsubscribePage(w, http.StatusOK, unsubscribePageData{
+		Form:    true,
+		Token:   token,
+		Message: unsubscribeDescription(scope, false),
+	})
+}
+
+// UnsubscribeHandler consumes the token, both for the confirmation form and RFC 8058 one-click requests
+// which POST List-Unsubscribe=One-Click to the link of the List-Unsubscribe header.
+func (route *UnsubscribeRoute) UnsubscribeHandler(w http.ResponseWriter, request *http.Request) {
+	request.Body = http.MaxBytesReader(w, request.Body, maxUnsubscribeBody)
+	if err := request.ParseForm(); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	scope, err := route.service.Unsubscribe(request.Form.Get("token"))
+	if errors.Is(err, services.ErrInvalidUnsubscribeToken) {
+		renderUnsubscribePage(w, http.StatusBadRequest, unsubscribePageData{Message: err.Error()})
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to unsubscribe", "error", err)
+		renderUnsubscribePage(w, http.StatusInternalServerError, unsubscribePageData{Message: "Something went wrong, please try again later."})
+		return
+	}
+	renderUnsubscribePage(w, http.StatusOK, unsubscribePageData{Message: unsubscribeDescription(scope, true)})
+}
+
+func unsubscribeDescription(scope *services.UnsubscribeScope, done bool) string {
+	if done {
+		if scope.Feature != "" {
+			return "You will no longer receive " + string(scope.Feature) + " notifications from " + scope.DaoCode + "."
+		}
+		return "You have been unsubscribed from " + scope.DaoCode + "."
+	}
+	if scope.Feature != "" {
+		return "Stop receiving " + string(scope.Feature) + " notifications from " + scope.DaoCode + "?"
+	}
+	return "Unsubscribe from all notifications of " + scope.DaoCode + "?"
+}
+
+func renderUnsubscribePage(w http.ResponseWriter, status int, data unsubscribePageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := unsubscribePage.Execute(w, data); err != nil {
+		slog.Warn("Failed to render unsubscribe page", "error", err)
+	}
+}
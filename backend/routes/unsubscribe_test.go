@@ -0,0 +1,77 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ringecosystem/degov-square/services"
+)
+
+type fakeUnsubscribeService struct {
+	consumed []string
+}
+
+func (f *fakeUnsubscribeService) Parse(token string) (*services.UnsubscribeScope, error) {
+	if token != "valid" {
+		return nil, services.ErrInvalidUnsubscribeToken
+	}
+	return &services.UnsubscribeScope{UserID: "u1", DaoCode: "demo"}, nil
+}
+
+func (f *fakeUnsubscribeService) Unsubscribe(token string) (*services.UnsubscribeScope, error) {
+	scope, err := f.Parse(token)
+	if err == nil {
+		f.consumed = append(f.consumed, token)
+	}
+	return scope, err
+}
+
+func TestUnsubscribeConfirmDoesNotUnsubscribe(t *testing.T) {
+	service := &fakeUnsubscribeService{}
+	route := &UnsubscribeRoute{service: service}
+
+	recorder := httptest.NewRecorder()
+	route.ConfirmHandler(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/notifications/unsubscribe?token=valid", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d", recorder.Code)
+	}
+	if !strings.Contains(recorder.Body.String(), `<form method="post"`) {
+		t.Fatalf("expected a confirmation form, got %s", recorder.Body.String())
+	}
+	if len(service.consumed) != 0 {
+		t.Fatalf("GET must not unsubscribe")
+	}
+}
+
+func TestUnsubscribeOneClickPost(t *testing.T) {
+	service := &fakeUnsubscribeService{}
+	route := &UnsubscribeRoute{service: service}
+
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/notifications/unsubscribe?token=valid", strings.NewReader("List-Unsubscribe=One-Click"))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	route.UnsubscribeHandler(recorder, request)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d", recorder.Code)
+	}
+	if len(service.consumed) != 1 {
+		t.Fatalf("expected the token to be consumed once, got %v", service.consumed)
+	}
+}
+
+func TestUnsubscribeRejectsInvalidToken(t *testing.T) {
+	route := &UnsubscribeRoute{service: &fakeUnsubscribeService{}}
+
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/notifications/unsubscribe?token=forged", strings.NewReader("List-Unsubscribe=One-Click"))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	route.UnsubscribeHandler(recorder, request)
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d", recorder.Code)
+	}
+}
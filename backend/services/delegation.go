@@ -0,0 +1,158 @@
+package services
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/ringecosystem/degov-square/database"
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	"github.com/ringecosystem/degov-square/internal"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+// DelegationService keeps the last seen delegators of subscribed delegates so delegation changes can be notified.
+type DelegationService struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+func NewDelegationService() *DelegationService {
+	return newDelegationService(database.GetDB())
+}
+
+func newDelegationService(db *gorm.DB) *DelegationService {
+	return &DelegationService{
+		db:  db,
+		now: time.Now,
+	}
+}
+
+// Changes compares the current delegators of a delegate with the stored snapshot.
+// tracked is false when the delegate has never been snapshotted, the changes are then only a baseline.
+func (s *DelegationService) Changes(daoCode, delegate string, current []internal.Delegate) (changes []types.DelegationChange, tracked bool, err error) {
+	delegate = strings.ToLower(delegate)
+	var tracking dbmodels.DelegationTracking
+	result := s.db.Where("dao_code = ? AND delegate_address = ?", daoCode, delegate).Limit(1).Find(&tracking)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+
+	var snapshots []dbmodels.DelegationSnapshot
+	if err := s.db.Where("dao_code = ? AND delegate_address = ?", daoCode, delegate).Find(&snapshots).Error; err != nil {
+		return nil, false, err
+	}
+	previous := make(map[string]string, len(snapshots))
+	for _, snapshot := range snapshots {
+		previous[snapshot.DelegatorAddress] = snapshot.Power
+	}
+	return diffDelegations(previous, current), result.RowsAffected > 0, nil
+}
+
+// SaveSnapshot replaces the stored delegators of a delegate with the current ones.
+func (s *DelegationService) SaveSnapshot(daoCode, delegate string, current []internal.Delegate) error {
+	delegate = strings.ToLower(delegate)
+	now := s.now()
+	powers := delegatorPowers(current)
+	snapshots := make([]dbmodels.DelegationSnapshot, 0, len(powers))
+	for delegator, power := range powers {
+		if power.Sign() == 0 {
+			continue
+		}
+		snapshots = append(snapshots, dbmodels.DelegationSnapshot{
+			DaoCode:          daoCode,
+			DelegateAddress:  delegate,
+			DelegatorAddress: delegator,
+			Power:            power.String(),
+			CTime:            now,
+			UTime:            now,
+		})
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("dao_code = ? AND delegate_address = ?", daoCode, delegate).Delete(&dbmodels.DelegationSnapshot{}).Error; err != nil {
+			return err
+		}
+		if len(snapshots) > 0 {
+			if err := tx.CreateInBatches(snapshots, 200).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "dao_code"}, {Name: "delegate_address"}},
+			DoUpdates: clause.AssignmentColumns([]string{"time_synced"}),
+		}).Create(&dbmodels.DelegationTracking{
+			DaoCode:         daoCode,
+			DelegateAddress: delegate,
+			TimeSynced:      now,
+		}).Error
+	})
+}
+
+// DelegationPowerDelta sums the power a delegate gained and lost over the changes.
+func DelegationPowerDelta(changes []types.DelegationChange) (gained, lost *big.Int) {
+	gained, lost = new(big.Int), new(big.Int)
+	for _, change := range changes {
+		previous, _ := new(big.Int).SetString(change.PreviousPower, 10)
+		power, _ := new(big.Int).SetString(change.Power, 10)
+		if previous == nil {
+			previous = new(big.Int)
+		}
+		if power == nil {
+			power = new(big.Int)
+		}
+		delta := new(big.Int).Sub(power, previous)
+		if delta.Sign() > 0 {
+			gained.Add(gained, delta)
+		} else {
+			lost.Sub(lost, delta)
+		}
+	}
+	return gained, lost
+}
+
+// diffDelegations returns the delegators whose power differs from the previous snapshot, ordered by delegator.
+func diffDelegations(previous map[string]string, current []internal.Delegate) []types.DelegationChange {
+	powers := delegatorPowers(current)
+	changes := make([]types.DelegationChange, 0)
+	for delegator, power := range powers {
+		previousPower := "0"
+		if value, ok := previous[delegator]; ok {
+			previousPower = value
+		}
+		if previousPower != power.String() {
+			changes = append(changes, types.DelegationChange{Delegator: delegator, PreviousPower: previousPower, Power: power.String()})
+		}
+	}
+	for delegator, previousPower := range previous {
+		if _, ok := powers[delegator]; !ok && previousPower != "0" {
+			changes = append(changes, types.DelegationChange{Delegator: delegator, PreviousPower: previousPower, Power: "0"})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Delegator < changes[j].Delegator
+	})
+	return changes
+}
+
+// delegatorPowers sums the indexer delegations by lowercased delegator, unparsable powers count as zero
+func delegatorPowers(delegates []internal.Delegate) map[string]*big.Int {
+	powers := make(map[string]*big.Int, len(delegates))
+	for _, delegate := range delegates {
+		delegator := strings.ToLower(delegate.FromDelegate)
+		power, ok := new(big.Int).SetString(delegate.Power, 10)
+		if !ok {
+			power = new(big.Int)
+		}
+		if existing, ok := powers[delegator]; ok {
+			existing.Add(existing, power)
+			continue
+		}
+		powers[delegator] = power
+	}
+	return powers
+}
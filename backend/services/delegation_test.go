@@ -0,0 +1,90 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/ringecosystem/degov-square/internal"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+func TestDiffDelegations(t *testing.T) {
+	previous := map[string]string{
+		"0xaaa": "100",
+		"0xbbb": "50",
+		"0xccc": "10",
+	}
+	current := []internal.Delegate{
+		{FromDelegate: "0xAAA", Power: "100"},
+		{FromDelegate: "0xbbb", Power: "80"},
+		{FromDelegate: "0xddd", Power: "5"},
+		{FromDelegate: "0xeee", Power: "0"},
+	}
+
+	changes := diffDelegations(previous, current)
+	expected := []types.DelegationChange{
+		{Delegator: "0xbbb", PreviousPower: "50", Power: "80"},
+		{Delegator: "0xccc", PreviousPower: "10", Power: "0"},
+		{Delegator: "0xddd", PreviousPower: "0", Power: "5"},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+
+	gained, lost := DelegationPowerDelta(changes)
+	if gained.String() != "35" || lost.String() != "10" {
+		t.Fatalf("expected 35 gained and 10 lost, got %s and %s", gained, lost)
+	}
+}
+
+func TestDelegationSnapshotBaseline(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	for _, statement := range []string{
+		`CREATE TABLE dgv_delegation_snapshot (dao_code TEXT, delegate_address TEXT, delegator_address TEXT, power TEXT NOT NULL, ctime DATETIME, utime DATETIME, PRIMARY KEY (dao_code, delegate_address, delegator_address))`,
+		`CREATE TABLE dgv_delegation_tracking (dao_code TEXT, delegate_address TEXT, time_synced DATETIME, PRIMARY KEY (dao_code, delegate_address))`,
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatalf("create table: %v", err)
+		}
+	}
+	service := newDelegationService(db)
+	service.now = func() time.Time { return time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC) }
+
+	first := []internal.Delegate{{FromDelegate: "0xaaa", Power: "100"}}
+	changes, tracked, err := service.Changes("demo", "0xDelegate", first)
+	if err != nil {
+		t.Fatalf("diff first sync: %v", err)
+	}
+	if tracked || len(changes) != 1 {
+		t.Fatalf("first sync should be an untracked baseline, tracked=%v changes=%+v", tracked, changes)
+	}
+	if err := service.SaveSnapshot("demo", "0xDelegate", first); err != nil {
+		t.Fatalf("save baseline: %v", err)
+	}
+
+	second := []internal.Delegate{{FromDelegate: "0xbbb", Power: "20"}}
+	changes, tracked, err = service.Changes("demo", "0xdelegate", second)
+	if err != nil {
+		t.Fatalf("diff second sync: %v", err)
+	}
+	expected := []types.DelegationChange{
+		{Delegator: "0xaaa", PreviousPower: "100", Power: "0"},
+		{Delegator: "0xbbb", PreviousPower: "0", Power: "20"},
+	}
+	if !tracked || !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("unexpected second sync, tracked=%v changes=%+v", tracked, changes)
+	}
+	if err := service.SaveSnapshot("demo", "0xdelegate", second); err != nil {
+		t.Fatalf("save snapshot: %v", err)
+	}
+	if changes, _, err := service.Changes("demo", "0xdelegate", second); err != nil || len(changes) != 0 {
+		t.Fatalf("expected no changes after the snapshot, got %+v err=%v", changes, err)
+	}
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+)
+
+// ExpiringStore keeps short lived values in the database, every instance behind the load balancer sees the OTP codes
+// and rate limits written by the others. Expired values read as missing and are purged when new values are written.
+type ExpiringStore struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+func NewExpiringStore(db *gorm.DB) *ExpiringStore {
+	return &ExpiringStore{db: db, now: time.Now}
+}
+
+// Get returns the value stored under the key, nil when there is none or it has expired
+func (s *ExpiringStore) Get(key string) (*dbmodels.ExpiringValue, error) {
+	var value dbmodels.ExpiringValue
+	err := s.db.Where("id = ? AND expires_at > ?", key, s.now()).First(&value).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", key, err)
+	}
+	return &value, nil
+}
+
+// Set stores the value under the key for ttl, replacing any previous value
+func (s *ExpiringStore) Set(key, value string, ttl time.Duration) error {
+	now := s.now()
+	s.purge(now)
+	entry := dbmodels.ExpiringValue{ID: key, Value: value, ExpiresAt: now.Add(ttl), CTime: now}
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "attempts", "expires_at", "ctime"}),
+	}).Create(&entry).Error; err != nil {
+		return fmt.Errorf("error storing %s: %w", key, err)
+	}
+	return nil
+}
+
+// Update replaces the value stored under the key and keeps its expiry, it reports false when the key has expired
+func (s *ExpiringStore) Update(key, value string) (bool, error) {
+	result := s.db.Model(&dbmodels.ExpiringValue{}).
+		Where("id = ? AND expires_at > ?", key, s.now()).
+		Update("value", value)
+	if result.Error != nil {
+		return false, fmt.Errorf("error updating %s: %w", key, result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (s *ExpiringStore) Delete(keys ...string) error {
+	if err := s.db.Where("id IN ?", keys).Delete(&dbmodels.ExpiringValue{}).Error; err != nil {
+		return fmt.Errorf("error deleting %v: %w", keys, err)
+	}
+	return nil
+}
+
+// Reserve takes the key for ttl unless another caller holds it, which makes it a rate limit window shared by every
+// instance. It returns how long the current holder keeps the key, zero when the key was reserved for the caller.
+func (s *ExpiringStore) Reserve(key string, ttl time.Duration) (time.Duration, error) {
+	now := s.now()
+	s.purge(now)
+	entry := dbmodels.ExpiringValue{ID: key, ExpiresAt: now.Add(ttl), CTime: now}
+	// an expired holder is replaced, a live one keeps the key and the insert affects no row
+	result := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "attempts", "expires_at", "ctime"}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: entry.TableName() + ".expires_at <= ?", Vars: []interface{}{now}},
+		}},
+	}).Create(&entry)
+	if result.Error != nil {
+		return 0, fmt.Errorf("error reserving %s: %w", key, result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return 0, nil
+	}
+	holder, err := s.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	if holder == nil {
+		// the holder expired in between, the caller may try again right away
+		return time.Second, nil
+	}
+	return holder.ExpiresAt.Sub(now), nil
+}
+
+// Increment counts an attempt under the key and returns the attempts counted so far. The count starts over ttl after
+// the first attempt, so it limits the attempts per window.
+func (s *ExpiringStore) Increment(key string, ttl time.Duration) (int, error) {
+	now := s.now()
+	s.purge(now)
+	entry := dbmodels.ExpiringValue{ID: key, Attempts: 1, ExpiresAt: now.Add(ttl), CTime: now}
+	table := entry.TableName()
+	// both assignments read the row as it was before the update, an expired window starts over
+	expired := "CASE WHEN " + table + ".expires_at <= ? THEN "
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "id"}},
+		DoUpdates: clause.Set{
+			{Column: clause.Column{Name: "attempts"}, Value: gorm.Expr(expired+"1 ELSE "+table+".attempts + 1 END", now)},
+			{Column: clause.Column{Name: "expires_at"}, Value: gorm.Expr(expired+"excluded.expires_at ELSE "+table+".expires_at END", now)},
+		},
+	}).Create(&entry).Error; err != nil {
+		return 0, fmt.Errorf("error counting %s: %w", key, err)
+	}
+	counted, err := s.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	if counted == nil {
+		return 1, nil
+	}
+	return counted.Attempts, nil
+}
+
+// purge drops expired values, a failure only leaves rows that read as missing anyway
+func (s *ExpiringStore) purge(now time.Time) {
+	s.db.Where("expires_at <= ?", now).Delete(&dbmodels.ExpiringValue{})
+}
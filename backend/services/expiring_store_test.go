@@ -0,0 +1,54 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpiringStoreReserveAndExpire(t *testing.T) {
+	db := newTestNotificationDB(t)
+	now := time.Now()
+	store := NewExpiringStore(db)
+	store.now = func() time.Time { return now }
+	other := NewExpiringStore(db)
+	other.now = store.now
+
+	if remaining, err := store.Reserve("limit:u1", time.Minute); err != nil || remaining != 0 {
+		t.Fatalf("expected the key to be reserved, got %v %v", remaining, err)
+	}
+	now = now.Add(20 * time.Second)
+	if remaining, err := other.Reserve("limit:u1", time.Minute); err != nil || remaining != 40*time.Second {
+		t.Fatalf("expected another instance to wait 40s, got %v %v", remaining, err)
+	}
+	now = now.Add(40 * time.Second)
+	if remaining, err := other.Reserve("limit:u1", time.Minute); err != nil || remaining != 0 {
+		t.Fatalf("expected the expired reservation to be taken over, got %v %v", remaining, err)
+	}
+
+	for want := 1; want <= 3; want++ {
+		if attempts, err := other.Increment("attempts:u1", time.Minute); err != nil || attempts != want {
+			t.Fatalf("expected attempt %d, got %d %v", want, attempts, err)
+		}
+	}
+	now = now.Add(time.Minute)
+	if attempts, err := store.Increment("attempts:u1", time.Minute); err != nil || attempts != 1 {
+		t.Fatalf("expected the count to start over, got %d %v", attempts, err)
+	}
+
+	if err := store.Set("otp:u1", "123456", time.Minute); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if updated, err := other.Update("otp:u1", "654321"); err != nil || !updated {
+		t.Fatalf("update: %v %v", updated, err)
+	}
+	if value, err := store.Get("otp:u1"); err != nil || value == nil || value.Value != "654321" {
+		t.Fatalf("expected the updated value, got %+v %v", value, err)
+	}
+	now = now.Add(time.Minute)
+	if value, err := store.Get("otp:u1"); err != nil || value != nil {
+		t.Fatalf("expected the value to expire, got %+v %v", value, err)
+	}
+	if updated, err := store.Update("otp:u1", "111111"); err != nil || updated {
+		t.Fatalf("expected an expired value not to be updated, got %v %v", updated, err)
+	}
+}
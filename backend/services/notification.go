@@ -1,32 +1,62 @@
 package services
 
 import (
-	"math"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/ringecosystem/degov-square/database"
 	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	"github.com/ringecosystem/degov-square/internal/config"
 	"github.com/ringecosystem/degov-square/internal/utils"
 	"github.com/ringecosystem/degov-square/types"
 )
 
 type NotificationService struct {
-	db *gorm.DB
+	db          *gorm.DB
+	retryPolicy NotificationRetryPolicy
+	eventLease  time.Duration
+	now         func() time.Time
+	random      func() float64
 }
 
 func NewNotificationService() *NotificationService {
+	return newNotificationService(database.GetDB())
+}
+
+func newNotificationService(db *gorm.DB) *NotificationService {
 	return &NotificationService{
-		db: database.GetDB(),
+		db:          db,
+		retryPolicy: notificationRetryPolicyFromConfig(),
+		eventLease:  config.GetConfig().GetNotificationEventLease(),
+		now:         time.Now,
+		random:      rand.Float64,
 	}
 }
 
+// nextExecuteAfterFailure returns when the given failed attempt should be retried and whether retries are exhausted
+func (s *NotificationService) nextExecuteAfterFailure(attempts int) (time.Time, bool) {
+	return s.now().Add(s.retryPolicy.Delay(attempts, s.random())), s.retryPolicy.Exhausted(attempts)
+}
+
 func (s *NotificationService) SaveEvent(event dbmodels.NotificationEvent) error {
 	return s.SaveEvents([]dbmodels.NotificationEvent{event})
 }
 
 func (s *NotificationService) SaveEvents(events []dbmodels.NotificationEvent) error {
+	return saveNotificationEvents(s.db, events)
+}
+
+// saveNotificationEvents queues the events as pending, db may be a transaction the events are created in.
+// Events with a dedupe key that was already saved are skipped, so a task that crashes before advancing its
+// offset can save the same events again.
+func saveNotificationEvents(db *gorm.DB, events []dbmodels.NotificationEvent) error {
 	if len(events) == 0 {
 		return nil
 	}
@@ -36,15 +66,57 @@ func (s *NotificationService) SaveEvents(events []dbmodels.NotificationEvent) er
 		events[i].Reached = 0
 		events[i].State = dbmodels.NotificationEventStatePending
 		events[i].TimeNextExecute = time.Now()
+		if events[i].DedupeKey == nil {
+			events[i].DedupeKey = notificationEventDedupeKey(events[i])
+		}
 	}
 
-	if err := s.db.Create(&events).Error; err != nil {
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "dedupe_key"}},
+		DoNothing: true,
+	}).Create(&events).Error; err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// notificationEventDedupeKey returns the natural key of an event, nil for events that may happen more than once
+func notificationEventDedupeKey(event dbmodels.NotificationEvent) *string {
+	parts := []string{string(event.Type), event.DaoCode, event.ProposalID}
+	switch event.Type {
+	case dbmodels.SubscribeFeatureProposalNew,
+		dbmodels.SubscribeFeatureVoteEnd,
+		dbmodels.SubscribeFeatureExecutionReady,
+		dbmodels.SubscribeFeatureExecutionExpiring:
+	case dbmodels.SubscribeFeatureVoteEmitted:
+		if event.VoteID == nil {
+			return nil
+		}
+		parts = append(parts, *event.VoteID)
+	case dbmodels.SubscribeFeatureProposalStateChanged:
+		if event.Payload == nil {
+			return nil
+		}
+		var payload struct {
+			NewState string `json:"new_state"`
+		}
+		if err := json.Unmarshal([]byte(*event.Payload), &payload); err != nil || payload.NewState == "" {
+			return nil
+		}
+		parts = append(parts, payload.NewState)
+	case dbmodels.SubscribeFeatureVoteReminder:
+		if event.TargetAddress == nil {
+			return nil
+		}
+		parts = append(parts, strings.ToLower(*event.TargetAddress))
+	default:
+		return nil
+	}
+	key := strings.Join(parts, ":")
+	return &key
+}
+
 func (s *NotificationService) InspectEventWithProposal(input types.InspectNotificationEventInput) (*dbmodels.NotificationEvent, error) {
 	var event dbmodels.NotificationEvent
 	query := s.db.Where("dao_code = ? AND proposal_id = ? AND type = ?", input.DaoCode, input.ProposalID, input.Type)
@@ -54,6 +126,10 @@ func (s *NotificationService) InspectEventWithProposal(input types.InspectNotifi
 		query = query.Where("vote_id = ?", *input.VoteID)
 	}
 
+	if input.TargetAddress != nil {
+		query = query.Where("target_address = ?", strings.ToLower(*input.TargetAddress))
+	}
+
 	// Add States condition if provided
 	if input.States != nil && len(*input.States) > 0 {
 		query = query.Where("state IN ?", *input.States)
@@ -108,7 +184,10 @@ func (s *NotificationService) StoreRecords(records []dbmodels.NotificationRecord
 
 	for i := range recordsToCreate {
 		recordsToCreate[i].ID = utils.NextIDString()
-		recordsToCreate[i].TimeNextExecute = time.Now()
+		// digest records are scheduled for the end of their window by the caller
+		if recordsToCreate[i].TimeNextExecute.IsZero() {
+			recordsToCreate[i].TimeNextExecute = s.now()
+		}
 	}
 
 	if err := s.db.Create(&recordsToCreate).Error; err != nil {
@@ -126,7 +205,7 @@ func (s *NotificationService) ListLimitEvents(input types.ListLimitEventsInput)
 		query = query.Where("state IN ?", *input.States)
 	}
 
-	query = query.Where("time_next_execute <= ?", time.Now())
+	query = query.Where("time_next_execute <= ?", s.now())
 
 	if err := query.Order("time_next_execute asc, ctime asc").Limit(input.Limit).Find(&events).Error; err != nil {
 		return nil, err
@@ -134,36 +213,92 @@ func (s *NotificationService) ListLimitEvents(input types.ListLimitEventsInput)
 	return events, nil
 }
 
+// ErrNotificationEventLeaseLost is returned when the lease of an event expired and another run claimed it
+var ErrNotificationEventLeaseLost = errors.New("notification event lease was lost")
+
+// updateLeasedEvent updates the event, only while the given lease is still held when leaseUntil is set
+func (s *NotificationService) updateLeasedEvent(id string, leaseUntil *time.Time, updates map[string]interface{}) error {
+	query := s.db.Model(&dbmodels.NotificationEvent{}).Where("id = ?", id)
+	if leaseUntil != nil {
+		query = query.Where("state = ? AND time_next_execute = ?", dbmodels.NotificationEventStateProgress, *leaseUntil)
+	}
+	result := query.Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if leaseUntil != nil && result.RowsAffected == 0 {
+		return ErrNotificationEventLeaseLost
+	}
+	return nil
+}
+
 func (s *NotificationService) UpdateEventState(input types.UpdateEventStateInput) error {
-	return s.db.
-		Model(&dbmodels.NotificationEvent{}).Where("id = ?", input.ID).
-		Updates(map[string]interface{}{
-			"state": input.State,
-			"utime": time.Now(),
-		}).
-		Error
+	return s.updateLeasedEvent(input.ID, input.LeaseUntil, map[string]interface{}{
+		"state": input.State,
+		"utime": time.Now(),
+	})
 }
 
 func (s *NotificationService) UpdateEventRetryTimes(input types.UpdateEventRetryTimes) error {
-	backoffMinutes := math.Pow(2, float64(input.TimesRetry))
-	if backoffMinutes > 1440 {
-		backoffMinutes = 1440
-	}
-	delay := time.Duration(backoffMinutes) * time.Minute
-	NextExecutableTime := time.Now().Add(delay)
+	nextExecute, exhausted := s.nextExecuteAfterFailure(input.TimesRetry)
 
 	updates := map[string]interface{}{
 		"times_retry":       input.TimesRetry,
 		"message":           input.Message,
-		"utime":             time.Now(),
-		"time_next_execute": NextExecutableTime,
+		"utime":             s.now(),
+		"time_next_execute": nextExecute,
+		"state":             dbmodels.NotificationEventStatePending,
 	}
 
-	if input.TimesRetry > 3 {
+	if exhausted {
 		updates["state"] = dbmodels.NotificationEventStateFailed
 	}
 
-	return s.db.Model(&dbmodels.NotificationEvent{}).Where("id = ?", input.ID).Updates(updates).Error
+	return s.updateLeasedEvent(input.ID, input.LeaseUntil, updates)
+}
+
+// ClaimEvent moves a due event into PROGRESS and leases it until it is completed or rescheduled.
+// An event found still in PROGRESS was abandoned by a previous run, that run counts as a failed attempt.
+// It returns false when the event was claimed by another worker or has run out of attempts. A claimed event is
+// updated in place, its TimeNextExecute is the lease to pass to UpdateEventState and UpdateEventRetryTimes.
+func (s *NotificationService) ClaimEvent(event *dbmodels.NotificationEvent) (bool, error) {
+	now := s.now()
+	claimed := *event
+	claimed.State = dbmodels.NotificationEventStateProgress
+	// postgres keeps microseconds, the lease is compared for equality when the run finishes
+	claimed.TimeNextExecute = now.Add(s.eventLease).Truncate(time.Microsecond)
+	claimed.UTime = now
+
+	exhausted := false
+	if event.State == dbmodels.NotificationEventStateProgress {
+		claimed.TimesRetry++
+		message := fmt.Sprintf("[%d] Event processing did not finish within the lease", claimed.TimesRetry)
+		if event.Message != nil {
+			message = *event.Message + "\n\n-------\n" + message
+		}
+		claimed.Message = &message
+		if exhausted = s.retryPolicy.Exhausted(claimed.TimesRetry); exhausted {
+			claimed.State = dbmodels.NotificationEventStateFailed
+		}
+	}
+
+	result := s.db.Model(&dbmodels.NotificationEvent{}).
+		Where("id = ? AND state = ? AND time_next_execute <= ?", event.ID, event.State, now).
+		Updates(map[string]interface{}{
+			"state":             claimed.State,
+			"time_next_execute": claimed.TimeNextExecute,
+			"times_retry":       claimed.TimesRetry,
+			"message":           claimed.Message,
+			"utime":             claimed.UTime,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	*event = claimed
+	return !exhausted, nil
 }
 
 func (s *NotificationService) ListLimitRecords(input types.ListLimitRecordsInput) ([]dbmodels.NotificationRecord, error) {
@@ -174,7 +309,11 @@ func (s *NotificationService) ListLimitRecords(input types.ListLimitRecordsInput
 		query = query.Where("state IN ?", *input.States)
 	}
 
-	query = query.Where("time_next_execute <= ?", time.Now())
+	if input.InstantOnly {
+		query = query.Where("digest IS NULL")
+	}
+
+	query = query.Where("time_next_execute <= ?", s.now())
 
 	if err := query.Order("time_next_execute asc, ctime asc").Limit(input.Limit).Find(&records).Error; err != nil {
 		return nil, err
@@ -193,23 +332,176 @@ func (s *NotificationService) UpdateRecordState(input types.UpdateRecordStateInp
 }
 
 func (s *NotificationService) UpdateRecordRetryTimes(input types.UpdateRecordRetryTimes) error {
-	backoffMinutes := math.Pow(2, float64(input.TimesRetry))
-	if backoffMinutes > 1440 {
-		backoffMinutes = 1440
-	}
-	delay := time.Duration(backoffMinutes) * time.Minute
-	NextExecutableTime := time.Now().Add(delay)
+	nextExecute, exhausted := s.nextExecuteAfterFailure(input.TimesRetry)
 
 	updates := map[string]interface{}{
 		"times_retry":       input.TimesRetry,
 		"message":           input.Message,
-		"time_next_execute": NextExecutableTime,
-		"utime":             time.Now(),
+		"time_next_execute": nextExecute,
+		"utime":             s.now(),
 	}
 
-	if input.TimesRetry > 3 {
+	if exhausted {
 		updates["state"] = dbmodels.NotificationRecordStateSentFail
 	}
 
 	return s.db.Model(&dbmodels.NotificationRecord{}).Where("id = ?", input.ID).Updates(updates).Error
 }
+
+// ListInboxAudiences returns the locales and timezones of the users whose records of the event have no inbox content yet.
+func (s *NotificationService) ListInboxAudiences(eventID string) ([]types.InboxAudience, error) {
+	var audiences []types.InboxAudience
+	if err := s.db.Raw(`
+SELECT DISTINCT COALESCE(pref.locale, ?) AS locale, COALESCE(pref.timezone, ?) AS timezone
+FROM dgv_notification_record AS r
+LEFT JOIN dgv_notification_preference AS pref ON pref.user_id = r.user_id
+WHERE r.event_id = ? AND r.content IS NULL`,
+		utils.LocaleEnglish, defaultNotificationTimezone, eventID,
+	).Scan(&audiences).Error; err != nil {
+		return nil, err
+	}
+	return audiences, nil
+}
+
+// StoreInboxContent stores the inbox content of an audience on up to limit records of the event still missing it and
+// returns how many were updated, callers repeat it until fewer than limit are.
+func (s *NotificationService) StoreInboxContent(eventID string, audience types.InboxAudience, output *types.TemplateOutput, limit int) (int64, error) {
+	result := s.db.Exec(`
+UPDATE dgv_notification_record SET title = ?, content = ?
+WHERE id IN (
+    SELECT r.id
+    FROM dgv_notification_record AS r
+    LEFT JOIN dgv_notification_preference AS pref ON pref.user_id = r.user_id
+    WHERE r.event_id = ? AND r.content IS NULL AND COALESCE(pref.locale, ?) = ? AND COALESCE(pref.timezone, ?) = ?
+    LIMIT ?
+)`,
+		output.Title, output.PlainTextContent,
+		eventID, utils.LocaleEnglish, audience.Locale, defaultNotificationTimezone, audience.Timezone,
+		limit,
+	)
+	return result.RowsAffected, result.Error
+}
+
+// DeferRecords pushes pending records back to the given time without counting an attempt, e.g. for quiet hours.
+func (s *NotificationService) DeferRecords(ids []string, until time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.db.
+		Model(&dbmodels.NotificationRecord{}).
+		Where("id IN ? AND state = ?", ids, dbmodels.NotificationRecordStatePending).
+		Updates(map[string]interface{}{
+			"time_next_execute": until,
+			"utime":             s.now(),
+		}).Error
+}
+
+// EnsureDeliveries creates a pending delivery for every channel the record has not been attempted on yet,
+// and returns all deliveries of the record.
+func (s *NotificationService) EnsureDeliveries(record *dbmodels.NotificationRecord, channels []dbmodels.NotificationChannel) ([]dbmodels.NotificationDelivery, error) {
+	if len(channels) > 0 {
+		now := time.Now()
+		deliveries := make([]dbmodels.NotificationDelivery, 0, len(channels))
+		for _, channel := range channels {
+			deliveries = append(deliveries, dbmodels.NotificationDelivery{
+				ID:              utils.NextIDString(),
+				RecordID:        record.ID,
+				ChannelID:       channel.ID,
+				ChannelType:     channel.ChannelType,
+				State:           dbmodels.NotificationDeliveryStatePending,
+				TimeNextExecute: now,
+				CTime:           now,
+			})
+		}
+		if err := s.db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "record_id"}, {Name: "channel_id"}},
+			DoNothing: true,
+		}).Create(&deliveries).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	var results []dbmodels.NotificationDelivery
+	if err := s.db.Where("record_id = ?", record.ID).Order("ctime asc").Find(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (s *NotificationService) UpdateDeliveryState(input types.UpdateDeliveryStateInput) error {
+	return s.db.
+		Model(&dbmodels.NotificationDelivery{}).
+		Where("id = ?", input.ID).
+		Updates(map[string]interface{}{
+			"state":         input.State,
+			"response_code": input.ResponseCode,
+			"utime":         time.Now(),
+		}).Error
+}
+
+// RemoveGoneChannel deletes a channel the receiver reported as gone and cancels its pending deliveries
+func (s *NotificationService) RemoveGoneChannel(channelID string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", channelID).Delete(&dbmodels.NotificationChannel{}).Error; err != nil {
+			return fmt.Errorf("error removing notification channel: %w", err)
+		}
+		return cancelChannelDeliveries(tx, channelID, nil)
+	})
+}
+
+func (s *NotificationService) UpdateDeliveryRetryTimes(input types.UpdateDeliveryRetryTimes) error {
+	nextExecute, exhausted := s.nextExecuteAfterFailure(input.TimesRetry)
+
+	updates := map[string]interface{}{
+		"times_retry":       input.TimesRetry,
+		"last_error":        input.LastError,
+		"response_code":     input.ResponseCode,
+		"time_next_execute": nextExecute,
+		"utime":             s.now(),
+	}
+
+	if exhausted {
+		updates["state"] = dbmodels.NotificationDeliveryStateSentFail
+	}
+
+	return s.db.Model(&dbmodels.NotificationDelivery{}).Where("id = ?", input.ID).Updates(updates).Error
+}
+
+// AggregateRecordState derives the record state from its deliveries: it stays pending while any delivery
+// can still be retried, and is only sent ok when every delivery succeeded.
+func (s *NotificationService) AggregateRecordState(recordID string) (dbmodels.NotificationRecordState, error) {
+	var deliveries []dbmodels.NotificationDelivery
+	if err := s.db.Where("record_id = ?", recordID).Find(&deliveries).Error; err != nil {
+		return "", err
+	}
+
+	state := dbmodels.NotificationRecordStateSentOk
+	var nextExecute *time.Time
+	for _, delivery := range deliveries {
+		switch delivery.State {
+		case dbmodels.NotificationDeliveryStatePending:
+			state = dbmodels.NotificationRecordStatePending
+			if nextExecute == nil || delivery.TimeNextExecute.Before(*nextExecute) {
+				next := delivery.TimeNextExecute
+				nextExecute = &next
+			}
+		case dbmodels.NotificationDeliveryStateSentFail:
+			if state == dbmodels.NotificationRecordStateSentOk {
+				state = dbmodels.NotificationRecordStateSentFail
+			}
+		}
+		// cancelled deliveries count neither as sent nor as failed
+	}
+
+	updates := map[string]interface{}{
+		"state": state,
+		"utime": time.Now(),
+	}
+	if nextExecute != nil {
+		updates["time_next_execute"] = *nextExecute
+	}
+	if err := s.db.Model(&dbmodels.NotificationRecord{}).Where("id = ?", recordID).Updates(updates).Error; err != nil {
+		return "", err
+	}
+	return state, nil
+}
@@ -0,0 +1,312 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/ringecosystem/degov-square/database"
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	gqlmodels "github.com/ringecosystem/degov-square/graph/models"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+const maxDeadLetterPageSize = 200
+
+// ErrEmptyDeadLetterFilter refuses bulk actions that would select every failed entry by accident
+var ErrEmptyDeadLetterFilter = errors.New("select dead letters by id, DAO, type or time")
+
+// NotificationDeadLetterService inspects the events and records that ran out of retries,
+// operators replay them with a fresh retry budget or discard them.
+type NotificationDeadLetterService struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+func NewNotificationDeadLetterService() *NotificationDeadLetterService {
+	return newNotificationDeadLetterService(database.GetDB())
+}
+
+func newNotificationDeadLetterService(db *gorm.DB) *NotificationDeadLetterService {
+	return &NotificationDeadLetterService{
+		db:  db,
+		now: time.Now,
+	}
+}
+
+// List returns a page of failed events or records for the admin API
+func (s *NotificationDeadLetterService) List(input gqlmodels.NotificationDeadLettersInput) (*gqlmodels.NotificationDeadLetterPage, error) {
+	limit, offset := 50, 0
+	if input.Limit != nil {
+		limit = int(*input.Limit)
+	}
+	if input.Offset != nil {
+		offset = int(*input.Offset)
+	}
+	filter := deadLetterFilter(nil, input.DaoCode, input.Types, input.Since, input.Until)
+
+	page := &gqlmodels.NotificationDeadLetterPage{Items: []*gqlmodels.NotificationDeadLetter{}}
+	switch input.Kind {
+	case gqlmodels.NotificationDeadLetterKindEvent:
+		events, total, err := s.ListEvents(filter, limit, offset)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range events {
+			page.Items = append(page.Items, &gqlmodels.NotificationDeadLetter{
+				ID:          event.ID,
+				Kind:        gqlmodels.NotificationDeadLetterKindEvent,
+				DaoCode:     event.DaoCode,
+				ChainID:     int32(event.ChainID),
+				Type:        gqlmodels.FeatureName(event.Type),
+				ProposalID:  event.ProposalID,
+				VoteID:      event.VoteID,
+				UserAddress: event.TargetAddress,
+				TimesRetry:  int32(event.TimesRetry),
+				Message:     event.Message,
+				Ctime:       event.CTime,
+				Utime:       event.UTime,
+			})
+		}
+		page.Total = int32(total)
+	case gqlmodels.NotificationDeadLetterKindRecord:
+		records, total, err := s.ListRecords(filter, limit, offset)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			page.Items = append(page.Items, &gqlmodels.NotificationDeadLetter{
+				ID:          record.ID,
+				Kind:        gqlmodels.NotificationDeadLetterKindRecord,
+				DaoCode:     record.DaoCode,
+				ChainID:     int32(record.ChainID),
+				Type:        gqlmodels.FeatureName(record.Type),
+				ProposalID:  record.ProposalID,
+				VoteID:      record.VoteID,
+				UserID:      &record.UserID,
+				UserAddress: &record.UserAddress,
+				TimesRetry:  int32(record.TimesRetry),
+				Message:     record.Message,
+				Ctime:       record.CTime,
+				Utime:       record.UTime,
+			})
+		}
+		page.Total = int32(total)
+	default:
+		return nil, fmt.Errorf("unsupported dead letter kind %s", input.Kind)
+	}
+	return page, nil
+}
+
+// Replay replays the selected failed events or records and returns how many were replayed
+func (s *NotificationDeadLetterService) Replay(input gqlmodels.NotificationDeadLetterActionInput) (int32, error) {
+	filter := deadLetterFilter(input.Ids, input.DaoCode, input.Types, input.Since, input.Until)
+	var affected int64
+	var err error
+	switch input.Kind {
+	case gqlmodels.NotificationDeadLetterKindEvent:
+		affected, err = s.ReplayEvents(filter)
+	case gqlmodels.NotificationDeadLetterKindRecord:
+		affected, err = s.ReplayRecords(filter)
+	default:
+		err = fmt.Errorf("unsupported dead letter kind %s", input.Kind)
+	}
+	return int32(affected), err
+}
+
+// Discard discards the selected failed events or records and returns how many were discarded
+func (s *NotificationDeadLetterService) Discard(input gqlmodels.NotificationDeadLetterActionInput) (int32, error) {
+	filter := deadLetterFilter(input.Ids, input.DaoCode, input.Types, input.Since, input.Until)
+	var affected int64
+	var err error
+	switch input.Kind {
+	case gqlmodels.NotificationDeadLetterKindEvent:
+		affected, err = s.DiscardEvents(filter)
+	case gqlmodels.NotificationDeadLetterKindRecord:
+		affected, err = s.DiscardRecords(filter)
+	default:
+		err = fmt.Errorf("unsupported dead letter kind %s", input.Kind)
+	}
+	return int32(affected), err
+}
+
+// ListEvents returns a page of failed events, most recently failed first, with the total number matching the filter
+func (s *NotificationDeadLetterService) ListEvents(filter types.NotificationDeadLetterFilter, limit, offset int) ([]dbmodels.NotificationEvent, int64, error) {
+	if err := validateDeadLetterPage(limit, offset); err != nil {
+		return nil, 0, err
+	}
+	query := deadLetterScope(s.db.Model(&dbmodels.NotificationEvent{}), filter).
+		Where("state = ?", dbmodels.NotificationEventStateFailed)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var events []dbmodels.NotificationEvent
+	if err := query.Order("utime desc, id desc").Limit(limit).Offset(offset).Find(&events).Error; err != nil {
+		return nil, 0, err
+	}
+	return events, total, nil
+}
+
+// ListRecords returns a page of failed records, most recently failed first, with the total number matching the filter
+func (s *NotificationDeadLetterService) ListRecords(filter types.NotificationDeadLetterFilter, limit, offset int) ([]dbmodels.NotificationRecord, int64, error) {
+	if err := validateDeadLetterPage(limit, offset); err != nil {
+		return nil, 0, err
+	}
+	query := deadLetterScope(s.db.Model(&dbmodels.NotificationRecord{}), filter).
+		Where("state = ?", dbmodels.NotificationRecordStateSentFail)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var records []dbmodels.NotificationRecord
+	if err := query.Order("utime desc, id desc").Limit(limit).Offset(offset).Find(&records).Error; err != nil {
+		return nil, 0, err
+	}
+	return records, total, nil
+}
+
+// ReplayEvents moves failed events back to pending, they are picked up by the next event run with their retries reset
+func (s *NotificationDeadLetterService) ReplayEvents(filter types.NotificationDeadLetterFilter) (int64, error) {
+	if isEmptyDeadLetterFilter(filter) {
+		return 0, ErrEmptyDeadLetterFilter
+	}
+	now := s.now()
+	result := deadLetterScope(s.db.Model(&dbmodels.NotificationEvent{}), filter).
+		Where("state = ?", dbmodels.NotificationEventStateFailed).
+		Updates(map[string]interface{}{
+			"state":             dbmodels.NotificationEventStatePending,
+			"times_retry":       0,
+			"time_next_execute": now,
+			"message":           appendDeadLetterNote("Replayed", now),
+			"utime":             now,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// ReplayRecords moves failed records back to pending, the deliveries that failed are attempted again,
+// channels that already received the notification are not notified twice.
+func (s *NotificationDeadLetterService) ReplayRecords(filter types.NotificationDeadLetterFilter) (int64, error) {
+	if isEmptyDeadLetterFilter(filter) {
+		return 0, ErrEmptyDeadLetterFilter
+	}
+	now := s.now()
+	var replayed int64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		failedRecords := deadLetterScope(tx.Model(&dbmodels.NotificationRecord{}), filter).
+			Select("id").
+			Where("state = ?", dbmodels.NotificationRecordStateSentFail)
+		if err := tx.Model(&dbmodels.NotificationDelivery{}).
+			Where("record_id IN (?) AND state = ?", failedRecords, dbmodels.NotificationDeliveryStateSentFail).
+			Updates(map[string]interface{}{
+				"state":             dbmodels.NotificationDeliveryStatePending,
+				"times_retry":       0,
+				"time_next_execute": now,
+				"utime":             now,
+			}).Error; err != nil {
+			return err
+		}
+
+		result := deadLetterScope(tx.Model(&dbmodels.NotificationRecord{}), filter).
+			Where("state = ?", dbmodels.NotificationRecordStateSentFail).
+			Updates(map[string]interface{}{
+				"state":             dbmodels.NotificationRecordStatePending,
+				"times_retry":       0,
+				"time_next_execute": now,
+				"message":           appendDeadLetterNote("Replayed", now),
+				"utime":             now,
+			})
+		replayed = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return replayed, nil
+}
+
+// DiscardEvents marks failed events as discarded, they stay in the table with their message log
+func (s *NotificationDeadLetterService) DiscardEvents(filter types.NotificationDeadLetterFilter) (int64, error) {
+	if isEmptyDeadLetterFilter(filter) {
+		return 0, ErrEmptyDeadLetterFilter
+	}
+	now := s.now()
+	result := deadLetterScope(s.db.Model(&dbmodels.NotificationEvent{}), filter).
+		Where("state = ?", dbmodels.NotificationEventStateFailed).
+		Updates(map[string]interface{}{
+			"state":   dbmodels.NotificationEventStateDiscarded,
+			"message": appendDeadLetterNote("Discarded", now),
+			"utime":   now,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// DiscardRecords marks failed records as discarded, they are still listed in the inbox of the user
+func (s *NotificationDeadLetterService) DiscardRecords(filter types.NotificationDeadLetterFilter) (int64, error) {
+	if isEmptyDeadLetterFilter(filter) {
+		return 0, ErrEmptyDeadLetterFilter
+	}
+	now := s.now()
+	result := deadLetterScope(s.db.Model(&dbmodels.NotificationRecord{}), filter).
+		Where("state = ?", dbmodels.NotificationRecordStateSentFail).
+		Updates(map[string]interface{}{
+			"state":   dbmodels.NotificationRecordStateDiscarded,
+			"message": appendDeadLetterNote("Discarded", now),
+			"utime":   now,
+		})
+	return result.RowsAffected, result.Error
+}
+
+func deadLetterFilter(ids []string, daoCode *string, features []gqlmodels.FeatureName, since, until *time.Time) types.NotificationDeadLetterFilter {
+	filter := types.NotificationDeadLetterFilter{IDs: ids, Since: since, Until: until}
+	if daoCode != nil {
+		filter.DaoCode = *daoCode
+	}
+	for _, feature := range features {
+		filter.Types = append(filter.Types, dbmodels.SubscribeFeatureName(feature))
+	}
+	return filter
+}
+
+func deadLetterScope(query *gorm.DB, filter types.NotificationDeadLetterFilter) *gorm.DB {
+	if len(filter.IDs) > 0 {
+		query = query.Where("id IN ?", filter.IDs)
+	}
+	if filter.DaoCode != "" {
+		query = query.Where("dao_code = ?", filter.DaoCode)
+	}
+	if len(filter.Types) > 0 {
+		query = query.Where("type IN ?", filter.Types)
+	}
+	if filter.Since != nil {
+		query = query.Where("utime >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		query = query.Where("utime < ?", *filter.Until)
+	}
+	return query
+}
+
+func isEmptyDeadLetterFilter(filter types.NotificationDeadLetterFilter) bool {
+	return len(filter.IDs) == 0 && filter.DaoCode == "" && len(filter.Types) == 0 && filter.Since == nil && filter.Until == nil
+}
+
+func validateDeadLetterPage(limit, offset int) error {
+	if limit < 1 || limit > maxDeadLetterPageSize {
+		return fmt.Errorf("limit must be between 1 and %d", maxDeadLetterPageSize)
+	}
+	if offset < 0 {
+		return errors.New("offset must not be negative")
+	}
+	return nil
+}
+
+// appendDeadLetterNote adds the operator action to the message log, in the format the retries use
+func appendDeadLetterNote(action string, now time.Time) interface{} {
+	note := fmt.Sprintf("[%s] %s by an operator", now.UTC().Format(time.RFC3339), action)
+	return gorm.Expr("COALESCE(message || ?, ?)", "\n\n-------\n"+note, note)
+}
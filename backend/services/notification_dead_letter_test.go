@@ -0,0 +1,143 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	"github.com/ringecosystem/degov-square/internal/utils"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+func seedFailedRecord(t *testing.T, db *gorm.DB, id, daoCode string, failedAt time.Time) {
+	t.Helper()
+	record := &dbmodels.NotificationRecord{
+		ID:              id,
+		Code:            "event_" + id,
+		EventID:         "event_" + id,
+		DaoCode:         daoCode,
+		Type:            dbmodels.SubscribeFeatureProposalNew,
+		ProposalID:      "0x01",
+		UserID:          "u1",
+		UserAddress:     "0xabc",
+		State:           dbmodels.NotificationRecordStateSentFail,
+		Message:         utils.StringPtr("[5] Failed to build notification record: timeout"),
+		TimesRetry:      5,
+		TimeNextExecute: failedAt,
+		CTime:           failedAt,
+		UTime:           failedAt,
+	}
+	if err := db.Create(record).Error; err != nil {
+		t.Fatalf("seed record: %v", err)
+	}
+}
+
+func TestListDeadLetterRecordsFiltersByDaoAndTime(t *testing.T) {
+	db := newTestNotificationDB(t)
+	service := newNotificationDeadLetterService(db)
+	now := time.Now()
+	seedFailedRecord(t, db, "r1", "demo", now.Add(-time.Hour))
+	seedFailedRecord(t, db, "r2", "demo", now.Add(-48*time.Hour))
+	seedFailedRecord(t, db, "r3", "other", now.Add(-time.Hour))
+	seedTestNotificationRecord(t, db, "pending")
+
+	since := now.Add(-24 * time.Hour)
+	records, total, err := service.ListRecords(types.NotificationDeadLetterFilter{DaoCode: "demo", Since: &since}, 10, 0)
+	if err != nil {
+		t.Fatalf("list records: %v", err)
+	}
+	if total != 1 || len(records) != 1 || records[0].ID != "r1" {
+		t.Fatalf("unexpected dead letters %d %+v", total, records)
+	}
+	if records[0].Message == nil || !strings.Contains(*records[0].Message, "timeout") {
+		t.Fatalf("expected the message log, got %v", records[0].Message)
+	}
+
+	if _, _, err := service.ListRecords(types.NotificationDeadLetterFilter{}, maxDeadLetterPageSize+1, 0); err == nil {
+		t.Fatalf("expected the page size to be limited")
+	}
+}
+
+func TestReplayDeadLetterRecordResetsFailedDeliveries(t *testing.T) {
+	db := newTestNotificationDB(t)
+	service := newNotificationDeadLetterService(db)
+	now := time.Now()
+	seedFailedRecord(t, db, "r1", "demo", now.Add(-time.Hour))
+	seedFailedRecord(t, db, "r2", "demo", now.Add(-time.Hour))
+	for _, delivery := range []dbmodels.NotificationDelivery{
+		{ID: "d1", RecordID: "r1", ChannelID: "email", ChannelType: dbmodels.NotificationChannelTypeEmail, State: dbmodels.NotificationDeliveryStateSentOk},
+		{ID: "d2", RecordID: "r1", ChannelID: "hook", ChannelType: dbmodels.NotificationChannelTypeWebhook, State: dbmodels.NotificationDeliveryStateSentFail, TimesRetry: 5},
+		{ID: "d3", RecordID: "r2", ChannelID: "hook", ChannelType: dbmodels.NotificationChannelTypeWebhook, State: dbmodels.NotificationDeliveryStateSentFail, TimesRetry: 5},
+	} {
+		delivery.TimeNextExecute = now.Add(-time.Hour)
+		if err := db.Create(&delivery).Error; err != nil {
+			t.Fatalf("seed delivery: %v", err)
+		}
+	}
+
+	if _, err := service.ReplayRecords(types.NotificationDeadLetterFilter{}); !errors.Is(err, ErrEmptyDeadLetterFilter) {
+		t.Fatalf("expected an empty filter to be refused, got %v", err)
+	}
+	replayed, err := service.ReplayRecords(types.NotificationDeadLetterFilter{IDs: []string{"r1"}})
+	if err != nil || replayed != 1 {
+		t.Fatalf("replay records: %d %v", replayed, err)
+	}
+
+	var record dbmodels.NotificationRecord
+	if err := db.First(&record, "id = ?", "r1").Error; err != nil {
+		t.Fatalf("load record: %v", err)
+	}
+	if record.State != dbmodels.NotificationRecordStatePending || record.TimesRetry != 0 || record.TimeNextExecute.After(time.Now()) {
+		t.Fatalf("record was not replayed: %+v", record)
+	}
+	if !strings.HasPrefix(*record.Message, "[5] Failed") || !strings.Contains(*record.Message, "Replayed by an operator") {
+		t.Fatalf("expected the replay to be appended to the message log, got %q", *record.Message)
+	}
+
+	states := map[string]dbmodels.NotificationDeliveryState{}
+	var deliveries []dbmodels.NotificationDelivery
+	if err := db.Find(&deliveries).Error; err != nil {
+		t.Fatalf("load deliveries: %v", err)
+	}
+	for _, delivery := range deliveries {
+		states[delivery.ID] = delivery.State
+	}
+	if states["d1"] != dbmodels.NotificationDeliveryStateSentOk || states["d2"] != dbmodels.NotificationDeliveryStatePending || states["d3"] != dbmodels.NotificationDeliveryStateSentFail {
+		t.Fatalf("unexpected delivery states %v", states)
+	}
+}
+
+func TestDiscardDeadLetterEvents(t *testing.T) {
+	db := newTestNotificationDB(t)
+	service := newNotificationDeadLetterService(db)
+	for _, event := range []dbmodels.NotificationEvent{
+		{ID: "e1", DaoCode: "demo", Type: dbmodels.SubscribeFeatureVoteEmitted, ProposalID: "0x01", State: dbmodels.NotificationEventStateFailed},
+		{ID: "e2", DaoCode: "demo", Type: dbmodels.SubscribeFeatureProposalNew, ProposalID: "0x01", State: dbmodels.NotificationEventStateFailed},
+		{ID: "e3", DaoCode: "demo", Type: dbmodels.SubscribeFeatureVoteEmitted, ProposalID: "0x02", State: dbmodels.NotificationEventStateCompleted},
+	} {
+		if err := db.Create(&event).Error; err != nil {
+			t.Fatalf("seed event: %v", err)
+		}
+	}
+
+	discarded, err := service.DiscardEvents(types.NotificationDeadLetterFilter{Types: []dbmodels.SubscribeFeatureName{dbmodels.SubscribeFeatureVoteEmitted}})
+	if err != nil || discarded != 1 {
+		t.Fatalf("discard events: %d %v", discarded, err)
+	}
+	events, total, err := service.ListEvents(types.NotificationDeadLetterFilter{}, 10, 0)
+	if err != nil || total != 1 || events[0].ID != "e2" {
+		t.Fatalf("expected only e2 to be left, got %d %+v %v", total, events, err)
+	}
+
+	var event dbmodels.NotificationEvent
+	if err := db.First(&event, "id = ?", "e1").Error; err != nil {
+		t.Fatalf("load event: %v", err)
+	}
+	if event.State != dbmodels.NotificationEventStateDiscarded || event.Message == nil || !strings.Contains(*event.Message, "Discarded by an operator") {
+		t.Fatalf("unexpected discarded event %+v", event)
+	}
+}
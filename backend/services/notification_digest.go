@@ -0,0 +1,60 @@
+package services
+
+import (
+	"time"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+// IsDigestStrategy reports whether records of the strategy are held and sent as a digest.
+func IsDigestStrategy(strategy string) bool {
+	return strategy == dbmodels.SubscribeStrategyHourlyDigest || strategy == dbmodels.SubscribeStrategyDailyDigest
+}
+
+// NextDigestTime returns the end of the digest window that contains now, daily windows end at midnight in the given location.
+func NextDigestTime(strategy string, now time.Time, location *time.Location) time.Time {
+	if location == nil {
+		location = time.UTC
+	}
+	now = now.In(location)
+	switch strategy {
+	case dbmodels.SubscribeStrategyHourlyDigest:
+		return time.Date(now.Year(), now.Month(), now.Day(), now.Hour()+1, 0, 0, 0, location)
+	case dbmodels.SubscribeStrategyDailyDigest:
+		return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, location)
+	default:
+		return now
+	}
+}
+
+// ListDueDigests returns the users whose digest window has closed and still have pending records in it.
+func (s *NotificationService) ListDueDigests(limit int) ([]types.NotificationDigestGroup, error) {
+	var groups []types.NotificationDigestGroup
+	err := s.db.
+		Model(&dbmodels.NotificationRecord{}).
+		Select("user_id, digest").
+		Where("digest IS NOT NULL AND state = ? AND time_next_execute <= ?", dbmodels.NotificationRecordStatePending, s.now()).
+		Group("user_id, digest").
+		Order("MIN(time_next_execute) asc").
+		Limit(limit).
+		Scan(&groups).Error
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+func (s *NotificationService) ListDigestRecords(input types.ListDigestRecordsInput) ([]dbmodels.NotificationRecord, error) {
+	var records []dbmodels.NotificationRecord
+	err := s.db.
+		Where("user_id = ? AND digest = ? AND state = ? AND time_next_execute <= ?",
+			input.UserID, input.Digest, dbmodels.NotificationRecordStatePending, s.now()).
+		Order("ctime asc").
+		Limit(input.Limit).
+		Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
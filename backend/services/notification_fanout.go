@@ -0,0 +1,89 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+var digestStrategies = []string{dbmodels.SubscribeStrategyHourlyDigest, dbmodels.SubscribeStrategyDailyDigest}
+
+type digestSchedule struct {
+	Strategy string
+	Timezone string
+}
+
+// FanOutEvent creates the records of every subscriber the event reaches in a single INSERT ... SELECT. Records that
+// already exist are skipped, so an event that failed half way can be fanned out again. It returns the number of
+// records created.
+func (s *NotificationService) FanOutEvent(event *dbmodels.NotificationEvent, strategies []string) (int64, error) {
+	subscribersSQL, subscribersParams, err := subscribedUsersSQL(types.ListSubscribeUserInput{
+		Feature:       event.Type,
+		Strategies:    strategies,
+		DaoCode:       event.DaoCode,
+		ProposalID:    &event.ProposalID,
+		TimeEvent:     &event.TimeEvent,
+		TargetAddress: event.TargetAddress,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// digests close at the end of the window in the user's own timezone, the subscribers only use a few timezones
+	var schedules []digestSchedule
+	scheduleParams := append(append([]interface{}{}, subscribersParams...), digestStrategies)
+	if err := s.db.Raw(subscribersSQL+`
+SELECT DISTINCT r.strategy, COALESCE(pref.timezone, 'UTC') AS timezone
+FROM SubscribedUsers AS r
+LEFT JOIN dgv_notification_preference AS pref ON pref.user_id = r.user_id
+WHERE r.strategy IN ?`,
+		scheduleParams...,
+	).Scan(&schedules).Error; err != nil {
+		return 0, fmt.Errorf("failed to list digest timezones: %w", err)
+	}
+
+	now := s.now()
+	var nextExecute strings.Builder
+	nextExecuteParams := make([]interface{}, 0, 3*len(schedules)+2*len(digestStrategies))
+	for _, schedule := range schedules {
+		location := preferenceLocation(&dbmodels.NotificationPreference{Timezone: schedule.Timezone})
+		nextExecute.WriteString(" WHEN r.strategy = ? AND COALESCE(pref.timezone, 'UTC') = ? THEN ?")
+		nextExecuteParams = append(nextExecuteParams, schedule.Strategy, schedule.Timezone, NextDigestTime(schedule.Strategy, now, location))
+	}
+	for _, strategy := range digestStrategies {
+		// a timezone saved after the lookup above uses UTC
+		nextExecute.WriteString(" WHEN r.strategy = ? THEN ?")
+		nextExecuteParams = append(nextExecuteParams, strategy, NextDigestTime(strategy, now, time.UTC))
+	}
+
+	params := append([]interface{}{}, subscribersParams...)
+	params = append(params, digestStrategies, dbmodels.NotificationRecordStatePending)
+	params = append(params, nextExecuteParams...)
+	params = append(params, event.ID)
+	result := s.db.Exec(subscribersSQL+`
+INSERT INTO dgv_notification_record (
+    id, code, event_id, chain_id, dao_code, type, proposal_id, vote_id, payload,
+    user_id, user_address, digest, state, times_retry, time_next_execute, utime
+)
+SELECT
+    e.id || '_' || r.user_id, e.id || '_' || r.user_id, e.id, e.chain_id, e.dao_code, e.type, e.proposal_id, e.vote_id, e.payload,
+    r.user_id, r.user_address,
+    CASE WHEN r.strategy IN ? THEN r.strategy END,
+    ?, 0,
+    CASE`+nextExecute.String()+` ELSE CURRENT_TIMESTAMP END,
+    CURRENT_TIMESTAMP
+FROM SubscribedUsers AS r
+CROSS JOIN dgv_notification_event AS e
+LEFT JOIN dgv_notification_preference AS pref ON pref.user_id = r.user_id
+WHERE e.id = ?
+ON CONFLICT (event_id, user_id) DO NOTHING`,
+		params...,
+	)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+)
+
+func newTestFanOutService(t testing.TB) *NotificationService {
+	t.Helper()
+	db := newTestNotificationDB(t)
+	for _, statement := range []string{
+		`CREATE TABLE dgv_subscribed_feature (id TEXT, chain_id INTEGER, user_id TEXT, user_address TEXT, dao_code TEXT, proposal_id TEXT, feature TEXT, strategy TEXT, ctime DATETIME)`,
+		`CREATE TABLE dgv_user_subscribed_dao (user_id TEXT, dao_code TEXT, state TEXT, ctime DATETIME)`,
+		`CREATE TABLE dgv_user_subscribed_proposal (user_id TEXT, dao_code TEXT, proposal_id TEXT, state TEXT, ctime DATETIME)`,
+		`CREATE INDEX idx_test_subscribed_feature ON dgv_subscribed_feature (dao_code, feature, strategy)`,
+		`CREATE INDEX idx_test_user_subscribed_dao ON dgv_user_subscribed_dao (user_id, dao_code)`,
+		`CREATE TABLE dgv_notification_preference (user_id TEXT PRIMARY KEY, timezone TEXT NOT NULL DEFAULT 'UTC')`,
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatalf("create subscription table: %v", err)
+		}
+	}
+	service := newNotificationService(db)
+	service.now = func() time.Time { return time.Date(2025, 6, 1, 10, 30, 0, 0, time.UTC) }
+	return service
+}
+
+func saveTestFanOutEvent(t testing.TB, service *NotificationService, id string, target *string) *dbmodels.NotificationEvent {
+	t.Helper()
+	event := dbmodels.NotificationEvent{
+		ID:            id,
+		ChainID:       46,
+		DaoCode:       "demo",
+		Type:          dbmodels.SubscribeFeatureProposalNew,
+		ProposalID:    "0x01",
+		TargetAddress: target,
+		State:         dbmodels.NotificationEventStateProgress,
+		TimeEvent:     time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC),
+	}
+	if err := service.db.Create(&event).Error; err != nil {
+		t.Fatalf("save event: %v", err)
+	}
+	return &event
+}
+
+func TestFanOutEventCreatesOneRecordPerSubscriber(t *testing.T) {
+	service := newTestFanOutService(t)
+	for _, statement := range []string{
+		`INSERT INTO dgv_user_subscribed_dao VALUES
+			('u1', 'demo', 'ACTIVE', '2025-05-01 00:00:00'),
+			('u2', 'demo', 'ACTIVE', '2025-05-01 00:00:00'),
+			('u3', 'demo', 'INACTIVE', '2025-05-01 00:00:00'),
+			('u4', 'demo', 'ACTIVE', '2025-05-01 00:00:00'),
+			('u5', 'demo', 'ACTIVE', '2025-07-01 00:00:00')`,
+		`INSERT INTO dgv_user_subscribed_proposal VALUES ('u2', 'demo', '0x01', 'ACTIVE', '2025-05-02 00:00:00')`,
+		`INSERT INTO dgv_subscribed_feature (id, user_id, user_address, dao_code, proposal_id, feature, strategy, ctime) VALUES
+			('f1', 'u1', '0xaaa', 'demo', NULL, 'PROPOSAL_NEW', 'true', '2025-05-01 00:00:00'),
+			('f2', 'u2', '0xbbb', 'demo', NULL, 'PROPOSAL_NEW', 'daily_digest', '2025-05-01 00:00:00'),
+			('f3', 'u2', '0xbbb', 'demo', '0x01', 'PROPOSAL_NEW', 'instant', '2025-05-02 00:00:00'),
+			('f4', 'u3', '0xccc', 'demo', NULL, 'PROPOSAL_NEW', 'true', '2025-05-01 00:00:00'),
+			('f5', 'u4', '0xddd', 'demo', NULL, 'PROPOSAL_NEW', 'daily_digest', '2025-05-01 00:00:00'),
+			('f6', 'u5', '0xeee', 'demo', NULL, 'PROPOSAL_NEW', 'true', '2025-07-01 00:00:00'),
+			('f7', 'u1', '0xaaa', 'demo', NULL, 'VOTE_END', 'true', '2025-05-01 00:00:00')`,
+		`INSERT INTO dgv_notification_preference VALUES ('u4', 'Asia/Shanghai')`,
+	} {
+		if err := service.db.Exec(statement).Error; err != nil {
+			t.Fatalf("seed subscriptions: %v", err)
+		}
+	}
+	event := saveTestFanOutEvent(t, service, "e1", nil)
+	strategies := SubscribeStrategies(event.Type)
+
+	created, err := service.FanOutEvent(event, strategies)
+	if err != nil || created != 3 {
+		t.Fatalf("expected 3 records, got %d %v", created, err)
+	}
+	// fanning out again after a partial failure creates nothing twice
+	if created, err := service.FanOutEvent(event, strategies); err != nil || created != 0 {
+		t.Fatalf("expected the second fan out to create nothing, got %d %v", created, err)
+	}
+
+	var records []dbmodels.NotificationRecord
+	if err := service.db.Order("user_id ASC").Find(&records).Error; err != nil {
+		t.Fatalf("list records: %v", err)
+	}
+	if len(records) != 3 || records[0].UserID != "u1" || records[1].UserID != "u2" || records[2].UserID != "u4" {
+		t.Fatalf("unexpected records %+v", records)
+	}
+	for _, record := range records {
+		if record.Code != "e1_"+record.UserID || record.ChainID != 46 || record.ProposalID != "0x01" || record.State != dbmodels.NotificationRecordStatePending {
+			t.Fatalf("unexpected record %+v", record)
+		}
+	}
+	if records[1].Digest != nil {
+		t.Fatalf("expected the proposal level instant strategy to win, got digest %v", *records[1].Digest)
+	}
+	// 10:30 UTC is 18:30 in Shanghai, the daily digest closes at the next local midnight
+	if records[2].Digest == nil || *records[2].Digest != dbmodels.SubscribeStrategyDailyDigest ||
+		!records[2].TimeNextExecute.Equal(time.Date(2025, 6, 1, 16, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected digest record %+v", records[2])
+	}
+
+	target := "0xAAA"
+	targeted := saveTestFanOutEvent(t, service, "e2", &target)
+	if created, err := service.FanOutEvent(targeted, strategies); err != nil || created != 1 {
+		t.Fatalf("expected only the target to be notified, got %d %v", created, err)
+	}
+}
+
+func TestFanOutEventLetsProposalSettingsOverrideTheDao(t *testing.T) {
+	service := newTestFanOutService(t)
+	for _, statement := range []string{
+		`INSERT INTO dgv_user_subscribed_dao VALUES
+			('u1', 'demo', 'ACTIVE', '2025-05-01 00:00:00'),
+			('u2', 'demo', 'ACTIVE', '2025-05-01 00:00:00'),
+			('u3', 'demo', 'ACTIVE', '2025-05-01 00:00:00')`,
+		`INSERT INTO dgv_user_subscribed_proposal VALUES
+			('u1', 'demo', '0x01', 'ACTIVE', '2025-05-02 00:00:00'),
+			('u2', 'demo', '0x01', 'ACTIVE', '2025-05-02 00:00:00'),
+			('u3', 'demo', '0x01', 'ACTIVE', '2025-05-02 00:00:00')`,
+		`INSERT INTO dgv_subscribed_feature (id, user_id, user_address, dao_code, proposal_id, feature, strategy, ctime) VALUES
+			('f1', 'u1', '0xaaa', 'demo', NULL, 'VOTE_EMITTED', 'true', '2025-05-01 00:00:00'),
+			('f2', 'u1', '0xaaa', 'demo', '0x01', 'VOTE_EMITTED', 'false', '2025-05-02 00:00:00'),
+			('f3', 'u2', '0xbbb', 'demo', NULL, 'VOTE_EMITTED', 'true', '2025-05-01 00:00:00'),
+			('f4', 'u2', '0xbbb', 'demo', '0x01', 'VOTE_EMITTED', 'min_weight:1000', '2025-05-02 00:00:00'),
+			('f5', 'u3', '0xccc', 'demo', NULL, 'VOTE_EMITTED', 'false', '2025-05-01 00:00:00'),
+			('f6', 'u3', '0xccc', 'demo', '0x01', 'VOTE_EMITTED', 'true', '2025-05-02 00:00:00')`,
+	} {
+		if err := service.db.Exec(statement).Error; err != nil {
+			t.Fatalf("seed subscriptions: %v", err)
+		}
+	}
+	strategies := SubscribeStrategies(dbmodels.SubscribeFeatureVoteEmitted)
+
+	// a small vote reaches no threshold, the proposal level min_weight of u2 has to keep its DAO level true out
+	small := saveTestFanOutEvent(t, service, "small", nil)
+	small.Type = dbmodels.SubscribeFeatureVoteEmitted
+	if err := service.db.Save(small).Error; err != nil {
+		t.Fatalf("save event: %v", err)
+	}
+	if created, err := service.FanOutEvent(small, strategies); err != nil || created != 1 {
+		t.Fatalf("expected only u3 to be notified, got %d %v", created, err)
+	}
+	var users []string
+	if err := service.db.Model(&dbmodels.NotificationRecord{}).Where("event_id = ?", small.ID).Pluck("user_id", &users).Error; err != nil {
+		t.Fatalf("list records: %v", err)
+	}
+	if len(users) != 1 || users[0] != "u3" {
+		t.Fatalf("expected the proposal level settings to win, got %v", users)
+	}
+
+	large := saveTestFanOutEvent(t, service, "large", nil)
+	large.Type = dbmodels.SubscribeFeatureVoteEmitted
+	if err := service.db.Save(large).Error; err != nil {
+		t.Fatalf("save event: %v", err)
+	}
+	if created, err := service.FanOutEvent(large, append(strategies, "min_weight:1000")); err != nil || created != 2 {
+		t.Fatalf("expected u2 and u3 to be notified of a vote over the threshold, got %d %v", created, err)
+	}
+}
+
+func BenchmarkFanOutEvent(b *testing.B) {
+	const subscribers = 100_000
+	service := newTestFanOutService(b)
+	service.db = service.db.Session(&gorm.Session{Logger: logger.Default.LogMode(logger.Silent)})
+	for _, statement := range []string{
+		`WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < ?)
+		INSERT INTO dgv_user_subscribed_dao SELECT 'u' || i, 'demo', 'ACTIVE', '2025-05-01 00:00:00' FROM n`,
+		`WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < ?)
+		INSERT INTO dgv_subscribed_feature (id, user_id, user_address, dao_code, feature, strategy, ctime)
+		SELECT 'f' || i, 'u' || i, printf('0x%040x', i), 'demo', 'PROPOSAL_NEW',
+			CASE i % 10 WHEN 0 THEN 'daily_digest' WHEN 1 THEN 'hourly_digest' ELSE 'true' END, '2025-05-01 00:00:00'
+		FROM n`,
+		`WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < ?)
+		INSERT INTO dgv_notification_preference SELECT 'u' || i, CASE i % 3 WHEN 0 THEN 'Asia/Shanghai' ELSE 'Europe/Berlin' END FROM n WHERE i % 5 = 0`,
+	} {
+		if err := service.db.Exec(statement, subscribers).Error; err != nil {
+			b.Fatalf("seed subscribers: %v", err)
+		}
+	}
+	strategies := SubscribeStrategies(dbmodels.SubscribeFeatureProposalNew)
+
+	events := 0
+	b.Run("new event", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			events++
+			event := saveTestFanOutEvent(b, service, fmt.Sprintf("new-%d", events), nil)
+			created, err := service.FanOutEvent(event, strategies)
+			if err != nil || created != subscribers {
+				b.Fatalf("fan out: %d %v", created, err)
+			}
+		}
+		b.ReportMetric(float64(subscribers*b.N)/b.Elapsed().Seconds(), "records/s")
+	})
+
+	replayed := saveTestFanOutEvent(b, service, "replayed", nil)
+	if _, err := service.FanOutEvent(replayed, strategies); err != nil {
+		b.Fatalf("fan out: %v", err)
+	}
+	b.Run("replayed event", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			created, err := service.FanOutEvent(replayed, strategies)
+			if err != nil || created != 0 {
+				b.Fatalf("replay: %d %v", created, err)
+			}
+		}
+		b.ReportMetric(float64(subscribers*b.N)/b.Elapsed().Seconds(), "subscribers/s")
+	})
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/ringecosystem/degov-square/database"
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	gqlmodels "github.com/ringecosystem/degov-square/graph/models"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+const maxNotificationPageSize = 50
+
+type notificationCursor struct {
+	Time time.Time `json:"time"`
+	ID   string    `json:"id"`
+}
+
+// NotificationInboxService exposes the notification records of a user as an in-app inbox.
+type NotificationInboxService struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+func NewNotificationInboxService() *NotificationInboxService {
+	return newNotificationInboxService(database.GetDB())
+}
+
+func newNotificationInboxService(db *gorm.DB) *NotificationInboxService {
+	return &NotificationInboxService{
+		db:  db,
+		now: time.Now,
+	}
+}
+
+func (s *NotificationInboxService) List(user *types.UserSessInfo, input gqlmodels.MyNotificationsInput) (*gqlmodels.NotificationPage, error) {
+	if user == nil || user.Id == "" {
+		return nil, errors.New("unauthorized")
+	}
+	first := 20
+	if input.First != nil {
+		first = int(*input.First)
+	}
+	if first < 1 || first > maxNotificationPageSize {
+		return nil, errors.New("invalid page size")
+	}
+
+	query := s.db.Where("user_id = ?", user.Id)
+	if input.Archived != nil && *input.Archived {
+		query = query.Where("time_archived IS NOT NULL")
+	} else {
+		query = query.Where("time_archived IS NULL")
+	}
+	if input.UnreadOnly != nil && *input.UnreadOnly {
+		query = query.Where("time_read IS NULL")
+	}
+	if len(input.Types) > 0 {
+		features := make([]dbmodels.SubscribeFeatureName, 0, len(input.Types))
+		for _, feature := range input.Types {
+			features = append(features, dbmodels.SubscribeFeatureName(feature))
+		}
+		query = query.Where("type IN ?", features)
+	}
+	if len(input.DaoCodes) > 0 {
+		query = query.Where("dao_code IN ?", input.DaoCodes)
+	}
+	if input.After != nil && strings.TrimSpace(*input.After) != "" {
+		cursor, err := decodeNotificationCursor(strings.TrimSpace(*input.After))
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("ctime < ? OR (ctime = ? AND id < ?)", cursor.Time, cursor.Time, cursor.ID)
+	}
+
+	var records []dbmodels.NotificationRecord
+	if err := query.Order("ctime DESC").Order("id DESC").Limit(first + 1).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	hasNextPage := len(records) > first
+	if hasNextPage {
+		records = records[:first]
+	}
+
+	items := make([]*gqlmodels.Notification, 0, len(records))
+	for i := range records {
+		items = append(items, notificationToGraphQL(&records[i]))
+	}
+	var endCursor *string
+	if len(records) > 0 {
+		encoded, err := encodeNotificationCursor(records[len(records)-1])
+		if err != nil {
+			return nil, err
+		}
+		endCursor = &encoded
+	}
+	return &gqlmodels.NotificationPage{
+		Items:    items,
+		PageInfo: &gqlmodels.NotificationPageInfo{EndCursor: endCursor, HasNextPage: hasNextPage},
+	}, nil
+}
+
+func (s *NotificationInboxService) UnreadCount(user *types.UserSessInfo) (int32, error) {
+	if user == nil || user.Id == "" {
+		return 0, errors.New("unauthorized")
+	}
+	var count int64
+	err := s.db.Model(&dbmodels.NotificationRecord{}).
+		Where("user_id = ? AND time_read IS NULL AND time_archived IS NULL", user.Id).
+		Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	return int32(count), nil
+}
+
+// MarkRead marks the given notifications, or all of them, as read and returns how many were unread.
+func (s *NotificationInboxService) MarkRead(user *types.UserSessInfo, input gqlmodels.MarkNotificationsReadInput) (int32, error) {
+	if user == nil || user.Id == "" {
+		return 0, errors.New("unauthorized")
+	}
+	query := s.db.Model(&dbmodels.NotificationRecord{}).Where("user_id = ? AND time_read IS NULL", user.Id)
+	if input.All == nil || !*input.All {
+		if len(input.Ids) == 0 {
+			return 0, nil
+		}
+		query = query.Where("id IN ?", input.Ids)
+	}
+	result := query.Update("time_read", s.now())
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return int32(result.RowsAffected), nil
+}
+
+// Archive moves notifications out of the inbox, or back into it when archived is false.
+func (s *NotificationInboxService) Archive(user *types.UserSessInfo, input gqlmodels.ArchiveNotificationsInput) (int32, error) {
+	if user == nil || user.Id == "" {
+		return 0, errors.New("unauthorized")
+	}
+	if len(input.Ids) == 0 {
+		return 0, nil
+	}
+	query := s.db.Model(&dbmodels.NotificationRecord{}).Where("user_id = ? AND id IN ?", user.Id, input.Ids)
+	var result *gorm.DB
+	if input.Archived == nil || *input.Archived {
+		result = query.Where("time_archived IS NULL").Update("time_archived", s.now())
+	} else {
+		result = query.Where("time_archived IS NOT NULL").Update("time_archived", nil)
+	}
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return int32(result.RowsAffected), nil
+}
+
+func notificationToGraphQL(record *dbmodels.NotificationRecord) *gqlmodels.Notification {
+	return &gqlmodels.Notification{
+		ID:         record.ID,
+		Type:       gqlmodels.FeatureName(record.Type),
+		DaoCode:    record.DaoCode,
+		ChainID:    int32(record.ChainID),
+		ProposalID: record.ProposalID,
+		Title:      record.Title,
+		Content:    record.Content,
+		Read:       record.TimeRead != nil,
+		Archived:   record.TimeArchived != nil,
+		Ctime:      record.CTime,
+	}
+}
+
+func encodeNotificationCursor(record dbmodels.NotificationRecord) (string, error) {
+	payload, err := json.Marshal(notificationCursor{Time: record.CTime, ID: record.ID})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(payload), nil
+}
+
+func decodeNotificationCursor(value string) (*notificationCursor, error) {
+	payload, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	var cursor notificationCursor
+	if err := json.Unmarshal(payload, &cursor); err != nil || cursor.Time.IsZero() || strings.TrimSpace(cursor.ID) == "" {
+		return nil, errors.New("invalid cursor")
+	}
+	return &cursor, nil
+}
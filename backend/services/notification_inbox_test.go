@@ -0,0 +1,174 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	gqlmodels "github.com/ringecosystem/degov-square/graph/models"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+func seedTestInboxRecords(t *testing.T, service *NotificationInboxService) {
+	t.Helper()
+	base := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	records := []dbmodels.NotificationRecord{
+		{ID: "n1", Code: "c1", EventID: "e1", DaoCode: "alpha", Type: dbmodels.SubscribeFeatureProposalNew, ProposalID: "0x01", UserID: "user-1", UserAddress: "0xabc", State: dbmodels.NotificationRecordStateSentOk, CTime: base},
+		{ID: "n2", Code: "c2", EventID: "e2", DaoCode: "alpha", Type: dbmodels.SubscribeFeatureVoteEmitted, ProposalID: "0x01", UserID: "user-1", UserAddress: "0xabc", State: dbmodels.NotificationRecordStateSentOk, CTime: base.Add(time.Minute)},
+		{ID: "n3", Code: "c3", EventID: "e3", DaoCode: "beta", Type: dbmodels.SubscribeFeatureProposalNew, ProposalID: "0x02", UserID: "user-1", UserAddress: "0xabc", State: dbmodels.NotificationRecordStatePending, CTime: base.Add(2 * time.Minute)},
+		{ID: "n4", Code: "c4", EventID: "e4", DaoCode: "alpha", Type: dbmodels.SubscribeFeatureProposalNew, ProposalID: "0x01", UserID: "user-2", UserAddress: "0xdef", State: dbmodels.NotificationRecordStateSentOk, CTime: base},
+	}
+	if err := service.db.Create(&records).Error; err != nil {
+		t.Fatalf("seed records: %v", err)
+	}
+}
+
+func TestNotificationInboxListsFiltersAndPaginates(t *testing.T) {
+	db := newTestNotificationDB(t)
+	service := newNotificationInboxService(db)
+	seedTestInboxRecords(t, service)
+	user := &types.UserSessInfo{Id: "user-1"}
+
+	first := int32(2)
+	page, err := service.List(user, gqlmodels.MyNotificationsInput{First: &first})
+	if err != nil {
+		t.Fatalf("list first page: %v", err)
+	}
+	if len(page.Items) != 2 || page.Items[0].ID != "n3" || page.Items[1].ID != "n2" || !page.PageInfo.HasNextPage {
+		t.Fatalf("unexpected first page: %+v", page)
+	}
+	page, err = service.List(user, gqlmodels.MyNotificationsInput{First: &first, After: page.PageInfo.EndCursor})
+	if err != nil {
+		t.Fatalf("list second page: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].ID != "n1" || page.PageInfo.HasNextPage {
+		t.Fatalf("unexpected second page: %+v", page)
+	}
+
+	filtered, err := service.List(user, gqlmodels.MyNotificationsInput{
+		Types:    []gqlmodels.FeatureName{gqlmodels.FeatureNameProposalNew},
+		DaoCodes: []string{"alpha"},
+	})
+	if err != nil {
+		t.Fatalf("list filtered: %v", err)
+	}
+	if len(filtered.Items) != 1 || filtered.Items[0].ID != "n1" {
+		t.Fatalf("unexpected filtered page: %+v", filtered.Items)
+	}
+
+	bad := "not-a-cursor"
+	if _, err := service.List(user, gqlmodels.MyNotificationsInput{After: &bad}); err == nil {
+		t.Fatal("expected an invalid cursor error")
+	}
+}
+
+func TestNotificationInboxReadAndArchive(t *testing.T) {
+	db := newTestNotificationDB(t)
+	service := newNotificationInboxService(db)
+	seedTestInboxRecords(t, service)
+	user := &types.UserSessInfo{Id: "user-1"}
+
+	if count, err := service.UnreadCount(user); err != nil || count != 3 {
+		t.Fatalf("expected 3 unread, got %d (%v)", count, err)
+	}
+	// another user's notification is never touched
+	if changed, err := service.MarkRead(user, gqlmodels.MarkNotificationsReadInput{Ids: []string{"n1", "n4"}}); err != nil || changed != 1 {
+		t.Fatalf("expected 1 notification marked read, got %d (%v)", changed, err)
+	}
+	if changed, err := service.Archive(user, gqlmodels.ArchiveNotificationsInput{Ids: []string{"n2"}}); err != nil || changed != 1 {
+		t.Fatalf("expected 1 notification archived, got %d (%v)", changed, err)
+	}
+	if count, _ := service.UnreadCount(user); count != 1 {
+		t.Fatalf("expected 1 unread after read and archive, got %d", count)
+	}
+
+	unreadOnly := true
+	page, err := service.List(user, gqlmodels.MyNotificationsInput{UnreadOnly: &unreadOnly})
+	if err != nil || len(page.Items) != 1 || page.Items[0].ID != "n3" {
+		t.Fatalf("unexpected unread inbox: %+v (%v)", page, err)
+	}
+	archived := true
+	page, err = service.List(user, gqlmodels.MyNotificationsInput{Archived: &archived})
+	if err != nil || len(page.Items) != 1 || page.Items[0].ID != "n2" || !page.Items[0].Archived {
+		t.Fatalf("unexpected archive: %+v (%v)", page, err)
+	}
+
+	all := true
+	if changed, err := service.MarkRead(user, gqlmodels.MarkNotificationsReadInput{All: &all}); err != nil || changed != 2 {
+		t.Fatalf("expected the remaining 2 notifications marked read, got %d (%v)", changed, err)
+	}
+	if count, _ := service.UnreadCount(&types.UserSessInfo{Id: "user-2"}); count != 1 {
+		t.Fatalf("other users keep their unread notifications, got %d", count)
+	}
+}
+
+func TestNotificationInboxReturnsStoredContent(t *testing.T) {
+	db := newTestNotificationDB(t)
+	service := newNotificationInboxService(db)
+	seedTestInboxRecords(t, service)
+	if err := db.Exec(`UPDATE dgv_notification_record SET title = 'Title n3', content = 'Body n3' WHERE id = 'n3'`).Error; err != nil {
+		t.Fatalf("store content: %v", err)
+	}
+
+	page, err := service.List(&types.UserSessInfo{Id: "user-1"}, gqlmodels.MyNotificationsInput{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if page.Items[0].Title == nil || *page.Items[0].Title != "Title n3" || *page.Items[0].Content != "Body n3" {
+		t.Fatalf("expected the stored content, got %+v", page.Items[0])
+	}
+	if page.Items[1].Title != nil || page.Items[1].Content != nil {
+		t.Fatalf("records without stored content are returned without it, got %+v", page.Items[1])
+	}
+}
+
+func TestStoreInboxContentPerAudienceInBatches(t *testing.T) {
+	db := newTestNotificationDB(t)
+	service := newNotificationService(db)
+	for _, statement := range []string{
+		`CREATE TABLE dgv_notification_preference (user_id TEXT PRIMARY KEY, timezone TEXT NOT NULL DEFAULT 'UTC', locale TEXT NOT NULL DEFAULT 'en')`,
+		`INSERT INTO dgv_notification_preference VALUES ('u2', 'UTC', 'en'), ('u3', 'Asia/Shanghai', 'zh-CN')`,
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatalf("seed preferences: %v", err)
+		}
+	}
+	records := make([]dbmodels.NotificationRecord, 0, 4)
+	for _, user := range []string{"u1", "u2", "u3"} {
+		records = append(records, dbmodels.NotificationRecord{
+			ID: "e1_" + user, Code: "e1_" + user, EventID: "e1", DaoCode: "demo", Type: dbmodels.SubscribeFeatureProposalNew,
+			ProposalID: "0x01", UserID: user, UserAddress: "0x" + user, State: dbmodels.NotificationRecordStatePending,
+		})
+	}
+	records = append(records, dbmodels.NotificationRecord{
+		ID: "e2_u1", Code: "e2_u1", EventID: "e2", DaoCode: "demo", Type: dbmodels.SubscribeFeatureProposalNew,
+		ProposalID: "0x02", UserID: "u1", UserAddress: "0xu1", State: dbmodels.NotificationRecordStatePending,
+	})
+	if err := db.Create(&records).Error; err != nil {
+		t.Fatalf("seed records: %v", err)
+	}
+
+	audiences, err := service.ListInboxAudiences("e1")
+	if err != nil || len(audiences) != 2 {
+		t.Fatalf("expected users without a preference to share the default audience, got %+v %v", audiences, err)
+	}
+	english := types.InboxAudience{Locale: "en", Timezone: "UTC"}
+	output := &types.TemplateOutput{Title: "New proposal", PlainTextContent: "Body"}
+	for _, want := range []int64{1, 1, 0} {
+		if stored, err := service.StoreInboxContent("e1", english, output, 1); err != nil || stored != want {
+			t.Fatalf("expected batches of one record, got %d %v", stored, err)
+		}
+	}
+	audiences, err = service.ListInboxAudiences("e1")
+	if err != nil || len(audiences) != 1 || audiences[0] != (types.InboxAudience{Locale: "zh-CN", Timezone: "Asia/Shanghai"}) {
+		t.Fatalf("expected only the chinese audience to be left, got %+v %v", audiences, err)
+	}
+
+	var stored []dbmodels.NotificationRecord
+	if err := db.Where("content IS NOT NULL").Order("id").Find(&stored).Error; err != nil {
+		t.Fatalf("list records: %v", err)
+	}
+	if len(stored) != 2 || stored[0].ID != "e1_u1" || stored[1].ID != "e1_u2" || *stored[0].Title != "New proposal" {
+		t.Fatalf("expected only the english records of the event to be stored, got %+v", stored)
+	}
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/ringecosystem/degov-square/database"
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	gqlmodels "github.com/ringecosystem/degov-square/graph/models"
+	"github.com/ringecosystem/degov-square/internal/utils"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+const defaultNotificationTimezone = "UTC"
+
+// defaultUrgentFeatures are delivered during quiet hours unless the user chose otherwise
+var defaultUrgentFeatures = []dbmodels.SubscribeFeatureName{dbmodels.SubscribeFeatureVoteEnd}
+
+type NotificationPreferenceService struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+func NewNotificationPreferenceService() *NotificationPreferenceService {
+	return newNotificationPreferenceService(database.GetDB())
+}
+
+func newNotificationPreferenceService(db *gorm.DB) *NotificationPreferenceService {
+	return &NotificationPreferenceService{db: db, now: time.Now}
+}
+
+// Inspect returns the stored preference of a user, or the defaults when the user never saved one.
+func (s *NotificationPreferenceService) Inspect(userID string) (*dbmodels.NotificationPreference, error) {
+	var preference dbmodels.NotificationPreference
+	err := s.db.Where("user_id = ?", userID).First(&preference).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return defaultNotificationPreference(userID), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &preference, nil
+}
+
+func (s *NotificationPreferenceService) Get(user *types.UserSessInfo) (*gqlmodels.NotificationPreference, error) {
+	if user == nil || user.Id == "" {
+		return nil, errors.New("unauthorized")
+	}
+	preference, err := s.Inspect(user.Id)
+	if err != nil {
+		return nil, err
+	}
+	return notificationPreferenceToGraphQL(preference), nil
+}
+
+// Update changes the fields of the preference the input sets, a nil field keeps its current value.
+func (s *NotificationPreferenceService) Update(user *types.UserSessInfo, input gqlmodels.UpdateNotificationPreferenceInput) (*gqlmodels.NotificationPreference, error) {
+	if user == nil || user.Id == "" {
+		return nil, errors.New("unauthorized")
+	}
+	current, err := s.Inspect(user.Id)
+	if err != nil {
+		return nil, err
+	}
+	preference := *current
+	preference.UserID = user.Id
+	preference.UTime = s.now()
+
+	if input.Timezone != nil {
+		timezone := strings.TrimSpace(*input.Timezone)
+		if timezone == "" {
+			timezone = defaultNotificationTimezone
+		}
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone %q", timezone)
+		}
+		preference.Timezone = timezone
+	}
+
+	if (input.QuietHoursStart == nil) != (input.QuietHoursEnd == nil) {
+		return nil, errors.New("quiet hours need both a start and an end")
+	}
+	if input.QuietHoursStart != nil {
+		hasStart, hasEnd := *input.QuietHoursStart != "", *input.QuietHoursEnd != ""
+		if hasStart != hasEnd {
+			return nil, errors.New("quiet hours need both a start and an end")
+		}
+		preference.QuietHoursStart, preference.QuietHoursEnd = nil, nil
+		if hasStart {
+			start, err := parseClockMinutes(*input.QuietHoursStart)
+			if err != nil {
+				return nil, err
+			}
+			end, err := parseClockMinutes(*input.QuietHoursEnd)
+			if err != nil {
+				return nil, err
+			}
+			if start == end {
+				return nil, errors.New("quiet hours start and end must differ")
+			}
+			preference.QuietHoursStart = &start
+			preference.QuietHoursEnd = &end
+		}
+	}
+
+	if input.Locale != nil {
+		locale, ok := utils.NormalizeLocale(*input.Locale)
+		if !ok {
+			return nil, fmt.Errorf("unsupported locale %q", *input.Locale)
+		}
+		preference.Locale = locale
+	}
+
+	urgentFeatures := preferenceUrgentFeatures(current)
+	if input.UrgentFeatures != nil {
+		urgentFeatures = make([]dbmodels.SubscribeFeatureName, 0, len(input.UrgentFeatures))
+		for _, feature := range input.UrgentFeatures {
+			dbFeature, ok := subscribeFeatureName(feature)
+			if !ok {
+				return nil, fmt.Errorf("unsupported feature %s", feature)
+			}
+			urgentFeatures = append(urgentFeatures, dbFeature)
+		}
+	}
+	raw, err := json.Marshal(urgentFeatures)
+	if err != nil {
+		return nil, err
+	}
+	preference.UrgentFeatures = string(raw)
+
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"timezone", "quiet_hours_start", "quiet_hours_end", "urgent_features", "locale", "utime"}),
+	}).Create(&preference).Error; err != nil {
+		return nil, err
+	}
+	return notificationPreferenceToGraphQL(&preference), nil
+}
+
+// DeferUntil reports when a notification of the feature may be delivered to the user, ok is false when it can go out now.
+func (s *NotificationPreferenceService) DeferUntil(userID string, feature dbmodels.SubscribeFeatureName) (time.Time, bool, error) {
+	preference, err := s.Inspect(userID)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	until, ok := QuietHoursEnd(preference, feature, s.now())
+	return until, ok, nil
+}
+
+// QuietHoursEnd returns the end of the quiet hours that contain now, urgent features are never held.
+func QuietHoursEnd(preference *dbmodels.NotificationPreference, feature dbmodels.SubscribeFeatureName, now time.Time) (time.Time, bool) {
+	if preference == nil || preference.QuietHoursStart == nil || preference.QuietHoursEnd == nil {
+		return time.Time{}, false
+	}
+	for _, urgent := range preferenceUrgentFeatures(preference) {
+		if urgent == feature {
+			return time.Time{}, false
+		}
+	}
+
+	start, end := *preference.QuietHoursStart, *preference.QuietHoursEnd
+	local := now.In(preferenceLocation(preference))
+	minutes := local.Hour()*60 + local.Minute()
+	endToday := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, local.Location())
+
+	if start < end {
+		if minutes >= start && minutes < end {
+			return endToday, true
+		}
+		return time.Time{}, false
+	}
+	// the window wraps midnight, e.g. 22:00 - 07:00
+	if minutes >= start {
+		return endToday.AddDate(0, 0, 1), true
+	}
+	if minutes < end {
+		return endToday, true
+	}
+	return time.Time{}, false
+}
+
+func defaultNotificationPreference(userID string) *dbmodels.NotificationPreference {
+	raw, _ := json.Marshal(defaultUrgentFeatures)
+	return &dbmodels.NotificationPreference{
+		UserID:         userID,
+		Timezone:       defaultNotificationTimezone,
+		UrgentFeatures: string(raw),
+		Locale:         utils.LocaleEnglish,
+	}
+}
+
+func preferenceLocation(preference *dbmodels.NotificationPreference) *time.Location {
+	if preference == nil || preference.Timezone == "" {
+		return time.UTC
+	}
+	location, err := time.LoadLocation(preference.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+func preferenceUrgentFeatures(preference *dbmodels.NotificationPreference) []dbmodels.SubscribeFeatureName {
+	var features []dbmodels.SubscribeFeatureName
+	if err := json.Unmarshal([]byte(preference.UrgentFeatures), &features); err != nil {
+		return defaultUrgentFeatures
+	}
+	return features
+}
+
+func parseClockMinutes(value string) (int, error) {
+	parsed, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+func formatClockMinutes(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+func notificationPreferenceToGraphQL(preference *dbmodels.NotificationPreference) *gqlmodels.NotificationPreference {
+	output := &gqlmodels.NotificationPreference{
+		Timezone:       preference.Timezone,
+		UrgentFeatures: []gqlmodels.FeatureName{},
+		Locale:         preference.Locale,
+	}
+	if preference.QuietHoursStart != nil && preference.QuietHoursEnd != nil {
+		start, end := formatClockMinutes(*preference.QuietHoursStart), formatClockMinutes(*preference.QuietHoursEnd)
+		output.QuietHoursStart = &start
+		output.QuietHoursEnd = &end
+	}
+	for _, feature := range preferenceUrgentFeatures(preference) {
+		output.UrgentFeatures = append(output.UrgentFeatures, gqlmodels.FeatureName(feature))
+	}
+	return output
+}
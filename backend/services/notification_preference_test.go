@@ -0,0 +1,137 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	gqlmodels "github.com/ringecosystem/degov-square/graph/models"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+func testQuietHoursPreference(start, end int) *dbmodels.NotificationPreference {
+	preference := defaultNotificationPreference("user-1")
+	preference.QuietHoursStart = &start
+	preference.QuietHoursEnd = &end
+	return preference
+}
+
+func TestQuietHoursEndHandlesOvernightWindows(t *testing.T) {
+	preference := testQuietHoursPreference(22*60, 7*60)
+
+	late := time.Date(2025, 6, 1, 23, 30, 0, 0, time.UTC)
+	if until, ok := QuietHoursEnd(preference, dbmodels.SubscribeFeatureProposalNew, late); !ok || !until.Equal(time.Date(2025, 6, 2, 7, 0, 0, 0, time.UTC)) {
+		t.Fatalf("23:30 should be held until the next morning, got %s ok=%v", until, ok)
+	}
+	early := time.Date(2025, 6, 2, 6, 59, 0, 0, time.UTC)
+	if until, ok := QuietHoursEnd(preference, dbmodels.SubscribeFeatureProposalNew, early); !ok || !until.Equal(time.Date(2025, 6, 2, 7, 0, 0, 0, time.UTC)) {
+		t.Fatalf("06:59 should be held until 07:00, got %s ok=%v", until, ok)
+	}
+	if _, ok := QuietHoursEnd(preference, dbmodels.SubscribeFeatureProposalNew, time.Date(2025, 6, 2, 7, 0, 0, 0, time.UTC)); ok {
+		t.Fatal("07:00 is outside the quiet hours")
+	}
+	if _, ok := QuietHoursEnd(preference, dbmodels.SubscribeFeatureVoteEnd, late); ok {
+		t.Fatal("urgent features must bypass quiet hours")
+	}
+}
+
+func TestQuietHoursEndUsesUserTimezone(t *testing.T) {
+	preference := testQuietHoursPreference(9*60, 17*60)
+	preference.Timezone = "Asia/Tokyo"
+	tokyo, err := time.LoadLocation(preference.Timezone)
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+
+	// 01:00 UTC is 10:00 in Tokyo
+	until, ok := QuietHoursEnd(preference, dbmodels.SubscribeFeatureProposalNew, time.Date(2025, 6, 1, 1, 0, 0, 0, time.UTC))
+	if !ok || !until.Equal(time.Date(2025, 6, 1, 17, 0, 0, 0, tokyo)) {
+		t.Fatalf("expected delivery at 17:00 Tokyo time, got %s ok=%v", until, ok)
+	}
+	if _, ok := QuietHoursEnd(preference, dbmodels.SubscribeFeatureProposalNew, time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)); ok {
+		t.Fatal("18:00 in Tokyo is outside the quiet hours")
+	}
+}
+
+func TestUpdateNotificationPreference(t *testing.T) {
+	db := newTestNotificationDB(t)
+	if err := db.Exec(`CREATE TABLE dgv_notification_preference (
+		user_id TEXT PRIMARY KEY, timezone TEXT NOT NULL DEFAULT 'UTC', quiet_hours_start INTEGER, quiet_hours_end INTEGER,
+		urgent_features TEXT NOT NULL DEFAULT '[]', locale TEXT NOT NULL DEFAULT 'en', ctime DATETIME DEFAULT CURRENT_TIMESTAMP, utime DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`).Error; err != nil {
+		t.Fatalf("create preference table: %v", err)
+	}
+	service := newNotificationPreferenceService(db)
+	user := &types.UserSessInfo{Id: "user-1"}
+	start, end := "22:00", "07:30"
+
+	for name, input := range map[string]gqlmodels.UpdateNotificationPreferenceInput{
+		"unknown timezone": {Timezone: stringPtr("Mars/Olympus")},
+		"start only":       {QuietHoursStart: &start},
+		"empty end":        {QuietHoursStart: &start, QuietHoursEnd: stringPtr("")},
+		"bad clock":        {QuietHoursStart: &start, QuietHoursEnd: stringPtr("25:00")},
+		"empty window":     {QuietHoursStart: &start, QuietHoursEnd: &start},
+		"unknown locale":   {Locale: stringPtr("fr")},
+	} {
+		if _, err := service.Update(user, input); err == nil {
+			t.Fatalf("%s: expected validation error", name)
+		}
+	}
+
+	defaults, err := service.Get(user)
+	if err != nil {
+		t.Fatalf("get default preference: %v", err)
+	}
+	if defaults.Timezone != "UTC" || defaults.Locale != "en" || defaults.QuietHoursStart != nil || len(defaults.UrgentFeatures) != 1 || defaults.UrgentFeatures[0] != gqlmodels.FeatureNameVoteEnd {
+		t.Fatalf("unexpected default preference: %+v", defaults)
+	}
+
+	if created, err := service.Update(user, gqlmodels.UpdateNotificationPreferenceInput{}); err != nil || created.Timezone != "UTC" || len(created.UrgentFeatures) != 1 {
+		t.Fatalf("expected an empty update to store the defaults, got %+v %v", created, err)
+	}
+	updated, err := service.Update(user, gqlmodels.UpdateNotificationPreferenceInput{
+		Timezone:        stringPtr("Europe/Berlin"),
+		QuietHoursStart: &start,
+		QuietHoursEnd:   &end,
+		UrgentFeatures:  []gqlmodels.FeatureName{},
+		Locale:          stringPtr("zh_cn"),
+	})
+	if err != nil {
+		t.Fatalf("update preference: %v", err)
+	}
+	if updated.Timezone != "Europe/Berlin" || *updated.QuietHoursStart != "22:00" || *updated.QuietHoursEnd != "07:30" || len(updated.UrgentFeatures) != 0 || updated.Locale != "zh-CN" {
+		t.Fatalf("unexpected updated preference: %+v", updated)
+	}
+
+	stored, err := service.Inspect(user.Id)
+	if err != nil {
+		t.Fatalf("inspect preference: %v", err)
+	}
+	if stored.Timezone != "Europe/Berlin" || *stored.QuietHoursStart != 22*60 || *stored.QuietHoursEnd != 7*60+30 || stored.Locale != "zh-CN" {
+		t.Fatalf("preference was not upserted: %+v", stored)
+	}
+
+	// a partial update only changes the fields it sets
+	partial, err := service.Update(user, gqlmodels.UpdateNotificationPreferenceInput{Locale: stringPtr("en")})
+	if err != nil {
+		t.Fatalf("partial update: %v", err)
+	}
+	if partial.Locale != "en" || partial.Timezone != "Europe/Berlin" || partial.QuietHoursStart == nil || *partial.QuietHoursStart != "22:00" ||
+		*partial.QuietHoursEnd != "07:30" || len(partial.UrgentFeatures) != 0 {
+		t.Fatalf("expected the other fields to be kept, got %+v", partial)
+	}
+	stored, err = service.Inspect(user.Id)
+	if err != nil || stored.QuietHoursStart == nil || stored.Timezone != "Europe/Berlin" || stored.UrgentFeatures != "[]" {
+		t.Fatalf("expected the stored preference to keep the other fields, got %+v %v", stored, err)
+	}
+
+	// empty values turn quiet hours off and reset the timezone
+	cleared, err := service.Update(user, gqlmodels.UpdateNotificationPreferenceInput{
+		Timezone:        stringPtr(""),
+		QuietHoursStart: stringPtr(""),
+		QuietHoursEnd:   stringPtr(""),
+	})
+	if err != nil || cleared.Timezone != "UTC" || cleared.QuietHoursStart != nil || cleared.QuietHoursEnd != nil || cleared.Locale != "en" {
+		t.Fatalf("expected quiet hours off and the timezone reset, got %+v %v", cleared, err)
+	}
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	stdhtml "html"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/microcosm-cc/bluemonday"
+	"gorm.io/gorm"
+
+	"github.com/ringecosystem/degov-square/database"
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	gqlmodels "github.com/ringecosystem/degov-square/graph/models"
+	"github.com/ringecosystem/degov-square/internal/utils"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+const testNotificationInterval = time.Minute
+
+var blankLinesPattern = regexp.MustCompile(`\n{3,}`)
+
+// previewRenderer is the part of the TemplateService previews need
+type previewRenderer interface {
+	GeneratePreviewTemplate(record *dbmodels.NotificationRecord) (*types.TemplateOutput, error)
+}
+
+type previewNotifier interface {
+	Notify(input types.NotifyInput) (*types.NotifyOutput, error)
+}
+
+// NotificationPreviewService renders notifications on demand, to preview them or to check a channel receives them
+type NotificationPreviewService struct {
+	db       *gorm.DB
+	renderer previewRenderer
+	notifier previewNotifier
+	// a test send per channel is allowed once per testNotificationInterval
+	throttle *ExpiringStore
+}
+
+func NewNotificationPreviewService() *NotificationPreviewService {
+	db := database.GetDB()
+	return &NotificationPreviewService{
+		db:       db,
+		renderer: NewTemplateService(),
+		notifier: NewNotifierService(),
+		throttle: NewExpiringStore(db),
+	}
+}
+
+func (s *NotificationPreviewService) Preview(user *types.UserSessInfo, input gqlmodels.PreviewNotificationInput) (*gqlmodels.NotificationPreview, error) {
+	_, output, err := s.render(user, input.Feature, input.DaoCode, input.ProposalID)
+	if err != nil {
+		return nil, err
+	}
+	return &gqlmodels.NotificationPreview{
+		Title:     output.Title,
+		HTML:      output.RichTextContent,
+		Markdown:  output.PlainTextContent,
+		PlainText: markdownToPlainText(output.PlainTextContent),
+	}, nil
+}
+
+// SendTest delivers a rendered example to a verified channel of the user, the title is marked as a test
+func (s *NotificationPreviewService) SendTest(user *types.UserSessInfo, input gqlmodels.SendTestNotificationInput) (*gqlmodels.SendTestNotificationOutput, error) {
+	var channel dbmodels.NotificationChannel
+	err := s.db.Where("id = ? AND user_id = ?", input.ChannelID, user.Id).First(&channel).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &gqlmodels.SendTestNotificationOutput{
+			Code:    1,
+			Message: utils.StringPtr("Notification channel not found"),
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if channel.Verified != 1 {
+		return &gqlmodels.SendTestNotificationOutput{
+			Code:    1,
+			Message: utils.StringPtr("Please verify the notification channel first"),
+		}, nil
+	}
+
+	record, output, err := s.render(user, input.Feature, input.DaoCode, input.ProposalID)
+	if err != nil {
+		return nil, err
+	}
+	output.Title = utils.TruncateText("[Test] "+output.Title, 80)
+
+	remaining, err := s.throttle.Reserve("test_notification:"+channel.ID, testNotificationInterval)
+	if err != nil {
+		return nil, err
+	}
+	if remaining > 0 {
+		return &gqlmodels.SendTestNotificationOutput{
+			Code:    1,
+			Message: utils.StringPtr("Please wait a minute before sending another test notification"),
+		}, nil
+	}
+	if _, err := s.notifier.Notify(types.NotifyInput{
+		Type:     channel.ChannelType,
+		To:       channel.ChannelValue,
+		Template: output,
+		Record:   record,
+		Payload:  channel.Payload,
+	}); err != nil {
+		return &gqlmodels.SendTestNotificationOutput{
+			Code:    1,
+			Message: utils.StringPtr(fmt.Sprintf("Failed to send the test notification: %v", err)),
+		}, nil
+	}
+	return &gqlmodels.SendTestNotificationOutput{Code: 0}, nil
+}
+
+func (s *NotificationPreviewService) render(user *types.UserSessInfo, feature gqlmodels.FeatureName, daoCode string, proposalID *string) (*dbmodels.NotificationRecord, *types.TemplateOutput, error) {
+	featureName, ok := subscribeFeatureName(feature)
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported feature %s", feature)
+	}
+	now := time.Now()
+	record := &dbmodels.NotificationRecord{
+		ID:              "preview",
+		Code:            "preview",
+		EventID:         "preview",
+		DaoCode:         daoCode,
+		Type:            featureName,
+		UserID:          user.Id,
+		UserAddress:     user.Address,
+		State:           dbmodels.NotificationRecordStatePending,
+		TimeNextExecute: now,
+		CTime:           now,
+		UTime:           now,
+	}
+	if proposalID != nil {
+		record.ProposalID = strings.TrimSpace(*proposalID)
+	}
+	output, err := s.renderer.GeneratePreviewTemplate(record)
+	if err != nil {
+		return nil, nil, err
+	}
+	return record, output, nil
+}
+
+// markdownToPlainText drops the markdown formatting, links keep their text
+func markdownToPlainText(md string) string {
+	text := stdhtml.UnescapeString(bluemonday.StrictPolicy().Sanitize(mdToHTML([]byte(md))))
+	return strings.TrimSpace(blankLinesPattern.ReplaceAllString(text, "\n\n"))
+}
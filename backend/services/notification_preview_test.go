@@ -0,0 +1,90 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	gqlmodels "github.com/ringecosystem/degov-square/graph/models"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+type fakePreviewRenderer struct {
+	records []*dbmodels.NotificationRecord
+}
+
+func (f *fakePreviewRenderer) GeneratePreviewTemplate(record *dbmodels.NotificationRecord) (*types.TemplateOutput, error) {
+	f.records = append(f.records, record)
+	return &types.TemplateOutput{
+		Title:            "[Demo] New Proposal: Example",
+		RichTextContent:  "<p>Example</p>",
+		PlainTextContent: "## DeGov.AI\n\nHello **0xabc**,\n\n- [Proposal](https://demo.degov.ai/proposal/1) &amp; more",
+	}, nil
+}
+
+type fakePreviewNotifier struct {
+	sent []types.NotifyInput
+}
+
+func (f *fakePreviewNotifier) Notify(input types.NotifyInput) (*types.NotifyOutput, error) {
+	f.sent = append(f.sent, input)
+	return &types.NotifyOutput{}, nil
+}
+
+func TestSendTestNotificationToVerifiedChannel(t *testing.T) {
+	db := newTestNotificationDB(t)
+	for _, statement := range []string{
+		`CREATE TABLE dgv_notification_channel (id TEXT, user_id TEXT, user_address TEXT, verified INTEGER, channel_type TEXT, channel_value TEXT, payload TEXT, ctime DATETIME)`,
+		`INSERT INTO dgv_notification_channel (id, user_id, user_address, verified, channel_type, channel_value) VALUES
+			('c1', 'u1', '0xabc', 1, 'EMAIL', 'voter@example.com'),
+			('c2', 'u1', '0xabc', 0, 'EMAIL', 'pending@example.com'),
+			('c3', 'u2', '0xdef', 1, 'EMAIL', 'other@example.com')`,
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatalf("seed channels: %v", err)
+		}
+	}
+	renderer := &fakePreviewRenderer{}
+	notifier := &fakePreviewNotifier{}
+	service := &NotificationPreviewService{db: db, renderer: renderer, notifier: notifier, throttle: NewExpiringStore(db)}
+	user := &types.UserSessInfo{Id: "u1", Address: "0xabc"}
+	proposalID := "0x01"
+
+	for _, channelID := range []string{"c2", "c3", "missing"} {
+		output, err := service.SendTest(user, gqlmodels.SendTestNotificationInput{ChannelID: channelID, Feature: gqlmodels.FeatureNameProposalNew, DaoCode: "demo"})
+		if err != nil || output.Code != 1 {
+			t.Fatalf("expected channel %s to be refused, got %+v %v", channelID, output, err)
+		}
+	}
+
+	output, err := service.SendTest(user, gqlmodels.SendTestNotificationInput{ChannelID: "c1", Feature: gqlmodels.FeatureNameVoteEmitted, DaoCode: "demo", ProposalID: &proposalID})
+	if err != nil || output.Code != 0 {
+		t.Fatalf("send test notification: %+v %v", output, err)
+	}
+	if len(notifier.sent) != 1 || notifier.sent[0].To != "voter@example.com" || !strings.HasPrefix(notifier.sent[0].Template.Title, "[Test] ") {
+		t.Fatalf("unexpected delivery: %+v", notifier.sent)
+	}
+	record := renderer.records[0]
+	if record.Type != dbmodels.SubscribeFeatureVoteEmitted || record.ProposalID != "0x01" || record.UserID != "u1" {
+		t.Fatalf("unexpected preview record: %+v", record)
+	}
+
+	output, err = service.SendTest(user, gqlmodels.SendTestNotificationInput{ChannelID: "c1", Feature: gqlmodels.FeatureNameProposalNew, DaoCode: "demo"})
+	if err != nil || output.Code != 1 || len(notifier.sent) != 1 {
+		t.Fatalf("expected a second test send to be rate limited, got %+v %v", output, err)
+	}
+}
+
+func TestPreviewNotificationRendersAllFormats(t *testing.T) {
+	service := &NotificationPreviewService{renderer: &fakePreviewRenderer{}}
+	preview, err := service.Preview(&types.UserSessInfo{Id: "u1", Address: "0xabc"}, gqlmodels.PreviewNotificationInput{Feature: gqlmodels.FeatureNameProposalNew, DaoCode: "demo"})
+	if err != nil {
+		t.Fatalf("preview: %v", err)
+	}
+	if preview.HTML != "<p>Example</p>" || !strings.Contains(preview.Markdown, "**0xabc**") {
+		t.Fatalf("unexpected preview: %+v", preview)
+	}
+	if want := "DeGov.AI\n\nHello 0xabc,\n\nProposal & more"; preview.PlainText != want {
+		t.Fatalf("unexpected plain text %q", preview.PlainText)
+	}
+}
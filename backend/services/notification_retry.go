@@ -0,0 +1,50 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"github.com/ringecosystem/degov-square/internal/config"
+)
+
+// NotificationRetryPolicy computes the exponential backoff used by notification events, records and deliveries.
+type NotificationRetryPolicy struct {
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	MaxAttempts int
+	// Jitter randomizes the delay by up to this fraction in both directions, 0 disables it
+	Jitter float64
+}
+
+func notificationRetryPolicyFromConfig() NotificationRetryPolicy {
+	cfg := config.GetConfig()
+	return NotificationRetryPolicy{
+		BaseDelay:   cfg.GetNotificationRetryBaseDelay(),
+		MaxDelay:    cfg.GetNotificationRetryMaxDelay(),
+		MaxAttempts: cfg.GetNotificationRetryMaxAttempts(),
+		Jitter:      cfg.GetNotificationRetryJitter(),
+	}
+}
+
+// Delay returns how long to wait after the given number of failed attempts. random is expected in [0, 1).
+func (p NotificationRetryPolicy) Delay(attempts int, random float64) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := float64(p.BaseDelay) * math.Pow(2, float64(attempts-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*random - 1)
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return time.Duration(delay)
+}
+
+// Exhausted reports whether no further attempt should be made after the given number of failures.
+func (p NotificationRetryPolicy) Exhausted(attempts int) bool {
+	return attempts >= p.MaxAttempts
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/ringecosystem/degov-square/database"
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	gqlmodels "github.com/ringecosystem/degov-square/graph/models"
+	"github.com/ringecosystem/degov-square/internal/config"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+const (
+	// notificationStreamLookback re-reads recent events so rows committed slightly out of order are not missed
+	notificationStreamLookback = 10 * time.Second
+	// notificationStreamBuffer events are queued per subscriber, a slower client misses the rest
+	notificationStreamBuffer = 64
+	notificationStreamBatch  = 500
+)
+
+type notificationStreamSubscriber struct {
+	filter func(event *dbmodels.NotificationEvent) bool
+	events chan dbmodels.NotificationEvent
+}
+
+// NotificationEventStream pushes the events tracking tasks store in dgv_notification_event to live GraphQL subscriptions.
+// Events are polled from the database, so subscribers on every instance see them wherever the tasks run.
+type NotificationEventStream struct {
+	db         *gorm.DB
+	interval   time.Duration
+	now        func() time.Time
+	daoService *DaoService
+
+	mu          sync.Mutex
+	subscribers map[int]*notificationStreamSubscriber
+	nextID      int
+	running     bool
+	watermark   time.Time
+	seen        map[string]time.Time
+}
+
+func NewNotificationEventStream() *NotificationEventStream {
+	stream := newNotificationEventStream(database.GetDB(), config.GetConfig().GetGraphQLSubscriptionPollInterval())
+	stream.daoService = NewDaoService()
+	return stream
+}
+
+func newNotificationEventStream(db *gorm.DB, interval time.Duration) *NotificationEventStream {
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	return &NotificationEventStream{
+		db:          db,
+		interval:    interval,
+		now:         time.Now,
+		subscribers: make(map[int]*notificationStreamSubscriber),
+		seen:        make(map[string]time.Time),
+	}
+}
+
+// Subscribe returns the events accepted by filter until ctx is done, polling starts with the first subscriber.
+func (s *NotificationEventStream) Subscribe(ctx context.Context, filter func(event *dbmodels.NotificationEvent) bool) <-chan dbmodels.NotificationEvent {
+	subscriber := &notificationStreamSubscriber{
+		filter: filter,
+		events: make(chan dbmodels.NotificationEvent, notificationStreamBuffer),
+	}
+
+	s.mu.Lock()
+	id := s.nextID
+	s.nextID++
+	s.subscribers[id] = subscriber
+	if !s.running {
+		s.running = true
+		s.watermark = s.now()
+		go s.run()
+	}
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		delete(s.subscribers, id)
+		close(subscriber.events)
+		s.mu.Unlock()
+	}()
+	return subscriber.events
+}
+
+func (s *NotificationEventStream) run() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.mu.Lock()
+		if len(s.subscribers) == 0 {
+			s.running = false
+			s.mu.Unlock()
+			return
+		}
+		s.mu.Unlock()
+
+		if err := s.poll(); err != nil {
+			slog.Warn("Failed to poll notification events for subscriptions", "error", err)
+		}
+	}
+}
+
+// poll publishes the events stored since the last poll.
+func (s *NotificationEventStream) poll() error {
+	s.mu.Lock()
+	since := s.watermark.Add(-notificationStreamLookback)
+	s.mu.Unlock()
+
+	var events []dbmodels.NotificationEvent
+	if err := s.db.
+		Select("id", "chain_id", "dao_code", "type", "proposal_id", "vote_id", "payload", "time_event", "ctime").
+		Where("ctime > ?", since).
+		Order("ctime asc").
+		Limit(notificationStreamBatch).
+		Find(&events).Error; err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range events {
+		event := events[i]
+		if _, ok := s.seen[event.ID]; ok {
+			continue
+		}
+		s.seen[event.ID] = event.CTime
+		if event.CTime.After(s.watermark) {
+			s.watermark = event.CTime
+		}
+		for _, subscriber := range s.subscribers {
+			if !subscriber.filter(&event) {
+				continue
+			}
+			select {
+			case subscriber.events <- event:
+			default:
+				// the client does not keep up, live updates are best effort
+			}
+		}
+	}
+	for id, ctime := range s.seen {
+		if ctime.Before(s.watermark.Add(-notificationStreamLookback)) {
+			delete(s.seen, id)
+		}
+	}
+	return nil
+}
+
+func (s *NotificationEventStream) ProposalStateChanged(ctx context.Context, daoCode string) <-chan *gqlmodels.ProposalStateChangedEvent {
+	events := s.Subscribe(ctx, func(event *dbmodels.NotificationEvent) bool {
+		return event.Type == dbmodels.SubscribeFeatureProposalStateChanged && event.DaoCode == daoCode
+	})
+	output := make(chan *gqlmodels.ProposalStateChangedEvent)
+	go func() {
+		defer close(output)
+		for event := range events {
+			converted := proposalStateChangedToGraphQL(&event)
+			if converted == nil {
+				continue
+			}
+			select {
+			case output <- converted:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return output
+}
+
+func (s *NotificationEventStream) VoteCast(ctx context.Context, daoCode string, proposalID string) <-chan *gqlmodels.VoteCastEvent {
+	events := s.Subscribe(ctx, func(event *dbmodels.NotificationEvent) bool {
+		return event.Type == dbmodels.SubscribeFeatureVoteEmitted && event.DaoCode == daoCode && event.ProposalID == proposalID
+	})
+	output := make(chan *gqlmodels.VoteCastEvent)
+	go func() {
+		defer close(output)
+		for event := range events {
+			voteID := ""
+			if event.VoteID != nil {
+				voteID = *event.VoteID
+			}
+			select {
+			case output <- &gqlmodels.VoteCastEvent{
+				DaoCode:    event.DaoCode,
+				ChainID:    int32(event.ChainID),
+				ProposalID: event.ProposalID,
+				VoteID:     voteID,
+				Time:       event.TimeEvent,
+			}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// DaoMetricsUpdated emits the DAO again whenever a proposal or vote event arrives for it.
+// Events that arrive together are folded into a single update per DAO.
+func (s *NotificationEventStream) DaoMetricsUpdated(ctx context.Context, daoCode *string) <-chan *gqlmodels.Dao {
+	events := s.Subscribe(ctx, func(event *dbmodels.NotificationEvent) bool {
+		if daoCode != nil && *daoCode != "" && event.DaoCode != *daoCode {
+			return false
+		}
+		switch event.Type {
+		case dbmodels.SubscribeFeatureProposalNew, dbmodels.SubscribeFeatureProposalStateChanged, dbmodels.SubscribeFeatureVoteEmitted:
+			return true
+		}
+		return false
+	})
+	output := make(chan *gqlmodels.Dao)
+	go func() {
+		defer close(output)
+		for event := range events {
+			codes := []string{event.DaoCode}
+			pending := map[string]bool{event.DaoCode: true}
+		drain:
+			for {
+				select {
+				case next, ok := <-events:
+					if !ok {
+						break drain
+					}
+					if !pending[next.DaoCode] {
+						pending[next.DaoCode] = true
+						codes = append(codes, next.DaoCode)
+					}
+				default:
+					break drain
+				}
+			}
+
+			for _, code := range codes {
+				dao, err := s.daoService.Inspect(types.BasicInput[string]{Input: code})
+				if err != nil {
+					slog.Warn("Failed to load DAO for metrics subscription", "dao_code", code, "error", err)
+					continue
+				}
+				select {
+				case output <- dao:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return output
+}
+
+func proposalStateChangedToGraphQL(event *dbmodels.NotificationEvent) *gqlmodels.ProposalStateChangedEvent {
+	if event.Payload == nil {
+		return nil
+	}
+	var payload struct {
+		OldState string `json:"old_state"`
+		NewState string `json:"new_state"`
+	}
+	if err := json.Unmarshal([]byte(*event.Payload), &payload); err != nil || payload.NewState == "" {
+		slog.Warn("Invalid proposal state change payload", "event_id", event.ID, "error", err)
+		return nil
+	}
+	output := &gqlmodels.ProposalStateChangedEvent{
+		DaoCode:    event.DaoCode,
+		ChainID:    int32(event.ChainID),
+		ProposalID: event.ProposalID,
+		NewState:   gqlmodels.ProposalState(payload.NewState),
+		// time_event of a state change holds the proposal creation, ctime is when the change was seen
+		Time: event.CTime,
+	}
+	if oldState := gqlmodels.ProposalState(payload.OldState); oldState.IsValid() {
+		output.OldState = &oldState
+	}
+	if !output.NewState.IsValid() {
+		return nil
+	}
+	return output
+}
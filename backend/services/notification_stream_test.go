@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	gqlmodels "github.com/ringecosystem/degov-square/graph/models"
+)
+
+func TestNotificationEventStreamPushesNewEventsOnce(t *testing.T) {
+	db := newTestNotificationDB(t)
+	stream := newNotificationEventStream(db, time.Hour)
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	stream.now = func() time.Time { return start }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	states := stream.ProposalStateChanged(ctx, "demo")
+	votes := stream.VoteCast(ctx, "demo", "0x01")
+
+	oldPayload := `{"old_state": "PENDING", "new_state": "ACTIVE"}`
+	voteID := "vote-1"
+	events := []dbmodels.NotificationEvent{
+		// stored before the subscription started, never replayed
+		{ID: "old", DaoCode: "demo", Type: dbmodels.SubscribeFeatureProposalStateChanged, ProposalID: "0x01", State: dbmodels.NotificationEventStatePending, Payload: &oldPayload, CTime: start.Add(-time.Minute)},
+		{ID: "state", DaoCode: "demo", Type: dbmodels.SubscribeFeatureProposalStateChanged, ProposalID: "0x01", State: dbmodels.NotificationEventStatePending, Payload: &oldPayload, CTime: start.Add(time.Second)},
+		{ID: "other-dao", DaoCode: "other", Type: dbmodels.SubscribeFeatureProposalStateChanged, ProposalID: "0x01", State: dbmodels.NotificationEventStatePending, Payload: &oldPayload, CTime: start.Add(time.Second)},
+		{ID: "vote", DaoCode: "demo", Type: dbmodels.SubscribeFeatureVoteEmitted, ProposalID: "0x01", VoteID: &voteID, State: dbmodels.NotificationEventStatePending, TimeEvent: start, CTime: start.Add(2 * time.Second)},
+		{ID: "other-proposal", DaoCode: "demo", Type: dbmodels.SubscribeFeatureVoteEmitted, ProposalID: "0x02", VoteID: &voteID, State: dbmodels.NotificationEventStatePending, CTime: start.Add(2 * time.Second)},
+	}
+	if err := db.Create(&events).Error; err != nil {
+		t.Fatalf("seed events: %v", err)
+	}
+
+	// the second poll re-reads the lookback window and must not publish anything twice
+	for i := 0; i < 2; i++ {
+		if err := stream.poll(); err != nil {
+			t.Fatalf("poll: %v", err)
+		}
+	}
+
+	select {
+	case state := <-states:
+		if state.ProposalID != "0x01" || state.NewState != gqlmodels.ProposalStateActive || state.OldState == nil || *state.OldState != gqlmodels.ProposalStatePending {
+			t.Fatalf("unexpected state change: %+v", state)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a proposal state change")
+	}
+	select {
+	case vote := <-votes:
+		if vote.VoteID != "vote-1" || !vote.Time.Equal(start) {
+			t.Fatalf("unexpected vote: %+v", vote)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a vote")
+	}
+	select {
+	case state := <-states:
+		t.Fatalf("unexpected extra state change: %+v", state)
+	case vote := <-votes:
+		t.Fatalf("unexpected extra vote: %+v", vote)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel()
+	if _, ok := <-states; ok {
+		t.Fatal("subscription channel must close with the context")
+	}
+}
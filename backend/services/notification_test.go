@@ -0,0 +1,440 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	"github.com/ringecosystem/degov-square/internal/utils"
+	"github.com/ringecosystem/degov-square/types"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestNotificationDB(t testing.TB) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite db: %v", err)
+	}
+	for _, statement := range []string{
+		`CREATE TABLE dgv_notification_record (
+			id TEXT PRIMARY KEY, code TEXT NOT NULL UNIQUE, event_id TEXT NOT NULL, chain_id INTEGER NOT NULL,
+			dao_code TEXT NOT NULL, type TEXT NOT NULL, proposal_id TEXT NOT NULL, vote_id TEXT,
+			user_id TEXT NOT NULL, user_address TEXT NOT NULL, state TEXT NOT NULL, message TEXT, payload TEXT, digest TEXT,
+			title TEXT, content TEXT, time_read DATETIME, time_archived DATETIME,
+			times_retry INTEGER NOT NULL DEFAULT 0, time_next_execute DATETIME,
+			ctime DATETIME DEFAULT CURRENT_TIMESTAMP, utime DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (event_id, user_id)
+		)`,
+		`CREATE TABLE dgv_notification_event (
+			id TEXT PRIMARY KEY, chain_id INTEGER NOT NULL, dao_code TEXT NOT NULL, type TEXT NOT NULL,
+			proposal_id TEXT NOT NULL, vote_id TEXT, target_address TEXT, dedupe_key TEXT UNIQUE, reached INTEGER NOT NULL DEFAULT 0, state TEXT NOT NULL,
+			payload TEXT, message TEXT, time_event DATETIME, times_retry INTEGER NOT NULL DEFAULT 0,
+			time_next_execute DATETIME, ctime DATETIME DEFAULT CURRENT_TIMESTAMP, utime DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE dgv_notification_delivery (
+			id TEXT PRIMARY KEY, record_id TEXT NOT NULL, channel_id TEXT NOT NULL, channel_type TEXT NOT NULL,
+			state TEXT NOT NULL, times_retry INTEGER NOT NULL DEFAULT 0, last_error TEXT, response_code INTEGER,
+			time_next_execute DATETIME NOT NULL, ctime DATETIME DEFAULT CURRENT_TIMESTAMP, utime DATETIME,
+			UNIQUE (record_id, channel_id)
+		)`,
+		`CREATE TABLE dgv_expiring_value (
+			id TEXT PRIMARY KEY, value TEXT NOT NULL DEFAULT '', attempts INTEGER NOT NULL DEFAULT 0, expires_at DATETIME NOT NULL, ctime DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatalf("create notification table: %v", err)
+		}
+	}
+	return db
+}
+
+func seedTestNotificationRecord(t *testing.T, db *gorm.DB, id string) *dbmodels.NotificationRecord {
+	t.Helper()
+	record := &dbmodels.NotificationRecord{
+		ID:              id,
+		Code:            "event_" + id,
+		EventID:         "event",
+		DaoCode:         "demo",
+		Type:            dbmodels.SubscribeFeatureProposalNew,
+		ProposalID:      "0x01",
+		UserID:          id,
+		UserAddress:     "0xabc",
+		State:           dbmodels.NotificationRecordStatePending,
+		TimeNextExecute: time.Now(),
+	}
+	if err := db.Create(record).Error; err != nil {
+		t.Fatalf("seed record: %v", err)
+	}
+	return record
+}
+
+func TestEnsureDeliveriesIsIdempotentPerChannel(t *testing.T) {
+	db := newTestNotificationDB(t)
+	service := newNotificationService(db)
+	record := seedTestNotificationRecord(t, db, "record-1")
+
+	channels := []dbmodels.NotificationChannel{
+		{ID: "email", ChannelType: dbmodels.NotificationChannelTypeEmail},
+		{ID: "hook", ChannelType: dbmodels.NotificationChannelTypeWebhook},
+	}
+	first, err := service.EnsureDeliveries(record, channels)
+	if err != nil {
+		t.Fatalf("ensure deliveries: %v", err)
+	}
+	if len(first) != 2 {
+		t.Fatalf("expected 2 deliveries, got %d", len(first))
+	}
+	if err := service.UpdateDeliveryState(types.UpdateDeliveryStateInput{ID: first[0].ID, State: dbmodels.NotificationDeliveryStateSentOk}); err != nil {
+		t.Fatalf("update delivery state: %v", err)
+	}
+
+	second, err := service.EnsureDeliveries(record, channels)
+	if err != nil {
+		t.Fatalf("ensure deliveries again: %v", err)
+	}
+	if len(second) != 2 {
+		t.Fatalf("expected deliveries to be reused, got %d", len(second))
+	}
+	for _, delivery := range second {
+		if delivery.ChannelID == first[0].ChannelID && delivery.State != dbmodels.NotificationDeliveryStateSentOk {
+			t.Fatalf("delivered channel was reset to %s", delivery.State)
+		}
+	}
+}
+
+func TestAggregateRecordStateFromDeliveries(t *testing.T) {
+	db := newTestNotificationDB(t)
+	service := newNotificationService(db)
+	record := seedTestNotificationRecord(t, db, "record-1")
+
+	deliveries, err := service.EnsureDeliveries(record, []dbmodels.NotificationChannel{
+		{ID: "email", ChannelType: dbmodels.NotificationChannelTypeEmail},
+		{ID: "hook", ChannelType: dbmodels.NotificationChannelTypeWebhook},
+	})
+	if err != nil {
+		t.Fatalf("ensure deliveries: %v", err)
+	}
+	byChannel := map[string]dbmodels.NotificationDelivery{}
+	for _, delivery := range deliveries {
+		byChannel[delivery.ChannelID] = delivery
+	}
+
+	if err := service.UpdateDeliveryState(types.UpdateDeliveryStateInput{ID: byChannel["email"].ID, State: dbmodels.NotificationDeliveryStateSentOk}); err != nil {
+		t.Fatalf("update email delivery: %v", err)
+	}
+	if err := service.UpdateDeliveryRetryTimes(types.UpdateDeliveryRetryTimes{ID: byChannel["hook"].ID, TimesRetry: 1, LastError: "boom"}); err != nil {
+		t.Fatalf("update hook delivery: %v", err)
+	}
+
+	state, err := service.AggregateRecordState(record.ID)
+	if err != nil {
+		t.Fatalf("aggregate: %v", err)
+	}
+	if state != dbmodels.NotificationRecordStatePending {
+		t.Fatalf("expected record to wait for the webhook retry, got %s", state)
+	}
+	var stored dbmodels.NotificationRecord
+	if err := db.First(&stored, "id = ?", record.ID).Error; err != nil {
+		t.Fatalf("load record: %v", err)
+	}
+	if !stored.TimeNextExecute.After(time.Now()) {
+		t.Fatalf("expected record to be rescheduled with the delivery, got %s", stored.TimeNextExecute)
+	}
+
+	if err := service.UpdateDeliveryRetryTimes(types.UpdateDeliveryRetryTimes{ID: byChannel["hook"].ID, TimesRetry: service.retryPolicy.MaxAttempts, LastError: "boom"}); err != nil {
+		t.Fatalf("fail hook delivery: %v", err)
+	}
+	state, err = service.AggregateRecordState(record.ID)
+	if err != nil {
+		t.Fatalf("aggregate: %v", err)
+	}
+	if state != dbmodels.NotificationRecordStateSentFail {
+		t.Fatalf("expected record to fail once the webhook gave up, got %s", state)
+	}
+}
+
+func TestAggregateRecordStateIgnoresCancelledDeliveries(t *testing.T) {
+	db := newTestNotificationDB(t)
+	service := newNotificationService(db)
+	record := seedTestNotificationRecord(t, db, "record-1")
+
+	deliveries, err := service.EnsureDeliveries(record, []dbmodels.NotificationChannel{
+		{ID: "email", ChannelType: dbmodels.NotificationChannelTypeEmail},
+		{ID: "removed", ChannelType: dbmodels.NotificationChannelTypeWebhook},
+	})
+	if err != nil {
+		t.Fatalf("ensure deliveries: %v", err)
+	}
+	for _, delivery := range deliveries {
+		state := dbmodels.NotificationDeliveryStateSentOk
+		if delivery.ChannelID == "removed" {
+			state = dbmodels.NotificationDeliveryStateCancelled
+		}
+		if err := service.UpdateDeliveryState(types.UpdateDeliveryStateInput{ID: delivery.ID, State: state}); err != nil {
+			t.Fatalf("update delivery: %v", err)
+		}
+	}
+
+	if state, err := service.AggregateRecordState(record.ID); err != nil || state != dbmodels.NotificationRecordStateSentOk {
+		t.Fatalf("expected a cancelled channel not to fail the record, got %s %v", state, err)
+	}
+}
+
+func TestNotificationRetryPolicyDelay(t *testing.T) {
+	policy := NotificationRetryPolicy{BaseDelay: time.Minute, MaxDelay: 10 * time.Minute, MaxAttempts: 5, Jitter: 0.5}
+
+	if got := policy.Delay(1, 0.5); got != time.Minute {
+		t.Fatalf("first retry delay = %s, want 1m", got)
+	}
+	if got := policy.Delay(3, 0.5); got != 4*time.Minute {
+		t.Fatalf("third retry delay = %s, want 4m", got)
+	}
+	if got := policy.Delay(10, 0.5); got != 10*time.Minute {
+		t.Fatalf("delay should be capped, got %s", got)
+	}
+	if low, high := policy.Delay(2, 0), policy.Delay(2, 0.999); low != time.Minute || high <= 2*time.Minute {
+		t.Fatalf("unexpected jitter bounds: %s .. %s", low, high)
+	}
+	if policy.Exhausted(4) || !policy.Exhausted(5) {
+		t.Fatalf("expected the fifth failure to exhaust retries")
+	}
+}
+
+func TestSaveEventsSkipsDuplicates(t *testing.T) {
+	service := newNotificationService(newTestNotificationDB(t))
+	voteEvents := func() []dbmodels.NotificationEvent {
+		var events []dbmodels.NotificationEvent
+		for _, voteID := range []string{"v1", "v2"} {
+			events = append(events, dbmodels.NotificationEvent{
+				DaoCode: "demo", Type: dbmodels.SubscribeFeatureVoteEmitted, ProposalID: "0x01", VoteID: utils.StringPtr(voteID),
+			})
+		}
+		return events
+	}
+	stateEvent := func(state string) dbmodels.NotificationEvent {
+		return dbmodels.NotificationEvent{
+			DaoCode: "demo", Type: dbmodels.SubscribeFeatureProposalStateChanged, ProposalID: "0x01",
+			Payload: utils.StringPtr(`{"new_state": "` + state + `"}`),
+		}
+	}
+	delegation := dbmodels.NotificationEvent{DaoCode: "demo", Type: dbmodels.SubscribeFeatureDelegationChanged}
+
+	// the vote page is saved again when the task crashed before advancing its offset
+	for i := 0; i < 2; i++ {
+		if err := service.SaveEvents(append(voteEvents(), stateEvent("ACTIVE"), delegation)); err != nil {
+			t.Fatalf("save events: %v", err)
+		}
+	}
+	if err := service.SaveEvent(stateEvent("SUCCEEDED")); err != nil {
+		t.Fatalf("save state event: %v", err)
+	}
+
+	var events []dbmodels.NotificationEvent
+	if err := service.db.Order("type ASC").Find(&events).Error; err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	counts := map[dbmodels.SubscribeFeatureName]int{}
+	for _, event := range events {
+		counts[event.Type]++
+	}
+	if counts[dbmodels.SubscribeFeatureVoteEmitted] != 2 || counts[dbmodels.SubscribeFeatureProposalStateChanged] != 2 ||
+		counts[dbmodels.SubscribeFeatureDelegationChanged] != 2 {
+		t.Fatalf("unexpected events %v", counts)
+	}
+}
+
+func TestClaimEventCountsAbandonedRunsAndFails(t *testing.T) {
+	db := newTestNotificationDB(t)
+	service := newNotificationService(db)
+	service.retryPolicy = NotificationRetryPolicy{BaseDelay: time.Minute, MaxAttempts: 2}
+	service.eventLease = time.Minute
+	now := time.Now()
+	service.now = func() time.Time { return now }
+
+	event := dbmodels.NotificationEvent{
+		ID:              "event-1",
+		DaoCode:         "demo",
+		Type:            dbmodels.SubscribeFeatureProposalNew,
+		ProposalID:      "0x01",
+		State:           dbmodels.NotificationEventStatePending,
+		TimeNextExecute: now.Add(-time.Second),
+	}
+	if err := db.Create(&event).Error; err != nil {
+		t.Fatalf("seed event: %v", err)
+	}
+
+	claimed, err := service.ClaimEvent(&event)
+	if err != nil || !claimed {
+		t.Fatalf("expected pending event to be claimed, claimed=%v err=%v", claimed, err)
+	}
+	if claimed, _ := service.ClaimEvent(&event); claimed {
+		t.Fatalf("an event leased by another run must not be claimed twice")
+	}
+
+	// the worker died: once the lease expires the event is picked up again as a retry
+	now = now.Add(2 * time.Minute)
+	if err := db.First(&event, "id = ?", event.ID).Error; err != nil {
+		t.Fatalf("reload event: %v", err)
+	}
+	claimed, err = service.ClaimEvent(&event)
+	if err != nil || !claimed {
+		t.Fatalf("expected abandoned event to be reclaimed, claimed=%v err=%v", claimed, err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if err := db.First(&event, "id = ?", event.ID).Error; err != nil {
+		t.Fatalf("reload event: %v", err)
+	}
+	if claimed, _ := service.ClaimEvent(&event); claimed {
+		t.Fatalf("expected exhausted event not to be claimed")
+	}
+	if err := db.First(&event, "id = ?", event.ID).Error; err != nil {
+		t.Fatalf("reload event: %v", err)
+	}
+	if event.State != dbmodels.NotificationEventStateFailed || event.TimesRetry != 2 || event.Message == nil {
+		t.Fatalf("expected event to fail after two abandoned runs, got state=%s retries=%d", event.State, event.TimesRetry)
+	}
+}
+
+func TestEventRunUpdatesKeepTheClaimAndNeedTheLease(t *testing.T) {
+	db := newTestNotificationDB(t)
+	service := newNotificationService(db)
+	service.retryPolicy = NotificationRetryPolicy{BaseDelay: time.Minute, MaxAttempts: 5}
+	service.eventLease = time.Minute
+	now := time.Now()
+	service.now = func() time.Time { return now }
+
+	// left in PROGRESS by a run that died
+	event := dbmodels.NotificationEvent{
+		ID:              "event-1",
+		DaoCode:         "demo",
+		Type:            dbmodels.SubscribeFeatureProposalNew,
+		ProposalID:      "0x01",
+		State:           dbmodels.NotificationEventStateProgress,
+		TimeNextExecute: now.Add(-time.Second),
+	}
+	if err := db.Create(&event).Error; err != nil {
+		t.Fatalf("seed event: %v", err)
+	}
+	if claimed, err := service.ClaimEvent(&event); err != nil || !claimed {
+		t.Fatalf("claim event: %v %v", claimed, err)
+	}
+	if event.TimesRetry != 1 || event.Message == nil {
+		t.Fatalf("expected the claim to count the abandoned run in memory, got %+v", event)
+	}
+	leaseUntil := event.TimeNextExecute
+
+	// this run fails as well, both attempts are kept
+	if err := service.UpdateEventRetryTimes(types.UpdateEventRetryTimes{
+		ID:         event.ID,
+		TimesRetry: event.TimesRetry + 1,
+		Message:    *event.Message + "\n\n-------\n[2] boom",
+		LeaseUntil: &leaseUntil,
+	}); err != nil {
+		t.Fatalf("update retry times: %v", err)
+	}
+	var stored dbmodels.NotificationEvent
+	if err := db.First(&stored, "id = ?", event.ID).Error; err != nil {
+		t.Fatalf("reload event: %v", err)
+	}
+	if stored.TimesRetry != 2 || stored.Message == nil || !strings.Contains(*stored.Message, "[1]") || !strings.Contains(*stored.Message, "[2] boom") {
+		t.Fatalf("expected both attempts to be recorded, got %d %v", stored.TimesRetry, stored.Message)
+	}
+
+	// the lease is gone once the event was rescheduled, a late completion must not overwrite it
+	if err := service.UpdateEventState(types.UpdateEventStateInput{
+		ID:         event.ID,
+		State:      dbmodels.NotificationEventStateCompleted,
+		LeaseUntil: &leaseUntil,
+	}); !errors.Is(err, ErrNotificationEventLeaseLost) {
+		t.Fatalf("expected the lease to be lost, got %v", err)
+	}
+}
+
+func TestNextDigestTimeAlignsToUTCWindows(t *testing.T) {
+	now := time.Date(2025, 6, 1, 22, 15, 0, 0, time.FixedZone("UTC+8", 8*3600))
+
+	if got, want := NextDigestTime(dbmodels.SubscribeStrategyHourlyDigest, now, nil), time.Date(2025, 6, 1, 15, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("hourly digest = %s, want %s", got, want)
+	}
+	if got, want := NextDigestTime(dbmodels.SubscribeStrategyDailyDigest, now, nil), time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("daily digest = %s, want %s", got, want)
+	}
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	if got, want := NextDigestTime(dbmodels.SubscribeStrategyDailyDigest, now, berlin), time.Date(2025, 6, 2, 0, 0, 0, 0, berlin); !got.Equal(want) {
+		t.Fatalf("daily digest in Berlin = %s, want %s", got, want)
+	}
+}
+
+func TestDigestRecordsAreHeldUntilWindowCloses(t *testing.T) {
+	db := newTestNotificationDB(t)
+	service := newNotificationService(db)
+	now := time.Date(2025, 6, 1, 10, 30, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+
+	hourly := dbmodels.SubscribeStrategyHourlyDigest
+	windowEnd := NextDigestTime(hourly, now, nil)
+	records := []dbmodels.NotificationRecord{
+		{Code: "instant", EventID: "e1", DaoCode: "demo", Type: dbmodels.SubscribeFeatureProposalNew, ProposalID: "0x01", UserID: "user-1", UserAddress: "0xabc", State: dbmodels.NotificationRecordStatePending},
+		{Code: "vote-1", EventID: "e2", DaoCode: "demo", Type: dbmodels.SubscribeFeatureVoteEmitted, ProposalID: "0x01", UserID: "user-2", UserAddress: "0xdef", State: dbmodels.NotificationRecordStatePending, Digest: &hourly, TimeNextExecute: windowEnd},
+		{Code: "vote-2", EventID: "e3", DaoCode: "demo", Type: dbmodels.SubscribeFeatureVoteEmitted, ProposalID: "0x01", UserID: "user-2", UserAddress: "0xdef", State: dbmodels.NotificationRecordStatePending, Digest: &hourly, TimeNextExecute: windowEnd},
+	}
+	if err := service.StoreRecords(records); err != nil {
+		t.Fatalf("store records: %v", err)
+	}
+
+	states := []dbmodels.NotificationRecordState{dbmodels.NotificationRecordStatePending}
+	instant, err := service.ListLimitRecords(types.ListLimitRecordsInput{Limit: 10, States: &states, InstantOnly: true})
+	if err != nil {
+		t.Fatalf("list instant records: %v", err)
+	}
+	if len(instant) != 1 || instant[0].Code != "instant" {
+		t.Fatalf("expected only the instant record to be dispatched, got %+v", instant)
+	}
+	if groups, _ := service.ListDueDigests(10); len(groups) != 0 {
+		t.Fatalf("digest must wait for the window to close, got %+v", groups)
+	}
+
+	now = windowEnd.Add(time.Second)
+	groups, err := service.ListDueDigests(10)
+	if err != nil {
+		t.Fatalf("list due digests: %v", err)
+	}
+	if len(groups) != 1 || groups[0].UserID != "user-2" || groups[0].Digest != hourly {
+		t.Fatalf("unexpected digest groups: %+v", groups)
+	}
+	digestRecords, err := service.ListDigestRecords(types.ListDigestRecordsInput{UserID: "user-2", Digest: hourly, Limit: 10})
+	if err != nil {
+		t.Fatalf("list digest records: %v", err)
+	}
+	if len(digestRecords) != 2 {
+		t.Fatalf("expected both votes in the digest, got %d", len(digestRecords))
+	}
+}
+
+func TestDeferRecordsPostponesPendingRecords(t *testing.T) {
+	db := newTestNotificationDB(t)
+	service := newNotificationService(db)
+	record := seedTestNotificationRecord(t, db, "record-1")
+
+	until := time.Now().Add(6 * time.Hour).Truncate(time.Second)
+	if err := service.DeferRecords([]string{record.ID}, until); err != nil {
+		t.Fatalf("defer records: %v", err)
+	}
+
+	var stored dbmodels.NotificationRecord
+	if err := db.First(&stored, "id = ?", record.ID).Error; err != nil {
+		t.Fatalf("load record: %v", err)
+	}
+	if !stored.TimeNextExecute.Equal(until) || stored.State != dbmodels.NotificationRecordStatePending || stored.TimesRetry != 0 {
+		t.Fatalf("expected record to stay pending until %s, got %+v", until, stored)
+	}
+}
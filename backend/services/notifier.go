@@ -1,59 +1,104 @@
 package services
 
 import (
+	"errors"
+	"fmt"
 	"log/slog"
+	"net/http"
 	"strings"
+	"time"
 
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
 	"github.com/ringecosystem/degov-square/internal/config"
 	"github.com/ringecosystem/degov-square/types"
-	"github.com/sendgrid/sendgrid-go"
-	"github.com/sendgrid/sendgrid-go/helpers/mail"
 	"gorm.io/gorm"
 )
 
 var globalNotifier *NotifierService
 
+// ErrNotificationChannelGone is returned by Notify when the receiver reports the channel will never accept messages
+// again, such as an expired web push subscription
+var ErrNotificationChannelGone = errors.New("notification channel no longer exists")
+
 func getNotifier() *NotifierService {
 	if globalNotifier == nil {
-		globalNotifier = &NotifierService{}
+		cfg := config.GetConfig()
+		siteConfig := config.GetDegovSiteConfig()
+		timeout := cfg.GetDuration("NOTIFICATION_WEBHOOK_TIMEOUT")
+		httpClient := newPublicHTTPClient(timeout, cfg.GetBool("NOTIFICATION_ALLOW_PRIVATE_NETWORKS"))
+		globalNotifier = newNotifierService(nil, httpClient, notifierConfig{
+			TelegramAPIBaseURL:     strings.TrimRight(cfg.GetString("TELEGRAM_API_BASE_URL"), "/"),
+			TelegramBotToken:       cfg.GetString("TELEGRAM_BOT_TOKEN"),
+			SiteName:               siteConfig.Name,
+			SiteLogo:               siteConfig.Logo,
+			WebPushVAPIDPublicKey:  cfg.GetString("WEB_PUSH_VAPID_PUBLIC_KEY"),
+			WebPushVAPIDPrivateKey: cfg.GetString("WEB_PUSH_VAPID_PRIVATE_KEY"),
+			WebPushSubject:         cfg.GetString("WEB_PUSH_SUBJECT"),
+			WebPushTTL:             cfg.GetDuration("WEB_PUSH_TTL"),
+		})
+		// the Bot API comes from the config and may be a local stand-in, only the endpoints of users are guarded
+		globalNotifier.configuredClient = &http.Client{Timeout: timeout}
+		globalNotifier.emailProvider = newEmailProvider(cfg)
 	}
 	return globalNotifier
 }
 
+type notifierConfig struct {
+	TelegramAPIBaseURL string
+	TelegramBotToken   string
+	SiteName           string
+	SiteLogo           string
+	// WebPushVAPIDPublicKey and WebPushVAPIDPrivateKey are the raw P-256 keys, base64url encoded
+	WebPushVAPIDPublicKey  string
+	WebPushVAPIDPrivateKey string
+	WebPushSubject         string
+	WebPushTTL             time.Duration
+}
+
 type NotifierService struct {
-	db *gorm.DB
+	db     *gorm.DB
+	config notifierConfig
+	// httpClient sends to the endpoints users register, webhooks, Discord and Slack webhooks and push services, it
+	// refuses non-public addresses. configuredClient sends to the endpoints of the config, such as the Bot API.
+	httpClient       *http.Client
+	configuredClient *http.Client
+	emailProvider    emailProvider
+	now              func() time.Time
 }
 
 func NewNotifierService() *NotifierService {
 	return getNotifier()
 }
 
-func (n *NotifierService) Notify(input types.NotifyInput) error {
-	sendgridApiKey := config.GetString("SENDGRID_API_KEY")
-	if sendgridApiKey != "" {
-		if err := n.notifyUseSendGrid(input); err != nil {
-			slog.Warn("Failed to send notification [sendgrid]", "error", err)
-		}
+func newNotifierService(db *gorm.DB, httpClient *http.Client, cfg notifierConfig) *NotifierService {
+	return &NotifierService{
+		db:               db,
+		config:           cfg,
+		httpClient:       httpClient,
+		configuredClient: httpClient,
+		now:              time.Now,
 	}
-	return nil
 }
 
-func (n *NotifierService) notifyUseSendGrid(input types.NotifyInput) error {
-	template := input.Template
-	from := mail.NewEmail(config.GetString("SENDGRID_FROM_USER"), config.GetString("SENDGRID_FROM_EMAIL"))
-	nameParts := strings.Split(input.To, "@")
-	name := nameParts[0]
-	to := mail.NewEmail(name, input.To)
-	subject := template.Title
-	plainTextContent := template.PlainTextContent
-	htmlContent := template.RichTextContent
-	message := mail.NewSingleEmail(from, subject, to, plainTextContent, htmlContent)
-	client := sendgrid.NewSendClient(config.GetString("SENDGRID_API_KEY"))
-	response, err := client.Send(message)
-	if err != nil {
-		slog.Error("Failed to send notification", "error", err)
-		return err
+func (n *NotifierService) Notify(input types.NotifyInput) (*types.NotifyOutput, error) {
+	switch input.Type {
+	case dbmodels.NotificationChannelTypeEmail:
+		if n.emailProvider == nil {
+			slog.Warn("Skip email notification, no email provider is configured", "to", input.To)
+			return &types.NotifyOutput{}, nil
+		}
+		return n.notifyUseEmail(input)
+	case dbmodels.NotificationChannelTypeWebhook:
+		return n.notifyUseWebhook(input)
+	case dbmodels.NotificationChannelTypeTelegram:
+		return n.notifyUseTelegram(input)
+	case dbmodels.NotificationChannelTypeDiscord:
+		return n.notifyUseDiscord(input)
+	case dbmodels.NotificationChannelTypeSlack:
+		return n.notifyUseSlack(input)
+	case dbmodels.NotificationChannelTypeWebPush:
+		return n.notifyUseWebPush(input)
+	default:
+		return nil, fmt.Errorf("unsupported notification channel type: %s", input.Type)
 	}
-	slog.Info("Notification sent successfully", "to", input.To, "status_code", response.StatusCode)
-	return nil
 }
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+)
+
+// chatWebhookHosts lists the incoming webhook endpoints accepted for chat channels, keyed by host
+var chatWebhookHosts = map[dbmodels.NotificationChannelType]map[string]string{
+	dbmodels.NotificationChannelTypeDiscord: {
+		"discord.com":        "/api/webhooks/",
+		"discordapp.com":     "/api/webhooks/",
+		"ptb.discord.com":    "/api/webhooks/",
+		"canary.discord.com": "/api/webhooks/",
+	},
+	dbmodels.NotificationChannelTypeSlack: {
+		"hooks.slack.com": "/services/",
+	},
+}
+
+// ValidateChatWebhookURL makes sure a Discord or Slack channel points at the provider's incoming webhook endpoint.
+func ValidateChatWebhookURL(channelType dbmodels.NotificationChannelType, raw string) error {
+	hosts, ok := chatWebhookHosts[channelType]
+	if !ok {
+		return fmt.Errorf("unsupported chat channel type: %s", channelType)
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Scheme != "https" || parsed.User != nil {
+		return fmt.Errorf("%s webhook url must be an https url", strings.ToLower(string(channelType)))
+	}
+	prefix, ok := hosts[strings.ToLower(parsed.Hostname())]
+	if !ok || parsed.Port() != "" || !strings.HasPrefix(parsed.Path, prefix) {
+		return fmt.Errorf("url is not a %s incoming webhook", strings.ToLower(string(channelType)))
+	}
+	return nil
+}
+
+// postChatWebhook posts a JSON message to an incoming webhook, any non-2xx status is returned as an error
+func (n *NotifierService) postChatWebhook(endpoint string, message any) (int, error) {
+	raw, err := json.Marshal(message)
+	if err != nil {
+		return 0, fmt.Errorf("error encoding chat message: %w", err)
+	}
+	resp, err := n.httpClient.Post(endpoint, "application/json", bytes.NewReader(raw))
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			// incoming webhook urls carry their credentials in the path
+			err = urlErr.Err
+		}
+		return 0, fmt.Errorf("error sending chat message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, webhookErrorBodyLimit))
+		return resp.StatusCode, fmt.Errorf("chat webhook responded with status %d: %s", resp.StatusCode, string(detail))
+	}
+	return resp.StatusCode, nil
+}
+
+// proposalStateColor picks the accent color used by chat cards for a proposal state
+func proposalStateColor(state string) int {
+	switch dbmodels.ProposalState(strings.ToUpper(state)) {
+	case dbmodels.ProposalStateActive:
+		return 0x3b82f6
+	case dbmodels.ProposalStateSucceeded, dbmodels.ProposalStateExecuted:
+		return 0x22c55e
+	case dbmodels.ProposalStateQueued:
+		return 0xa855f7
+	case dbmodels.ProposalStateDefeated, dbmodels.ProposalStateCanceled, dbmodels.ProposalStateExpired:
+		return 0xef4444
+	default:
+		return 0x6b7280
+	}
+}
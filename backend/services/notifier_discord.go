@@ -0,0 +1,111 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ringecosystem/degov-square/internal/utils"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+const (
+	discordTitleLimit       = 256
+	discordDescriptionLimit = 4096
+	discordFieldValueLimit  = 1024
+)
+
+type discordWebhookMessage struct {
+	Username        string                 `json:"username,omitempty"`
+	AvatarURL       string                 `json:"avatar_url,omitempty"`
+	Embeds          []discordEmbed         `json:"embeds"`
+	AllowedMentions discordAllowedMentions `json:"allowed_mentions"`
+}
+
+type discordAllowedMentions struct {
+	Parse []string `json:"parse"`
+}
+
+type discordEmbed struct {
+	Title       string              `json:"title,omitempty"`
+	URL         string              `json:"url,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Color       int                 `json:"color"`
+	Author      *discordEmbedAuthor `json:"author,omitempty"`
+	Fields      []discordEmbedField `json:"fields,omitempty"`
+	Footer      *discordEmbedFooter `json:"footer,omitempty"`
+	Timestamp   string              `json:"timestamp,omitempty"`
+}
+
+type discordEmbedAuthor struct {
+	Name    string `json:"name"`
+	IconURL string `json:"icon_url,omitempty"`
+}
+
+type discordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type discordEmbedFooter struct {
+	Text    string `json:"text"`
+	IconURL string `json:"icon_url,omitempty"`
+}
+
+func (n *NotifierService) notifyUseDiscord(input types.NotifyInput) (*types.NotifyOutput, error) {
+	if input.Template == nil {
+		return nil, errors.New("discord notification requires template output")
+	}
+	statusCode, err := n.postChatWebhook(input.To, n.buildDiscordMessage(input.Template))
+	if err != nil {
+		return &types.NotifyOutput{StatusCode: statusCode}, fmt.Errorf("error sending discord message: %w", err)
+	}
+	return &types.NotifyOutput{StatusCode: statusCode}, nil
+}
+
+func (n *NotifierService) buildDiscordMessage(template *types.TemplateOutput) discordWebhookMessage {
+	embed := discordEmbed{
+		Title:     utils.TruncateText(template.Title, discordTitleLimit),
+		Color:     proposalStateColor(""),
+		Timestamp: n.now().UTC().Format(time.RFC3339),
+	}
+	if n.config.SiteName != "" {
+		embed.Footer = &discordEmbedFooter{Text: n.config.SiteName, IconURL: n.config.SiteLogo}
+	}
+
+	summary := template.Summary
+	if summary == nil {
+		// messages without a proposal, such as verification codes, are sent as plain markdown
+		embed.Description = utils.TruncateText(template.PlainTextContent, discordDescriptionLimit)
+	} else {
+		embed.Title = utils.TruncateText(summary.ProposalTitle, discordTitleLimit)
+		embed.URL = summary.ProposalLink
+		embed.Description = fmt.Sprintf("**%s** · %s", summary.Headline, summary.DaoName)
+		embed.Color = proposalStateColor(summary.ProposalState)
+		embed.Author = &discordEmbedAuthor{Name: summary.DaoName, IconURL: summary.DaoLogo}
+		embed.Fields = append(embed.Fields, discordEmbedField{Name: "State", Value: summary.ProposalState, Inline: true})
+		if votes := summary.Votes; votes != nil {
+			embed.Fields = append(embed.Fields,
+				discordEmbedField{Name: "For", Value: fmt.Sprintf("%s (%s)", votes.For, utils.FormatPercent(votes.PercentFor)), Inline: true},
+				discordEmbedField{Name: "Against", Value: fmt.Sprintf("%s (%s)", votes.Against, utils.FormatPercent(votes.PercentAgainst)), Inline: true},
+				discordEmbedField{Name: "Abstain", Value: fmt.Sprintf("%s (%s)", votes.Abstain, utils.FormatPercent(votes.PercentAbstain)), Inline: true},
+				discordEmbedField{Name: "Quorum", Value: fmt.Sprintf("%s / %s (%s)", votes.Total, votes.Quorum, utils.FormatPercent(votes.PercentQuorum)), Inline: true},
+			)
+		}
+		if summary.ProposalLink != "" {
+			embed.Fields = append(embed.Fields, discordEmbedField{
+				Name:  "Link",
+				Value: utils.TruncateText(fmt.Sprintf("[View proposal](%s)", summary.ProposalLink), discordFieldValueLimit),
+			})
+		}
+	}
+
+	return discordWebhookMessage{
+		Username:  n.config.SiteName,
+		AvatarURL: n.config.SiteLogo,
+		Embeds:    []discordEmbed{embed},
+		// proposal titles are user supplied, never let them ping @everyone
+		AllowedMentions: discordAllowedMentions{Parse: []string{}},
+	}
+}
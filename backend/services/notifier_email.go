@@ -0,0 +1,307 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ringecosystem/degov-square/internal/config"
+	"github.com/ringecosystem/degov-square/types"
+	"github.com/sendgrid/sendgrid-go"
+	sgmail "github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+const (
+	EmailProviderSendGrid = "sendgrid"
+	EmailProviderSMTP     = "smtp"
+
+	SMTPTLSModeStartTLS = "starttls"
+	SMTPTLSModeImplicit = "tls"
+	SMTPTLSModeNone     = "none"
+)
+
+// emailMessage is a rendered email, independent of the provider delivering it
+type emailMessage struct {
+	// FromName overrides the from name of the provider when set
+	FromName  string
+	To        string
+	Subject   string
+	PlainText string
+	HTML      string
+	Headers   map[string]string
+}
+
+type emailProvider interface {
+	Send(message emailMessage) (*types.NotifyOutput, error)
+}
+
+// newEmailProvider returns the provider chosen by NOTIFICATION_EMAIL_PROVIDER, nil when it is not configured
+func newEmailProvider(cfg *config.Config) emailProvider {
+	switch provider := strings.ToLower(cfg.GetString("NOTIFICATION_EMAIL_PROVIDER")); provider {
+	case EmailProviderSMTP:
+		if cfg.GetString("SMTP_HOST") == "" {
+			return nil
+		}
+		return &smtpEmailProvider{
+			host:        cfg.GetString("SMTP_HOST"),
+			port:        cfg.GetInt("SMTP_PORT"),
+			username:    cfg.GetString("SMTP_USERNAME"),
+			password:    cfg.GetString("SMTP_PASSWORD"),
+			tlsMode:     strings.ToLower(cfg.GetString("SMTP_TLS_MODE")),
+			timeout:     cfg.GetDuration("SMTP_TIMEOUT"),
+			fromName:    cfg.GetString("SMTP_FROM_USER"),
+			fromAddress: cfg.GetString("SMTP_FROM_EMAIL"),
+			now:         time.Now,
+		}
+	case EmailProviderSendGrid, "":
+		if cfg.GetString("SENDGRID_API_KEY") == "" {
+			return nil
+		}
+		return &sendgridEmailProvider{
+			apiKey:      cfg.GetString("SENDGRID_API_KEY"),
+			fromName:    cfg.GetString("SENDGRID_FROM_USER"),
+			fromAddress: cfg.GetString("SENDGRID_FROM_EMAIL"),
+		}
+	default:
+		slog.Warn("Unsupported email provider, emails are not sent", "provider", provider)
+		return nil
+	}
+}
+
+func (n *NotifierService) notifyUseEmail(input types.NotifyInput) (*types.NotifyOutput, error) {
+	template := input.Template
+	message := emailMessage{
+		FromName:  template.SenderName,
+		To:        input.To,
+		Subject:   template.Title,
+		PlainText: template.PlainTextContent,
+		HTML:      template.RichTextContent,
+		Headers:   map[string]string{},
+	}
+	if template.UnsubscribeURL != "" {
+		// RFC 8058 one-click unsubscribe, mail clients POST List-Unsubscribe=One-Click to the link
+		message.Headers["List-Unsubscribe"] = "<" + template.UnsubscribeURL + ">"
+		message.Headers["List-Unsubscribe-Post"] = "List-Unsubscribe=One-Click"
+	}
+
+	output, err := n.emailProvider.Send(message)
+	if err != nil {
+		slog.Error("Failed to send notification", "error", err)
+		return output, err
+	}
+	slog.Info("Notification sent successfully", "to", input.To, "status_code", output.StatusCode)
+	return output, nil
+}
+
+func fromName(message emailMessage, configured string) string {
+	if message.FromName != "" {
+		return message.FromName
+	}
+	return configured
+}
+
+type sendgridEmailProvider struct {
+	apiKey      string
+	fromName    string
+	fromAddress string
+}
+
+func (p *sendgridEmailProvider) Send(message emailMessage) (*types.NotifyOutput, error) {
+	from := sgmail.NewEmail(fromName(message, p.fromName), p.fromAddress)
+	name, _, _ := strings.Cut(message.To, "@")
+	to := sgmail.NewEmail(name, message.To)
+	sgMessage := sgmail.NewSingleEmail(from, message.Subject, to, message.PlainText, message.HTML)
+	for key, value := range message.Headers {
+		sgMessage.SetHeader(key, value)
+	}
+	response, err := sendgrid.NewSendClient(p.apiKey).Send(sgMessage)
+	if err != nil {
+		return nil, err
+	}
+	output := &types.NotifyOutput{StatusCode: response.StatusCode}
+	if response.StatusCode >= 300 {
+		return output, fmt.Errorf("sendgrid responded with status %d: %s", response.StatusCode, response.Body)
+	}
+	return output, nil
+}
+
+// smtpEmailProvider delivers through a mail relay, STARTTLS upgrades a plain connection and tls connects with implicit TLS (usually port 465)
+type smtpEmailProvider struct {
+	host        string
+	port        int
+	username    string
+	password    string
+	tlsMode     string
+	timeout     time.Duration
+	fromName    string
+	fromAddress string
+	now         func() time.Time
+}
+
+func (p *smtpEmailProvider) Send(message emailMessage) (*types.NotifyOutput, error) {
+	body, err := buildMIMEMessage(mail.Address{Name: fromName(message, p.fromName), Address: p.fromAddress}, message, p.now())
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := p.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	if p.username != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return nil, errors.New("smtp server does not support AUTH")
+		}
+		// PlainAuth refuses to send credentials without TLS unless the relay is on localhost
+		if err := client.Auth(smtp.PlainAuth("", p.username, p.password, p.host)); err != nil {
+			return nil, fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	if err := client.Mail(p.fromAddress); err != nil {
+		return nil, fmt.Errorf("smtp MAIL FROM: %w", err)
+	}
+	if err := client.Rcpt(message.To); err != nil {
+		return smtpOutput(err), fmt.Errorf("smtp RCPT TO: %w", err)
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return smtpOutput(err), fmt.Errorf("smtp DATA: %w", err)
+	}
+	if _, err := writer.Write(body); err != nil {
+		return nil, fmt.Errorf("smtp write message: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return smtpOutput(err), fmt.Errorf("smtp message rejected: %w", err)
+	}
+	if err := client.Quit(); err != nil {
+		slog.Warn("Failed to close smtp session", "host", p.host, "error", err)
+	}
+	return &types.NotifyOutput{StatusCode: 250}, nil
+}
+
+func (p *smtpEmailProvider) dial() (*smtp.Client, error) {
+	addr := net.JoinHostPort(p.host, strconv.Itoa(p.port))
+	dialer := &net.Dialer{Timeout: p.timeout}
+	tlsConfig := &tls.Config{ServerName: p.host, MinVersion: tls.VersionTLS12}
+
+	var conn net.Conn
+	var err error
+	if p.tlsMode == SMTPTLSModeImplicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("smtp connect %s: %w", addr, err)
+	}
+	if p.timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(p.timeout))
+	}
+
+	client, err := smtp.NewClient(conn, p.host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("smtp handshake: %w", err)
+	}
+	// anything but tls and none upgrades with STARTTLS, a typo must not send credentials in clear text
+	if p.tlsMode != SMTPTLSModeImplicit && p.tlsMode != SMTPTLSModeNone {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, errors.New("smtp server does not support STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	return client, nil
+}
+
+// smtpOutput keeps the reply code of a rejected command, so permanent failures are visible on the delivery
+func smtpOutput(err error) *types.NotifyOutput {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return &types.NotifyOutput{StatusCode: protoErr.Code}
+	}
+	return nil
+}
+
+// buildMIMEMessage renders a multipart/alternative message with quoted-printable plain text and HTML parts
+func buildMIMEMessage(from mail.Address, message emailMessage, date time.Time) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", message.PlainText},
+		{"text/html; charset=utf-8", message.HTML},
+	} {
+		writer, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(writer)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	messageID := make([]byte, 16)
+	if _, err := rand.Read(messageID); err != nil {
+		return nil, err
+	}
+	_, domain, _ := strings.Cut(from.Address, "@")
+	headers := map[string]string{
+		"From":         from.String(),
+		"To":           (&mail.Address{Address: message.To}).String(),
+		"Subject":      mime.QEncoding.Encode("utf-8", message.Subject),
+		"Date":         date.Format(time.RFC1123Z),
+		"Message-ID":   "<" + hex.EncodeToString(messageID) + "@" + domain + ">",
+		"MIME-Version": "1.0",
+		"Content-Type": "multipart/alternative; boundary=" + parts.Boundary(),
+	}
+	for key, value := range message.Headers {
+		headers[textproto.CanonicalMIMEHeaderKey(key)] = value
+	}
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var out bytes.Buffer
+	for _, key := range keys {
+		value := strings.NewReplacer("\r", "", "\n", "").Replace(headers[key])
+		fmt.Fprintf(&out, "%s: %s\r\n", key, value)
+	}
+	out.WriteString("\r\n")
+	out.Write(body.Bytes())
+	return out.Bytes(), nil
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ringecosystem/degov-square/internal/utils"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+const (
+	slackHeaderLimit  = 150
+	slackSectionLimit = 3000
+)
+
+var (
+	slackEscaper         = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	slackMarkdownBold    = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	slackMarkdownLink    = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	slackMarkdownHeading = regexp.MustCompile(`(?m)^#{1,6}\s+(.+)$`)
+)
+
+type slackWebhookMessage struct {
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type     string         `json:"type"`
+	Text     *slackText     `json:"text,omitempty"`
+	Fields   []slackText    `json:"fields,omitempty"`
+	Elements []slackElement `json:"elements,omitempty"`
+}
+
+type slackText struct {
+	Type  string `json:"type"`
+	Text  string `json:"text"`
+	Emoji bool   `json:"emoji,omitempty"`
+}
+
+type slackElement struct {
+	Type string `json:"type"`
+	// Text is a text object for buttons and a plain string for context elements
+	Text     any    `json:"text,omitempty"`
+	URL      string `json:"url,omitempty"`
+	ImageURL string `json:"image_url,omitempty"`
+	AltText  string `json:"alt_text,omitempty"`
+	Style    string `json:"style,omitempty"`
+}
+
+func (n *NotifierService) notifyUseSlack(input types.NotifyInput) (*types.NotifyOutput, error) {
+	if input.Template == nil {
+		return nil, errors.New("slack notification requires template output")
+	}
+	statusCode, err := n.postChatWebhook(input.To, buildSlackMessage(input.Template))
+	if err != nil {
+		return &types.NotifyOutput{StatusCode: statusCode}, fmt.Errorf("error sending slack message: %w", err)
+	}
+	return &types.NotifyOutput{StatusCode: statusCode}, nil
+}
+
+func buildSlackMessage(template *types.TemplateOutput) slackWebhookMessage {
+	message := slackWebhookMessage{
+		// text is what Slack shows in push notifications and clients without block support
+		Text: slackEscaper.Replace(template.Title),
+	}
+
+	summary := template.Summary
+	if summary == nil {
+		message.Blocks = []slackBlock{
+			{Type: "header", Text: &slackText{Type: "plain_text", Text: utils.TruncateText(template.Title, slackHeaderLimit), Emoji: true}},
+			{Type: "section", Text: &slackText{Type: "mrkdwn", Text: utils.TruncateText(markdownToSlack(template.PlainTextContent), slackSectionLimit)}},
+		}
+		return message
+	}
+
+	context := slackBlock{Type: "context"}
+	if summary.DaoLogo != "" {
+		context.Elements = append(context.Elements, slackElement{Type: "image", ImageURL: summary.DaoLogo, AltText: summary.DaoName})
+	}
+	context.Elements = append(context.Elements, slackElement{
+		Type: "mrkdwn",
+		Text: fmt.Sprintf("*%s* · %s", slackEscaper.Replace(summary.Headline), slackEscaper.Replace(summary.DaoName)),
+	})
+
+	details := slackBlock{
+		Type:   "section",
+		Fields: []slackText{{Type: "mrkdwn", Text: "*State*\n" + slackEscaper.Replace(summary.ProposalState)}},
+	}
+	if votes := summary.Votes; votes != nil {
+		details.Fields = append(details.Fields,
+			slackText{Type: "mrkdwn", Text: fmt.Sprintf("*Quorum*\n%s / %s (%s)", votes.Total, votes.Quorum, utils.FormatPercent(votes.PercentQuorum))},
+			slackText{Type: "mrkdwn", Text: fmt.Sprintf("*For*\n%s (%s)", votes.For, utils.FormatPercent(votes.PercentFor))},
+			slackText{Type: "mrkdwn", Text: fmt.Sprintf("*Against*\n%s (%s)", votes.Against, utils.FormatPercent(votes.PercentAgainst))},
+			slackText{Type: "mrkdwn", Text: fmt.Sprintf("*Abstain*\n%s (%s)", votes.Abstain, utils.FormatPercent(votes.PercentAbstain))},
+		)
+	}
+
+	message.Blocks = []slackBlock{
+		{Type: "header", Text: &slackText{Type: "plain_text", Text: utils.TruncateText(summary.ProposalTitle, slackHeaderLimit), Emoji: true}},
+		context,
+		details,
+	}
+	if summary.ProposalLink != "" {
+		message.Blocks = append(message.Blocks, slackBlock{
+			Type: "actions",
+			Elements: []slackElement{{
+				Type:  "button",
+				Text:  &slackText{Type: "plain_text", Text: "View Proposal"},
+				URL:   summary.ProposalLink,
+				Style: "primary",
+			}},
+		})
+	}
+	return message
+}
+
+// markdownToSlack rewrites the subset of markdown used by the templates into Slack mrkdwn
+func markdownToSlack(md string) string {
+	text := slackEscaper.Replace(md)
+	text = slackMarkdownLink.ReplaceAllString(text, "<$2|$1>")
+	text = slackMarkdownBold.ReplaceAllString(text, "*$1*")
+	text = slackMarkdownHeading.ReplaceAllStringFunc(text, func(line string) string {
+		heading := slackMarkdownHeading.FindStringSubmatch(line)[1]
+		return "*" + strings.Trim(heading, "* ") + "*"
+	})
+	return strings.TrimSpace(text)
+}
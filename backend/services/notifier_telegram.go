@@ -0,0 +1,211 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/parser"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+const (
+	telegramMessageLimit = 4096
+	telegramParseMode    = "MarkdownV2"
+)
+
+var (
+	telegramSpecialChars = "_*[]()~`>#+-=|{}.!\\"
+	telegramBlankLines   = regexp.MustCompile(`\n{3,}`)
+)
+
+type telegramSendMessageRequest struct {
+	ChatID                string `json:"chat_id"`
+	Text                  string `json:"text"`
+	ParseMode             string `json:"parse_mode,omitempty"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview"`
+}
+
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+}
+
+func (n *NotifierService) notifyUseTelegram(input types.NotifyInput) (*types.NotifyOutput, error) {
+	if input.Template == nil {
+		return nil, errors.New("telegram notification requires template output")
+	}
+	return n.SendTelegramMessage(input.To, MarkdownToTelegramV2(input.Template.PlainTextContent))
+}
+
+// SendTelegramMessage sends text that is already formatted as MarkdownV2 to the given chat.
+func (n *NotifierService) SendTelegramMessage(chatID, text string) (*types.NotifyOutput, error) {
+	if n.config.TelegramBotToken == "" {
+		return nil, errors.New("telegram bot token is not configured")
+	}
+	raw, err := json.Marshal(telegramSendMessageRequest{
+		ChatID:                chatID,
+		Text:                  text,
+		ParseMode:             telegramParseMode,
+		DisableWebPagePreview: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding telegram message: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/bot%s/sendMessage", n.config.TelegramAPIBaseURL, n.config.TelegramBotToken)
+	resp, err := n.configuredClient.Post(endpoint, "application/json", bytes.NewReader(raw))
+	if err != nil {
+		// the request url contains the bot token, keep it out of stored errors
+		return nil, errors.New("error sending telegram message: request failed")
+	}
+	defer resp.Body.Close()
+
+	output := &types.NotifyOutput{StatusCode: resp.StatusCode}
+	var result telegramResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return output, fmt.Errorf("error decoding telegram response (status %d): %w", resp.StatusCode, err)
+	}
+	if !result.OK {
+		return output, fmt.Errorf("telegram responded with error %d: %s", result.ErrorCode, result.Description)
+	}
+	return output, nil
+}
+
+// EscapeTelegramText escapes plain text so it can be embedded in a MarkdownV2 message.
+func EscapeTelegramText(text string) string {
+	return escapeTelegram(text, telegramSpecialChars)
+}
+
+func escapeTelegram(text, specials string) string {
+	var builder strings.Builder
+	builder.Grow(len(text))
+	for _, r := range text {
+		if strings.ContainsRune(specials, r) {
+			builder.WriteByte('\\')
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// MarkdownToTelegramV2 converts the markdown produced by the notification templates into
+// Telegram MarkdownV2, escaping everything Telegram would otherwise reject.
+func MarkdownToTelegramV2(md string) string {
+	p := parser.NewWithExtensions(parser.CommonExtensions | parser.NoEmptyLineBeforeBlock)
+	doc := p.Parse([]byte(md))
+
+	renderer := &telegramRenderer{}
+	text := renderer.render(doc)
+	text = telegramBlankLines.ReplaceAllString(strings.TrimSpace(text), "\n\n")
+	return truncateTelegramMessage(text)
+}
+
+type telegramRenderer struct {
+	bold   int
+	italic int
+}
+
+func (r *telegramRenderer) children(node ast.Node) string {
+	var builder strings.Builder
+	for _, child := range node.GetChildren() {
+		builder.WriteString(r.render(child))
+	}
+	return builder.String()
+}
+
+func (r *telegramRenderer) wrap(marker string, depth *int, node ast.Node) string {
+	// Telegram does not allow nesting the same entity, only the outermost one emits markers
+	*depth++
+	content := r.children(node)
+	*depth--
+	if *depth > 0 || strings.TrimSpace(content) == "" {
+		return content
+	}
+	return marker + content + marker
+}
+
+func (r *telegramRenderer) render(node ast.Node) string {
+	switch n := node.(type) {
+	case *ast.Text:
+		return EscapeTelegramText(string(n.Literal))
+	case *ast.Softbreak, *ast.Hardbreak:
+		return "\n"
+	case *ast.HTMLSpan:
+		return EscapeTelegramText(string(n.Literal))
+	case *ast.HTMLBlock:
+		return EscapeTelegramText(string(n.Literal)) + "\n\n"
+	case *ast.Strong:
+		return r.wrap("*", &r.bold, n)
+	case *ast.Heading:
+		return r.wrap("*", &r.bold, n) + "\n\n"
+	case *ast.Emph:
+		return r.wrap("_", &r.italic, n)
+	case *ast.Del:
+		return "~" + r.children(n) + "~"
+	case *ast.Code:
+		return "`" + escapeTelegram(string(n.Literal), "`\\") + "`"
+	case *ast.CodeBlock:
+		return "```\n" + escapeTelegram(string(n.Literal), "`\\") + "```\n\n"
+	case *ast.Link:
+		label := r.children(n)
+		if strings.TrimSpace(label) == "" {
+			label = EscapeTelegramText(string(n.Destination))
+		}
+		return "[" + label + "](" + escapeTelegram(string(n.Destination), ")\\") + ")"
+	case *ast.Image:
+		return "[" + EscapeTelegramText("image") + "](" + escapeTelegram(string(n.Destination), ")\\") + ")"
+	case *ast.Paragraph:
+		if _, inList := n.Parent.(*ast.ListItem); inList {
+			return r.children(n) + "\n"
+		}
+		return r.children(n) + "\n\n"
+	case *ast.List:
+		var builder strings.Builder
+		index := n.Start
+		if index == 0 {
+			index = 1
+		}
+		for _, item := range n.Children {
+			bullet := "• "
+			if n.ListFlags&ast.ListTypeOrdered != 0 {
+				bullet = strconv.Itoa(index) + "\\. "
+				index++
+			}
+			builder.WriteString(bullet + strings.TrimSpace(r.render(item)) + "\n")
+		}
+		return builder.String() + "\n"
+	case *ast.BlockQuote:
+		lines := strings.Split(strings.TrimSpace(r.children(n)), "\n")
+		for i, line := range lines {
+			lines[i] = ">" + line
+		}
+		return strings.Join(lines, "\n") + "\n\n"
+	case *ast.HorizontalRule:
+		return EscapeTelegramText("---") + "\n\n"
+	default:
+		return r.children(node)
+	}
+}
+
+// truncateTelegramMessage keeps the message under the Bot API limit, cutting on a paragraph
+// boundary so no entity is left open.
+func truncateTelegramMessage(text string) string {
+	if utf8.RuneCountInString(text) <= telegramMessageLimit {
+		return text
+	}
+	suffix := "\n\n" + EscapeTelegramText("…")
+	runes := []rune(text)
+	cut := string(runes[:telegramMessageLimit-utf8.RuneCountInString(suffix)])
+	if idx := strings.LastIndex(cut, "\n\n"); idx > 0 {
+		cut = cut[:idx]
+	}
+	return cut + suffix
+}
@@ -0,0 +1,678 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	"github.com/ringecosystem/degov-square/internal/utils"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+func TestNotifyWebhookSignsVersionedRecordPayload(t *testing.T) {
+	secret := "whsec_test"
+	now := time.Unix(1750000000, 0)
+	var (
+		gotHeaders http.Header
+		gotBody    []byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeaders = r.Header.Clone()
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	notifier := newNotifierService(nil, server.Client(), notifierConfig{})
+	notifier.now = func() time.Time { return now }
+
+	record := &dbmodels.NotificationRecord{
+		Code:        "event-1_user-1",
+		EventID:     "event-1",
+		ChainID:     1,
+		DaoCode:     "demo",
+		Type:        dbmodels.SubscribeFeatureVoteEmitted,
+		ProposalID:  "0x01",
+		VoteID:      utils.StringPtr("vote-1"),
+		UserAddress: "0xabc",
+		CTime:       now,
+	}
+	_, err := notifier.Notify(types.NotifyInput{
+		Type:     dbmodels.NotificationChannelTypeWebhook,
+		To:       server.URL,
+		Template: &types.TemplateOutput{Title: "New vote", PlainTextContent: "Someone voted"},
+		Record:   record,
+		Payload:  utils.StringPtr(utils.ToJSON(WebhookChannelPayload{Secret: secret})),
+	})
+	if err != nil {
+		t.Fatalf("notify webhook: %v", err)
+	}
+
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	if got := gotHeaders.Get(WebhookHeaderTimestamp); got != timestamp {
+		t.Fatalf("timestamp header = %q, want %q", got, timestamp)
+	}
+	if got, want := gotHeaders.Get(WebhookHeaderSignature), SignWebhookPayload(secret, now.Unix(), gotBody); got != want {
+		t.Fatalf("signature header = %q, want %q", got, want)
+	}
+	if got, want := gotHeaders.Get(WebhookHeaderIdempotencyKey), WebhookIdempotencyKey(record.Code); got != want {
+		t.Fatalf("idempotency header = %q, want %q", got, want)
+	}
+
+	var body struct {
+		Version        int                     `json:"version"`
+		Event          string                  `json:"event"`
+		IdempotencyKey string                  `json:"idempotency_key"`
+		Data           webhookNotificationData `json:"data"`
+	}
+	if err := json.Unmarshal(gotBody, &body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body.Version != WebhookPayloadVersion || body.Event != webhookEventNotification {
+		t.Fatalf("unexpected envelope: %+v", body)
+	}
+	if body.Data.Code != record.Code || body.Data.Title != "New vote" || body.Data.VoteID == nil || *body.Data.VoteID != "vote-1" {
+		t.Fatalf("unexpected data: %+v", body.Data)
+	}
+}
+
+func TestNotifyWebhookRequiresSecretForRecords(t *testing.T) {
+	notifier := newNotifierService(nil, http.DefaultClient, notifierConfig{})
+	_, err := notifier.Notify(types.NotifyInput{
+		Type:     dbmodels.NotificationChannelTypeWebhook,
+		To:       "https://example.invalid/hook",
+		Template: &types.TemplateOutput{Title: "title"},
+		Record:   &dbmodels.NotificationRecord{Code: "code"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "signing secret") {
+		t.Fatalf("expected missing secret error, got %v", err)
+	}
+}
+
+func TestNotifyWebhookReturnsErrorOnNon2xx(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	notifier := newNotifierService(nil, server.Client(), notifierConfig{})
+	output, err := notifier.Notify(types.NotifyInput{
+		Type:     dbmodels.NotificationChannelTypeWebhook,
+		To:       server.URL,
+		Template: &types.TemplateOutput{Title: "otp", PlainTextContent: "123456"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "502") || output == nil || output.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected status error, got %v", err)
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	if err := ValidateWebhookURL("https://hooks.example.com/degov"); err != nil {
+		t.Fatalf("expected https url to be valid: %v", err)
+	}
+	for _, raw := range []string{"", "hooks.example.com", "ftp://hooks.example.com", "http://hooks.example.com"} {
+		if err := ValidateWebhookURL(raw); err == nil {
+			t.Fatalf("expected %q to be rejected", raw)
+		}
+	}
+}
+
+func TestPublicHTTPClientRefusesInternalAddresses(t *testing.T) {
+	for _, address := range []string{
+		"127.0.0.1", "10.0.0.8", "172.16.3.4", "192.168.1.1", "169.254.169.254", "100.100.100.200",
+		"0.0.0.0", "::1", "fe80::1", "fd00::1", "::ffff:127.0.0.1", "224.0.0.1",
+	} {
+		if isPublicAddr(netip.MustParseAddr(address)) {
+			t.Fatalf("expected %s to be refused", address)
+		}
+	}
+	for _, address := range []string{"1.1.1.1", "140.82.112.3", "2606:4700:4700::1111"} {
+		if !isPublicAddr(netip.MustParseAddr(address)) {
+			t.Fatalf("expected %s to be allowed", address)
+		}
+	}
+
+	var hits int
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer internal.Close()
+	// a host name resolving to loopback is refused on connect, as a rebound name would be
+	target := strings.Replace(internal.URL, "127.0.0.1", "localhost", 1)
+
+	notifier := newNotifierService(nil, newPublicHTTPClient(time.Second, false), notifierConfig{})
+	_, err := notifier.Notify(types.NotifyInput{
+		Type:     dbmodels.NotificationChannelTypeWebhook,
+		To:       target,
+		Template: &types.TemplateOutput{Title: "Hello"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "not a public address") || hits != 0 {
+		t.Fatalf("expected the loopback webhook to be refused, got %v after %d hits", err, hits)
+	}
+
+	notifier = newNotifierService(nil, newPublicHTTPClient(time.Second, true), notifierConfig{})
+	if _, err := notifier.Notify(types.NotifyInput{
+		Type:     dbmodels.NotificationChannelTypeWebhook,
+		To:       target,
+		Template: &types.TemplateOutput{Title: "Hello"},
+	}); err != nil || hits != 1 {
+		t.Fatalf("expected private networks to be allowed when configured, got %v after %d hits", err, hits)
+	}
+
+	// the Bot API is configured by the operator, a local stand-in is reached with the guarded client in place
+	botAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":1}}`))
+	}))
+	defer botAPI.Close()
+	notifier = newNotifierService(nil, newPublicHTTPClient(time.Second, false), notifierConfig{
+		TelegramAPIBaseURL: strings.Replace(botAPI.URL, "127.0.0.1", "localhost", 1),
+		TelegramBotToken:   "123:abc",
+	})
+	notifier.configuredClient = &http.Client{Timeout: time.Second}
+	if _, err := notifier.SendTelegramMessage("1", "hi"); err != nil {
+		t.Fatalf("expected the local Bot API to be reached, got %v", err)
+	}
+}
+
+func TestMarkdownToTelegramV2EscapesAndKeepsFormatting(t *testing.T) {
+	md := "## DeGov.AI\n\n---\n\nHello 0xabc_def,\n\n- **Title:** [Fund (v2).](https://example.com/p/1?a=b)\n- **Voting Ends:** July 1, 2025\n\n### **Proposal Details**\n\n> quoted #1\n"
+	got := MarkdownToTelegramV2(md)
+
+	for _, want := range []string{
+		"*DeGov\\.AI*",
+		"Hello 0xabc\\_def,",
+		"• *Title:* [Fund \\(v2\\)\\.](https://example.com/p/1?a=b)",
+		"• *Voting Ends:* July 1, 2025",
+		"*Proposal Details*",
+		">quoted \\#1",
+		"\\-\\-\\-",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("telegram markdown missing %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "**") {
+		t.Fatalf("nested bold must not produce empty entities:\n%s", got)
+	}
+}
+
+func TestNotifyTelegramPostsMarkdownV2ToConfiguredAPI(t *testing.T) {
+	var (
+		gotPath string
+		gotBody telegramSendMessageRequest
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		_ = json.NewDecoder(r.Body).Decode(&gotBody)
+		_, _ = w.Write([]byte(`{"ok":true,"result":{}}`))
+	}))
+	defer server.Close()
+
+	notifier := newNotifierService(nil, server.Client(), notifierConfig{
+		TelegramAPIBaseURL: server.URL,
+		TelegramBotToken:   "123:abc",
+	})
+	output, err := notifier.Notify(types.NotifyInput{
+		Type:     dbmodels.NotificationChannelTypeTelegram,
+		To:       "-1001",
+		Template: &types.TemplateOutput{Title: "Vote", PlainTextContent: "**Vote** ends soon!"},
+	})
+	if err != nil {
+		t.Fatalf("notify telegram: %v", err)
+	}
+	if output.StatusCode != http.StatusOK || gotPath != "/bot123:abc/sendMessage" {
+		t.Fatalf("unexpected request: status=%d path=%s", output.StatusCode, gotPath)
+	}
+	if gotBody.ChatID != "-1001" || gotBody.ParseMode != telegramParseMode || gotBody.Text != "*Vote* ends soon\\!" {
+		t.Fatalf("unexpected message: %+v", gotBody)
+	}
+}
+
+func TestNotifyTelegramReportsBotAPIErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`))
+	}))
+	defer server.Close()
+
+	notifier := newNotifierService(nil, server.Client(), notifierConfig{TelegramAPIBaseURL: server.URL, TelegramBotToken: "123:abc"})
+	output, err := notifier.SendTelegramMessage("1", "hi")
+	if err == nil || !strings.Contains(err.Error(), "blocked") || output.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected bot api error, got output=%+v err=%v", output, err)
+	}
+}
+
+func testProposalTemplate() *types.TemplateOutput {
+	return &types.TemplateOutput{
+		Title:            "[Demo] New Proposal: Fund <grants> & more",
+		PlainTextContent: "ignored",
+		Summary: &types.TemplateSummary{
+			DaoName:       "Demo",
+			ProposalTitle: "Fund <grants> & more",
+			ProposalLink:  "https://demo.degov.ai/proposal/1",
+			ProposalState: "ACTIVE",
+			Headline:      "New Proposal",
+			Votes: &types.TemplateVoteTally{
+				For: "1.2K", Against: "300", Abstain: "0", Total: "1.5K", Quorum: "1K",
+				PercentFor: 80, PercentAgainst: 20, PercentQuorum: 150,
+			},
+		},
+	}
+}
+
+func TestNotifyDiscordPostsProposalEmbed(t *testing.T) {
+	var got discordWebhookMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	notifier := newNotifierService(nil, server.Client(), notifierConfig{SiteName: "DeGov.AI"})
+	output, err := notifier.Notify(types.NotifyInput{
+		Type:     dbmodels.NotificationChannelTypeDiscord,
+		To:       server.URL,
+		Template: testProposalTemplate(),
+	})
+	if err != nil || output.StatusCode != http.StatusNoContent {
+		t.Fatalf("notify discord: output=%+v err=%v", output, err)
+	}
+	if len(got.Embeds) != 1 {
+		t.Fatalf("expected one embed, got %d", len(got.Embeds))
+	}
+	embed := got.Embeds[0]
+	if embed.Title != "Fund <grants> & more" || embed.URL != "https://demo.degov.ai/proposal/1" || embed.Color != proposalStateColor("ACTIVE") {
+		t.Fatalf("unexpected embed: %+v", embed)
+	}
+	fields := map[string]string{}
+	for _, field := range embed.Fields {
+		fields[field.Name] = field.Value
+	}
+	if fields["State"] != "ACTIVE" || fields["For"] != "1.2K (80.00%)" || fields["Quorum"] != "1.5K / 1K (150.00%)" {
+		t.Fatalf("unexpected fields: %+v", fields)
+	}
+	if got.AllowedMentions.Parse == nil || len(got.AllowedMentions.Parse) != 0 {
+		t.Fatalf("mentions must be disabled: %+v", got.AllowedMentions)
+	}
+}
+
+func TestBuildSlackMessageUsesBlockKit(t *testing.T) {
+	message := buildSlackMessage(testProposalTemplate())
+	if message.Text != "[Demo] New Proposal: Fund &lt;grants&gt; &amp; more" {
+		t.Fatalf("fallback text must be escaped, got %q", message.Text)
+	}
+	if len(message.Blocks) != 4 {
+		t.Fatalf("expected header, context, fields and actions blocks, got %d", len(message.Blocks))
+	}
+	header, context, details, actions := message.Blocks[0], message.Blocks[1], message.Blocks[2], message.Blocks[3]
+	if header.Type != "header" || header.Text.Text != "Fund <grants> & more" {
+		t.Fatalf("unexpected header: %+v", header.Text)
+	}
+	if context.Elements[0].Text != "*New Proposal* · Demo" {
+		t.Fatalf("unexpected context: %+v", context.Elements)
+	}
+	if len(details.Fields) != 5 || details.Fields[2].Text != "*For*\n1.2K (80.00%)" {
+		t.Fatalf("unexpected fields: %+v", details.Fields)
+	}
+	if actions.Elements[0].URL != "https://demo.degov.ai/proposal/1" {
+		t.Fatalf("unexpected actions: %+v", actions.Elements)
+	}
+
+	otp := buildSlackMessage(&types.TemplateOutput{Title: "Code", PlainTextContent: "### **Your code**\n\nUse **123456** on [DeGov](https://degov.ai)"})
+	if text := otp.Blocks[1].Text.Text; text != "*Your code*\n\nUse *123456* on <https://degov.ai|DeGov>" {
+		t.Fatalf("unexpected mrkdwn: %q", text)
+	}
+}
+
+func TestValidateChatWebhookURL(t *testing.T) {
+	valid := map[dbmodels.NotificationChannelType]string{
+		dbmodels.NotificationChannelTypeDiscord: "https://discord.com/api/webhooks/1/abc",
+		dbmodels.NotificationChannelTypeSlack:   "https://hooks.slack.com/services/T0/B0/xyz",
+	}
+	for channelType, raw := range valid {
+		if err := ValidateChatWebhookURL(channelType, raw); err != nil {
+			t.Fatalf("expected %s url to be valid: %v", channelType, err)
+		}
+	}
+	for _, raw := range []string{
+		"http://discord.com/api/webhooks/1/abc",
+		"https://discord.com.evil.io/api/webhooks/1/abc",
+		"https://discord.com/channels/1",
+		"https://hooks.slack.com/services/T0/B0/xyz",
+	} {
+		if err := ValidateChatWebhookURL(dbmodels.NotificationChannelTypeDiscord, raw); err == nil {
+			t.Fatalf("expected %q to be rejected for discord", raw)
+		}
+	}
+}
+
+// startTestSMTPServer accepts a single SMTP session and hands back the received DATA
+func startTestSMTPServer(t *testing.T, extensions []string) (string, int, <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := textproto.NewConn(conn)
+		_ = reader.PrintfLine("220 localhost ESMTP test")
+		for {
+			line, err := reader.ReadLine()
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch command {
+			case "EHLO":
+				lines := append([]string{"localhost"}, extensions...)
+				for i, ext := range lines {
+					separator := "-"
+					if i == len(lines)-1 {
+						separator = " "
+					}
+					_ = reader.PrintfLine("250%s%s", separator, ext)
+				}
+			case "AUTH":
+				_ = reader.PrintfLine("235 2.7.0 Authentication successful")
+			case "DATA":
+				_ = reader.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+				data, _ := reader.ReadDotBytes()
+				received <- string(data)
+				_ = reader.PrintfLine("250 2.0.0 Ok: queued")
+			case "QUIT":
+				_ = reader.PrintfLine("221 2.0.0 Bye")
+				return
+			default:
+				_ = reader.PrintfLine("250 2.0.0 Ok")
+			}
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, received
+}
+
+func TestNotifySMTPSendsMultipartMessage(t *testing.T) {
+	host, port, received := startTestSMTPServer(t, []string{"AUTH PLAIN"})
+	notifier := newNotifierService(nil, http.DefaultClient, notifierConfig{})
+	notifier.emailProvider = &smtpEmailProvider{
+		host:        host,
+		port:        port,
+		username:    "relay",
+		password:    "secret",
+		tlsMode:     SMTPTLSModeNone,
+		timeout:     5 * time.Second,
+		fromName:    "DeGov Notifications",
+		fromAddress: "notifications@degov.ai",
+		now:         func() time.Time { return time.Unix(1750000000, 0).UTC() },
+	}
+
+	output, err := notifier.Notify(types.NotifyInput{
+		Type: dbmodels.NotificationChannelTypeEmail,
+		To:   "voter@example.com",
+		Template: &types.TemplateOutput{
+			Title:            "[Demo] Vote ends – soon",
+			PlainTextContent: "Vote ends soon",
+			RichTextContent:  "<p>Vote ends soon</p>",
+			UnsubscribeURL:   "https://api.example.com/api/v1/notifications/unsubscribe?token=abc",
+		},
+	})
+	if err != nil {
+		t.Fatalf("notify smtp: %v", err)
+	}
+	if output.StatusCode != 250 {
+		t.Fatalf("unexpected status code %d", output.StatusCode)
+	}
+
+	message := <-received
+	for _, want := range []string{
+		"From: \"DeGov Notifications\" <notifications@degov.ai>",
+		"To: <voter@example.com>",
+		"Subject: =?utf-8?q?[Demo]_Vote_ends_=E2=80=93_soon?=",
+		"List-Unsubscribe: <https://api.example.com/api/v1/notifications/unsubscribe?token=abc>",
+		"List-Unsubscribe-Post: List-Unsubscribe=One-Click",
+		"Content-Type: multipart/alternative; boundary=",
+		"Content-Type: text/plain; charset=utf-8",
+		"Content-Type: text/html; charset=utf-8",
+		"<p>Vote ends soon</p>",
+	} {
+		if !strings.Contains(message, want) {
+			t.Fatalf("message missing %q in:\n%s", want, message)
+		}
+	}
+}
+
+func TestSMTPProviderRequiresStartTLS(t *testing.T) {
+	host, port, _ := startTestSMTPServer(t, nil)
+	provider := &smtpEmailProvider{host: host, port: port, tlsMode: SMTPTLSModeStartTLS, timeout: 5 * time.Second, now: time.Now}
+	if _, err := provider.Send(emailMessage{To: "voter@example.com"}); err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Fatalf("expected the missing STARTTLS to be reported, got %v", err)
+	}
+}
+
+func mustDecodeWebPushBase64(t *testing.T, value string) []byte {
+	t.Helper()
+	decoded, err := decodeWebPushBase64(value)
+	if err != nil {
+		t.Fatalf("decode %q: %v", value, err)
+	}
+	return decoded
+}
+
+// the example of RFC 8291 appendix A
+func TestEncryptWebPushPayloadMatchesRFC8291(t *testing.T) {
+	as, err := ecdh.P256().NewPrivateKey(mustDecodeWebPushBase64(t, "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"))
+	if err != nil {
+		t.Fatalf("parse application server key: %v", err)
+	}
+	keys, err := parseWebPushKeys(WebPushChannelPayload{
+		P256dh: "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4",
+		Auth:   "BTBZMqHH6r4Tts7J_aSIgg",
+	})
+	if err != nil {
+		t.Fatalf("parse subscription keys: %v", err)
+	}
+
+	body, err := encryptWebPushPayload([]byte("When I grow up, I want to be a watermelon"), keys, as, mustDecodeWebPushBase64(t, "DGv6ra1nlYgDCS1FRnbzlw"))
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	want := "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN"
+	if got := base64.RawURLEncoding.EncodeToString(body); got != want {
+		t.Fatalf("encrypted body = %s, want %s", got, want)
+	}
+}
+
+// decryptTestWebPushPayload decrypts an aes128gcm body the way the browser does
+func decryptTestWebPushPayload(t *testing.T, body []byte, ua *ecdh.PrivateKey, auth []byte) []byte {
+	t.Helper()
+	if len(body) < 86 || binary.BigEndian.Uint32(body[16:20]) != webPushRecordSize || body[20] != 65 {
+		t.Fatalf("unexpected aes128gcm header %x", body[:min(len(body), 86)])
+	}
+	salt, asPublic := body[:16], body[21:86]
+	as, err := ecdh.P256().NewPublicKey(asPublic)
+	if err != nil {
+		t.Fatalf("parse application server key: %v", err)
+	}
+	secret, _ := ua.ECDH(as)
+	prkKey, _ := hkdf.Extract(sha256.New, secret, auth)
+	ikm, _ := hkdf.Expand(sha256.New, prkKey, "WebPush: info\x00"+string(ua.PublicKey().Bytes())+string(asPublic), 32)
+	prk, _ := hkdf.Extract(sha256.New, ikm, salt)
+	cek, _ := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	nonce, _ := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	block, _ := aes.NewCipher(cek)
+	gcm, _ := cipher.NewGCM(block)
+	plaintext, err := gcm.Open(nil, nonce, body[86:], nil)
+	if err != nil || len(plaintext) == 0 || plaintext[len(plaintext)-1] != 0x02 {
+		t.Fatalf("decrypt web push body: %v", err)
+	}
+	return plaintext[:len(plaintext)-1]
+}
+
+func TestNotifyWebPushSendsVAPIDSignedEncryptedMessage(t *testing.T) {
+	vapid, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate vapid key: %v", err)
+	}
+	vapidPrivate, _ := vapid.Bytes()
+	vapidPublic, _ := vapid.PublicKey.Bytes()
+	ua, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate subscriber key: %v", err)
+	}
+	auth := []byte("0123456789abcdef")
+
+	var (
+		gotHeaders http.Header
+		gotBody    []byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeaders = r.Header.Clone()
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	now := time.Unix(1750000000, 0)
+	notifier := newNotifierService(nil, server.Client(), notifierConfig{
+		SiteLogo:               "https://square.degov.ai/logo.png",
+		WebPushVAPIDPublicKey:  base64.RawURLEncoding.EncodeToString(vapidPublic),
+		WebPushVAPIDPrivateKey: base64.RawURLEncoding.EncodeToString(vapidPrivate),
+		WebPushSubject:         "mailto:ops@degov.ai",
+		WebPushTTL:             time.Hour,
+	})
+	notifier.now = func() time.Time { return now }
+	output, err := notifier.Notify(types.NotifyInput{
+		Type:     dbmodels.NotificationChannelTypeWebPush,
+		To:       server.URL + "/push/abc",
+		Template: testProposalTemplate(),
+		Record:   &dbmodels.NotificationRecord{Code: "event-1_user-1"},
+		Payload: utils.StringPtr(utils.ToJSON(WebPushChannelPayload{
+			P256dh: base64.RawURLEncoding.EncodeToString(ua.PublicKey().Bytes()),
+			Auth:   base64.URLEncoding.EncodeToString(auth),
+		})),
+	})
+	if err != nil || output.StatusCode != http.StatusCreated {
+		t.Fatalf("notify web push: output=%+v err=%v", output, err)
+	}
+	if gotHeaders.Get("Content-Encoding") != "aes128gcm" || gotHeaders.Get("TTL") != "3600" {
+		t.Fatalf("unexpected headers: %+v", gotHeaders)
+	}
+
+	var token, key string
+	for _, part := range strings.Split(strings.TrimPrefix(gotHeaders.Get("Authorization"), "vapid "), ", ") {
+		name, value, _ := strings.Cut(part, "=")
+		switch name {
+		case "t":
+			token = value
+		case "k":
+			key = value
+		}
+	}
+	if key != base64.RawURLEncoding.EncodeToString(vapidPublic) {
+		t.Fatalf("unexpected vapid key %q", key)
+	}
+	segments := strings.Split(token, ".")
+	if len(segments) != 3 {
+		t.Fatalf("unexpected vapid token %q", token)
+	}
+	signature := mustDecodeWebPushBase64(t, segments[2])
+	digest := sha256.Sum256([]byte(segments[0] + "." + segments[1]))
+	if len(signature) != 64 || !ecdsa.Verify(&vapid.PublicKey, digest[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])) {
+		t.Fatalf("vapid token signature does not verify")
+	}
+	var claims struct {
+		Aud string `json:"aud"`
+		Exp int64  `json:"exp"`
+		Sub string `json:"sub"`
+	}
+	if err := json.Unmarshal(mustDecodeWebPushBase64(t, segments[1]), &claims); err != nil {
+		t.Fatalf("decode claims: %v", err)
+	}
+	if claims.Aud != server.URL || claims.Sub != "mailto:ops@degov.ai" || claims.Exp != now.Add(webPushTokenLifetime).Unix() {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+
+	var message webPushMessage
+	if err := json.Unmarshal(decryptTestWebPushPayload(t, gotBody, ua, auth), &message); err != nil {
+		t.Fatalf("decode message: %v", err)
+	}
+	if message.Title != "[Demo] New Proposal: Fund <grants> & more" || message.Body != "New Proposal: Fund <grants> & more" ||
+		message.URL != "https://demo.degov.ai/proposal/1" || message.Icon != "https://square.degov.ai/logo.png" || message.Tag != "event-1_user-1" {
+		t.Fatalf("unexpected message: %+v", message)
+	}
+}
+
+func TestNotifyWebPushReportsExpiredSubscription(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer server.Close()
+
+	vapid, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	vapidPrivate, _ := vapid.Bytes()
+	ua, _ := ecdh.P256().GenerateKey(rand.Reader)
+	notifier := newNotifierService(nil, server.Client(), notifierConfig{
+		WebPushVAPIDPublicKey:  "public",
+		WebPushVAPIDPrivateKey: base64.RawURLEncoding.EncodeToString(vapidPrivate),
+	})
+	output, err := notifier.Notify(types.NotifyInput{
+		Type:     dbmodels.NotificationChannelTypeWebPush,
+		To:       server.URL,
+		Template: &types.TemplateOutput{Title: "Verify", PlainTextContent: "Your code is 123456"},
+		Payload: utils.StringPtr(utils.ToJSON(WebPushChannelPayload{
+			P256dh: base64.RawURLEncoding.EncodeToString(ua.PublicKey().Bytes()),
+			Auth:   "BTBZMqHH6r4Tts7J_aSIgg",
+		})),
+	})
+	if !errors.Is(err, ErrNotificationChannelGone) || output == nil || output.StatusCode != http.StatusGone || !strings.Contains(err.Error(), "expired") {
+		t.Fatalf("expected an expired subscription error, got output=%+v err=%v", output, err)
+	}
+}
+
+func TestParseWebPushSubscription(t *testing.T) {
+	valid := `{"endpoint":"https://fcm.googleapis.com/fcm/send/abc","expirationTime":null,"keys":{"p256dh":"BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4","auth":"BTBZMqHH6r4Tts7J_aSIgg"}}`
+	if subscription, err := ParseWebPushSubscription(valid); err != nil || subscription.Endpoint != "https://fcm.googleapis.com/fcm/send/abc" {
+		t.Fatalf("parse valid subscription: %+v %v", subscription, err)
+	}
+	for _, raw := range []string{
+		"https://fcm.googleapis.com/fcm/send/abc",
+		`{"endpoint":"ftp://push.example.com/abc","keys":{"p256dh":"BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4","auth":"BTBZMqHH6r4Tts7J_aSIgg"}}`,
+		`{"endpoint":"https://fcm.googleapis.com/fcm/send/abc","keys":{"p256dh":"AAAA","auth":"BTBZMqHH6r4Tts7J_aSIgg"}}`,
+		`{"endpoint":"https://fcm.googleapis.com/fcm/send/abc","keys":{"p256dh":"BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4","auth":"short"}}`,
+	} {
+		if _, err := ParseWebPushSubscription(raw); err == nil {
+			t.Fatalf("expected %s to be rejected", raw)
+		}
+	}
+}
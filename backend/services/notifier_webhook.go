@@ -0,0 +1,190 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ringecosystem/degov-square/internal/config"
+	"github.com/ringecosystem/degov-square/internal/utils"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+const (
+	// WebhookPayloadVersion is bumped whenever the webhook body changes incompatibly.
+	WebhookPayloadVersion = 1
+
+	WebhookHeaderSignature      = "X-DeGov-Signature"
+	WebhookHeaderTimestamp      = "X-DeGov-Timestamp"
+	WebhookHeaderIdempotencyKey = "X-DeGov-Idempotency-Key"
+	WebhookHeaderEvent          = "X-DeGov-Event"
+
+	webhookEventNotification = "notification"
+	webhookEventMessage      = "message"
+	webhookSecretPrefix      = "whsec_"
+	webhookErrorBodyLimit    = 512
+)
+
+// WebhookChannelPayload is stored as JSON in NotificationChannel.Payload for webhook channels.
+type WebhookChannelPayload struct {
+	Secret string `json:"secret"`
+}
+
+type webhookBody struct {
+	Version        int    `json:"version"`
+	Event          string `json:"event"`
+	IdempotencyKey string `json:"idempotency_key"`
+	Timestamp      int64  `json:"timestamp"`
+	Data           any    `json:"data"`
+}
+
+type webhookNotificationData struct {
+	Code        string    `json:"code"`
+	EventID     string    `json:"event_id"`
+	Type        string    `json:"type"`
+	ChainID     int       `json:"chain_id"`
+	DaoCode     string    `json:"dao_code"`
+	ProposalID  string    `json:"proposal_id"`
+	VoteID      *string   `json:"vote_id,omitempty"`
+	UserAddress string    `json:"user_address"`
+	Title       string    `json:"title"`
+	Content     string    `json:"content"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type webhookMessageData struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+// NewWebhookSecret generates a signing secret for a newly verified webhook channel.
+func NewWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("error generating webhook secret: %w", err)
+	}
+	return webhookSecretPrefix + hex.EncodeToString(buf), nil
+}
+
+// ValidateWebhookURL only accepts absolute https URLs; plain http is allowed in development.
+func ValidateWebhookURL(raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" {
+		return errors.New("webhook url must be an absolute url")
+	}
+	switch parsed.Scheme {
+	case "https":
+		return nil
+	case "http":
+		if config.GetAppEnv().IsDevelopment() {
+			return nil
+		}
+	}
+	return errors.New("webhook url must use https")
+}
+
+// WebhookIdempotencyKey derives a stable key from the record code so receivers can drop retried deliveries.
+func WebhookIdempotencyKey(recordCode string) string {
+	sum := sha256.Sum256([]byte(recordCode))
+	return hex.EncodeToString(sum[:16])
+}
+
+// SignWebhookPayload signs "<timestamp>.<body>" with HMAC-SHA256 and returns the header value.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func parseWebhookChannelPayload(payload *string) WebhookChannelPayload {
+	var result WebhookChannelPayload
+	if payload == nil || *payload == "" {
+		return result
+	}
+	_ = json.Unmarshal([]byte(*payload), &result)
+	return result
+}
+
+func (n *NotifierService) notifyUseWebhook(input types.NotifyInput) (*types.NotifyOutput, error) {
+	if input.Template == nil {
+		return nil, errors.New("webhook notification requires template output")
+	}
+	timestamp := n.now().Unix()
+	secret := parseWebhookChannelPayload(input.Payload).Secret
+
+	body := webhookBody{
+		Version:   WebhookPayloadVersion,
+		Timestamp: timestamp,
+	}
+	if record := input.Record; record != nil {
+		// Notification records must always be signed; only the verification message is sent before a secret exists.
+		if secret == "" {
+			return nil, errors.New("webhook channel has no signing secret")
+		}
+		body.Event = webhookEventNotification
+		body.IdempotencyKey = WebhookIdempotencyKey(record.Code)
+		body.Data = webhookNotificationData{
+			Code:        record.Code,
+			EventID:     record.EventID,
+			Type:        string(record.Type),
+			ChainID:     record.ChainID,
+			DaoCode:     record.DaoCode,
+			ProposalID:  record.ProposalID,
+			VoteID:      record.VoteID,
+			UserAddress: record.UserAddress,
+			Title:       input.Template.Title,
+			Content:     input.Template.PlainTextContent,
+			CreatedAt:   record.CTime,
+		}
+	} else {
+		body.Event = webhookEventMessage
+		body.IdempotencyKey = utils.NextIDString()
+		body.Data = webhookMessageData{
+			Title:   input.Template.Title,
+			Content: input.Template.PlainTextContent,
+		}
+	}
+
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding webhook body: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, input.To, bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("error building webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "DeGov-Webhook/"+strconv.Itoa(WebhookPayloadVersion))
+	req.Header.Set(WebhookHeaderEvent, body.Event)
+	req.Header.Set(WebhookHeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookHeaderIdempotencyKey, body.IdempotencyKey)
+	if secret != "" {
+		req.Header.Set(WebhookHeaderSignature, SignWebhookPayload(secret, timestamp, raw))
+	}
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	output := &types.NotifyOutput{StatusCode: resp.StatusCode}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, webhookErrorBodyLimit))
+		return output, fmt.Errorf("webhook responded with status %d: %s", resp.StatusCode, string(detail))
+	}
+	return output, nil
+}
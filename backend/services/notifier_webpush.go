@@ -0,0 +1,290 @@
+package services
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ringecosystem/degov-square/internal/utils"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+const (
+	// webPushRecordSize is the aes128gcm record size, push services accept at most 4096 bytes of payload
+	webPushRecordSize = 4096
+	// webPushPlaintextLimit leaves room for the header (86 bytes), the padding delimiter and the GCM tag
+	webPushPlaintextLimit = webPushRecordSize - 86 - 1 - 16
+	webPushBodyLimit      = 512
+	webPushTokenLifetime  = 12 * time.Hour
+)
+
+// WebPushChannelPayload is stored as JSON in NotificationChannel.Payload for web push channels, the channel value is
+// the subscription endpoint.
+type WebPushChannelPayload struct {
+	P256dh string `json:"p256dh"`
+	Auth   string `json:"auth"`
+}
+
+// WebPushSubscription is the JSON form of a browser PushSubscription, as returned by PushSubscription.toJSON().
+type WebPushSubscription struct {
+	Endpoint string                `json:"endpoint"`
+	Keys     WebPushChannelPayload `json:"keys"`
+}
+
+// webPushMessage is the decrypted payload the service worker of the web app shows as a notification
+type webPushMessage struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	URL   string `json:"url,omitempty"`
+	Icon  string `json:"icon,omitempty"`
+	// Tag lets the browser replace a notification that was delivered twice
+	Tag string `json:"tag,omitempty"`
+}
+
+// ParseWebPushSubscription parses and validates the subscription a browser registered for the VAPID public key.
+func ParseWebPushSubscription(raw string) (*WebPushSubscription, error) {
+	var subscription WebPushSubscription
+	if err := json.Unmarshal([]byte(raw), &subscription); err != nil {
+		return nil, errors.New("web push subscription must be the JSON of a PushSubscription")
+	}
+	if err := ValidateWebhookURL(subscription.Endpoint); err != nil {
+		return nil, errors.New("web push endpoint must be an https url")
+	}
+	if _, err := parseWebPushKeys(subscription.Keys); err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+type webPushKeys struct {
+	ua   *ecdh.PublicKey
+	auth []byte
+}
+
+func parseWebPushKeys(payload WebPushChannelPayload) (*webPushKeys, error) {
+	p256dh, err := decodeWebPushBase64(payload.P256dh)
+	if err != nil {
+		return nil, errors.New("web push p256dh key is not base64url encoded")
+	}
+	ua, err := ecdh.P256().NewPublicKey(p256dh)
+	if err != nil {
+		return nil, errors.New("web push p256dh key is not a P-256 public key")
+	}
+	auth, err := decodeWebPushBase64(payload.Auth)
+	if err != nil || len(auth) != 16 {
+		return nil, errors.New("web push auth secret must be 16 bytes")
+	}
+	return &webPushKeys{ua: ua, auth: auth}, nil
+}
+
+// decodeWebPushBase64 accepts base64url with or without padding, browsers and libraries differ
+func decodeWebPushBase64(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+func parseWebPushChannelPayload(payload *string) WebPushChannelPayload {
+	var result WebPushChannelPayload
+	if payload == nil || *payload == "" {
+		return result
+	}
+	_ = json.Unmarshal([]byte(*payload), &result)
+	return result
+}
+
+func (n *NotifierService) notifyUseWebPush(input types.NotifyInput) (*types.NotifyOutput, error) {
+	if input.Template == nil {
+		return nil, errors.New("web push notification requires template output")
+	}
+	keys, err := parseWebPushKeys(parseWebPushChannelPayload(input.Payload))
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := json.Marshal(n.buildWebPushMessage(input))
+	if err != nil {
+		return nil, fmt.Errorf("error encoding web push message: %w", err)
+	}
+	if len(plaintext) > webPushPlaintextLimit {
+		return nil, fmt.Errorf("web push message is %d bytes, at most %d are allowed", len(plaintext), webPushPlaintextLimit)
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("error generating web push salt: %w", err)
+	}
+	as, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("error generating web push key: %w", err)
+	}
+	body, err := encryptWebPushPayload(plaintext, keys, as, salt)
+	if err != nil {
+		return nil, err
+	}
+	authorization, err := n.webPushAuthorization(input.To)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, input.To, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error building web push request: %w", err)
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(n.config.WebPushTTL.Seconds())))
+	req.Header.Set("Urgency", "normal")
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			// push endpoints carry the subscription token in the path
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("error sending web push: %w", err)
+	}
+	defer resp.Body.Close()
+
+	output := &types.NotifyOutput{StatusCode: resp.StatusCode}
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return output, fmt.Errorf("web push subscription has expired, status %d: %w", resp.StatusCode, ErrNotificationChannelGone)
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, webhookErrorBodyLimit))
+		return output, fmt.Errorf("web push service responded with status %d: %s", resp.StatusCode, string(detail))
+	}
+	return output, nil
+}
+
+func (n *NotifierService) buildWebPushMessage(input types.NotifyInput) webPushMessage {
+	message := webPushMessage{
+		Title: input.Template.Title,
+		Body:  input.Template.PlainTextContent,
+		Icon:  n.config.SiteLogo,
+	}
+	if summary := input.Template.Summary; summary != nil {
+		message.Body = summary.Headline + ": " + summary.ProposalTitle
+		message.URL = summary.ProposalLink
+		if summary.DaoLogo != "" {
+			message.Icon = summary.DaoLogo
+		}
+	}
+	if input.Record != nil {
+		message.Tag = input.Record.Code
+	}
+	message.Body = utils.TruncateText(message.Body, webPushBodyLimit)
+	return message
+}
+
+// encryptWebPushPayload encrypts the plaintext for the subscriber as a single aes128gcm record (RFC 8291, RFC 8188)
+func encryptWebPushPayload(plaintext []byte, keys *webPushKeys, as *ecdh.PrivateKey, salt []byte) ([]byte, error) {
+	secret, err := as.ECDH(keys.ua)
+	if err != nil {
+		return nil, fmt.Errorf("error deriving web push secret: %w", err)
+	}
+	asPublic := as.PublicKey().Bytes()
+
+	// the input keying material mixes the ECDH secret with the subscriber's auth secret and both public keys
+	keyInfo := append([]byte("WebPush: info\x00"), keys.ua.Bytes()...)
+	keyInfo = append(keyInfo, asPublic...)
+	prkKey, err := hkdf.Extract(sha256.New, secret, keys.auth)
+	if err != nil {
+		return nil, err
+	}
+	ikm, err := hkdf.Expand(sha256.New, prkKey, string(keyInfo), 32)
+	if err != nil {
+		return nil, err
+	}
+
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, 16+4+1+len(asPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, webPushRecordSize)
+	header = append(header, byte(len(asPublic)))
+	header = append(header, asPublic...)
+	// 0x02 marks the last record, no further padding is added
+	return gcm.Seal(header, nonce, append(plaintext, 0x02), nil), nil
+}
+
+// webPushAuthorization signs a VAPID token for the origin of the push endpoint (RFC 8292)
+func (n *NotifierService) webPushAuthorization(endpoint string) (string, error) {
+	if n.config.WebPushVAPIDPublicKey == "" || n.config.WebPushVAPIDPrivateKey == "" {
+		return "", errors.New("web push vapid keys are not configured")
+	}
+	rawPrivate, err := decodeWebPushBase64(n.config.WebPushVAPIDPrivateKey)
+	if err != nil {
+		return "", errors.New("web push vapid private key is not base64url encoded")
+	}
+	privateKey, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), rawPrivate)
+	if err != nil {
+		return "", fmt.Errorf("invalid web push vapid private key: %w", err)
+	}
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid web push endpoint: %w", err)
+	}
+
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	claims, err := json.Marshal(map[string]any{
+		"aud": parsed.Scheme + "://" + parsed.Host,
+		"exp": n.now().Add(webPushTokenLifetime).Unix(),
+		"sub": n.config.WebPushSubject,
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, privateKey, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("error signing web push token: %w", err)
+	}
+	// JWS wants the fixed size r || s form, not ASN.1
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	return fmt.Sprintf("vapid t=%s.%s, k=%s",
+		signingInput,
+		base64.RawURLEncoding.EncodeToString(signature),
+		strings.TrimRight(n.config.WebPushVAPIDPublicKey, "="),
+	), nil
+}
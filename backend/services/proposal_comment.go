@@ -51,12 +51,15 @@ type proposalCommentLimiter struct {
 
 type ProposalCommentService struct {
 	db      *gorm.DB
+	ens     commentMentionResolver
 	now     func() time.Time
 	limiter *proposalCommentLimiter
 }
 
 func NewProposalCommentService() *ProposalCommentService {
-	return newProposalCommentService(database.GetDB())
+	service := newProposalCommentService(database.GetDB())
+	service.ens = NewENSService()
+	return service
 }
 
 func newProposalCommentService(db *gorm.DB) *ProposalCommentService {
@@ -150,11 +153,14 @@ func (s *ProposalCommentService) Create(user *types.UserSessInfo, input gqlmodel
 		State:       dbmodels.ProposalCommentStateActive,
 		CTime:       now,
 	}
+	// mentioned ENS names are resolved before the transaction, a slow RPC must not hold it open
+	mentions := s.resolveCommentMentions(input.DaoCode, body)
 
 	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var parent *dbmodels.ProposalComment
 		if input.ReplyToID != nil {
-			var parent dbmodels.ProposalComment
-			if err := tx.Where("id = ? AND dao_code = ? AND proposal_id = ?", *input.ReplyToID, input.DaoCode, proposalID).First(&parent).Error; err != nil {
+			parent = &dbmodels.ProposalComment{}
+			if err := tx.Where("id = ? AND dao_code = ? AND proposal_id = ?", *input.ReplyToID, input.DaoCode, proposalID).First(parent).Error; err != nil {
 				if errors.Is(err, gorm.ErrRecordNotFound) {
 					return commentError("reply_parent_not_found")
 				}
@@ -164,7 +170,10 @@ func (s *ProposalCommentService) Create(user *types.UserSessInfo, input gqlmodel
 				return commentError("reply_parent_deleted")
 			}
 		}
-		return tx.Create(&comment).Error
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+		return saveCommentNotificationEvents(tx, &comment, parent, mentions)
 	})
 	if err != nil {
 		return nil, err
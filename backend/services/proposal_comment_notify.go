@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"gorm.io/gorm"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	"github.com/ringecosystem/degov-square/internal/utils"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+const (
+	maxCommentMentions           = 10
+	maxCommentExcerptRunes       = 280
+	commentMentionResolveTimeout = 5 * time.Second
+)
+
+// commentMentionPattern matches "@0x…" addresses and "@name.eth" ENS names that are not part of a longer word, e.g. an email
+var commentMentionPattern = regexp.MustCompile(`(?:^|[^\w@.])@(0x[0-9a-fA-F]{40}|(?:[a-zA-Z0-9-]+\.)+eth)\b`)
+
+// commentMentionResolver resolves the ENS names mentioned in comments, it is the ENSService outside of tests
+type commentMentionResolver interface {
+	Resolve(ctx context.Context, daoCode *string, address *string, name *string) (*ENSRecord, error)
+}
+
+// parseCommentMentions returns the distinct addresses and ENS names mentioned in the body, in order of appearance
+func parseCommentMentions(body string) []string {
+	seen := make(map[string]bool)
+	var mentions []string
+	for _, match := range commentMentionPattern.FindAllStringSubmatch(body, -1) {
+		mention := strings.ToLower(match[1])
+		if seen[mention] {
+			continue
+		}
+		seen[mention] = true
+		mentions = append(mentions, mention)
+		if len(mentions) == maxCommentMentions {
+			break
+		}
+	}
+	return mentions
+}
+
+// resolveCommentMentions returns the lowercase addresses mentioned in the body, ENS names that do not resolve are skipped
+func (s *ProposalCommentService) resolveCommentMentions(daoCode string, body string) []string {
+	mentions := parseCommentMentions(body)
+	if len(mentions) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), commentMentionResolveTimeout)
+	defer cancel()
+
+	addresses := make([]string, 0, len(mentions))
+	for _, mention := range mentions {
+		if common.IsHexAddress(mention) {
+			addresses = append(addresses, mention)
+			continue
+		}
+		if s.ens == nil {
+			continue
+		}
+		name := mention
+		record, err := s.ens.Resolve(ctx, &daoCode, nil, &name)
+		if err != nil {
+			slog.Warn("failed to resolve mentioned ENS name", "dao_code", daoCode, "name", mention, "error", err)
+			continue
+		}
+		if record == nil || record.Address == nil || !common.IsHexAddress(*record.Address) {
+			continue
+		}
+		addresses = append(addresses, strings.ToLower(*record.Address))
+	}
+	return addresses
+}
+
+// commentNotificationEvents builds the events of a new comment, the author of the replied comment gets COMMENT_REPLY
+// and every other mentioned address COMMENT_MENTION. Nobody is notified of their own comment.
+func commentNotificationEvents(comment *dbmodels.ProposalComment, parent *dbmodels.ProposalComment, mentions []string) ([]dbmodels.NotificationEvent, error) {
+	payload, err := json.Marshal(types.CommentEventPayload{
+		CommentID: comment.ID,
+		ReplyToID: comment.ReplyToID,
+		Author:    comment.UserAddress,
+		Excerpt:   utils.TruncateText(comment.Body, maxCommentExcerptRunes),
+	})
+	if err != nil {
+		return nil, err
+	}
+	payloadString := string(payload)
+
+	notified := map[string]bool{comment.UserAddress: true}
+	var events []dbmodels.NotificationEvent
+	addEvent := func(feature dbmodels.SubscribeFeatureName, address string) {
+		address = strings.ToLower(address)
+		if notified[address] {
+			return
+		}
+		notified[address] = true
+		events = append(events, dbmodels.NotificationEvent{
+			ChainID:       comment.ChainID,
+			DaoCode:       comment.DaoCode,
+			Type:          feature,
+			ProposalID:    comment.ProposalID,
+			TargetAddress: &address,
+			Payload:       &payloadString,
+			TimeEvent:     comment.CTime,
+		})
+	}
+
+	if parent != nil {
+		addEvent(dbmodels.SubscribeFeatureCommentReply, parent.UserAddress)
+	}
+	for _, address := range mentions {
+		addEvent(dbmodels.SubscribeFeatureCommentMention, address)
+	}
+	return events, nil
+}
+
+// saveCommentNotificationEvents queues the events of a new comment in the transaction that creates it
+func saveCommentNotificationEvents(tx *gorm.DB, comment *dbmodels.ProposalComment, parent *dbmodels.ProposalComment, mentions []string) error {
+	events, err := commentNotificationEvents(comment, parent, mentions)
+	if err != nil {
+		return err
+	}
+	return saveNotificationEvents(tx, events)
+}
@@ -1,6 +1,8 @@
 package services
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"testing"
@@ -23,6 +25,7 @@ func newProposalCommentTestService(t *testing.T, features string) *ProposalComme
 		`CREATE TABLE dgv_dao (id TEXT PRIMARY KEY, code TEXT UNIQUE NOT NULL, chain_id INTEGER NOT NULL, state TEXT NOT NULL, features TEXT)`,
 		`CREATE TABLE dgv_proposal_tracking (id TEXT PRIMARY KEY, dao_code TEXT NOT NULL, proposal_id TEXT NOT NULL)`,
 		`CREATE TABLE dgv_proposal_comment (id TEXT PRIMARY KEY, dao_code TEXT NOT NULL, chain_id INTEGER NOT NULL, proposal_id TEXT NOT NULL, user_id TEXT NOT NULL, user_address TEXT NOT NULL, reply_to_id TEXT, body TEXT NOT NULL, state TEXT NOT NULL, ctime DATETIME NOT NULL, utime DATETIME)`,
+		`CREATE TABLE dgv_notification_event (id TEXT PRIMARY KEY, chain_id INTEGER NOT NULL, dao_code TEXT NOT NULL, type TEXT NOT NULL, proposal_id TEXT NOT NULL, vote_id TEXT, target_address TEXT, dedupe_key TEXT UNIQUE, reached INTEGER NOT NULL DEFAULT 0, state TEXT NOT NULL, payload TEXT, message TEXT, time_event DATETIME, times_retry INTEGER NOT NULL DEFAULT 0, time_next_execute DATETIME, ctime DATETIME DEFAULT CURRENT_TIMESTAMP, utime DATETIME DEFAULT CURRENT_TIMESTAMP)`,
 	} {
 		if err := db.Exec(statement).Error; err != nil {
 			t.Fatalf("create test table: %v", err)
@@ -189,6 +192,75 @@ func TestProposalCommentWriteRateLimitBoundsTrackedWallets(t *testing.T) {
 	}
 }
 
+type fakeCommentMentionResolver map[string]string
+
+func (f fakeCommentMentionResolver) Resolve(_ context.Context, _ *string, _ *string, name *string) (*ENSRecord, error) {
+	address, ok := f[*name]
+	if !ok {
+		return nil, fmt.Errorf("ens name %s not found", *name)
+	}
+	return &ENSRecord{Address: &address, Name: name}, nil
+}
+
+func TestParseCommentMentions(t *testing.T) {
+	mentions := parseCommentMentions("cc @Vitalik.eth and @0xAa00000000000000000000000000000000000001, again @vitalik.eth, not mail@alice.eth or @0x1234")
+	if len(mentions) != 2 || mentions[0] != "vitalik.eth" || mentions[1] != "0xaa00000000000000000000000000000000000001" {
+		t.Fatalf("mentions = %v", mentions)
+	}
+}
+
+func TestProposalCommentNotifiesReplyAndMentions(t *testing.T) {
+	service := newProposalCommentTestService(t, `["proposal-comments"]`)
+	service.ens = fakeCommentMentionResolver{"bob.eth": "0xBB00000000000000000000000000000000000002"}
+	alice := &types.UserSessInfo{Id: "alice", Address: "0xAA00000000000000000000000000000000000001"}
+	bob := &types.UserSessInfo{Id: "bob", Address: "0xbb00000000000000000000000000000000000002"}
+
+	root, err := service.Create(alice, gqlmodels.CreateProposalCommentInput{
+		DaoCode: "demo", ProposalID: "42", Body: "what do you think @bob.eth? (and @missing.eth, and me @0xaa00000000000000000000000000000000000001)",
+	})
+	if err != nil {
+		t.Fatalf("Create(root): %v", err)
+	}
+	_, err = service.Create(bob, gqlmodels.CreateProposalCommentInput{
+		DaoCode: "demo", ProposalID: "42", Body: "agreed @0xaa00000000000000000000000000000000000001, cc @0xCC00000000000000000000000000000000000003", ReplyToID: &root.ID,
+	})
+	if err != nil {
+		t.Fatalf("Create(reply): %v", err)
+	}
+
+	var events []dbmodels.NotificationEvent
+	if err := service.db.Order("type ASC").Order("target_address ASC").Find(&events).Error; err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	want := []struct {
+		feature dbmodels.SubscribeFeatureName
+		target  string
+	}{
+		{dbmodels.SubscribeFeatureCommentMention, "0xbb00000000000000000000000000000000000002"},
+		{dbmodels.SubscribeFeatureCommentMention, "0xcc00000000000000000000000000000000000003"},
+		{dbmodels.SubscribeFeatureCommentReply, "0xaa00000000000000000000000000000000000001"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("events = %#v", events)
+	}
+	for i, event := range events {
+		if event.Type != want[i].feature || event.TargetAddress == nil || *event.TargetAddress != want[i].target {
+			t.Fatalf("event %d = %s %v, want %s %s", i, event.Type, event.TargetAddress, want[i].feature, want[i].target)
+		}
+		if event.State != dbmodels.NotificationEventStatePending || event.ProposalID != "42" || event.ChainID != 46 || event.Payload == nil {
+			t.Fatalf("event %d = %#v", i, event)
+		}
+	}
+
+	var payload types.CommentEventPayload
+	if err := json.Unmarshal([]byte(*events[2].Payload), &payload); err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+	if payload.Author != "0xbb00000000000000000000000000000000000002" || payload.ReplyToID == nil || *payload.ReplyToID != root.ID || payload.Excerpt == "" {
+		t.Fatalf("payload = %#v", payload)
+	}
+}
+
 func assertProposalCommentError(t *testing.T, err error, code string) {
 	t.Helper()
 	var commentErr *ProposalCommentError
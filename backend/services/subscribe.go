@@ -3,6 +3,7 @@ package services
 import (
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
 	"time"
 
@@ -77,29 +78,13 @@ func (s *SubscribeService) buildFeatures(
 			continue
 		}
 
-		var dbFeatureName dbmodels.SubscribeFeatureName
-		var strategy string
-
-		switch featureSetting.Name {
-		case gqlmodels.FeatureNameVoteEnd:
-			dbFeatureName = dbmodels.SubscribeFeatureVoteEnd
-		case gqlmodels.FeatureNameVoteEmitted:
-			dbFeatureName = dbmodels.SubscribeFeatureVoteEmitted
-		case gqlmodels.FeatureNameProposalStateChanged:
-			dbFeatureName = dbmodels.SubscribeFeatureProposalStateChanged
-		case gqlmodels.FeatureNameProposalNew:
-			dbFeatureName = dbmodels.SubscribeFeatureProposalNew
-		default:
+		dbFeatureName, ok := subscribeFeatureName(featureSetting.Name)
+		if !ok {
 			// skip unsupported feature
 			slog.Warn("skip unsupported feature", "feature", featureSetting.Name)
 			continue
 		}
-
-		if featureSetting.Strategy != nil && *featureSetting.Strategy != "" {
-			strategy = *featureSetting.Strategy
-		} else {
-			strategy = "true"
-		}
+		strategy := featureStrategy(featureSetting)
 
 		features = append(features, dbmodels.SubscribeFeature{
 			ID:          utils.NextIDString(),
@@ -116,10 +101,106 @@ func (s *SubscribeService) buildFeatures(
 	return features
 }
 
+func subscribeFeatureName(name gqlmodels.FeatureName) (dbmodels.SubscribeFeatureName, bool) {
+	switch name {
+	case gqlmodels.FeatureNameVoteEnd:
+		return dbmodels.SubscribeFeatureVoteEnd, true
+	case gqlmodels.FeatureNameVoteEmitted:
+		return dbmodels.SubscribeFeatureVoteEmitted, true
+	case gqlmodels.FeatureNameProposalStateChanged:
+		return dbmodels.SubscribeFeatureProposalStateChanged, true
+	case gqlmodels.FeatureNameProposalNew:
+		return dbmodels.SubscribeFeatureProposalNew, true
+	case gqlmodels.FeatureNameDelegationChanged:
+		return dbmodels.SubscribeFeatureDelegationChanged, true
+	case gqlmodels.FeatureNameExecutionReady:
+		return dbmodels.SubscribeFeatureExecutionReady, true
+	case gqlmodels.FeatureNameExecutionExpiring:
+		return dbmodels.SubscribeFeatureExecutionExpiring, true
+	case gqlmodels.FeatureNameVoteReminder:
+		return dbmodels.SubscribeFeatureVoteReminder, true
+	case gqlmodels.FeatureNameCommentReply:
+		return dbmodels.SubscribeFeatureCommentReply, true
+	case gqlmodels.FeatureNameCommentMention:
+		return dbmodels.SubscribeFeatureCommentMention, true
+	default:
+		return "", false
+	}
+}
+
+func featureStrategy(featureSetting *gqlmodels.FeatureSettingsInput) string {
+	if featureSetting.Strategy != nil && *featureSetting.Strategy != "" {
+		return *featureSetting.Strategy
+	}
+	return dbmodels.SubscribeStrategyEnabled
+}
+
+// SubscribeStrategies returns the strategies that produce notifications for a feature.
+// Vote end and execution reminders are time sensitive and are never held for a digest, delegation changes have no proposal to be grouped by.
+// Comment replies and mentions are conversations and are delivered right away.
+func SubscribeStrategies(feature dbmodels.SubscribeFeatureName) []string {
+	switch feature {
+	case dbmodels.SubscribeFeatureProposalNew,
+		dbmodels.SubscribeFeatureProposalStateChanged,
+		dbmodels.SubscribeFeatureVoteEmitted:
+		return []string{
+			dbmodels.SubscribeStrategyEnabled,
+			dbmodels.SubscribeStrategyInstant,
+			dbmodels.SubscribeStrategyHourlyDigest,
+			dbmodels.SubscribeStrategyDailyDigest,
+		}
+	case dbmodels.SubscribeFeatureVoteEnd,
+		dbmodels.SubscribeFeatureDelegationChanged,
+		dbmodels.SubscribeFeatureExecutionReady,
+		dbmodels.SubscribeFeatureExecutionExpiring,
+		dbmodels.SubscribeFeatureVoteReminder,
+		dbmodels.SubscribeFeatureCommentReply,
+		dbmodels.SubscribeFeatureCommentMention:
+		return []string{dbmodels.SubscribeStrategyEnabled, dbmodels.SubscribeStrategyInstant}
+	default:
+		return nil
+	}
+}
+
+// validateFeatureSettings rejects strategies a feature does not support, "false" is always accepted to turn a feature off.
+// VOTE_EMITTED additionally accepts the vote threshold strategies and VOTE_REMINDER a lead time.
+func validateFeatureSettings(featureSettings []*gqlmodels.FeatureSettingsInput) error {
+	for _, featureSetting := range featureSettings {
+		if featureSetting == nil {
+			continue
+		}
+		feature, ok := subscribeFeatureName(featureSetting.Name)
+		if !ok {
+			continue
+		}
+		strategy := featureStrategy(featureSetting)
+		if strategy == "false" || slices.Contains(SubscribeStrategies(feature), strategy) {
+			continue
+		}
+		if IsVoteThresholdStrategy(strategy) && feature == dbmodels.SubscribeFeatureVoteEmitted {
+			if _, err := ParseVoteThreshold(strategy); err != nil {
+				return err
+			}
+			continue
+		}
+		if feature == dbmodels.SubscribeFeatureVoteReminder {
+			if _, err := ParseVoteReminderLead(strategy); err != nil {
+				return err
+			}
+			continue
+		}
+		return fmt.Errorf("unsupported strategy %q for feature %s", strategy, feature)
+	}
+	return nil
+}
+
 func (s *SubscribeService) SubscribeDao(baseInput types.BasicInput[gqlmodels.SubscribeDaoInput]) (*gqlmodels.SubscribedDaoOutput, error) {
 	user := baseInput.User
 	sdInput := baseInput.Input
 	featureSettings := sdInput.Features
+	if err := validateFeatureSettings(featureSettings); err != nil {
+		return nil, err
+	}
 
 	existingDao, err := s.daoService.Inspect(types.BasicInput[string]{
 		User:  user,
@@ -247,6 +328,9 @@ func (s *SubscribeService) SubscribeProposal(baseInput types.BasicInput[gqlmodel
 	user := baseInput.User
 	spInput := baseInput.Input
 	featureSettings := spInput.Features
+	if err := validateFeatureSettings(featureSettings); err != nil {
+		return nil, err
+	}
 
 	existingDao, err := s.daoService.Inspect(types.BasicInput[string]{
 		User:  user,
@@ -343,17 +427,20 @@ func (s *SubscribeService) UnsubscribeProposal(baseInput types.BasicInput[gqlmod
 	return output, nil
 }
 
-func (s *SubscribeService) ListSubscribedUser(input types.ListSubscribeUserInput) ([]types.ListSubscribedUserOutput, error) {
+// subscribedUsersSQL returns a WITH clause that lists the users the input reaches as SubscribedUsers. Every setting a
+// user has for the feature is ranked first, a proposal level one winning over the DAO level one, and only then is the
+// winning setting matched against the strategies, so a proposal level false or threshold overrides a DAO level true.
+func subscribedUsersSQL(input types.ListSubscribeUserInput) (string, []interface{}, error) {
 	strategies := input.Strategies
 	if len(strategies) == 0 {
-		return nil, fmt.Errorf("no strategies provided for feature %s", input.Feature)
+		return "", nil, fmt.Errorf("no strategies provided for feature %s", input.Feature)
 	}
 
 	queryParams := make([]interface{}, 0)
 	whereConditions := make([]string, 0)
 
-	whereConditions = append(whereConditions, "f.feature = ?", "f.strategy IN ?", "f.dao_code = ?")
-	queryParams = append(queryParams, input.Feature, strategies, input.DaoCode)
+	whereConditions = append(whereConditions, "f.feature = ?", "f.dao_code = ?")
+	queryParams = append(queryParams, input.Feature, input.DaoCode)
 
 	if input.ProposalID != nil {
 		whereConditions = append(whereConditions, "(f.proposal_id = ? OR f.proposal_id IS NULL)")
@@ -362,6 +449,11 @@ func (s *SubscribeService) ListSubscribedUser(input types.ListSubscribeUserInput
 		whereConditions = append(whereConditions, "f.proposal_id IS NULL")
 	}
 
+	if input.TargetAddress != nil {
+		whereConditions = append(whereConditions, "LOWER(f.user_address) = LOWER(?)")
+		queryParams = append(queryParams, *input.TargetAddress)
+	}
+
 	if input.TimeEvent != nil {
 		whereConditions = append(whereConditions, "((d.state = 'ACTIVE' AND d.ctime <= ?) OR (p.state = 'ACTIVE' AND p.ctime <= ?))")
 		queryParams = append(queryParams, *input.TimeEvent, *input.TimeEvent)
@@ -370,14 +462,12 @@ func (s *SubscribeService) ListSubscribedUser(input types.ListSubscribeUserInput
 	}
 
 	sqlTemplate := `
-WITH RankedResults AS (
+WITH RankedSettings AS (
     SELECT
-        f.user_id, f.user_address, f.chain_id, f.dao_code,
-        LEAST(d.ctime, p.ctime) AS ctime,
-        f.ctime AS order_ctime,
+        f.user_id, f.user_address, f.strategy,
         ROW_NUMBER() OVER(
-            PARTITION BY f.user_id, f.user_address, f.chain_id, f.dao_code, LEAST(d.ctime, p.ctime)
-            ORDER BY f.ctime ASC, f.user_id ASC
+            PARTITION BY f.user_id
+            ORDER BY (f.proposal_id IS NULL) ASC, f.ctime ASC
         ) as rn
     FROM
         dgv_subscribed_feature AS f
@@ -387,28 +477,35 @@ WITH RankedResults AS (
         dgv_user_subscribed_proposal AS p ON f.user_id = p.user_id AND f.proposal_id = p.proposal_id
     WHERE
         %s
+),
+SubscribedUsers AS (
+    SELECT user_id, user_address, strategy
+    FROM RankedSettings
+    WHERE rn = 1 AND strategy IN ?
 )
-SELECT user_id, user_address, chain_id, dao_code, ctime
-FROM RankedResults
-WHERE rn = 1
-ORDER BY order_ctime ASC, user_id ASC
-LIMIT ? OFFSET ?
 `
+	queryParams = append(queryParams, strategies)
+	return fmt.Sprintf(sqlTemplate, strings.Join(whereConditions, " AND ")), queryParams, nil
+}
 
-	whereClause := strings.Join(whereConditions, " AND ")
-	finalSQL := fmt.Sprintf(sqlTemplate, whereClause)
-
-	if input.Limit <= 0 {
-		input.Limit = 100
-	}
-	queryParams = append(queryParams, input.Limit, input.Offset)
-
-	var outputs []types.ListSubscribedUserOutput
-	if err := s.db.Raw(finalSQL, queryParams...).Scan(&outputs).Error; err != nil {
+// ListSubscribedAddresses returns the distinct lowercased addresses of users subscribed to a DAO with the feature enabled.
+func (s *SubscribeService) ListSubscribedAddresses(feature dbmodels.SubscribeFeatureName, daoCode string) ([]string, error) {
+	var addresses []string
+	err := s.db.
+		Table("dgv_subscribed_feature AS f").
+		Joins("JOIN dgv_user_subscribed_dao AS d ON f.user_id = d.user_id AND f.dao_code = d.dao_code").
+		Where("f.feature = ? AND f.dao_code = ? AND f.proposal_id IS NULL AND f.strategy IN ? AND d.state = ?",
+			feature,
+			daoCode,
+			SubscribeStrategies(feature),
+			dbmodels.SubscribeStateActive,
+		).
+		Distinct("LOWER(f.user_address)").
+		Pluck("LOWER(f.user_address)", &addresses).Error
+	if err != nil {
 		return nil, err
 	}
-
-	return outputs, nil
+	return addresses, nil
 }
 
 func (s *SubscribeService) resetDaoFeatures(input resetDaoFeaturesInput) error {
@@ -470,6 +567,16 @@ func (s *SubscribeService) ListFeatures(baseInput types.BasicInput[types.ListFea
 	return features, nil
 }
 
+// DisableFeature turns a feature off for every subscription of the user in the DAO,
+// proposal-level settings included since they would otherwise keep the feature enabled.
+func (s *SubscribeService) DisableFeature(user *types.UserSessInfo, daoCode string, feature dbmodels.SubscribeFeatureName) error {
+	return s.db.
+		Model(&dbmodels.SubscribeFeature{}).
+		Where("user_id = ? AND dao_code = ? AND feature = ?", user.Id, daoCode, feature).
+		Update("strategy", "false").
+		Error
+}
+
 type UserSubscribedDaoService struct {
 	db               *gorm.DB
 	daoService       *DaoService
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+const (
+	// DefaultVoteReminderLead is used by VOTE_REMINDER subscriptions that do not set a lead time
+	DefaultVoteReminderLead = 24 * time.Hour
+	maxVoteReminderLead     = 7 * 24 * time.Hour
+)
+
+// ParseVoteReminderLead parses the lead time of a VOTE_REMINDER strategy, e.g. "24h" or "90m".
+func ParseVoteReminderLead(strategy string) (time.Duration, error) {
+	if strategy == dbmodels.SubscribeStrategyEnabled || strategy == dbmodels.SubscribeStrategyInstant {
+		return DefaultVoteReminderLead, nil
+	}
+	lead, err := time.ParseDuration(strings.TrimSpace(strategy))
+	if err != nil || lead <= 0 || lead > maxVoteReminderLead {
+		return 0, fmt.Errorf("invalid vote reminder lead time %q, expected a duration up to %s", strategy, maxVoteReminderLead)
+	}
+	return lead, nil
+}
+
+// ListVoteReminderStrategies returns the distinct lead time strategies VOTE_REMINDER subscribers of a DAO use.
+func (s *SubscribeService) ListVoteReminderStrategies(daoCode string) ([]string, error) {
+	var strategies []string
+	err := s.db.
+		Model(&dbmodels.SubscribeFeature{}).
+		Distinct("strategy").
+		Where("dao_code = ? AND feature = ? AND strategy NOT IN ?",
+			daoCode,
+			dbmodels.SubscribeFeatureVoteReminder,
+			append(SubscribeStrategies(dbmodels.SubscribeFeatureVoteReminder), "false"),
+		).
+		Pluck("strategy", &strategies).Error
+	if err != nil {
+		return nil, err
+	}
+	return strategies, nil
+}
+
+// ListVoteReminderSubscribers returns the addresses subscribed to VOTE_REMINDER for a proposal with their lead time strategy.
+func (s *SubscribeService) ListVoteReminderSubscribers(daoCode, proposalID string) ([]types.VoteReminderSubscriber, error) {
+	var rows []struct {
+		UserAddress string
+		ProposalID  *string
+		Strategy    string
+	}
+	err := s.db.
+		Table("dgv_subscribed_feature AS f").
+		Select("LOWER(f.user_address) AS user_address, f.proposal_id, f.strategy").
+		Joins("LEFT JOIN dgv_user_subscribed_dao AS d ON f.user_id = d.user_id AND f.dao_code = d.dao_code").
+		Joins("LEFT JOIN dgv_user_subscribed_proposal AS p ON f.user_id = p.user_id AND f.proposal_id = p.proposal_id").
+		Where("f.feature = ? AND f.dao_code = ? AND (f.proposal_id = ? OR f.proposal_id IS NULL)",
+			dbmodels.SubscribeFeatureVoteReminder, daoCode, proposalID).
+		Where("(d.state = ? OR p.state = ?)", dbmodels.SubscribeStateActive, dbmodels.SubscribeStateActive).
+		Order("f.ctime ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	strategies := make(map[string]string, len(rows))
+	order := make([]string, 0, len(rows))
+	proposalLevel := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		if _, ok := strategies[row.UserAddress]; !ok {
+			order = append(order, row.UserAddress)
+		} else if proposalLevel[row.UserAddress] {
+			continue
+		}
+		strategies[row.UserAddress] = row.Strategy
+		proposalLevel[row.UserAddress] = row.ProposalID != nil
+	}
+
+	subscribers := make([]types.VoteReminderSubscriber, 0, len(order))
+	for _, address := range order {
+		if strategies[address] == "false" {
+			continue
+		}
+		subscribers = append(subscribers, types.VoteReminderSubscriber{UserAddress: address, Strategy: strategies[address]})
+	}
+	return subscribers, nil
+}
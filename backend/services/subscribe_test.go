@@ -0,0 +1,145 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	gqlmodels "github.com/ringecosystem/degov-square/graph/models"
+	"github.com/ringecosystem/degov-square/internal/utils"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+func TestValidateFeatureSettingsStrategies(t *testing.T) {
+	valid := []*gqlmodels.FeatureSettingsInput{
+		{Name: gqlmodels.FeatureNameProposalNew},
+		{Name: gqlmodels.FeatureNameVoteEmitted, Strategy: utils.StringPtr("hourly_digest")},
+		{Name: gqlmodels.FeatureNameProposalStateChanged, Strategy: utils.StringPtr("daily_digest")},
+		{Name: gqlmodels.FeatureNameVoteEnd, Strategy: utils.StringPtr("false")},
+	}
+	if err := validateFeatureSettings(valid); err != nil {
+		t.Fatalf("expected settings to be valid: %v", err)
+	}
+
+	for _, setting := range []*gqlmodels.FeatureSettingsInput{
+		{Name: gqlmodels.FeatureNameVoteEnd, Strategy: utils.StringPtr("daily_digest")},
+		{Name: gqlmodels.FeatureNameProposalNew, Strategy: utils.StringPtr("weekly")},
+		{Name: gqlmodels.FeatureNameDelegationChanged, Strategy: utils.StringPtr("hourly_digest")},
+		{Name: gqlmodels.FeatureNameExecutionExpiring, Strategy: utils.StringPtr("daily_digest")},
+	} {
+		if err := validateFeatureSettings([]*gqlmodels.FeatureSettingsInput{setting}); err == nil {
+			t.Fatalf("expected strategy %q to be rejected for %s", *setting.Strategy, setting.Name)
+		}
+	}
+}
+
+func TestValidateFeatureSettingsVoteThresholds(t *testing.T) {
+	for _, strategy := range []string{"min_weight:100000", "min_weight:2.5", "min_quorum_percent:5"} {
+		if err := validateFeatureSettings([]*gqlmodels.FeatureSettingsInput{
+			{Name: gqlmodels.FeatureNameVoteEmitted, Strategy: utils.StringPtr(strategy)},
+		}); err != nil {
+			t.Fatalf("expected %q to be accepted: %v", strategy, err)
+		}
+	}
+
+	for _, setting := range []*gqlmodels.FeatureSettingsInput{
+		{Name: gqlmodels.FeatureNameProposalNew, Strategy: utils.StringPtr("min_weight:100")},
+		{Name: gqlmodels.FeatureNameVoteEmitted, Strategy: utils.StringPtr("min_weight:")},
+		{Name: gqlmodels.FeatureNameVoteEmitted, Strategy: utils.StringPtr("min_weight:-1")},
+		{Name: gqlmodels.FeatureNameVoteEmitted, Strategy: utils.StringPtr("min_quorum_percent:1e3")},
+		{Name: gqlmodels.FeatureNameVoteEmitted, Strategy: utils.StringPtr("min_weight:0x10")},
+		{Name: gqlmodels.FeatureNameVoteEmitted, Strategy: utils.StringPtr("min_weight:0b1")},
+		{Name: gqlmodels.FeatureNameVoteEmitted, Strategy: utils.StringPtr("min_weight:0o7")},
+		{Name: gqlmodels.FeatureNameVoteEmitted, Strategy: utils.StringPtr("min_weight:1_000")},
+		{Name: gqlmodels.FeatureNameVoteEmitted, Strategy: utils.StringPtr("min_weight:1/2")},
+		{Name: gqlmodels.FeatureNameVoteEmitted, Strategy: utils.StringPtr("min_weight:.5")},
+		{Name: gqlmodels.FeatureNameVoteEmitted, Strategy: utils.StringPtr("min_weight:0")},
+	} {
+		if err := validateFeatureSettings([]*gqlmodels.FeatureSettingsInput{setting}); err == nil {
+			t.Fatalf("expected strategy %q to be rejected for %s", *setting.Strategy, setting.Name)
+		}
+	}
+}
+
+func TestPassingVoteThresholds(t *testing.T) {
+	strategies := []string{"min_weight:1000", "min_weight:1500.5", "min_quorum_percent:10", "min_quorum_percent:50"}
+
+	// 1200 tokens with 18 decimals against a quorum of 4000 tokens (30%)
+	payload := `{"weight":"1200000000000000000000","quorum":"4000000000000000000000","decimals":18}`
+	got := PassingVoteThresholds(strategies, &payload)
+	if len(got) != 2 || got[0] != "min_weight:1000" || got[1] != "min_quorum_percent:10" {
+		t.Fatalf("unexpected passing thresholds: %v", got)
+	}
+
+	// without proposal details the thresholds cannot be evaluated
+	weightOnly := `{"weight":"1200000000000000000000"}`
+	if got := PassingVoteThresholds(strategies, &weightOnly); len(got) != 0 {
+		t.Fatalf("votes without quorum and decimals must not pass, got %v", got)
+	}
+	if got := PassingVoteThresholds(strategies, nil); len(got) != 0 {
+		t.Fatalf("events without payload must not pass, got %v", got)
+	}
+}
+
+func TestValidateFeatureSettingsVoteReminderLead(t *testing.T) {
+	for _, strategy := range []string{"true", "24h", "90m", "false"} {
+		if err := validateFeatureSettings([]*gqlmodels.FeatureSettingsInput{
+			{Name: gqlmodels.FeatureNameVoteReminder, Strategy: utils.StringPtr(strategy)},
+		}); err != nil {
+			t.Fatalf("expected %q to be accepted: %v", strategy, err)
+		}
+	}
+	for _, setting := range []*gqlmodels.FeatureSettingsInput{
+		{Name: gqlmodels.FeatureNameVoteReminder, Strategy: utils.StringPtr("-1h")},
+		{Name: gqlmodels.FeatureNameVoteReminder, Strategy: utils.StringPtr("30d")},
+		{Name: gqlmodels.FeatureNameVoteReminder, Strategy: utils.StringPtr("200h")},
+		{Name: gqlmodels.FeatureNameVoteEnd, Strategy: utils.StringPtr("24h")},
+	} {
+		if err := validateFeatureSettings([]*gqlmodels.FeatureSettingsInput{setting}); err == nil {
+			t.Fatalf("expected strategy %q to be rejected for %s", *setting.Strategy, setting.Name)
+		}
+	}
+
+	if lead, err := ParseVoteReminderLead("true"); err != nil || lead != DefaultVoteReminderLead {
+		t.Fatalf("expected the default lead time, got %s %v", lead, err)
+	}
+	if lead, err := ParseVoteReminderLead("6h"); err != nil || lead != 6*time.Hour {
+		t.Fatalf("expected 6h, got %s %v", lead, err)
+	}
+}
+
+func TestListVoteReminderSubscribers(t *testing.T) {
+	db := newTestNotificationDB(t)
+	for _, statement := range []string{
+		`CREATE TABLE dgv_subscribed_feature (id TEXT, user_id TEXT, user_address TEXT, dao_code TEXT, proposal_id TEXT, feature TEXT, strategy TEXT, ctime DATETIME)`,
+		`CREATE TABLE dgv_user_subscribed_dao (user_id TEXT, dao_code TEXT, state TEXT)`,
+		`CREATE TABLE dgv_user_subscribed_proposal (user_id TEXT, dao_code TEXT, proposal_id TEXT, state TEXT)`,
+		`INSERT INTO dgv_user_subscribed_dao VALUES ('u1', 'demo', 'ACTIVE'), ('u2', 'demo', 'ACTIVE'), ('u3', 'demo', 'INACTIVE'), ('u4', 'demo', 'ACTIVE')`,
+		`INSERT INTO dgv_user_subscribed_proposal VALUES ('u2', 'demo', '0x01', 'ACTIVE'), ('u4', 'demo', '0x01', 'ACTIVE')`,
+		`INSERT INTO dgv_subscribed_feature VALUES
+			('f1', 'u1', '0xAAA', 'demo', NULL, 'VOTE_REMINDER', 'true', '2025-06-01 00:00:00'),
+			('f2', 'u2', '0xbbb', 'demo', NULL, 'VOTE_REMINDER', '24h', '2025-06-01 00:00:01'),
+			('f3', 'u2', '0xbbb', 'demo', '0x01', 'VOTE_REMINDER', '2h', '2025-06-01 00:00:02'),
+			('f4', 'u3', '0xccc', 'demo', NULL, 'VOTE_REMINDER', 'true', '2025-06-01 00:00:03'),
+			('f5', 'u4', '0xddd', 'demo', NULL, 'VOTE_REMINDER', '12h', '2025-06-01 00:00:04'),
+			('f6', 'u4', '0xddd', 'demo', '0x01', 'VOTE_REMINDER', 'false', '2025-06-01 00:00:05'),
+			('f7', 'u1', '0xAAA', 'demo', NULL, 'VOTE_END', 'true', '2025-06-01 00:00:06')`,
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatalf("seed subscriptions: %v", err)
+		}
+	}
+
+	service := &SubscribeService{db: db}
+	subscribers, err := service.ListVoteReminderSubscribers("demo", "0x01")
+	if err != nil {
+		t.Fatalf("list subscribers: %v", err)
+	}
+	expected := []types.VoteReminderSubscriber{
+		{UserAddress: "0xaaa", Strategy: "true"},
+		{UserAddress: "0xbbb", Strategy: "2h"},
+	}
+	if !reflect.DeepEqual(subscribers, expected) {
+		t.Fatalf("unexpected subscribers: %+v", subscribers)
+	}
+}
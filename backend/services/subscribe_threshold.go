@@ -0,0 +1,130 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+var voteThresholdPattern = regexp.MustCompile(`^\d+(\.\d+)?$`)
+
+// VoteThreshold is a parsed vote threshold strategy, a vote passes when it reaches the minimum
+type VoteThreshold struct {
+	// MinTokens is the minimum weight in whole tokens, nil when the threshold is relative to the quorum
+	MinTokens *big.Rat
+	// MinQuorumPercent is the minimum weight in percent of the proposal quorum
+	MinQuorumPercent *big.Rat
+}
+
+// IsVoteThresholdStrategy reports whether the strategy filters votes by weight.
+func IsVoteThresholdStrategy(strategy string) bool {
+	return strings.HasPrefix(strategy, dbmodels.SubscribeStrategyMinWeightPrefix) ||
+		strings.HasPrefix(strategy, dbmodels.SubscribeStrategyMinQuorumPercentPrefix)
+}
+
+// ParseVoteThreshold parses "min_weight:<tokens>" and "min_quorum_percent:<percent>" strategies.
+func ParseVoteThreshold(strategy string) (*VoteThreshold, error) {
+	var (
+		raw       string
+		threshold VoteThreshold
+	)
+	switch {
+	case strings.HasPrefix(strategy, dbmodels.SubscribeStrategyMinWeightPrefix):
+		raw = strings.TrimPrefix(strategy, dbmodels.SubscribeStrategyMinWeightPrefix)
+	case strings.HasPrefix(strategy, dbmodels.SubscribeStrategyMinQuorumPercentPrefix):
+		raw = strings.TrimPrefix(strategy, dbmodels.SubscribeStrategyMinQuorumPercentPrefix)
+	default:
+		return nil, fmt.Errorf("%q is not a vote threshold strategy", strategy)
+	}
+
+	// big.Rat also accepts fractions, exponents, 0x prefixes and underscores
+	if !voteThresholdPattern.MatchString(raw) {
+		return nil, fmt.Errorf("invalid vote threshold %q, expected a positive decimal number", raw)
+	}
+	value, ok := new(big.Rat).SetString(raw)
+	if !ok || value.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid vote threshold %q, expected a positive decimal number", raw)
+	}
+	if strings.HasPrefix(strategy, dbmodels.SubscribeStrategyMinWeightPrefix) {
+		threshold.MinTokens = value
+	} else {
+		threshold.MinQuorumPercent = value
+	}
+	return &threshold, nil
+}
+
+// Allows reports whether the vote reaches the threshold, votes that cannot be evaluated are not notified.
+func (t *VoteThreshold) Allows(vote *types.VoteEventPayload) bool {
+	if vote == nil {
+		return false
+	}
+	weight, ok := new(big.Rat).SetString(vote.Weight)
+	if !ok {
+		return false
+	}
+
+	if t.MinTokens != nil {
+		if vote.Decimals == nil {
+			return false
+		}
+		unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(*vote.Decimals)), nil)
+		minimum := new(big.Rat).Mul(t.MinTokens, new(big.Rat).SetInt(unit))
+		return weight.Cmp(minimum) >= 0
+	}
+
+	quorum, ok := new(big.Rat).SetString(vote.Quorum)
+	if !ok || quorum.Sign() <= 0 {
+		return false
+	}
+	// weight * 100 >= percent * quorum
+	left := new(big.Rat).Mul(weight, big.NewRat(100, 1))
+	right := new(big.Rat).Mul(t.MinQuorumPercent, quorum)
+	return left.Cmp(right) >= 0
+}
+
+// PassingVoteThresholds returns the threshold strategies the vote of a VOTE_EMITTED event reaches.
+func PassingVoteThresholds(strategies []string, payload *string) []string {
+	if payload == nil {
+		return nil
+	}
+	var vote types.VoteEventPayload
+	if err := json.Unmarshal([]byte(*payload), &vote); err != nil {
+		return nil
+	}
+
+	passing := make([]string, 0, len(strategies))
+	for _, strategy := range strategies {
+		threshold, err := ParseVoteThreshold(strategy)
+		if err != nil {
+			continue
+		}
+		if threshold.Allows(&vote) {
+			passing = append(passing, strategy)
+		}
+	}
+	return passing
+}
+
+// ListVoteThresholdStrategies returns the distinct vote threshold strategies subscribers of a DAO use.
+func (s *SubscribeService) ListVoteThresholdStrategies(daoCode string) ([]string, error) {
+	var strategies []string
+	err := s.db.
+		Model(&dbmodels.SubscribeFeature{}).
+		Distinct("strategy").
+		Where("dao_code = ? AND feature = ? AND (strategy LIKE ? OR strategy LIKE ?)",
+			daoCode,
+			dbmodels.SubscribeFeatureVoteEmitted,
+			dbmodels.SubscribeStrategyMinWeightPrefix+"%",
+			dbmodels.SubscribeStrategyMinQuorumPercentPrefix+"%",
+		).
+		Pluck("strategy", &strategies).Error
+	if err != nil {
+		return nil, err
+	}
+	return strategies, nil
+}
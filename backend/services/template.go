@@ -3,8 +3,10 @@ package services
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	tplHtml "html/template"
+	"io/fs"
 	"log/slog"
 	"math/big"
 	"os"
@@ -31,23 +33,31 @@ import (
 )
 
 type TemplateService struct {
-	daoService       *DaoService
-	proposalService  *ProposalService
-	daoConfigService *DaoConfigService
-	htmlTemplates    map[string]*tplHtml.Template
-	textTemplates    map[string]*tplText.Template
-	userService      *UserService
+	daoService         *DaoService
+	proposalService    *ProposalService
+	daoConfigService   *DaoConfigService
+	htmlTemplates      map[string]*tplHtml.Template
+	textTemplates      map[string]*tplText.Template
+	userService        *UserService
+	preferenceService  *NotificationPreferenceService
+	unsubscribeService *UnsubscribeService
 }
 
 func NewTemplateService() *TemplateService {
 	funcMap := tplText.FuncMap{
 		"formatDate":               utils.FormatDate,
+		"formatDateIn":             utils.FormatDateIn,
 		"formatLargeNumber":        utils.FormatLargeNumber,
 		"formatDecimal":            utils.FormatDecimal,
 		"formatPercent":            utils.FormatPercent,
 		"formatDurationShort":      utils.FormatDurationShort,
 		"formatBigIntWithDecimals": utils.FormatBigIntWithDecimals,
 		"formatAsMdQuote":          utils.FormatAsMdQuote,
+		// locale aware variants for translated templates, the locale is the first argument
+		"formatDateLocale":          utils.FormatDateLocale,
+		"formatLargeNumberLocale":   utils.FormatLargeNumberLocale,
+		"formatDurationShortLocale": utils.FormatDurationShortLocale,
+		"proposalStateName":         proposalStateName,
 	}
 
 	files, err := templates.TemplateFS.ReadDir("template")
@@ -58,14 +68,13 @@ func NewTemplateService() *TemplateService {
 	htmlTmpls := make(map[string]*tplHtml.Template)
 	for _, file := range files {
 		fileName := file.Name()
-		if file.IsDir() || !strings.HasSuffix(fileName, ".html") || fileName == "_layout.html" {
+		if file.IsDir() || !strings.HasSuffix(fileName, ".html") || strings.HasPrefix(fileName, "_layout.") {
 			continue
 		}
 
 		tmpl := tplHtml.Must(tplHtml.New(fileName).Funcs(funcMap).ParseFS(
 			templates.TemplateFS,
-			"template/_layout.html",
-			"template/"+fileName,
+			append(templateLayoutFiles(fileName), "template/"+fileName)...,
 		))
 
 		htmlTmpls[fileName] = tmpl
@@ -74,28 +83,42 @@ func NewTemplateService() *TemplateService {
 	textTmpls := make(map[string]*tplText.Template)
 	for _, file := range files {
 		fileName := file.Name()
-		if file.IsDir() || !strings.HasSuffix(fileName, ".md") || fileName == "_layout.md" {
+		if file.IsDir() || !strings.HasSuffix(fileName, ".md") || strings.HasPrefix(fileName, "_layout.") {
 			continue
 		}
 
 		tmpl := tplText.Must(tplText.New(fileName).Funcs(funcMap).ParseFS(
 			templates.TemplateFS,
-			"template/_layout.md",
-			"template/"+fileName,
+			append(templateLayoutFiles(fileName), "template/"+fileName)...,
 		))
 
 		textTmpls[fileName] = tmpl
 	}
 	return &TemplateService{
-		daoService:       NewDaoService(),
-		proposalService:  NewProposalService(),
-		daoConfigService: NewDaoConfigService(),
-		htmlTemplates:    htmlTmpls,
-		textTemplates:    textTmpls,
-		userService:      NewUserService(),
+		daoService:         NewDaoService(),
+		proposalService:    NewProposalService(),
+		daoConfigService:   NewDaoConfigService(),
+		htmlTemplates:      htmlTmpls,
+		textTemplates:      textTmpls,
+		userService:        NewUserService(),
+		preferenceService:  NewNotificationPreferenceService(),
+		unsubscribeService: NewUnsubscribeService(),
 	}
 }
 
+// templateLayoutFiles returns the layout a template is rendered in. A translated template such as proposal_new.zh-CN.md
+// also gets _layout.zh-CN.md, which overrides the blocks of the layout that have text in it.
+func templateLayoutFiles(fileName string) []string {
+	ext := filepath.Ext(fileName)
+	layouts := []string{"template/_layout" + ext}
+	if locale := filepath.Ext(strings.TrimSuffix(fileName, ext)); locale != "" {
+		if _, err := fs.Stat(templates.TemplateFS, "template/_layout"+locale+ext); err == nil {
+			layouts = append(layouts, "template/_layout"+locale+ext)
+		}
+	}
+	return layouts
+}
+
 type templateNotificationRecordData struct {
 	DegovSiteConfig types.DegovSiteConfig  `json:"degov_site_config"`
 	EmailStyle      *types.EmailStyle      `json:"email_style"`
@@ -109,6 +132,12 @@ type templateNotificationRecordData struct {
 	UserID          string                 `json:"user_id"`
 	UserAddress     string                 `json:"user_address"`
 	EnsName         *string                `json:"ens_name"`
+	// Timezone and Locale dates are rendered in, from the user's notification preference
+	Timezone string `json:"timezone"`
+	Locale   string `json:"locale"`
+	// UnsubscribeURL leaves the DAO and the proposal, UnsubscribeFeatureURL only turns off this kind of notification
+	UnsubscribeURL        string `json:"unsubscribe_url"`
+	UnsubscribeFeatureURL string `json:"unsubscribe_feature_url"`
 }
 
 type emailProposalInfo struct {
@@ -148,19 +177,51 @@ func (s *TemplateService) parsePayload(payload *string) map[string]interface{} {
 	return result
 }
 
-func (s *TemplateService) getTemplateFileName(notificationType dbmodels.SubscribeFeatureName, mode string) string {
+// getTemplateFileName returns the template of the notification type in the locale, the English one when it is not translated
+func (s *TemplateService) getTemplateFileName(notificationType dbmodels.SubscribeFeatureName, mode string, locale string) string {
+	var name string
 	switch notificationType {
 	case dbmodels.SubscribeFeatureProposalNew:
-		return "proposal_new." + mode
+		name = "proposal_new"
 	case dbmodels.SubscribeFeatureProposalStateChanged:
-		return "proposal_state_changed." + mode
+		name = "proposal_state_changed"
 	case dbmodels.SubscribeFeatureVoteEnd:
-		return "vote_end." + mode
+		name = "vote_end"
 	case dbmodels.SubscribeFeatureVoteEmitted:
-		return "vote_emitted." + mode
+		name = "vote_emitted"
+	case dbmodels.SubscribeFeatureDelegationChanged:
+		name = "delegation_changed"
+	case dbmodels.SubscribeFeatureExecutionReady:
+		name = "execution_ready"
+	case dbmodels.SubscribeFeatureExecutionExpiring:
+		name = "execution_expiring"
+	case dbmodels.SubscribeFeatureVoteReminder:
+		name = "vote_reminder"
+	case dbmodels.SubscribeFeatureCommentReply:
+		name = "comment_reply"
+	case dbmodels.SubscribeFeatureCommentMention:
+		name = "comment_mention"
 	default:
-		return "unknown." + mode // fallback
+		name = "unknown" // fallback
 	}
+	return s.localizedTemplateFileName(name, mode, locale)
+}
+
+// localizedTemplateFileName returns name.locale.mode when that translation exists, otherwise name.mode
+func (s *TemplateService) localizedTemplateFileName(name string, mode string, locale string) string {
+	if locale != "" && locale != utils.LocaleEnglish {
+		localized := name + "." + locale + "." + mode
+		var exists bool
+		if mode == "html" {
+			_, exists = s.htmlTemplates[localized]
+		} else {
+			_, exists = s.textTemplates[localized]
+		}
+		if exists {
+			return localized
+		}
+	}
+	return name + "." + mode
 }
 
 func (s *TemplateService) GenerateTemplateByNotificationRecord(record *dbmodels.NotificationRecord) (*types.TemplateOutput, error) {
@@ -172,7 +233,85 @@ func (s *TemplateService) GenerateTemplateByNotificationRecord(record *dbmodels.
 	if err != nil {
 		return nil, fmt.Errorf("failed to get DAO info: %w", err)
 	}
+	preference := s.userPreference(record.UserID)
+	if record.Type == dbmodels.SubscribeFeatureDelegationChanged {
+		// delegation changes are not tied to a proposal
+		source, err := s.loadDelegationSource(record, dao)
+		if err != nil {
+			return nil, err
+		}
+		return s.renderDelegationTemplate(record, source, preference, false)
+	}
+
+	source, err := s.loadRecordTemplateSource(record, dao)
+	if err != nil {
+		return nil, err
+	}
+	return s.renderRecordTemplate(record, source, preference, false)
+}
+
+// GenerateInboxByEvent renders the title and the plain text body of the records of an event for the in-app inbox,
+// without the email layout around it, so without its links and unsubscribe footer. The DAO, proposal and vote data is
+// loaded once and the body is rendered once per audience, it leaves out everything about a single user, such as the
+// greeting, so every record of the audience shares it.
+func (s *TemplateService) GenerateInboxByEvent(event *dbmodels.NotificationEvent, audiences []types.InboxAudience) (map[types.InboxAudience]*types.TemplateOutput, error) {
+	record := &dbmodels.NotificationRecord{
+		EventID:    event.ID,
+		ChainID:    event.ChainID,
+		DaoCode:    event.DaoCode,
+		Type:       event.Type,
+		ProposalID: event.ProposalID,
+		VoteID:     event.VoteID,
+		Payload:    event.Payload,
+	}
+	dao, err := s.daoService.Inspect(types.BasicInput[string]{Input: record.DaoCode})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DAO info: %w", err)
+	}
+
+	var render func(preference *dbmodels.NotificationPreference) (*types.TemplateOutput, error)
+	if record.Type == dbmodels.SubscribeFeatureDelegationChanged {
+		source, err := s.loadDelegationSource(record, dao)
+		if err != nil {
+			return nil, err
+		}
+		render = func(preference *dbmodels.NotificationPreference) (*types.TemplateOutput, error) {
+			return s.renderDelegationTemplate(record, source, preference, true)
+		}
+	} else {
+		source, err := s.loadRecordTemplateSource(record, dao)
+		if err != nil {
+			return nil, err
+		}
+		render = func(preference *dbmodels.NotificationPreference) (*types.TemplateOutput, error) {
+			return s.renderRecordTemplate(record, source, preference, true)
+		}
+	}
+
+	outputs := make(map[types.InboxAudience]*types.TemplateOutput, len(audiences))
+	for _, audience := range audiences {
+		output, err := render(&dbmodels.NotificationPreference{Locale: audience.Locale, Timezone: audience.Timezone})
+		if err != nil {
+			return nil, err
+		}
+		outputs[audience] = output
+	}
+	return outputs, nil
+}
 
+// recordTemplateSource is the DAO, proposal and vote data a notification record is rendered with
+type recordTemplateSource struct {
+	dao             *gqlmodels.Dao
+	daoConfig       *types.DaoConfig
+	proposal        *dbmodels.ProposalTracking
+	proposalIndexer *internal.Proposal
+	vote            *internal.VoteCast
+	// proposerEnsName and authorName are looked up once, however many audiences the record is rendered for
+	proposerEnsName *string
+	authorName      string
+}
+
+func (s *TemplateService) loadRecordTemplateSource(record *dbmodels.NotificationRecord, dao *gqlmodels.Dao) (*recordTemplateSource, error) {
 	// Get proposal information
 	proposal, err := s.proposalService.InspectProposal(types.InspectProposalInput{
 		DaoCode:    record.DaoCode,
@@ -194,49 +333,94 @@ func (s *TemplateService) GenerateTemplateByNotificationRecord(record *dbmodels.
 		GovernorAddress: daoConfig.Contracts.Governor,
 	}
 
-	var emailVote emailVoteInfo
-
-	// Parse payload data
-	payloadData := s.parsePayload(record.Payload)
-	title := "New notification from DeGov.AI"
-	emailProposal := emailProposalInfo{
-		ProposalDb: proposal,
-	}
-
 	proposalIndexer, err := degovIndexer.InspectProposal(scope, proposal.ProposalID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to inspect full proposal: %w", err)
 	}
-	emailProposal.ProposalIndexer = proposalIndexer
-
-	decimalsInt, err := strconv.Atoi(proposalIndexer.Decimals)
-	if err != nil {
-		slog.Warn("failed to parse decimals to int", "decimals", proposalIndexer.Decimals, "error", err)
-		payloadData["DecimalsInt"] = 1
-	} else {
-		payloadData["DecimalsInt"] = decimalsInt
+	source := &recordTemplateSource{
+		dao:             dao,
+		daoConfig:       daoConfig,
+		proposal:        proposal,
+		proposalIndexer: proposalIndexer,
 	}
 
 	if record.Type == dbmodels.SubscribeFeatureVoteEmitted {
+		if record.VoteID == nil {
+			return nil, errors.New("vote notification has no vote id")
+		}
 		voteIndexer, err := degovIndexer.QueryVote(scope, proposal.ProposalID, *record.VoteID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get vote info: %w", err)
 		}
-		emailVote.VoteIndexer = voteIndexer
+		source.vote = voteIndexer
 	}
 
-	if record.Type == dbmodels.SubscribeFeatureVoteEnd {
+	if record.Type == dbmodels.SubscribeFeatureVoteEnd && record.UserAddress != "" {
+		// the inbox renders the event for every user, without the vote of one of them
 		voteIndexer, err := degovIndexer.QueryVoteByVoter(scope, proposal.ProposalID, record.UserAddress)
 		if err != nil {
 			slog.Warn("failed to get vote for this user", "user_address", record.UserAddress, "error", err)
 		} else {
-			emailVote.VoteIndexer = voteIndexer
+			source.vote = voteIndexer
 		}
 	}
+	s.loadRecordNames(record, source)
+	return source, nil
+}
+
+// loadRecordNames looks up the ENS names of the proposer of a new proposal and of the author of a comment
+func (s *TemplateService) loadRecordNames(record *dbmodels.NotificationRecord, source *recordTemplateSource) {
+	switch record.Type {
+	case dbmodels.SubscribeFeatureProposalNew:
+		ensName, err := s.userService.GetENSName(source.proposalIndexer.Proposer)
+		if err != nil {
+			slog.Warn("failed to query ens name for user", "user_address", source.proposalIndexer.Proposer, "error", err)
+		} else {
+			source.proposerEnsName = ensName
+		}
+	case dbmodels.SubscribeFeatureCommentReply, dbmodels.SubscribeFeatureCommentMention:
+		author, ok := s.parsePayload(record.Payload)["author"].(string)
+		if !ok || author == "" {
+			return
+		}
+		source.authorName = author
+		authorEnsName, err := s.userService.GetENSName(author)
+		if err != nil {
+			slog.Warn("failed to query ens name for comment author", "user_address", author, "error", err)
+		} else if authorEnsName != nil && *authorEnsName != "" {
+			source.authorName = *authorEnsName
+		}
+	}
+}
+
+// renderRecordTemplate renders the record in the locale and timezone of the preference, the inbox only gets the body
+func (s *TemplateService) renderRecordTemplate(record *dbmodels.NotificationRecord, source *recordTemplateSource, preference *dbmodels.NotificationPreference, inbox bool) (*types.TemplateOutput, error) {
+	dao := source.dao
+	daoConfig := source.daoConfig
+	proposal := source.proposal
+	proposalIndexer := source.proposalIndexer
+	emailVote := emailVoteInfo{VoteIndexer: source.vote}
+
+	locale := preference.Locale
+
+	// Parse payload data
+	payloadData := s.parsePayload(record.Payload)
+	title := recordTemplateTitle(locale, record.Type, dao.Name, proposal.Title)
+	emailProposal := emailProposalInfo{
+		ProposalDb:      proposal,
+		ProposalIndexer: proposalIndexer,
+	}
+
+	decimalsInt, err := strconv.Atoi(proposalIndexer.Decimals)
+	if err != nil {
+		slog.Warn("failed to parse decimals to int", "decimals", proposalIndexer.Decimals, "error", err)
+		payloadData["DecimalsInt"] = 1
+	} else {
+		payloadData["DecimalsInt"] = decimalsInt
+	}
 
 	switch record.Type {
 	case dbmodels.SubscribeFeatureProposalNew:
-		title = fmt.Sprintf("[%s] New Proposal: %s", dao.Name, proposal.Title)
 		if config.GetDegovSiteConfig().EmailProposalIncludeDescription {
 			proposalDescriptionHtml := mdToHTML([]byte(proposalIndexer.Description))
 			proposalDescriptionHtmlTplHtmlContent := tplHtml.HTML(proposalDescriptionHtml)
@@ -250,16 +434,16 @@ func (s *TemplateService) GenerateTemplateByNotificationRecord(record *dbmodels.
 			}
 		}
 
-		ensName, err := s.userService.GetENSName(proposalIndexer.Proposer)
-		if err != nil {
-			slog.Warn("failed to query ens name for user", "user_address", proposalIndexer.Proposer, "error", err)
-		} else {
-			emailProposal.ProposerEnsName = ensName
-		}
+		emailProposal.ProposerEnsName = source.proposerEnsName
 	case dbmodels.SubscribeFeatureProposalStateChanged:
-		title = fmt.Sprintf("[%s] Proposal Status Update: %s", dao.Name, proposal.Title)
-	case dbmodels.SubscribeFeatureVoteEnd:
-		title = fmt.Sprintf("[%s] Vote End Reminder: %s", dao.Name, proposal.Title)
+	case dbmodels.SubscribeFeatureVoteEnd, dbmodels.SubscribeFeatureVoteReminder:
+		if record.Type == dbmodels.SubscribeFeatureVoteReminder {
+			if power, ok := payloadData["power"].(string); ok && power != "" {
+				if formatted, err := utils.FormatBigIntWithDecimals(&power, payloadData["DecimalsInt"].(int)); err == nil {
+					payloadData["VotingPower"] = utils.FormatLargeNumberLocale(locale, formatted)
+				}
+			}
+		}
 		emailVote.TotalVotePower = calculateTotalVotePower(proposalIndexer)
 		if proposalIndexer.MetricsVotesWeightForSum != nil {
 			emailVote.PercentFor = utils.CalculateBigIntRatioPercentage(*proposalIndexer.MetricsVotesWeightForSum, emailVote.TotalVotePower)
@@ -275,18 +459,26 @@ func (s *TemplateService) GenerateTemplateByNotificationRecord(record *dbmodels.
 		if err != nil {
 			slog.Warn("failed to parse vote end timestamp", "timestamp", proposalIndexer.VoteEndTimestamp, "error", err)
 		} else {
-			payloadData["TimeRemaining"] = utils.FormatDurationShort(time.Until(voteEndTime))
+			payloadData["TimeRemaining"] = utils.FormatDurationShortLocale(locale, time.Until(voteEndTime))
 		}
 	case dbmodels.SubscribeFeatureVoteEmitted:
-		title = fmt.Sprintf("[%s] Vote Emitted: %s", dao.Name, proposal.Title)
+	case dbmodels.SubscribeFeatureExecutionReady:
+	case dbmodels.SubscribeFeatureExecutionExpiring:
+		_, expiresAt, err := proposalIndexer.ExecutionWindow()
+		if err != nil {
+			slog.Warn("failed to read execution window", "proposal_id", proposal.ProposalID, "error", err)
+		} else {
+			payloadData["TimeRemaining"] = utils.FormatDurationShortLocale(locale, time.Until(expiresAt))
+		}
+	case dbmodels.SubscribeFeatureCommentReply, dbmodels.SubscribeFeatureCommentMention:
+		if source.authorName != "" {
+			payloadData["AuthorName"] = source.authorName
+		}
 	}
 
-	ensName, err := s.userService.GetENSName(record.UserAddress)
-	if err != nil {
-		slog.Warn("failed to query ens name for user", "user_address", record.UserAddress, "error", err)
-	}
+	ensName := s.recipientEnsName(record)
 
-	degovSiteConfig := config.GetDegovSiteConfig()
+	degovSiteConfig := siteConfigForDao(daoConfig)
 
 	emailStyle := config.GetEmailStyle()
 	emailStyle.ContainerMaxWidth = "85%"
@@ -304,10 +496,16 @@ func (s *TemplateService) GenerateTemplateByNotificationRecord(record *dbmodels.
 		UserID:          record.UserID,
 		UserAddress:     record.UserAddress,
 		EnsName:         ensName,
+		Timezone:        preference.Timezone,
+		Locale:          locale,
 	}
+	templateData.UnsubscribeURL, templateData.UnsubscribeFeatureURL = s.unsubscribeLinks(record)
 
-	richTemplateFileName := s.getTemplateFileName(record.Type, "html")
-	plainTemplateFileName := s.getTemplateFileName(record.Type, "md")
+	richTemplateFileName := s.getTemplateFileName(record.Type, "html", locale)
+	plainTemplateFileName := s.getTemplateFileName(record.Type, "md", locale)
+	if inbox {
+		return s.renderInboxTemplate(title, plainTemplateFileName, templateData)
+	}
 
 	richText, err := s.renderTemplate(richTemplateFileName, templateData)
 	if err != nil {
@@ -322,9 +520,42 @@ func (s *TemplateService) GenerateTemplateByNotificationRecord(record *dbmodels.
 		Title:            utils.TruncateText(title, 80),
 		RichTextContent:  richText,
 		PlainTextContent: plainText,
+		Summary:          buildTemplateSummary(record, dao, proposal, proposalIndexer, payloadData, locale),
+		UnsubscribeURL:   templateData.UnsubscribeURL,
+		SenderName:       degovSiteConfig.SenderName,
 	}, nil
 }
 
+// recipientEnsName returns the ENS name of the user the record is sent to, nil when the record is rendered for the
+// inbox of every user
+func (s *TemplateService) recipientEnsName(record *dbmodels.NotificationRecord) *string {
+	if record.UserAddress == "" {
+		return nil
+	}
+	ensName, err := s.userService.GetENSName(record.UserAddress)
+	if err != nil {
+		slog.Warn("failed to query ens name for user", "user_address", record.UserAddress, "error", err)
+	}
+	return ensName
+}
+
+// unsubscribeLinks returns the signed links to leave the DAO (and the proposal) and to turn off the record's feature,
+// both empty when unsubscribe links are not configured.
+func (s *TemplateService) unsubscribeLinks(record *dbmodels.NotificationRecord) (string, string) {
+	if s.unsubscribeService == nil {
+		return "", ""
+	}
+	scope := UnsubscribeScope{
+		UserID:      record.UserID,
+		UserAddress: record.UserAddress,
+		DaoCode:     record.DaoCode,
+	}
+	featureScope := scope
+	featureScope.Feature = record.Type
+	scope.ProposalID = record.ProposalID
+	return s.unsubscribeService.Link(scope), s.unsubscribeService.Link(featureScope)
+}
+
 func (s *TemplateService) renderTemplate(templateName string, data interface{}) (string, error) {
 	var finData interface{}
 	templateData, serr := structToMap(data)
@@ -390,6 +621,26 @@ func (s *TemplateService) renderTemplate(templateName string, data interface{})
 	return renderedText, nil
 }
 
+// renderInboxTemplate renders only the content block of a plain text template, the body of the notification
+func (s *TemplateService) renderInboxTemplate(title string, templateName string, data interface{}) (*types.TemplateOutput, error) {
+	tmpl, ok := s.textTemplates[templateName]
+	if !ok {
+		return nil, fmt.Errorf("md template %s not found", templateName)
+	}
+	templateData, err := structToMap(data)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, "content", templateData); err != nil {
+		return nil, fmt.Errorf("failed to execute inbox template %s: %w", templateName, err)
+	}
+	return &types.TemplateOutput{
+		Title:            utils.TruncateText(title, 80),
+		PlainTextContent: strings.TrimSpace(buf.String()),
+	}, nil
+}
+
 func (s *TemplateService) GenerateTemplateOTP(input types.GenerateTemplateOTPInput) (*types.TemplateOutput, error) {
 	if input.EmailStyle == nil {
 		emailStyle := config.GetEmailStyle()
@@ -534,3 +785,68 @@ func calculateTotalVotePower(proposal *internal.Proposal) string {
 
 	return total.String()
 }
+
+// buildTemplateSummary collects the proposal facts chat channels show as structured fields
+func buildTemplateSummary(record *dbmodels.NotificationRecord, dao *gqlmodels.Dao, proposal *dbmodels.ProposalTracking, proposalIndexer *internal.Proposal, payloadData map[string]interface{}, locale string) *types.TemplateSummary {
+	summary := &types.TemplateSummary{
+		DaoName:       dao.Name,
+		ProposalTitle: proposal.Title,
+		ProposalLink:  proposal.ProposalLink,
+		ProposalState: string(proposal.State),
+		Headline:      templateSummaryHeadline(record.Type, locale),
+	}
+	if dao.Logo != nil {
+		summary.DaoLogo = *dao.Logo
+	}
+	if newState, ok := payloadData["new_state"].(string); ok && newState != "" {
+		summary.ProposalState = newState
+	}
+	if proposalIndexer == nil {
+		return summary
+	}
+
+	decimals, _ := payloadData["DecimalsInt"].(int)
+	format := func(value *string) string {
+		if value == nil || *value == "" {
+			return "0"
+		}
+		formatted, err := utils.FormatBigIntWithDecimals(value, decimals)
+		if err != nil {
+			return *value
+		}
+		return utils.FormatLargeNumberLocale(locale, formatted)
+	}
+
+	total := calculateTotalVotePower(proposalIndexer)
+	tally := &types.TemplateVoteTally{
+		For:     format(proposalIndexer.MetricsVotesWeightForSum),
+		Against: format(proposalIndexer.MetricsVotesWeightAgainstSum),
+		Abstain: format(proposalIndexer.MetricsVotesWeightAbstainSum),
+		Total:   format(&total),
+		Quorum:  format(&proposalIndexer.Quorum),
+	}
+	if proposalIndexer.MetricsVotesWeightForSum != nil {
+		tally.PercentFor = utils.CalculateBigIntRatioPercentage(*proposalIndexer.MetricsVotesWeightForSum, total)
+	}
+	if proposalIndexer.MetricsVotesWeightAgainstSum != nil {
+		tally.PercentAgainst = utils.CalculateBigIntRatioPercentage(*proposalIndexer.MetricsVotesWeightAgainstSum, total)
+	}
+	if proposalIndexer.MetricsVotesWeightAbstainSum != nil {
+		tally.PercentAbstain = utils.CalculateBigIntRatioPercentage(*proposalIndexer.MetricsVotesWeightAbstainSum, total)
+	}
+	if proposalIndexer.Quorum != "" {
+		tally.PercentQuorum = utils.CalculateBigIntRatioPercentage(total, proposalIndexer.Quorum)
+	}
+	summary.Votes = tally
+	return summary
+}
+
+// userPreference returns the notification preference of the user, the defaults when it cannot be loaded
+func (s *TemplateService) userPreference(userID string) *dbmodels.NotificationPreference {
+	preference, err := s.preferenceService.Inspect(userID)
+	if err != nil {
+		slog.Warn("failed to load notification preference", "user_id", userID, "error", err)
+		return defaultNotificationPreference(userID)
+	}
+	return preference
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ringecosystem/degov-square/internal/config"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+const (
+	maxBrandingNameLength   = 64
+	maxBrandingFooterLength = 500
+	maxBrandingSocials      = 6
+)
+
+var brandingColorPattern = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// ValidateDaoBranding checks the branding a DAO declares in its registry config. Links and images must be absolute
+// https URLs and colors hex codes, they end up in the CSS and the markup of every email of the DAO.
+func ValidateDaoBranding(branding *types.DaoBranding) error {
+	if branding == nil {
+		return nil
+	}
+	for field, value := range map[string]string{"name": branding.Name, "senderName": branding.SenderName} {
+		if utf8.RuneCountInString(value) > maxBrandingNameLength {
+			return fmt.Errorf("branding %s is longer than %d characters", field, maxBrandingNameLength)
+		}
+		if strings.ContainsAny(value, "\r\n\"<>") {
+			return fmt.Errorf("branding %s contains invalid characters", field)
+		}
+	}
+	if utf8.RuneCountInString(branding.Footer) > maxBrandingFooterLength {
+		return fmt.Errorf("branding footer is longer than %d characters", maxBrandingFooterLength)
+	}
+	if branding.Theme != "" && branding.Theme != "dark" && branding.Theme != "light" {
+		return fmt.Errorf("branding theme %q must be dark or light", branding.Theme)
+	}
+	for field, value := range map[string]string{
+		"logo":      branding.Logo,
+		"logoLight": branding.LogoLight,
+		"logoDark":  branding.LogoDark,
+		"home":      branding.Home,
+	} {
+		if err := validateBrandingURL(value); err != nil {
+			return fmt.Errorf("branding %s: %w", field, err)
+		}
+	}
+	for field, value := range map[string]string{
+		"primary":     branding.Colors.Primary,
+		"primaryText": branding.Colors.PrimaryText,
+		"link":        branding.Colors.Link,
+	} {
+		if value != "" && !brandingColorPattern.MatchString(value) {
+			return fmt.Errorf("branding color %s %q is not a hex color", field, value)
+		}
+	}
+	if len(branding.Socials) > maxBrandingSocials {
+		return fmt.Errorf("branding has more than %d socials", maxBrandingSocials)
+	}
+	for i, social := range branding.Socials {
+		if social.Name == "" || social.Link == "" {
+			return fmt.Errorf("branding social %d needs a name and a link", i)
+		}
+		if social.Icon == "" && (social.IconLight == "" || social.IconDark == "") {
+			return fmt.Errorf("branding social %s needs an icon", social.Name)
+		}
+		for _, value := range []string{social.Link, social.Icon, social.IconLight, social.IconDark} {
+			if err := validateBrandingURL(value); err != nil {
+				return fmt.Errorf("branding social %s: %w", social.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func validateBrandingURL(value string) error {
+	if value == "" {
+		return nil
+	}
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+		return fmt.Errorf("%q is not an absolute https URL", value)
+	}
+	return nil
+}
+
+// applyDaoBranding overlays the branding of a DAO on the site config, fields the DAO leaves empty keep the site value
+func applyDaoBranding(site types.DegovSiteConfig, branding *types.DaoBranding) types.DegovSiteConfig {
+	if branding == nil {
+		return site
+	}
+	setIfNotEmpty := func(target *string, value string) {
+		if value != "" {
+			*target = value
+		}
+	}
+	setIfNotEmpty(&site.Name, branding.Name)
+	setIfNotEmpty(&site.Home, branding.Home)
+	setIfNotEmpty(&site.EmailTheme, branding.Theme)
+	setIfNotEmpty(&site.Footer, branding.Footer)
+	setIfNotEmpty(&site.Colors.Primary, branding.Colors.Primary)
+	setIfNotEmpty(&site.Colors.PrimaryText, branding.Colors.PrimaryText)
+	setIfNotEmpty(&site.Colors.Link, branding.Colors.Link)
+	if branding.Logo != "" {
+		// a single logo is used on both themes unless the DAO has one per theme
+		site.Logo, site.LogoLight, site.LogoDark = branding.Logo, branding.Logo, branding.Logo
+	}
+	setIfNotEmpty(&site.LogoLight, branding.LogoLight)
+	setIfNotEmpty(&site.LogoDark, branding.LogoDark)
+
+	site.SenderName = branding.SenderName
+	if site.SenderName == "" && branding.Name != "" {
+		site.SenderName = branding.Name
+	}
+	if len(branding.Socials) > 0 {
+		site.Socials = make([]types.DegovSiteConfigSocial, 0, len(branding.Socials))
+		for _, social := range branding.Socials {
+			item := types.DegovSiteConfigSocial{
+				Name:      social.Name,
+				Link:      social.Link,
+				Icon:      social.Icon,
+				IconLight: social.IconLight,
+				IconDark:  social.IconDark,
+			}
+			if item.IconLight == "" {
+				item.IconLight = social.Icon
+			}
+			if item.IconDark == "" {
+				item.IconDark = social.Icon
+			}
+			if item.Icon == "" {
+				item.Icon = item.IconLight
+			}
+			site.Socials = append(site.Socials, item)
+		}
+	}
+	return site
+}
+
+// siteConfigForDao returns the site config emails of the DAO are rendered with, the global one when the DAO has
+// no branding or its branding is invalid
+func siteConfigForDao(daoConfig *types.DaoConfig) types.DegovSiteConfig {
+	site := config.GetDegovSiteConfig()
+	if daoConfig == nil || daoConfig.Branding == nil {
+		return site
+	}
+	if err := ValidateDaoBranding(daoConfig.Branding); err != nil {
+		slog.Warn("ignoring invalid DAO branding", "dao", daoConfig.Code, "error", err)
+		return site
+	}
+	return applyDaoBranding(site, daoConfig.Branding)
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	gqlmodels "github.com/ringecosystem/degov-square/graph/models"
+	"github.com/ringecosystem/degov-square/types"
+	"gopkg.in/yaml.v3"
+)
+
+const brandedDaoConfig = `
+code: demo
+name: Demo DAO
+branding:
+  name: Demo DAO
+  senderName: Demo DAO Governance
+  logo: https://demo.example/logo.png
+  theme: light
+  colors:
+    primary: "#FF0066"
+    link: "#0af"
+  socials:
+    - name: X
+      link: https://x.com/demo
+      icon: https://demo.example/x.png
+  footer: Demo DAO is governed onchain by its token holders.
+`
+
+func TestValidateDaoBranding(t *testing.T) {
+	var daoConfig types.DaoConfig
+	if err := yaml.Unmarshal([]byte(brandedDaoConfig), &daoConfig); err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	if err := ValidateDaoBranding(daoConfig.Branding); err != nil {
+		t.Fatalf("expected the branding to be valid, got %v", err)
+	}
+
+	for name, mutate := range map[string]func(b *types.DaoBranding){
+		"http logo":      func(b *types.DaoBranding) { b.Logo = "http://demo.example/logo.png" },
+		"css in color":   func(b *types.DaoBranding) { b.Colors.Primary = "red; background:url(https://evil.example)" },
+		"unknown theme":  func(b *types.DaoBranding) { b.Theme = "neon" },
+		"header in name": func(b *types.DaoBranding) { b.SenderName = "Demo\r\nBcc: victim@example.com" },
+		"social no icon": func(b *types.DaoBranding) { b.Socials[0].Icon = "" },
+	} {
+		branding := *daoConfig.Branding
+		branding.Socials = append([]types.DaoBrandingSocial(nil), daoConfig.Branding.Socials...)
+		mutate(&branding)
+		if err := ValidateDaoBranding(&branding); err == nil {
+			t.Fatalf("%s: expected the branding to be refused", name)
+		}
+	}
+}
+
+func TestApplyDaoBrandingKeepsSiteDefaults(t *testing.T) {
+	var daoConfig types.DaoConfig
+	if err := yaml.Unmarshal([]byte(brandedDaoConfig), &daoConfig); err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	site := types.DegovSiteConfig{
+		Name:      "DeGov.AI",
+		Home:      "https://degov.ai",
+		Docs:      "https://docs.degov.ai",
+		LogoLight: "https://degov.ai/light.png",
+		LogoDark:  "https://degov.ai/dark.png",
+		Socials:   []types.DegovSiteConfigSocial{{Name: "GitHub", Link: "https://github.com/ringecosystem/degov"}},
+	}
+	branded := applyDaoBranding(site, daoConfig.Branding)
+	if branded.Name != "Demo DAO" || branded.SenderName != "Demo DAO Governance" || branded.EmailTheme != "light" {
+		t.Fatalf("unexpected branded config %+v", branded)
+	}
+	if branded.Home != "https://degov.ai" || branded.Docs != "https://docs.degov.ai" {
+		t.Fatalf("expected unset fields to keep the site config, got %+v", branded)
+	}
+	if branded.LogoLight != "https://demo.example/logo.png" || branded.LogoDark != "https://demo.example/logo.png" {
+		t.Fatalf("expected the logo on both themes, got %q %q", branded.LogoLight, branded.LogoDark)
+	}
+	if len(branded.Socials) != 1 || branded.Socials[0].IconDark != "https://demo.example/x.png" {
+		t.Fatalf("unexpected socials %+v", branded.Socials)
+	}
+
+	service := NewTemplateService()
+	output, err := service.renderDigestTemplate(templateDigestData{
+		DegovSiteConfig: branded,
+		EmailStyle:      &types.EmailStyle{},
+		Period:          digestPeriod(dbmodels.SubscribeStrategyDailyDigest),
+		Count:           1,
+		Daos: []*digestDaoSection{{
+			Dao: &gqlmodels.Dao{Code: "demo", Name: "Demo DAO"},
+			Proposals: []*digestProposalSection{{
+				Proposal: &dbmodels.ProposalTracking{ProposalID: "0x01", Title: "Raise quorum", ProposalLink: "https://demo.degov.ai/proposal/0x01"},
+				New:      true,
+			}},
+		}},
+		UserAddress: "0xabc",
+	})
+	if err != nil {
+		t.Fatalf("render digest: %v", err)
+	}
+	if output.Title != "[Demo DAO] Your daily digest: 1 update" || output.SenderName != "Demo DAO Governance" {
+		t.Fatalf("unexpected output %q %q", output.Title, output.SenderName)
+	}
+	for _, want := range []string{"color:#0af", "Demo DAO is governed onchain by its token holders.", "https://demo.example/logo.png"} {
+		if !strings.Contains(output.RichTextContent, want) {
+			t.Fatalf("html digest missing %q in:\n%s", want, output.RichTextContent)
+		}
+	}
+	if !strings.Contains(output.PlainTextContent, "## Demo DAO") || !strings.Contains(output.PlainTextContent, "Demo DAO is governed onchain") {
+		t.Fatalf("plain digest is not branded:\n%s", output.PlainTextContent)
+	}
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	gqlmodels "github.com/ringecosystem/degov-square/graph/models"
+	"github.com/ringecosystem/degov-square/internal/config"
+	"github.com/ringecosystem/degov-square/internal/utils"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+type templateDelegationData struct {
+	DegovSiteConfig       types.DegovSiteConfig `json:"degov_site_config"`
+	EmailStyle            *types.EmailStyle     `json:"email_style"`
+	Title                 *string               `json:"title"`
+	DaoConfig             *types.DaoConfig      `json:"dao_config"`
+	Dao                   *gqlmodels.Dao        `json:"dao"`
+	Delegation            *emailDelegationInfo  `json:"delegation"`
+	EventID               string                `json:"event_id"`
+	UserID                string                `json:"user_id"`
+	UserAddress           string                `json:"user_address"`
+	EnsName               *string               `json:"ens_name"`
+	Timezone              string                `json:"timezone"`
+	Locale                string                `json:"locale"`
+	UnsubscribeURL        string                `json:"unsubscribe_url"`
+	UnsubscribeFeatureURL string                `json:"unsubscribe_feature_url"`
+}
+
+// emailDelegationInfo holds the delegation change with powers already formatted for display
+type emailDelegationInfo struct {
+	PowerGained string                     `json:"power_gained"`
+	PowerLost   string                     `json:"power_lost"`
+	Changes     []emailDelegationChangeRow `json:"changes"`
+}
+
+type emailDelegationChangeRow struct {
+	Delegator     string  `json:"delegator"`
+	EnsName       *string `json:"ens_name"`
+	PreviousPower string  `json:"previous_power"`
+	Power         string  `json:"power"`
+	Removed       bool    `json:"removed"`
+	Added         bool    `json:"added"`
+}
+
+// delegationTemplateSource is the decoded change and the ENS names of its delegators a delegation record is rendered with
+type delegationTemplateSource struct {
+	dao               *gqlmodels.Dao
+	daoConfig         *types.DaoConfig
+	payload           types.DelegationEventPayload
+	delegatorEnsNames map[string]*string
+}
+
+func (s *TemplateService) loadDelegationSource(record *dbmodels.NotificationRecord, dao *gqlmodels.Dao) (*delegationTemplateSource, error) {
+	if record.Payload == nil {
+		return nil, errors.New("delegation notification has no payload")
+	}
+	source := &delegationTemplateSource{
+		dao:               dao,
+		delegatorEnsNames: make(map[string]*string),
+	}
+	if err := json.Unmarshal([]byte(*record.Payload), &source.payload); err != nil {
+		return nil, fmt.Errorf("failed to decode delegation payload: %w", err)
+	}
+
+	daoConfig, err := s.daoConfigService.StandardConfig(dao.Code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DAO config info: %w", err)
+	}
+	source.daoConfig = daoConfig
+
+	for _, change := range source.payload.Changes {
+		ensName, err := s.userService.GetENSName(change.Delegator)
+		if err != nil {
+			slog.Warn("failed to query ens name for delegator", "delegator", change.Delegator, "error", err)
+			continue
+		}
+		source.delegatorEnsNames[change.Delegator] = ensName
+	}
+	return source, nil
+}
+
+// renderDelegationTemplate renders the record in the locale and timezone of the preference, the inbox only gets the body
+func (s *TemplateService) renderDelegationTemplate(record *dbmodels.NotificationRecord, source *delegationTemplateSource, preference *dbmodels.NotificationPreference, inbox bool) (*types.TemplateOutput, error) {
+	dao := source.dao
+	daoConfig := source.daoConfig
+	payload := source.payload
+	locale := preference.Locale
+
+	delegation := &emailDelegationInfo{
+		PowerGained: formatDelegationPower(locale, payload.PowerGained, payload.Decimals),
+		PowerLost:   formatDelegationPower(locale, payload.PowerLost, payload.Decimals),
+		Changes:     make([]emailDelegationChangeRow, 0, len(payload.Changes)),
+	}
+	for _, change := range payload.Changes {
+		delegation.Changes = append(delegation.Changes, emailDelegationChangeRow{
+			Delegator:     change.Delegator,
+			EnsName:       source.delegatorEnsNames[change.Delegator],
+			PreviousPower: formatDelegationPower(locale, change.PreviousPower, payload.Decimals),
+			Power:         formatDelegationPower(locale, change.Power, payload.Decimals),
+			Added:         change.PreviousPower == "0",
+			Removed:       change.Power == "0",
+		})
+	}
+
+	ensName := s.recipientEnsName(record)
+
+	title := recordTemplateTitle(locale, record.Type, dao.Name, "")
+	emailStyle := config.GetEmailStyle()
+	emailStyle.ContainerMaxWidth = "85%"
+	templateData := templateDelegationData{
+		DegovSiteConfig: siteConfigForDao(daoConfig),
+		EmailStyle:      &emailStyle,
+		Title:           &title,
+		DaoConfig:       daoConfig,
+		Dao:             dao,
+		Delegation:      delegation,
+		EventID:         record.EventID,
+		UserID:          record.UserID,
+		UserAddress:     record.UserAddress,
+		EnsName:         ensName,
+		Timezone:        preference.Timezone,
+		Locale:          locale,
+	}
+	templateData.UnsubscribeURL, templateData.UnsubscribeFeatureURL = s.unsubscribeLinks(record)
+
+	richTemplateFileName := s.getTemplateFileName(record.Type, "html", locale)
+	plainTemplateFileName := s.getTemplateFileName(record.Type, "md", locale)
+	if inbox {
+		return s.renderInboxTemplate(title, plainTemplateFileName, templateData)
+	}
+	richText, err := s.renderTemplate(richTemplateFileName, templateData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render rich text template %s: %w", richTemplateFileName, err)
+	}
+	plainText, err := s.renderTemplate(plainTemplateFileName, templateData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render plain text template %s: %w", plainTemplateFileName, err)
+	}
+
+	return &types.TemplateOutput{
+		Title:            utils.TruncateText(title, 80),
+		RichTextContent:  richText,
+		PlainTextContent: plainText,
+		UnsubscribeURL:   templateData.UnsubscribeURL,
+		SenderName:       templateData.DegovSiteConfig.SenderName,
+	}, nil
+}
+
+func formatDelegationPower(locale string, power string, decimals int) string {
+	formatted, err := utils.FormatBigIntWithDecimals(&power, decimals)
+	if err != nil {
+		slog.Warn("failed to format delegation power", "power", power, "error", err)
+		return power
+	}
+	return utils.FormatLargeNumberLocale(locale, formatted)
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	gqlmodels "github.com/ringecosystem/degov-square/graph/models"
+	"github.com/ringecosystem/degov-square/internal/config"
+	"github.com/ringecosystem/degov-square/internal/utils"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+type templateDigestData struct {
+	DegovSiteConfig types.DegovSiteConfig `json:"degov_site_config"`
+	EmailStyle      *types.EmailStyle     `json:"email_style"`
+	Title           *string               `json:"title"`
+	Period          string                `json:"period"`
+	Count           int                   `json:"count"`
+	Daos            []*digestDaoSection   `json:"daos"`
+	UserAddress     string                `json:"user_address"`
+	EnsName         *string               `json:"ens_name"`
+	// Timezone and Locale of the user the digest goes to
+	Timezone string `json:"timezone"`
+	Locale   string `json:"locale"`
+	// UnsubscribeURL is only set when every record of the digest belongs to the same DAO
+	UnsubscribeURL string `json:"unsubscribe_url"`
+}
+
+type digestDaoSection struct {
+	Dao       *gqlmodels.Dao           `json:"dao"`
+	Proposals []*digestProposalSection `json:"proposals"`
+}
+
+type digestProposalSection struct {
+	Proposal *dbmodels.ProposalTracking `json:"proposal"`
+	New      bool                       `json:"new"`
+	States   []string                   `json:"states"`
+	Votes    int                        `json:"votes"`
+	VoteEnd  bool                       `json:"vote_end"`
+}
+
+type digestLookup struct {
+	dao      func(code string) (*gqlmodels.Dao, error)
+	proposal func(daoCode, proposalID string) (*dbmodels.ProposalTracking, error)
+}
+
+func digestPeriod(digest string) string {
+	if digest == dbmodels.SubscribeStrategyHourlyDigest {
+		return "hourly"
+	}
+	return "daily"
+}
+
+// groupDigestRecords folds the records of a digest into one entry per proposal, keeping the order they happened in.
+// Votes are counted instead of listed, a busy proposal takes a single line.
+func (s *TemplateService) groupDigestRecords(records []dbmodels.NotificationRecord, lookup digestLookup) ([]*digestDaoSection, error) {
+	var sections []*digestDaoSection
+	daoSections := make(map[string]*digestDaoSection)
+	proposalSections := make(map[string]*digestProposalSection)
+
+	for _, record := range records {
+		daoSection, ok := daoSections[record.DaoCode]
+		if !ok {
+			dao, err := lookup.dao(record.DaoCode)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get DAO info: %w", err)
+			}
+			daoSection = &digestDaoSection{Dao: dao}
+			daoSections[record.DaoCode] = daoSection
+			sections = append(sections, daoSection)
+		}
+
+		key := record.DaoCode + "/" + record.ProposalID
+		proposalSection, ok := proposalSections[key]
+		if !ok {
+			proposal, err := lookup.proposal(record.DaoCode, record.ProposalID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get proposal info: %w", err)
+			}
+			proposalSection = &digestProposalSection{Proposal: proposal}
+			proposalSections[key] = proposalSection
+			daoSection.Proposals = append(daoSection.Proposals, proposalSection)
+		}
+
+		switch record.Type {
+		case dbmodels.SubscribeFeatureProposalNew:
+			proposalSection.New = true
+		case dbmodels.SubscribeFeatureProposalStateChanged:
+			payload := s.parsePayload(record.Payload)
+			if state, ok := payload["new_state"].(string); ok && state != "" {
+				proposalSection.States = append(proposalSection.States, state)
+			}
+		case dbmodels.SubscribeFeatureVoteEmitted:
+			proposalSection.Votes++
+		case dbmodels.SubscribeFeatureVoteEnd:
+			proposalSection.VoteEnd = true
+		}
+	}
+	return sections, nil
+}
+
+// GenerateDigestTemplate renders a single message that summarizes every record of a digest window.
+func (s *TemplateService) GenerateDigestTemplate(input types.GenerateDigestTemplateInput) (*types.TemplateOutput, error) {
+	if len(input.Records) == 0 {
+		return nil, errors.New("digest has no records")
+	}
+
+	sections, err := s.groupDigestRecords(input.Records, digestLookup{
+		dao: func(code string) (*gqlmodels.Dao, error) {
+			return s.daoService.Inspect(types.BasicInput[string]{Input: code})
+		},
+		proposal: func(daoCode, proposalID string) (*dbmodels.ProposalTracking, error) {
+			return s.proposalService.InspectProposal(types.InspectProposalInput{DaoCode: daoCode, ProposalID: proposalID})
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ensName, err := s.userService.GetENSName(input.UserAddress)
+	if err != nil {
+		slog.Warn("failed to query ens name for user", "user_address", input.UserAddress, "error", err)
+	}
+
+	// a digest of a single DAO is sent with the branding of the DAO
+	siteConfig := config.GetDegovSiteConfig()
+	unsubscribeURL := ""
+	if len(sections) == 1 {
+		first := input.Records[0]
+		if daoConfig, err := s.daoConfigService.StandardConfig(first.DaoCode); err != nil {
+			slog.Warn("failed to get DAO config for digest branding", "dao", first.DaoCode, "error", err)
+		} else {
+			siteConfig = siteConfigForDao(daoConfig)
+		}
+		if s.unsubscribeService != nil {
+			unsubscribeURL = s.unsubscribeService.Link(UnsubscribeScope{
+				UserID:      first.UserID,
+				UserAddress: first.UserAddress,
+				DaoCode:     first.DaoCode,
+			})
+		}
+	}
+
+	preference := s.userPreference(input.Records[0].UserID)
+	emailStyle := config.GetEmailStyle()
+	emailStyle.ContainerMaxWidth = "85%"
+	return s.renderDigestTemplate(templateDigestData{
+		DegovSiteConfig: siteConfig,
+		EmailStyle:      &emailStyle,
+		Period:          digestPeriod(input.Digest),
+		Count:           len(input.Records),
+		Daos:            sections,
+		UserAddress:     input.UserAddress,
+		EnsName:         ensName,
+		Timezone:        preference.Timezone,
+		Locale:          preference.Locale,
+		UnsubscribeURL:  unsubscribeURL,
+	})
+}
+
+func (s *TemplateService) renderDigestTemplate(data templateDigestData) (*types.TemplateOutput, error) {
+	title := digestTemplateTitle(data.Locale, data.DegovSiteConfig.Name, data.Period, data.Count)
+	data.Title = &title
+
+	richTemplateFileName := s.localizedTemplateFileName("digest", "html", data.Locale)
+	plainTemplateFileName := s.localizedTemplateFileName("digest", "md", data.Locale)
+	richText, err := s.renderTemplate(richTemplateFileName, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render rich text template %s: %w", richTemplateFileName, err)
+	}
+	plainText, err := s.renderTemplate(plainTemplateFileName, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render plain text template %s: %w", plainTemplateFileName, err)
+	}
+
+	return &types.TemplateOutput{
+		Title:            utils.TruncateText(title, 80),
+		RichTextContent:  richText,
+		PlainTextContent: plainText,
+		UnsubscribeURL:   data.UnsubscribeURL,
+		SenderName:       data.DegovSiteConfig.SenderName,
+	}, nil
+}
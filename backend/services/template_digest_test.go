@@ -0,0 +1,66 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	gqlmodels "github.com/ringecosystem/degov-square/graph/models"
+	"github.com/ringecosystem/degov-square/internal/utils"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+func TestDigestTemplateFoldsRecordsPerProposal(t *testing.T) {
+	service := NewTemplateService()
+	records := []dbmodels.NotificationRecord{
+		{DaoCode: "demo", ProposalID: "0x01", Type: dbmodels.SubscribeFeatureProposalNew},
+		{DaoCode: "demo", ProposalID: "0x01", Type: dbmodels.SubscribeFeatureVoteEmitted},
+		{DaoCode: "demo", ProposalID: "0x02", Type: dbmodels.SubscribeFeatureProposalStateChanged, Payload: utils.StringPtr(`{"new_state":"SUCCEEDED"}`)},
+		{DaoCode: "demo", ProposalID: "0x01", Type: dbmodels.SubscribeFeatureVoteEmitted},
+	}
+	sections, err := service.groupDigestRecords(records, digestLookup{
+		dao: func(code string) (*gqlmodels.Dao, error) {
+			return &gqlmodels.Dao{Code: code, Name: "Demo DAO"}, nil
+		},
+		proposal: func(daoCode, proposalID string) (*dbmodels.ProposalTracking, error) {
+			return &dbmodels.ProposalTracking{ProposalID: proposalID, Title: "Proposal " + proposalID, ProposalLink: "https://demo.degov.ai/proposal/" + proposalID}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("group digest records: %v", err)
+	}
+	if len(sections) != 1 || len(sections[0].Proposals) != 2 {
+		t.Fatalf("expected one DAO with two proposals, got %+v", sections)
+	}
+	first := sections[0].Proposals[0]
+	if !first.New || first.Votes != 2 {
+		t.Fatalf("unexpected first proposal entry: %+v", first)
+	}
+
+	output, err := service.renderDigestTemplate(templateDigestData{
+		DegovSiteConfig: types.DegovSiteConfig{Name: "DeGov.AI"},
+		EmailStyle:      &types.EmailStyle{},
+		Period:          digestPeriod(dbmodels.SubscribeStrategyHourlyDigest),
+		Count:           len(records),
+		Daos:            sections,
+		UserAddress:     "0xabc",
+	})
+	if err != nil {
+		t.Fatalf("render digest: %v", err)
+	}
+	if output.Title != "[DeGov.AI] Your hourly digest: 4 updates" {
+		t.Fatalf("unexpected title: %q", output.Title)
+	}
+	for _, want := range []string{
+		"### **Demo DAO**",
+		"- [Proposal 0x01](https://demo.degov.ai/proposal/0x01)\n  - New proposal\n  - 2 new votes",
+		"Status changed to **SUCCEEDED**",
+	} {
+		if !strings.Contains(output.PlainTextContent, want) {
+			t.Fatalf("digest missing %q in:\n%s", want, output.PlainTextContent)
+		}
+	}
+	if !strings.Contains(output.RichTextContent, "2 new votes") {
+		t.Fatalf("html digest missing vote count")
+	}
+}
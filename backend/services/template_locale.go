@@ -0,0 +1,103 @@
+package services
+
+import (
+	"fmt"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	"github.com/ringecosystem/degov-square/internal/utils"
+)
+
+// templateHeadlines name each notification type in titles and chat messages, missing translations use English
+var templateHeadlines = map[string]map[dbmodels.SubscribeFeatureName]string{
+	utils.LocaleEnglish: {
+		dbmodels.SubscribeFeatureProposalNew:          "New Proposal",
+		dbmodels.SubscribeFeatureProposalStateChanged: "Proposal Status Update",
+		dbmodels.SubscribeFeatureVoteEnd:              "Vote End Reminder",
+		dbmodels.SubscribeFeatureVoteEmitted:          "Vote Emitted",
+		dbmodels.SubscribeFeatureDelegationChanged:    "Delegation Changed",
+		dbmodels.SubscribeFeatureExecutionReady:       "Ready For Execution",
+		dbmodels.SubscribeFeatureExecutionExpiring:    "Execution Window Closing",
+		dbmodels.SubscribeFeatureVoteReminder:         "You Have Not Voted Yet",
+		dbmodels.SubscribeFeatureCommentReply:         "New Reply",
+		dbmodels.SubscribeFeatureCommentMention:       "You Were Mentioned",
+	},
+	utils.LocaleChinese: {
+		dbmodels.SubscribeFeatureProposalNew:          "新提案",
+		dbmodels.SubscribeFeatureProposalStateChanged: "提案状态更新",
+		dbmodels.SubscribeFeatureVoteEnd:              "投票即将结束",
+		dbmodels.SubscribeFeatureVoteEmitted:          "新投票",
+		dbmodels.SubscribeFeatureDelegationChanged:    "委托变更",
+		dbmodels.SubscribeFeatureExecutionReady:       "提案可执行",
+		dbmodels.SubscribeFeatureExecutionExpiring:    "执行窗口即将关闭",
+		dbmodels.SubscribeFeatureVoteReminder:         "您尚未投票",
+		dbmodels.SubscribeFeatureCommentReply:         "新回复",
+		dbmodels.SubscribeFeatureCommentMention:       "有人提到了您",
+	},
+}
+
+var proposalStateNames = map[string]map[string]string{
+	utils.LocaleChinese: {
+		string(dbmodels.ProposalStatePending):   "待投票",
+		string(dbmodels.ProposalStateActive):    "投票中",
+		string(dbmodels.ProposalStateCanceled):  "已取消",
+		string(dbmodels.ProposalStateDefeated):  "未通过",
+		string(dbmodels.ProposalStateSucceeded): "已通过",
+		string(dbmodels.ProposalStateQueued):    "已排队",
+		string(dbmodels.ProposalStateExecuted):  "已执行",
+		string(dbmodels.ProposalStateExpired):   "已过期",
+	},
+}
+
+func templateSummaryHeadline(notificationType dbmodels.SubscribeFeatureName, locale string) string {
+	if headline, ok := templateHeadlines[locale][notificationType]; ok {
+		return headline
+	}
+	if headline, ok := templateHeadlines[utils.LocaleEnglish][notificationType]; ok {
+		return headline
+	}
+	if locale == utils.LocaleChinese {
+		return "通知"
+	}
+	return "Notification"
+}
+
+// recordTemplateTitle is the subject of a notification, "[DAO] Headline: Proposal title"
+func recordTemplateTitle(locale string, notificationType dbmodels.SubscribeFeatureName, daoName, proposalTitle string) string {
+	if _, ok := templateHeadlines[utils.LocaleEnglish][notificationType]; !ok {
+		if locale == utils.LocaleChinese {
+			return "来自 DeGov.AI 的新通知"
+		}
+		return "New notification from DeGov.AI"
+	}
+	headline := templateSummaryHeadline(notificationType, locale)
+	if proposalTitle == "" {
+		return fmt.Sprintf("[%s] %s", daoName, headline)
+	}
+	if locale == utils.LocaleChinese {
+		return fmt.Sprintf("[%s] %s：%s", daoName, headline, proposalTitle)
+	}
+	return fmt.Sprintf("[%s] %s: %s", daoName, headline, proposalTitle)
+}
+
+func digestTemplateTitle(locale, siteName, period string, count int) string {
+	if locale == utils.LocaleChinese {
+		periodName := "每日"
+		if period == "hourly" {
+			periodName = "每小时"
+		}
+		return fmt.Sprintf("[%s] 您的%s摘要：%d 条更新", siteName, periodName, count)
+	}
+	updates := "updates"
+	if count == 1 {
+		updates = "update"
+	}
+	return fmt.Sprintf("[%s] Your %s digest: %d %s", siteName, period, count, updates)
+}
+
+// proposalStateName translates a proposal state for templates, untranslated states are shown as they are
+func proposalStateName(locale string, state string) string {
+	if name, ok := proposalStateNames[locale][state]; ok {
+		return name
+	}
+	return state
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	gqlmodels "github.com/ringecosystem/degov-square/graph/models"
+	"github.com/ringecosystem/degov-square/internal/utils"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+func TestLocalizedTemplateFallsBackToEnglish(t *testing.T) {
+	service := NewTemplateService()
+	if name := service.getTemplateFileName(dbmodels.SubscribeFeatureProposalNew, "md", utils.LocaleChinese); name != "proposal_new.zh-CN.md" {
+		t.Fatalf("expected the translated template, got %s", name)
+	}
+	if name := service.getTemplateFileName(dbmodels.SubscribeFeatureProposalNew, "html", "fr"); name != "proposal_new.html" {
+		t.Fatalf("expected the English template for an unknown locale, got %s", name)
+	}
+	if name := service.getTemplateFileName("UNKNOWN", "md", utils.LocaleChinese); name != "unknown.md" {
+		t.Fatalf("expected the English template when there is no translation, got %s", name)
+	}
+	if title := recordTemplateTitle(utils.LocaleChinese, dbmodels.SubscribeFeatureVoteReminder, "Demo DAO", "Raise quorum"); title != "[Demo DAO] 您尚未投票：Raise quorum" {
+		t.Fatalf("unexpected title %q", title)
+	}
+}
+
+func TestDigestTemplateInChinese(t *testing.T) {
+	service := NewTemplateService()
+	output, err := service.renderDigestTemplate(templateDigestData{
+		DegovSiteConfig: types.DegovSiteConfig{Name: "DeGov.AI"},
+		EmailStyle:      &types.EmailStyle{},
+		Period:          digestPeriod(dbmodels.SubscribeStrategyDailyDigest),
+		Count:           2,
+		Daos: []*digestDaoSection{{
+			Dao: &gqlmodels.Dao{Code: "demo", Name: "Demo DAO"},
+			Proposals: []*digestProposalSection{{
+				Proposal: &dbmodels.ProposalTracking{ProposalID: "0x01", Title: "Raise quorum", ProposalLink: "https://demo.degov.ai/proposal/0x01"},
+				States:   []string{string(dbmodels.ProposalStateSucceeded)},
+				Votes:    1,
+			}},
+		}},
+		UserAddress:    "0xabc",
+		Locale:         utils.LocaleChinese,
+		UnsubscribeURL: "https://square.degov.ai/unsubscribe?token=t",
+	})
+	if err != nil {
+		t.Fatalf("render digest: %v", err)
+	}
+	if output.Title != "[DeGov.AI] 您的每日摘要：2 条更新" {
+		t.Fatalf("unexpected title: %q", output.Title)
+	}
+	for _, want := range []string{
+		"0xabc，您好：",
+		"状态变更为 **已通过**",
+		"1 张新投票",
+		"退订 https://square.degov.ai/unsubscribe?token=t",
+	} {
+		if !strings.Contains(output.PlainTextContent, want) {
+			t.Fatalf("digest missing %q in:\n%s", want, output.PlainTextContent)
+		}
+	}
+	if strings.Contains(output.PlainTextContent, "Want to change how you receive these emails?") {
+		t.Fatalf("expected the footer to be translated:\n%s", output.PlainTextContent)
+	}
+	if !strings.Contains(output.RichTextContent, `<html lang="zh-CN">`) || !strings.Contains(output.RichTextContent, "更新订阅偏好") {
+		t.Fatalf("html digest is not translated")
+	}
+}
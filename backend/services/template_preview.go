@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	gqlmodels "github.com/ringecosystem/degov-square/graph/models"
+	"github.com/ringecosystem/degov-square/internal"
+	"github.com/ringecosystem/degov-square/internal/utils"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+const previewProposalID = "0"
+
+// previewFeatures are the notification types that can be previewed or sent as a test
+var previewFeatures = map[dbmodels.SubscribeFeatureName]bool{
+	dbmodels.SubscribeFeatureProposalNew:          true,
+	dbmodels.SubscribeFeatureProposalStateChanged: true,
+	dbmodels.SubscribeFeatureVoteEmitted:          true,
+	dbmodels.SubscribeFeatureVoteEnd:              true,
+}
+
+// GeneratePreviewTemplate renders a record no event produced. Without a proposal it is rendered with fixture data,
+// with one the real proposal is used and a vote notification shows its first vote.
+func (s *TemplateService) GeneratePreviewTemplate(record *dbmodels.NotificationRecord) (*types.TemplateOutput, error) {
+	if !previewFeatures[record.Type] {
+		return nil, fmt.Errorf("preview is not supported for %s", record.Type)
+	}
+	dao, err := s.daoService.Inspect(types.BasicInput[string]{Input: record.DaoCode})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DAO info: %w", err)
+	}
+
+	var source *recordTemplateSource
+	if record.ProposalID == "" {
+		daoConfig, err := s.daoConfigService.StandardConfig(dao.Code)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get DAO config info: %w", err)
+		}
+		record.ProposalID = previewProposalID
+		source = previewFixtureSource(record, dao, daoConfig, time.Now())
+		s.loadRecordNames(record, source)
+	} else {
+		if record.Type == dbmodels.SubscribeFeatureVoteEmitted && record.VoteID == nil {
+			voteID, err := s.firstVoteID(dao.Code, record.ProposalID)
+			if err != nil {
+				return nil, err
+			}
+			record.VoteID = &voteID
+		}
+		if source, err = s.loadRecordTemplateSource(record, dao); err != nil {
+			return nil, err
+		}
+	}
+
+	record.ChainID = source.daoConfig.Chain.ID
+	if record.Type == dbmodels.SubscribeFeatureProposalStateChanged && record.Payload == nil {
+		record.Payload = utils.StringPtr(utils.ToJSON(map[string]string{"new_state": string(source.proposal.State)}))
+	}
+	return s.renderRecordTemplate(record, source, s.userPreference(record.UserID), false)
+}
+
+func (s *TemplateService) firstVoteID(daoCode, proposalID string) (string, error) {
+	daoConfig, err := s.daoConfigService.StandardConfig(daoCode)
+	if err != nil {
+		return "", fmt.Errorf("failed to get DAO config info: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	votes, err := internal.NewDegovIndexer(daoConfig.Indexer.Endpoint).QueryVotes(ctx, internal.ProposalScope{
+		ChainID:         daoConfig.Chain.ID,
+		DaoCode:         daoCode,
+		GovernorAddress: daoConfig.Contracts.Governor,
+	}, 0, 1, proposalID)
+	if err != nil {
+		return "", fmt.Errorf("failed to query votes: %w", err)
+	}
+	if len(votes) == 0 {
+		return "", errors.New("the proposal has no votes yet, preview without a proposal to use example data")
+	}
+	return votes[0].ID, nil
+}
+
+// previewFixtureSource is an example proposal of the DAO, proposed and voted on by the previewing user
+func previewFixtureSource(record *dbmodels.NotificationRecord, dao *gqlmodels.Dao, daoConfig *types.DaoConfig, now time.Time) *recordTemplateSource {
+	millis := func(t time.Time) string { return strconv.FormatInt(t.UnixMilli(), 10) }
+	state := dbmodels.ProposalStateActive
+	if record.Type == dbmodels.SubscribeFeatureProposalStateChanged {
+		state = dbmodels.ProposalStateSucceeded
+	}
+	title := "Example proposal: fund the community grants program"
+
+	return &recordTemplateSource{
+		dao:       dao,
+		daoConfig: daoConfig,
+		proposal: &dbmodels.ProposalTracking{
+			DaoCode:      dao.Code,
+			ChainId:      daoConfig.Chain.ID,
+			Title:        title,
+			ProposalLink: daoConfig.SiteURL,
+			ProposalID:   previewProposalID,
+			State:        state,
+		},
+		proposalIndexer: &internal.Proposal{
+			ProposalID:                   previewProposalID,
+			Title:                        title,
+			Description:                  "# " + title + "\n\nThis is an example notification, no action is needed.",
+			Proposer:                     record.UserAddress,
+			Decimals:                     "18",
+			Quorum:                       "1000000000000000000000000",
+			BlockTimestamp:               millis(now.Add(-72 * time.Hour)),
+			VoteStartTimestamp:           millis(now.Add(-48 * time.Hour)),
+			VoteEndTimestamp:             millis(now.Add(24 * time.Hour)),
+			MetricsVotesWeightForSum:     utils.StringPtr("1250000000000000000000000"),
+			MetricsVotesWeightAgainstSum: utils.StringPtr("310000000000000000000000"),
+			MetricsVotesWeightAbstainSum: utils.StringPtr("45000000000000000000000"),
+		},
+		vote: &internal.VoteCast{
+			ProposalID:     previewProposalID,
+			Support:        1,
+			Voter:          record.UserAddress,
+			Weight:         "250000000000000000000000",
+			BlockTimestamp: millis(now.Add(-time.Hour)),
+		},
+	}
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	gqlmodels "github.com/ringecosystem/degov-square/graph/models"
+	"github.com/ringecosystem/degov-square/internal/utils"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+func TestInboxTemplateLeavesOutTheEmailLayoutAndGreeting(t *testing.T) {
+	service := NewTemplateService()
+	data := templateDelegationData{
+		DegovSiteConfig: types.DegovSiteConfig{Name: "DeGov.AI"},
+		EmailStyle:      &types.EmailStyle{},
+		Dao:             &gqlmodels.Dao{Code: "demo", Name: "Demo DAO"},
+		Delegation: &emailDelegationInfo{PowerGained: "10", PowerLost: "0", Changes: []emailDelegationChangeRow{
+			{Delegator: "0xdef", PreviousPower: "0", Power: "10", Added: true},
+		}},
+		UserAddress:           "0xabc",
+		UnsubscribeURL:        "https://square.degov.ai/unsubscribe?token=dao",
+		UnsubscribeFeatureURL: "https://square.degov.ai/unsubscribe?token=feature",
+	}
+
+	email, err := service.renderTemplate("delegation_changed.md", data)
+	if err != nil {
+		t.Fatalf("render email: %v", err)
+	}
+	if !strings.Contains(email, "Hello 0xabc,") || !strings.Contains(email, data.UnsubscribeURL) {
+		t.Fatalf("expected the email to carry the unsubscribe footer, got %s", email)
+	}
+
+	// the inbox renders the event once for every user, so without the greeting
+	record := &dbmodels.NotificationRecord{EventID: "e1", DaoCode: "demo", Type: dbmodels.SubscribeFeatureDelegationChanged}
+	source := &delegationTemplateSource{
+		dao:       data.Dao,
+		daoConfig: &types.DaoConfig{},
+		payload: types.DelegationEventPayload{PowerGained: "10", PowerLost: "0", Changes: []types.DelegationChange{
+			{Delegator: "0xdef", PreviousPower: "0", Power: "10"},
+		}},
+	}
+	for locale, body := range map[string]string{utils.LocaleEnglish: "The voting power delegated to you", utils.LocaleChinese: "您在 Demo DAO"} {
+		inbox, err := service.renderDelegationTemplate(record, source, &dbmodels.NotificationPreference{Locale: locale, Timezone: "UTC"}, true)
+		if err != nil {
+			t.Fatalf("render %s inbox: %v", locale, err)
+		}
+		if !strings.HasPrefix(inbox.PlainTextContent, body) || !strings.Contains(inbox.PlainTextContent, "10") {
+			t.Fatalf("expected the %s body of the notification, got %s", locale, inbox.PlainTextContent)
+		}
+		for _, unwanted := range []string{"Hello", "您好", "unsubscribe", "Unsubscribe", "Follow us", "关注我们"} {
+			if strings.Contains(inbox.PlainTextContent, unwanted) {
+				t.Fatalf("expected no %q outside the body, got %s", unwanted, inbox.PlainTextContent)
+			}
+		}
+		if inbox.Title == "" || inbox.RichTextContent != "" {
+			t.Fatalf("unexpected inbox output %+v", inbox)
+		}
+	}
+}
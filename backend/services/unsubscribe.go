@@ -0,0 +1,159 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	gqlmodels "github.com/ringecosystem/degov-square/graph/models"
+	"github.com/ringecosystem/degov-square/internal/config"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+// UnsubscribePath is the public route that consumes unsubscribe tokens
+const UnsubscribePath = "/api/v1/notifications/unsubscribe"
+
+var ErrInvalidUnsubscribeToken = errors.New("invalid or expired unsubscribe link")
+
+// UnsubscribeScope is what an unsubscribe token turns off for one user.
+// Without a feature the DAO subscription, and the proposal subscription when set, are cancelled,
+// with a feature only that feature is turned off for the whole DAO.
+type UnsubscribeScope struct {
+	UserID      string                        `json:"u"`
+	UserAddress string                        `json:"a"`
+	DaoCode     string                        `json:"d"`
+	ProposalID  string                        `json:"p,omitempty"`
+	Feature     dbmodels.SubscribeFeatureName `json:"f,omitempty"`
+	Expires     int64                         `json:"e"`
+}
+
+// UnsubscribeService issues and consumes the signed unsubscribe links of notification emails.
+type UnsubscribeService struct {
+	secret           []byte
+	baseURL          string
+	ttl              time.Duration
+	now              func() time.Time
+	subscribeService *SubscribeService
+}
+
+func NewUnsubscribeService() *UnsubscribeService {
+	cfg := config.GetConfig()
+	baseURL := cfg.GetNotificationUnsubscribeBaseURL()
+	secret := cfg.GetNotificationUnsubscribeSecret()
+	if baseURL != "" {
+		// links live in inboxes for weeks, they get their own key rather than the session signing key
+		secret = cfg.GetStringRequired("NOTIFICATION_UNSUBSCRIBE_SECRET")
+		if secret == cfg.GetString("JWT_SECRET") {
+			slog.Error("NOTIFICATION_UNSUBSCRIBE_SECRET must differ from JWT_SECRET")
+			os.Exit(1)
+		}
+	}
+	service := newUnsubscribeService(secret, baseURL, cfg.GetNotificationUnsubscribeTokenTTL())
+	service.subscribeService = NewSubscribeService()
+	return service
+}
+
+func newUnsubscribeService(secret, baseURL string, ttl time.Duration) *UnsubscribeService {
+	return &UnsubscribeService{
+		secret:  []byte(secret),
+		baseURL: strings.TrimRight(baseURL, "/"),
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+// Link returns the unsubscribe URL for the scope, empty when no public base URL or secret is configured.
+func (s *UnsubscribeService) Link(scope UnsubscribeScope) string {
+	if s.baseURL == "" || len(s.secret) == 0 || scope.UserID == "" || scope.DaoCode == "" {
+		return ""
+	}
+	token, err := s.Token(scope)
+	if err != nil {
+		return ""
+	}
+	return s.baseURL + UnsubscribePath + "?token=" + url.QueryEscape(token)
+}
+
+// Token signs the scope, it expires after the configured TTL.
+func (s *UnsubscribeService) Token(scope UnsubscribeScope) (string, error) {
+	scope.Expires = s.now().Add(s.ttl).Unix()
+	payload, err := json.Marshal(scope)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + s.sign(encoded), nil
+}
+
+// Parse verifies the signature and expiry of a token.
+func (s *UnsubscribeService) Parse(token string) (*UnsubscribeScope, error) {
+	encoded, signature, ok := strings.Cut(strings.TrimSpace(token), ".")
+	if !ok || len(s.secret) == 0 || !hmac.Equal([]byte(signature), []byte(s.sign(encoded))) {
+		return nil, ErrInvalidUnsubscribeToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidUnsubscribeToken
+	}
+	var scope UnsubscribeScope
+	if err := json.Unmarshal(payload, &scope); err != nil || scope.UserID == "" || scope.DaoCode == "" {
+		return nil, ErrInvalidUnsubscribeToken
+	}
+	if s.now().Unix() > scope.Expires {
+		return nil, ErrInvalidUnsubscribeToken
+	}
+	return &scope, nil
+}
+
+// Unsubscribe consumes a token, links stay valid until they expire and unsubscribing twice is not an error.
+func (s *UnsubscribeService) Unsubscribe(token string) (*UnsubscribeScope, error) {
+	scope, err := s.Parse(token)
+	if err != nil {
+		return nil, err
+	}
+	user := &types.UserSessInfo{Id: scope.UserID, Address: scope.UserAddress}
+
+	if scope.Feature != "" {
+		if err := s.subscribeService.DisableFeature(user, scope.DaoCode, scope.Feature); err != nil {
+			return nil, err
+		}
+		return scope, nil
+	}
+
+	if _, err := s.subscribeService.InspectSubscribeDao(types.BasicInput[string]{User: user, Input: scope.DaoCode}); err == nil {
+		if _, err := s.subscribeService.UnsubscribeDao(types.BasicInput[gqlmodels.UnsubscribeDaoInput]{
+			User:  user,
+			Input: gqlmodels.UnsubscribeDaoInput{DaoCode: scope.DaoCode},
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if scope.ProposalID != "" {
+		if _, err := s.subscribeService.InspectSubscribeProposal(types.BasicInput[InspectSubscribeProposalInput]{
+			User:  user,
+			Input: InspectSubscribeProposalInput{DaoCode: scope.DaoCode, ProposalID: scope.ProposalID},
+		}); err == nil {
+			if _, err := s.subscribeService.UnsubscribeProposal(types.BasicInput[gqlmodels.UnsubscribeProposalInput]{
+				User:  user,
+				Input: gqlmodels.UnsubscribeProposalInput{DaoCode: scope.DaoCode, ProposalID: scope.ProposalID},
+			}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return scope, nil
+}
+
+func (s *UnsubscribeService) sign(encoded string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("unsubscribe:" + encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
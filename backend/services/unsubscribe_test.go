@@ -0,0 +1,106 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+)
+
+func TestUnsubscribeTokenRoundTrip(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	service := newUnsubscribeService("s3cret", "https://api.example.com/", 24*time.Hour)
+	service.now = func() time.Time { return now }
+
+	scope := UnsubscribeScope{UserID: "u1", UserAddress: "0xabc", DaoCode: "demo", Feature: dbmodels.SubscribeFeatureVoteEmitted}
+	link := service.Link(scope)
+	if !strings.HasPrefix(link, "https://api.example.com"+UnsubscribePath+"?token=") {
+		t.Fatalf("unexpected link: %s", link)
+	}
+	token, err := service.Token(scope)
+	if err != nil {
+		t.Fatalf("token: %v", err)
+	}
+
+	parsed, err := service.Parse(token)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if parsed.UserID != "u1" || parsed.DaoCode != "demo" || parsed.Feature != dbmodels.SubscribeFeatureVoteEmitted || parsed.ProposalID != "" {
+		t.Fatalf("unexpected scope: %+v", parsed)
+	}
+
+	encoded, signature, _ := strings.Cut(token, ".")
+	for _, tampered := range []string{
+		"",
+		encoded,
+		encoded + "." + signature + "x",
+		strings.Replace(encoded, encoded[:4], "AAAA", 1) + "." + signature,
+	} {
+		if _, err := service.Parse(tampered); !errors.Is(err, ErrInvalidUnsubscribeToken) {
+			t.Fatalf("expected %q to be rejected, got %v", tampered, err)
+		}
+	}
+	other := newUnsubscribeService("other", "", 24*time.Hour)
+	if _, err := other.Parse(token); !errors.Is(err, ErrInvalidUnsubscribeToken) {
+		t.Fatalf("expected a token signed with another secret to be rejected, got %v", err)
+	}
+
+	now = now.Add(25 * time.Hour)
+	if _, err := service.Parse(token); !errors.Is(err, ErrInvalidUnsubscribeToken) {
+		t.Fatalf("expected an expired token to be rejected, got %v", err)
+	}
+}
+
+func TestUnsubscribeLinkNeedsBaseURL(t *testing.T) {
+	service := newUnsubscribeService("s3cret", "", time.Hour)
+	if link := service.Link(UnsubscribeScope{UserID: "u1", DaoCode: "demo"}); link != "" {
+		t.Fatalf("expected no link without a base url, got %s", link)
+	}
+}
+
+func TestUnsubscribeConsumesToken(t *testing.T) {
+	db := newTestNotificationDB(t)
+	for _, statement := range []string{
+		`CREATE TABLE dgv_subscribed_feature (id TEXT, chain_id INTEGER, user_id TEXT, user_address TEXT, dao_code TEXT, proposal_id TEXT, feature TEXT, strategy TEXT, ctime DATETIME)`,
+		`CREATE TABLE dgv_user_subscribed_dao (id TEXT, chain_id INTEGER, user_id TEXT, user_address TEXT, dao_code TEXT, state TEXT, ctime DATETIME, utime DATETIME)`,
+		`CREATE TABLE dgv_user_subscribed_proposal (id TEXT, chain_id INTEGER, user_id TEXT, user_address TEXT, dao_code TEXT, proposal_id TEXT, state TEXT, ctime DATETIME, utime DATETIME)`,
+		`INSERT INTO dgv_user_subscribed_dao (id, user_id, user_address, dao_code, state) VALUES ('d1', 'u1', '0xabc', 'demo', 'ACTIVE')`,
+		`INSERT INTO dgv_user_subscribed_proposal (id, user_id, user_address, dao_code, proposal_id, state) VALUES ('p1', 'u1', '0xabc', 'demo', '0x01', 'ACTIVE')`,
+		`INSERT INTO dgv_subscribed_feature (id, user_id, user_address, dao_code, proposal_id, feature, strategy) VALUES
+			('f1', 'u1', '0xabc', 'demo', NULL, 'VOTE_EMITTED', 'true'),
+			('f2', 'u1', '0xabc', 'demo', '0x01', 'VOTE_EMITTED', 'true'),
+			('f3', 'u1', '0xabc', 'demo', NULL, 'PROPOSAL_NEW', 'true')`,
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatalf("seed subscriptions: %v", err)
+		}
+	}
+
+	service := newUnsubscribeService("s3cret", "", time.Hour)
+	service.subscribeService = &SubscribeService{db: db}
+
+	featureToken, _ := service.Token(UnsubscribeScope{UserID: "u1", UserAddress: "0xabc", DaoCode: "demo", Feature: dbmodels.SubscribeFeatureVoteEmitted})
+	if _, err := service.Unsubscribe(featureToken); err != nil {
+		t.Fatalf("unsubscribe feature: %v", err)
+	}
+	var disabled int64
+	db.Table("dgv_subscribed_feature").Where("strategy = 'false'").Count(&disabled)
+	if disabled != 2 {
+		t.Fatalf("expected both VOTE_EMITTED settings to be turned off, got %d", disabled)
+	}
+
+	token, _ := service.Token(UnsubscribeScope{UserID: "u1", UserAddress: "0xabc", DaoCode: "demo", ProposalID: "0x01"})
+	for i := 0; i < 2; i++ {
+		if _, err := service.Unsubscribe(token); err != nil {
+			t.Fatalf("unsubscribe #%d: %v", i+1, err)
+		}
+	}
+	var active int64
+	db.Raw(`SELECT (SELECT COUNT(*) FROM dgv_user_subscribed_dao WHERE state = 'ACTIVE') + (SELECT COUNT(*) FROM dgv_user_subscribed_proposal WHERE state = 'ACTIVE')`).Scan(&active)
+	if active != 0 {
+		t.Fatalf("expected the DAO and proposal subscriptions to be inactive, %d still active", active)
+	}
+}
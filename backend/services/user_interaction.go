@@ -1,12 +1,14 @@
 package services
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strconv"
 	"time"
 
-	"github.com/patrickmn/go-cache"
 	"gorm.io/gorm"
 
 	"github.com/ringecosystem/degov-square/database"
@@ -21,24 +23,42 @@ type UserInteractionService struct {
 	db              *gorm.DB
 	daoService      *DaoService
 	templateService *TemplateService
-	otpCache        *cache.Cache
-	rateLimitCache  *cache.Cache
+	codes           *ExpiringStore
 	notifierService *NotifierService
 	userService     *UserService
 }
 
 const ExpirationMinutes = 10
 
+// MaxChannelsPerType limits how many channels of the same type, e.g. email addresses, a user can verify
+const MaxChannelsPerType = 5
+
+const otpRateLimit = time.Minute
+
+// MaxOTPAttempts is how many wrong codes invalidate a pending OTP code, and how many unknown bind codes a Telegram
+// chat may send within ExpirationMinutes
+const MaxOTPAttempts = 5
+
+var (
+	ErrTelegramBindCodeInvalid = errors.New("telegram bind code is invalid or has expired")
+	ErrTelegramBindRateLimited = errors.New("too many telegram bind attempts")
+)
+
+// channelOTP binds a pending OTP code to the channel it was delivered to
+type channelOTP struct {
+	Code  string                            `json:"code"`
+	Type  gqlmodels.NotificationChannelType `json:"type"`
+	Value string                            `json:"value"`
+}
+
 func NewUserInteractionService() *UserInteractionService {
-	otpCache := cache.New(ExpirationMinutes*time.Minute, 5*time.Minute)
-	rateLimitCache := cache.New(1*time.Minute, 2*time.Minute)
+	db := database.GetDB()
 
 	return &UserInteractionService{
-		db:              database.GetDB(),
+		db:              db,
 		daoService:      NewDaoService(),
 		templateService: NewTemplateService(),
-		otpCache:        otpCache,
-		rateLimitCache:  rateLimitCache,
+		codes:           NewExpiringStore(db),
 		notifierService: NewNotifierService(),
 		userService:     NewUserService(),
 	}
@@ -104,34 +124,105 @@ func (s *UserInteractionService) VerifyNotificationChannel(baseInput types.Basic
 	user := baseInput.User
 	input := baseInput.Input
 
-	cachedOTP, found := s.otpCache.Get(user.Id)
-	if !found {
+	pending, _, err := s.pendingOTP(user.Id)
+	if err != nil {
+		return nil, err
+	}
+	if pending == nil {
 		return &gqlmodels.VerifyNotificationChannelOutput{
 			Code:    1,
 			Message: utils.StringPtr("OTP code has expired or does not exist"),
 		}, nil
 	}
 
-	cachedOTPStr, ok := cachedOTP.(string)
-	if !ok {
+	if pending.Code != input.OtpCode {
+		attempts, err := s.codes.Increment(otpAttemptsKey(user.Id), ExpirationMinutes*time.Minute)
+		if err != nil {
+			return nil, err
+		}
+		if attempts >= MaxOTPAttempts {
+			// the code could be guessed otherwise, a new one has to be requested
+			if err := s.codes.Delete(otpKey(user.Id), otpAttemptsKey(user.Id), telegramBindKey(pending.Code)); err != nil {
+				return nil, err
+			}
+			return &gqlmodels.VerifyNotificationChannelOutput{
+				Code:    1,
+				Message: utils.StringPtr("Too many invalid attempts, please request a new OTP code"),
+			}, nil
+		}
 		return &gqlmodels.VerifyNotificationChannelOutput{
 			Code:    1,
-			Message: utils.StringPtr("Invalid OTP code format"),
+			Message: utils.StringPtr("Invalid OTP code"),
+		}, nil
+	}
+	if pending.Type != input.Type {
+		return &gqlmodels.VerifyNotificationChannelOutput{
+			Code:    1,
+			Message: utils.StringPtr("Invalid OTP code"),
 		}, nil
 	}
 
-	if cachedOTPStr != input.OtpCode {
+	channelValue := input.Value
+	var channelPayload *string
+	if input.Type == gqlmodels.NotificationChannelTypeTelegram {
+		// the chat id is learned from the bot when the user sends the code to it
+		if pending.Value == "" {
+			return &gqlmodels.VerifyNotificationChannelOutput{
+				Code:    1,
+				Message: utils.StringPtr("Please send the code to the DeGov Telegram bot first"),
+			}, nil
+		}
+		channelValue = pending.Value
+	} else if input.Type == gqlmodels.NotificationChannelTypeWebPush {
+		// the channel is keyed by the endpoint, the keys only encrypt the messages sent to it
+		subscription, err := ParseWebPushSubscription(input.Value)
+		if err != nil || pending.Value != utils.ToJSON(subscription) {
+			return &gqlmodels.VerifyNotificationChannelOutput{
+				Code:    1,
+				Message: utils.StringPtr("Invalid OTP code"),
+			}, nil
+		}
+		channelValue = subscription.Endpoint
+		channelPayload = utils.StringPtr(utils.ToJSON(subscription.Keys))
+	} else if pending.Value != input.Value {
+		// the OTP only proves ownership of the channel it was sent to
 		return &gqlmodels.VerifyNotificationChannelOutput{
 			Code:    1,
 			Message: utils.StringPtr("Invalid OTP code"),
 		}, nil
 	}
 
-	s.otpCache.Delete(user.Id)
+	var existing []dbmodels.NotificationChannel
+	if err := s.db.Where("user_id = ? AND channel_type = ?", user.Id, input.Type).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	for _, channel := range existing {
+		if channel.ChannelValue == channelValue {
+			// verifying a channel again keeps its features and webhook secret, a browser may rotate its push keys
+			if err := s.codes.Delete(otpKey(user.Id)); err != nil {
+				return nil, err
+			}
+			updates := map[string]interface{}{"verified": 1}
+			if channelPayload != nil {
+				updates["payload"] = *channelPayload
+			}
+			if err := s.db.Model(&dbmodels.NotificationChannel{}).Where("id = ?", channel.ID).Updates(updates).Error; err != nil {
+				return nil, err
+			}
+			return &gqlmodels.VerifyNotificationChannelOutput{
+				Code: 0,
+			}, nil
+		}
+	}
+	if len(existing) >= MaxChannelsPerType {
+		return &gqlmodels.VerifyNotificationChannelOutput{
+			Code:    1,
+			Message: utils.StringPtr(fmt.Sprintf("At most %d channels of a type can be added, please remove one first", MaxChannelsPerType)),
+		}, nil
+	}
 
-	err := s.db.Delete(&dbmodels.NotificationChannel{}, "user_id = ? AND channel_type = ?", user.Id, input.Type).Error
-	if err != nil {
-		slog.Warn("error deleting existing unverified channel", "user_id", user.Id, "channel_type", input.Type, "err", err)
+	if err := s.codes.Delete(otpKey(user.Id)); err != nil {
+		return nil, err
 	}
 
 	notificationChannel := dbmodels.NotificationChannel{
@@ -140,9 +231,17 @@ func (s *UserInteractionService) VerifyNotificationChannel(baseInput types.Basic
 		UserAddress:  user.Address,
 		Verified:     1,
 		ChannelType:  dbmodels.NotificationChannelType(input.Type),
-		ChannelValue: input.Value,
+		ChannelValue: channelValue,
+		Payload:      channelPayload,
 		CTime:        time.Now(),
 	}
+	if notificationChannel.ChannelType == dbmodels.NotificationChannelTypeWebhook {
+		secret, err := NewWebhookSecret()
+		if err != nil {
+			return nil, err
+		}
+		notificationChannel.Payload = utils.StringPtr(utils.ToJSON(WebhookChannelPayload{Secret: secret}))
+	}
 	if err := s.db.Create(&notificationChannel).Error; err != nil {
 		return nil, err
 	}
@@ -167,30 +266,18 @@ func (s *UserInteractionService) resendOTPForChannel(baseInput types.BasicInput[
 	input := baseInput.Input
 
 	if !config.GetAppEnv().IsDevelopment() {
-		rateLimitKey := fmt.Sprintf("otp_rate_limit_%s", user.Id)
-
-		if cachedTime, found := s.rateLimitCache.Get(rateLimitKey); found {
-			if lastSentTime, ok := cachedTime.(time.Time); ok {
-				elapsed := time.Since(lastSentTime)
-				remaining := 60*time.Second - elapsed
-				if remaining > 0 {
-					remainingSeconds := int32(remaining.Seconds())
-					return &gqlmodels.ResendOTPOutput{
-						Code:      1,
-						RateLimit: &remainingSeconds,
-						Message:   utils.StringPtr(fmt.Sprintf("OTP can only be sent once per minute. Please try again in %d seconds", remainingSeconds)),
-					}, nil
-				}
-			} else {
-				return &gqlmodels.ResendOTPOutput{
-					Code:      1,
-					RateLimit: utils.Int32Ptr(60),
-					Message:   utils.StringPtr("OTP can only be sent once per minute. Please try again later"),
-				}, nil
-			}
+		remaining, err := s.codes.Reserve("otp_rate_limit:"+user.Id, otpRateLimit)
+		if err != nil {
+			return nil, err
+		}
+		if remaining > 0 {
+			remainingSeconds := int32(remaining.Seconds())
+			return &gqlmodels.ResendOTPOutput{
+				Code:      1,
+				RateLimit: &remainingSeconds,
+				Message:   utils.StringPtr(fmt.Sprintf("OTP can only be sent once per minute. Please try again in %d seconds", remainingSeconds)),
+			}, nil
 		}
-
-		s.rateLimitCache.Set(rateLimitKey, time.Now(), 1*time.Minute)
 	}
 
 	ensName, err := s.userService.GetENSName(user.Address)
@@ -199,12 +286,33 @@ func (s *UserInteractionService) resendOTPForChannel(baseInput types.BasicInput[
 	}
 
 	switch input.Type {
-	case gqlmodels.NotificationChannelTypeEmail:
+	case gqlmodels.NotificationChannelTypeEmail, gqlmodels.NotificationChannelTypeWebhook,
+		gqlmodels.NotificationChannelTypeDiscord, gqlmodels.NotificationChannelTypeSLACk:
+		var err error
+		switch input.Type {
+		case gqlmodels.NotificationChannelTypeWebhook:
+			err = ValidateWebhookURL(input.Value)
+		case gqlmodels.NotificationChannelTypeDiscord, gqlmodels.NotificationChannelTypeSLACk:
+			err = ValidateChatWebhookURL(dbmodels.NotificationChannelType(input.Type), input.Value)
+		}
+		if err != nil {
+			return &gqlmodels.ResendOTPOutput{
+				Code:    1,
+				Message: utils.StringPtr(err.Error()),
+			}, nil
+		}
+
 		otpCode, err := utils.NextOTPCode()
 		if err != nil {
 			return nil, fmt.Errorf("error generating OTP code: %w", err)
 		}
-		s.otpCache.Set(user.Id, otpCode, ExpirationMinutes*time.Minute)
+		if err := s.setPendingOTP(user.Id, channelOTP{
+			Code:  otpCode,
+			Type:  input.Type,
+			Value: input.Value,
+		}); err != nil {
+			return nil, err
+		}
 
 		templateOutput, err := s.templateService.GenerateTemplateOTP(types.GenerateTemplateOTPInput{
 			DegovSiteConfig: config.GetDegovSiteConfig(),
@@ -216,12 +324,16 @@ func (s *UserInteractionService) resendOTPForChannel(baseInput types.BasicInput[
 		if err != nil {
 			return nil, fmt.Errorf("error generating email content: %w", err)
 		}
-		if err := s.notifierService.Notify(types.NotifyInput{
-			Type:     dbmodels.NotificationChannelTypeEmail,
+		if _, err := s.notifierService.Notify(types.NotifyInput{
+			Type:     dbmodels.NotificationChannelType(input.Type),
 			To:       input.Value,
 			Template: templateOutput,
 		}); err != nil {
 			slog.Warn("Failed to notify", "err", err)
+			return &gqlmodels.ResendOTPOutput{
+				Code:    1,
+				Message: utils.StringPtr(fmt.Sprintf("Failed to deliver OTP code: %s", err.Error())),
+			}, nil
 		}
 
 		return &gqlmodels.ResendOTPOutput{
@@ -229,10 +341,91 @@ func (s *UserInteractionService) resendOTPForChannel(baseInput types.BasicInput[
 			Expiration: utils.Int32Ptr(3 * 60),
 		}, nil
 
-	case gqlmodels.NotificationChannelTypeWebhook:
+	case gqlmodels.NotificationChannelTypeWebPush:
+		if s.WebPushPublicKey() == nil {
+			return &gqlmodels.ResendOTPOutput{
+				Code:    1,
+				Message: utils.StringPtr("Web push notifications are not configured"),
+			}, nil
+		}
+		subscription, err := ParseWebPushSubscription(input.Value)
+		if err != nil {
+			return &gqlmodels.ResendOTPOutput{
+				Code:    1,
+				Message: utils.StringPtr(err.Error()),
+			}, nil
+		}
+
+		otpCode, err := utils.NextOTPCode()
+		if err != nil {
+			return nil, fmt.Errorf("error generating OTP code: %w", err)
+		}
+		if err := s.setPendingOTP(user.Id, channelOTP{
+			Code:  otpCode,
+			Type:  input.Type,
+			Value: utils.ToJSON(subscription),
+		}); err != nil {
+			return nil, err
+		}
+
+		templateOutput, err := s.templateService.GenerateTemplateOTP(types.GenerateTemplateOTPInput{
+			DegovSiteConfig: config.GetDegovSiteConfig(),
+			OTP:             otpCode,
+			Expiration:      ExpirationMinutes,
+			UserAddress:     user.Address,
+			EnsName:         ensName,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error generating web push content: %w", err)
+		}
+		if _, err := s.notifierService.Notify(types.NotifyInput{
+			Type:     dbmodels.NotificationChannelTypeWebPush,
+			To:       subscription.Endpoint,
+			Template: templateOutput,
+			Payload:  utils.StringPtr(utils.ToJSON(subscription.Keys)),
+		}); err != nil {
+			slog.Warn("Failed to notify", "err", err)
+			return &gqlmodels.ResendOTPOutput{
+				Code:    1,
+				Message: utils.StringPtr(fmt.Sprintf("Failed to deliver OTP code: %s", err.Error())),
+			}, nil
+		}
+
+		return &gqlmodels.ResendOTPOutput{
+			Code:       0,
+			Expiration: utils.Int32Ptr(ExpirationMinutes * 60),
+		}, nil
+
+	case gqlmodels.NotificationChannelTypeTelegram:
+		botUsername := config.GetString("TELEGRAM_BOT_USERNAME")
+		if botUsername == "" || config.GetString("TELEGRAM_BOT_TOKEN") == "" {
+			return &gqlmodels.ResendOTPOutput{
+				Code:    1,
+				Message: utils.StringPtr("Telegram notifications are not configured"),
+			}, nil
+		}
+
+		otpCode, err := utils.NextOTPCode()
+		if err != nil {
+			return nil, fmt.Errorf("error generating OTP code: %w", err)
+		}
+		if err := s.setPendingOTP(user.Id, channelOTP{
+			Code: otpCode,
+			Type: input.Type,
+		}); err != nil {
+			return nil, err
+		}
+		if err := s.codes.Set(telegramBindKey(otpCode), user.Id, ExpirationMinutes*time.Minute); err != nil {
+			return nil, err
+		}
+
 		return &gqlmodels.ResendOTPOutput{
-			Code:    0,
-			Message: utils.StringPtr("this method do not need send OTP to verify"),
+			Code: 0,
+			Message: utils.StringPtr(fmt.Sprintf(
+				"Send /start %s to @%s (https://t.me/%s?start=%s), then confirm the code here",
+				otpCode, botUsername, botUsername, otpCode,
+			)),
+			Expiration: utils.Int32Ptr(ExpirationMinutes * 60),
 		}, nil
 
 	default:
@@ -244,6 +437,92 @@ func (s *UserInteractionService) resendOTPForChannel(baseInput types.BasicInput[
 
 }
 
+// WebPushPublicKey returns the VAPID public key browsers subscribe with, nil when web push is not configured.
+func (s *UserInteractionService) WebPushPublicKey() *string {
+	publicKey := config.GetString("WEB_PUSH_VAPID_PUBLIC_KEY")
+	if publicKey == "" || config.GetString("WEB_PUSH_VAPID_PRIVATE_KEY") == "" {
+		return nil
+	}
+	return &publicKey
+}
+
+func otpKey(userID string) string {
+	return "otp:" + userID
+}
+
+func otpAttemptsKey(userID string) string {
+	return "otp_attempts:" + userID
+}
+
+func telegramBindKey(code string) string {
+	return "telegram_bind:" + code
+}
+
+func telegramBindAttemptsKey(chatID int64) string {
+	return "telegram_bind_attempts:" + strconv.FormatInt(chatID, 10)
+}
+
+// setPendingOTP replaces the pending OTP code of the user, the attempts of the previous code are forgotten
+func (s *UserInteractionService) setPendingOTP(userID string, pending channelOTP) error {
+	if err := s.codes.Delete(otpAttemptsKey(userID)); err != nil {
+		return err
+	}
+	return s.codes.Set(otpKey(userID), utils.ToJSON(pending), ExpirationMinutes*time.Minute)
+}
+
+// pendingOTP returns the OTP code waiting for the user and when it expires, nil when there is none
+func (s *UserInteractionService) pendingOTP(userID string) (*channelOTP, time.Time, error) {
+	stored, err := s.codes.Get(otpKey(userID))
+	if err != nil || stored == nil {
+		return nil, time.Time{}, err
+	}
+	var pending channelOTP
+	if err := json.Unmarshal([]byte(stored.Value), &pending); err != nil {
+		return nil, time.Time{}, fmt.Errorf("invalid pending OTP of user %s: %w", userID, err)
+	}
+	return &pending, stored.ExpiresAt, nil
+}
+
+// BindTelegramChat attaches the chat that sent a bind code to the pending Telegram channel of its user.
+// A chat sending MaxOTPAttempts unknown codes is refused until ExpirationMinutes after its first miss, so bind codes
+// cannot be guessed.
+func (s *UserInteractionService) BindTelegramChat(code string, chatID int64) error {
+	attempts, err := s.codes.Get(telegramBindAttemptsKey(chatID))
+	if err != nil {
+		return err
+	}
+	if attempts != nil && attempts.Attempts >= MaxOTPAttempts {
+		return ErrTelegramBindRateLimited
+	}
+
+	bind, err := s.codes.Get(telegramBindKey(code))
+	if err != nil {
+		return err
+	}
+	var pending *channelOTP
+	if bind != nil {
+		if pending, _, err = s.pendingOTP(bind.Value); err != nil {
+			return err
+		}
+	}
+	if pending == nil || pending.Code != code || pending.Type != gqlmodels.NotificationChannelTypeTelegram {
+		if _, err := s.codes.Increment(telegramBindAttemptsKey(chatID), ExpirationMinutes*time.Minute); err != nil {
+			return err
+		}
+		return ErrTelegramBindCodeInvalid
+	}
+
+	pending.Value = strconv.FormatInt(chatID, 10)
+	updated, err := s.codes.Update(otpKey(bind.Value), utils.ToJSON(pending))
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrTelegramBindCodeInvalid
+	}
+	return s.codes.Delete(telegramBindKey(code))
+}
+
 func (s UserInteractionService) ListChannel(
 	baseInput types.BasicInput[types.ListChannelInput],
 ) ([]dbmodels.NotificationChannel, error) {
@@ -265,3 +544,149 @@ func (s UserInteractionService) ListChannel(
 	}
 	return results, nil
 }
+
+func (s *UserInteractionService) RemoveNotificationChannel(baseInput types.BasicInput[gqlmodels.RemoveNotificationChannelInput]) (bool, error) {
+	user := baseInput.User
+	input := baseInput.Input
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND user_id = ?", input.ChannelID, user.Id).Delete(&dbmodels.NotificationChannel{})
+		if result.Error != nil {
+			return fmt.Errorf("error removing notification channel: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("notification channel not found")
+		}
+		return cancelChannelDeliveries(tx, input.ChannelID, nil)
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// cancelChannelDeliveries cancels the pending deliveries of the channel, except those of the features in keep
+func cancelChannelDeliveries(tx *gorm.DB, channelID string, keep []dbmodels.SubscribeFeatureName) error {
+	query := tx.Model(&dbmodels.NotificationDelivery{}).
+		Where("channel_id = ? AND state = ?", channelID, dbmodels.NotificationDeliveryStatePending)
+	if len(keep) > 0 {
+		query = query.Where("record_id IN (?)", tx.Model(&dbmodels.NotificationRecord{}).Select("id").Where("type NOT IN ?", keep))
+	}
+	if err := query.Updates(map[string]interface{}{
+		"state": dbmodels.NotificationDeliveryStateCancelled,
+		"utime": time.Now(),
+	}).Error; err != nil {
+		return fmt.Errorf("error cancelling channel deliveries: %w", err)
+	}
+	return nil
+}
+
+// SetChannelFeatures chooses the features delivered on a channel, nil features deliver every feature
+func (s *UserInteractionService) SetChannelFeatures(baseInput types.BasicInput[gqlmodels.SetChannelFeaturesInput]) (*dbmodels.NotificationChannel, error) {
+	user := baseInput.User
+	input := baseInput.Input
+
+	var (
+		features   *string
+		dbFeatures []dbmodels.SubscribeFeatureName
+	)
+	if input.Features != nil {
+		dbFeatures = make([]dbmodels.SubscribeFeatureName, 0, len(input.Features))
+		for _, feature := range input.Features {
+			dbFeature, ok := subscribeFeatureName(feature)
+			if !ok {
+				return nil, fmt.Errorf("unsupported feature %s", feature)
+			}
+			if !slices.Contains(dbFeatures, dbFeature) {
+				dbFeatures = append(dbFeatures, dbFeature)
+			}
+		}
+		features = utils.StringPtr(utils.ToJSON(dbFeatures))
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&dbmodels.NotificationChannel{}).
+			Where("id = ? AND user_id = ?", input.ChannelID, user.Id).
+			Update("features", features)
+		if result.Error != nil {
+			return fmt.Errorf("error updating channel features: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("notification channel not found")
+		}
+		if features == nil {
+			// every feature is delivered again
+			return nil
+		}
+		return cancelChannelDeliveries(tx, input.ChannelID, dbFeatures)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var channel dbmodels.NotificationChannel
+	if err := s.db.Where("id = ?", input.ChannelID).First(&channel).Error; err != nil {
+		return nil, err
+	}
+	return &channel, nil
+}
+
+// PendingChannel returns the channel the last OTP code of the user was sent to, nil when no code is waiting
+func (s *UserInteractionService) PendingChannel(user *types.UserSessInfo) *gqlmodels.PendingNotificationChannel {
+	pending, expiration, err := s.pendingOTP(user.Id)
+	if err != nil {
+		slog.Warn("Failed to read pending OTP", "user_id", user.Id, "err", err)
+		return nil
+	}
+	if pending == nil {
+		return nil
+	}
+	return &gqlmodels.PendingNotificationChannel{
+		ChannelType:  pending.Type,
+		ChannelValue: pending.Value,
+		ExpiresAt:    expiration,
+	}
+}
+
+// ChannelFeatures returns the features delivered on the channel, nil when it delivers every feature
+func ChannelFeatures(channel *dbmodels.NotificationChannel) []dbmodels.SubscribeFeatureName {
+	if channel.Features == nil {
+		return nil
+	}
+	features := []dbmodels.SubscribeFeatureName{}
+	if err := json.Unmarshal([]byte(*channel.Features), &features); err != nil {
+		slog.Warn("invalid channel features, delivering every feature", "channel_id", channel.ID, "err", err)
+		return nil
+	}
+	return features
+}
+
+// ChannelsForFeature keeps the channels the feature is delivered on
+func ChannelsForFeature(channels []dbmodels.NotificationChannel, feature dbmodels.SubscribeFeatureName) []dbmodels.NotificationChannel {
+	routed := make([]dbmodels.NotificationChannel, 0, len(channels))
+	for i := range channels {
+		features := ChannelFeatures(&channels[i])
+		if features == nil || slices.Contains(features, feature) {
+			routed = append(routed, channels[i])
+		}
+	}
+	return routed
+}
+
+func NotificationChannelToGraphQL(channel *dbmodels.NotificationChannel) *gqlmodels.NotificationChannel {
+	output := &gqlmodels.NotificationChannel{
+		ID:           channel.ID,
+		ChannelType:  gqlmodels.NotificationChannelType(channel.ChannelType),
+		ChannelValue: channel.ChannelValue,
+		Verified:     int32(channel.Verified),
+		Payload:      channel.Payload,
+		Ctime:        channel.CTime,
+	}
+	if features := ChannelFeatures(channel); features != nil {
+		output.Features = make([]gqlmodels.FeatureName, 0, len(features))
+		for _, feature := range features {
+			output.Features = append(output.Features, gqlmodels.FeatureName(feature))
+		}
+	}
+	return output
+}
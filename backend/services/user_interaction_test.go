@@ -0,0 +1,330 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	gqlmodels "github.com/ringecosystem/degov-square/graph/models"
+	"github.com/ringecosystem/degov-square/internal/utils"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+func newTestUserInteractionService(t *testing.T) *UserInteractionService {
+	t.Helper()
+	db := newTestNotificationDB(t)
+	if err := db.Exec(`CREATE TABLE dgv_notification_channel (
+		id TEXT PRIMARY KEY, user_id TEXT NOT NULL, user_address TEXT NOT NULL, verified INTEGER NOT NULL DEFAULT 0,
+		channel_type TEXT NOT NULL, channel_value TEXT NOT NULL, payload TEXT, features TEXT, ctime DATETIME,
+		UNIQUE (user_id, channel_type, channel_value)
+	)`).Error; err != nil {
+		t.Fatalf("create channel table: %v", err)
+	}
+	return &UserInteractionService{db: db, codes: NewExpiringStore(db)}
+}
+
+func verifyTestEmailChannel(t *testing.T, service *UserInteractionService, user *types.UserSessInfo, email string) *gqlmodels.VerifyNotificationChannelOutput {
+	t.Helper()
+	if err := service.setPendingOTP(user.Id, channelOTP{Code: "123456", Type: gqlmodels.NotificationChannelTypeEmail, Value: email}); err != nil {
+		t.Fatalf("store OTP: %v", err)
+	}
+	output, err := service.VerifyNotificationChannel(types.BasicInput[gqlmodels.VerifyNotificationChannelInput]{
+		User:  user,
+		Input: gqlmodels.VerifyNotificationChannelInput{Type: gqlmodels.NotificationChannelTypeEmail, Value: email, OtpCode: "123456"},
+	})
+	if err != nil {
+		t.Fatalf("verify %s: %v", email, err)
+	}
+	return output
+}
+
+func TestVerifyNotificationChannelKeepsSeveralEmails(t *testing.T) {
+	service := newTestUserInteractionService(t)
+	user := &types.UserSessInfo{Id: "u1", Address: "0xabc"}
+
+	for _, email := range []string{"work@example.com", "home@example.com", "work@example.com"} {
+		if output := verifyTestEmailChannel(t, service, user, email); output.Code != 0 {
+			t.Fatalf("verify %s: %+v", email, output)
+		}
+	}
+	channels, err := service.ListChannel(types.BasicInput[types.ListChannelInput]{User: user})
+	if err != nil || len(channels) != 2 {
+		t.Fatalf("expected two email channels, got %+v %v", channels, err)
+	}
+	if pending := service.PendingChannel(user); pending != nil {
+		t.Fatalf("expected the OTP to be consumed, got %+v", pending)
+	}
+
+	for i := len(channels); i < MaxChannelsPerType; i++ {
+		verifyTestEmailChannel(t, service, user, string(rune('a'+i))+"@example.com")
+	}
+	if output := verifyTestEmailChannel(t, service, user, "one-too-many@example.com"); output.Code != 1 {
+		t.Fatalf("expected the channel limit to be enforced, got %+v", output)
+	}
+	if pending := service.PendingChannel(user); pending == nil || pending.ChannelValue != "one-too-many@example.com" {
+		t.Fatalf("expected the refused channel to stay pending, got %+v", pending)
+	}
+}
+
+func TestSetChannelFeaturesRoutesRecords(t *testing.T) {
+	service := newTestUserInteractionService(t)
+	user := &types.UserSessInfo{Id: "u1", Address: "0xabc"}
+	other := &types.UserSessInfo{Id: "u2", Address: "0xdef"}
+	verifyTestEmailChannel(t, service, user, "work@example.com")
+	verifyTestEmailChannel(t, service, user, "home@example.com")
+	channels, err := service.ListChannel(types.BasicInput[types.ListChannelInput]{User: user})
+	if err != nil {
+		t.Fatalf("list channels: %v", err)
+	}
+	work, home := channels[0], channels[1]
+	if work.ChannelValue != "work@example.com" {
+		work, home = home, work
+	}
+
+	if _, err := service.SetChannelFeatures(types.BasicInput[gqlmodels.SetChannelFeaturesInput]{
+		User:  other,
+		Input: gqlmodels.SetChannelFeaturesInput{ChannelID: work.ID, Features: []gqlmodels.FeatureName{}},
+	}); err == nil {
+		t.Fatalf("expected another user to be refused")
+	}
+	updated, err := service.SetChannelFeatures(types.BasicInput[gqlmodels.SetChannelFeaturesInput]{
+		User: user,
+		Input: gqlmodels.SetChannelFeaturesInput{ChannelID: work.ID, Features: []gqlmodels.FeatureName{
+			gqlmodels.FeatureNameVoteEnd, gqlmodels.FeatureNameVoteReminder, gqlmodels.FeatureNameVoteEnd,
+		}},
+	})
+	if err != nil {
+		t.Fatalf("set channel features: %v", err)
+	}
+	if output := NotificationChannelToGraphQL(updated); len(output.Features) != 2 || output.Features[0] != gqlmodels.FeatureNameVoteEnd {
+		t.Fatalf("unexpected features %+v", output.Features)
+	}
+
+	channels, _ = service.ListChannel(types.BasicInput[types.ListChannelInput]{User: user})
+	if routed := ChannelsForFeature(channels, dbmodels.SubscribeFeatureProposalNew); len(routed) != 1 || routed[0].ID != home.ID {
+		t.Fatalf("expected PROPOSAL_NEW to skip the work channel, got %+v", routed)
+	}
+	if routed := ChannelsForFeature(channels, dbmodels.SubscribeFeatureVoteEnd); len(routed) != 2 {
+		t.Fatalf("expected VOTE_END on both channels, got %+v", routed)
+	}
+
+	if _, err := service.RemoveNotificationChannel(types.BasicInput[gqlmodels.RemoveNotificationChannelInput]{
+		User: other, Input: gqlmodels.RemoveNotificationChannelInput{ChannelID: home.ID},
+	}); err == nil {
+		t.Fatalf("expected another user to be refused")
+	}
+	if removed, err := service.RemoveNotificationChannel(types.BasicInput[gqlmodels.RemoveNotificationChannelInput]{
+		User: user, Input: gqlmodels.RemoveNotificationChannelInput{ChannelID: home.ID},
+	}); err != nil || !removed {
+		t.Fatalf("remove channel: %v %v", removed, err)
+	}
+	channels, _ = service.ListChannel(types.BasicInput[types.ListChannelInput]{User: user})
+	if len(channels) != 1 || channels[0].ID != work.ID {
+		t.Fatalf("unexpected channels after removal %+v", channels)
+	}
+}
+
+func TestVerifyWebPushChannelStoresEndpointAndKeys(t *testing.T) {
+	service := newTestUserInteractionService(t)
+	user := &types.UserSessInfo{Id: "u1", Address: "0xabc"}
+	raw := `{"endpoint":"https://fcm.googleapis.com/fcm/send/abc","expirationTime":null,"keys":{"p256dh":"BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4","auth":"BTBZMqHH6r4Tts7J_aSIgg"}}`
+	subscription, err := ParseWebPushSubscription(raw)
+	if err != nil {
+		t.Fatalf("parse subscription: %v", err)
+	}
+	if err := service.setPendingOTP(user.Id, channelOTP{Code: "123456", Type: gqlmodels.NotificationChannelTypeWebPush, Value: utils.ToJSON(subscription)}); err != nil {
+		t.Fatalf("store OTP: %v", err)
+	}
+
+	output, err := service.VerifyNotificationChannel(types.BasicInput[gqlmodels.VerifyNotificationChannelInput]{
+		User:  user,
+		Input: gqlmodels.VerifyNotificationChannelInput{Type: gqlmodels.NotificationChannelTypeWebPush, Value: raw, OtpCode: "123456"},
+	})
+	if err != nil || output.Code != 0 {
+		t.Fatalf("verify web push: %+v %v", output, err)
+	}
+	channels, err := service.ListChannel(types.BasicInput[types.ListChannelInput]{User: user})
+	if err != nil || len(channels) != 1 {
+		t.Fatalf("expected one channel, got %+v %v", channels, err)
+	}
+	keys := parseWebPushChannelPayload(channels[0].Payload)
+	if channels[0].ChannelValue != subscription.Endpoint || keys != subscription.Keys {
+		t.Fatalf("unexpected channel %+v", channels[0])
+	}
+}
+
+func TestChannelChangesCancelPendingDeliveries(t *testing.T) {
+	service := newTestUserInteractionService(t)
+	notifications := newNotificationService(service.db)
+	user := &types.UserSessInfo{Id: "u1", Address: "0xabc"}
+	verifyTestEmailChannel(t, service, user, "work@example.com")
+	verifyTestEmailChannel(t, service, user, "home@example.com")
+	channels, err := service.ListChannel(types.BasicInput[types.ListChannelInput]{User: user})
+	if err != nil || len(channels) != 2 {
+		t.Fatalf("list channels: %+v %v", channels, err)
+	}
+
+	proposalNew := seedTestNotificationRecord(t, service.db, "r1")
+	voteEnd := seedTestNotificationRecord(t, service.db, "r2")
+	if err := service.db.Model(voteEnd).Update("type", dbmodels.SubscribeFeatureVoteEnd).Error; err != nil {
+		t.Fatalf("update record type: %v", err)
+	}
+	for _, record := range []*dbmodels.NotificationRecord{proposalNew, voteEnd} {
+		if _, err := notifications.EnsureDeliveries(record, channels); err != nil {
+			t.Fatalf("ensure deliveries: %v", err)
+		}
+	}
+	pendingOn := func(channelID string) []string {
+		var recordIDs []string
+		if err := service.db.Model(&dbmodels.NotificationDelivery{}).
+			Where("channel_id = ? AND state = ?", channelID, dbmodels.NotificationDeliveryStatePending).
+			Order("record_id ASC").Pluck("record_id", &recordIDs).Error; err != nil {
+			t.Fatalf("list deliveries: %v", err)
+		}
+		return recordIDs
+	}
+
+	if _, err := service.SetChannelFeatures(types.BasicInput[gqlmodels.SetChannelFeaturesInput]{
+		User:  user,
+		Input: gqlmodels.SetChannelFeaturesInput{ChannelID: channels[0].ID, Features: []gqlmodels.FeatureName{gqlmodels.FeatureNameVoteEnd}},
+	}); err != nil {
+		t.Fatalf("set channel features: %v", err)
+	}
+	if pending := pendingOn(channels[0].ID); len(pending) != 1 || pending[0] != "r2" {
+		t.Fatalf("expected only the VOTE_END delivery to stay pending, got %v", pending)
+	}
+
+	if _, err := service.RemoveNotificationChannel(types.BasicInput[gqlmodels.RemoveNotificationChannelInput]{
+		User: user, Input: gqlmodels.RemoveNotificationChannelInput{ChannelID: channels[1].ID},
+	}); err != nil {
+		t.Fatalf("remove channel: %v", err)
+	}
+	if pending := pendingOn(channels[1].ID); len(pending) != 0 {
+		t.Fatalf("expected the removed channel to have no pending deliveries, got %v", pending)
+	}
+	if state, err := notifications.AggregateRecordState("r1"); err != nil || state != dbmodels.NotificationRecordStateSentOk {
+		t.Fatalf("expected cancelled deliveries not to fail the record, got %s %v", state, err)
+	}
+}
+
+func TestRemoveGoneChannelDropsItsDeliveries(t *testing.T) {
+	service := newTestUserInteractionService(t)
+	notifications := newNotificationService(service.db)
+	user := &types.UserSessInfo{Id: "u1", Address: "0xabc"}
+	verifyTestEmailChannel(t, service, user, "work@example.com")
+	verifyTestEmailChannel(t, service, user, "home@example.com")
+	channels, err := service.ListChannel(types.BasicInput[types.ListChannelInput]{User: user})
+	if err != nil || len(channels) != 2 {
+		t.Fatalf("list channels: %+v %v", channels, err)
+	}
+	record := seedTestNotificationRecord(t, service.db, "r1")
+	deliveries, err := notifications.EnsureDeliveries(record, channels)
+	if err != nil {
+		t.Fatalf("ensure deliveries: %v", err)
+	}
+	for _, delivery := range deliveries {
+		if delivery.ChannelID == channels[1].ID {
+			if err := notifications.UpdateDeliveryState(types.UpdateDeliveryStateInput{ID: delivery.ID, State: dbmodels.NotificationDeliveryStateSentOk}); err != nil {
+				t.Fatalf("update delivery: %v", err)
+			}
+		}
+	}
+
+	if err := notifications.RemoveGoneChannel(channels[0].ID); err != nil {
+		t.Fatalf("remove gone channel: %v", err)
+	}
+	remaining, _ := service.ListChannel(types.BasicInput[types.ListChannelInput]{User: user})
+	if len(remaining) != 1 || remaining[0].ID != channels[1].ID {
+		t.Fatalf("expected the gone channel to be removed, got %+v", remaining)
+	}
+	if state, err := notifications.AggregateRecordState(record.ID); err != nil || state != dbmodels.NotificationRecordStateSentOk {
+		t.Fatalf("expected the record to finish on the remaining channel, got %s %v", state, err)
+	}
+}
+
+func TestTelegramBindIsSharedBetweenInstances(t *testing.T) {
+	service := newTestUserInteractionService(t)
+	// the bot webhook may reach another instance than the one that issued the code
+	bot := &UserInteractionService{db: service.db, codes: NewExpiringStore(service.db)}
+	user := &types.UserSessInfo{Id: "u1", Address: "0xabc"}
+
+	if err := service.setPendingOTP(user.Id, channelOTP{Code: "123456", Type: gqlmodels.NotificationChannelTypeTelegram}); err != nil {
+		t.Fatalf("store OTP: %v", err)
+	}
+	if err := service.codes.Set(telegramBindKey("123456"), user.Id, time.Minute); err != nil {
+		t.Fatalf("store bind code: %v", err)
+	}
+	if err := bot.BindTelegramChat("123456", 42); err != nil {
+		t.Fatalf("expected the chat to be bound, got %v", err)
+	}
+	if err := bot.BindTelegramChat("123456", 43); !errors.Is(err, ErrTelegramBindCodeInvalid) {
+		t.Fatalf("expected the bind code to be used once, got %v", err)
+	}
+
+	output, err := service.VerifyNotificationChannel(types.BasicInput[gqlmodels.VerifyNotificationChannelInput]{
+		User:  user,
+		Input: gqlmodels.VerifyNotificationChannelInput{Type: gqlmodels.NotificationChannelTypeTelegram, OtpCode: "123456"},
+	})
+	if err != nil || output.Code != 0 {
+		t.Fatalf("verify telegram: %+v %v", output, err)
+	}
+	channels, err := service.ListChannel(types.BasicInput[types.ListChannelInput]{User: user})
+	if err != nil || len(channels) != 1 || channels[0].ChannelValue != "42" {
+		t.Fatalf("expected chat 42 to be stored, got %+v %v", channels, err)
+	}
+}
+
+func TestTelegramBindLimitsAttemptsPerChat(t *testing.T) {
+	service := newTestUserInteractionService(t)
+	user := &types.UserSessInfo{Id: "u1", Address: "0xabc"}
+	if err := service.setPendingOTP(user.Id, channelOTP{Code: "123456", Type: gqlmodels.NotificationChannelTypeTelegram}); err != nil {
+		t.Fatalf("store OTP: %v", err)
+	}
+	if err := service.codes.Set(telegramBindKey("123456"), user.Id, time.Minute); err != nil {
+		t.Fatalf("store bind code: %v", err)
+	}
+
+	for i := 0; i < MaxOTPAttempts; i++ {
+		if err := service.BindTelegramChat("00000"+string(rune('0'+i)), 42); !errors.Is(err, ErrTelegramBindCodeInvalid) {
+			t.Fatalf("attempt %d: expected an invalid code, got %v", i, err)
+		}
+	}
+	// the right code is refused as well once the chat guessed too often
+	if err := service.BindTelegramChat("123456", 42); !errors.Is(err, ErrTelegramBindRateLimited) {
+		t.Fatalf("expected the chat to be rate limited, got %v", err)
+	}
+	if err := service.BindTelegramChat("123456", 43); err != nil {
+		t.Fatalf("expected another chat to bind, got %v", err)
+	}
+}
+
+func TestVerifyInvalidatesOTPAfterTooManyMisses(t *testing.T) {
+	service := newTestUserInteractionService(t)
+	user := &types.UserSessInfo{Id: "u1", Address: "0xabc"}
+	if err := service.setPendingOTP(user.Id, channelOTP{Code: "123456", Type: gqlmodels.NotificationChannelTypeEmail, Value: "voter@example.com"}); err != nil {
+		t.Fatalf("store OTP: %v", err)
+	}
+
+	verify := func(code string) *gqlmodels.VerifyNotificationChannelOutput {
+		output, err := service.VerifyNotificationChannel(types.BasicInput[gqlmodels.VerifyNotificationChannelInput]{
+			User:  user,
+			Input: gqlmodels.VerifyNotificationChannelInput{Type: gqlmodels.NotificationChannelTypeEmail, Value: "voter@example.com", OtpCode: code},
+		})
+		if err != nil {
+			t.Fatalf("verify %s: %v", code, err)
+		}
+		return output
+	}
+	for i := 0; i < MaxOTPAttempts; i++ {
+		if output := verify("000000"); output.Code != 1 {
+			t.Fatalf("attempt %d: expected a wrong code to be refused, got %+v", i, output)
+		}
+	}
+	if pending := service.PendingChannel(user); pending != nil {
+		t.Fatalf("expected the OTP to be invalidated, got %+v", pending)
+	}
+	if output := verify("123456"); output.Code != 1 {
+		t.Fatalf("expected the invalidated code to be refused, got %+v", output)
+	}
+}
@@ -56,6 +56,30 @@ func GetTaskDefinitions() []TaskDefinition {
 			},
 			Constructor: func() Task { return NewTrackingVoteEndTask() },
 		},
+		{
+			Config: TaskConfig{
+				Name:     "tracking-vote-reminder",
+				Interval: cfg.GetTaskVoteReminderTrackingInterval(),
+				Enabled:  cfg.GetTaskVoteReminderTrackingEnabled(),
+			},
+			Constructor: func() Task { return NewTrackingVoteReminderTask() },
+		},
+		{
+			Config: TaskConfig{
+				Name:     "tracking-execution",
+				Interval: cfg.GetTaskExecutionTrackingInterval(),
+				Enabled:  cfg.GetTaskExecutionTrackingEnabled(),
+			},
+			Constructor: func() Task { return NewTrackingExecutionTask() },
+		},
+		{
+			Config: TaskConfig{
+				Name:     "tracking-delegation",
+				Interval: cfg.GetTaskDelegationTrackingInterval(),
+				Enabled:  cfg.GetTaskDelegationTrackingEnabled(),
+			},
+			Constructor: func() Task { return NewTrackingDelegationTask() },
+		},
 		{
 			Config: TaskConfig{
 				Name:     "notification-event",
@@ -72,6 +96,14 @@ func GetTaskDefinitions() []TaskDefinition {
 			},
 			Constructor: func() Task { return NewNotificationDispatcherTask() },
 		},
+		{
+			Config: TaskConfig{
+				Name:     "notification-digest",
+				Interval: cfg.GetTaskNotificationDigestInterval(),
+				Enabled:  cfg.GetTaskNotificationDigestEnabled(),
+			},
+			Constructor: func() Task { return NewNotificationDigestTask() },
+		},
 	}
 }
 
@@ -67,12 +67,12 @@ func (t *DaoSyncTask) Name() string {
 }
 
 // Execute performs the DAO synchronization
-func (t *DaoSyncTask) Execute() error {
-	return t.syncDaos()
+func (t *DaoSyncTask) Execute(ctx context.Context) error {
+	return t.syncDaos(ctx)
 }
 
 // SyncDaos fetches the latest DAO configuration and syncs it with the database
-func (t *DaoSyncTask) syncDaos() error {
+func (t *DaoSyncTask) syncDaos(ctx context.Context) error {
 	startTime := time.Now()
 	slog.Info("Starting DAO synchronization", "timestamp", startTime.Format(time.RFC3339))
 
@@ -95,6 +95,9 @@ func (t *DaoSyncTask) syncDaos() error {
 
 	// Process each chain and its DAOs
 	for chainName, daos := range registryConfigResult.Result {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		for _, daoInfo := range daos {
 			daoConfig, err := t.processSingleDao(registryConfigResult.RemoteLink, daoInfo, chainName, activeDaoCodes)
 			if err != nil {
@@ -146,6 +149,10 @@ func (t *DaoSyncTask) processSingleDao(remoteLink GithubConfigLink, daoInfo DaoR
 		slog.Warn("DAO config missing essential fields", "config_url", daoInfo.Config)
 		return types.DaoConfig{}, fmt.Errorf("missing essential fields in DAO config for code: %s", daoConfig.Config.Code)
 	}
+	if err := services.ValidateDaoBranding(daoConfig.Config.Branding); err != nil {
+		// emails of the DAO keep the DeGov.AI branding until the registry config is fixed
+		slog.Warn("DAO config has invalid email branding", "dao", daoConfig.Config.Code, "error", err)
+	}
 
 	cfg := config.GetConfig()
 	services.ApplyDaoConfigInputOverrides(
@@ -0,0 +1,187 @@
+package tasks
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ringecosystem/degov-square/database"
+	"github.com/ringecosystem/degov-square/internal/config"
+)
+
+const taskLockQueryTimeout = 5 * time.Second
+
+// TaskLocker decides which instance executes a task, a task only runs on the instance holding its lock
+type TaskLocker interface {
+	// Acquire reports whether this instance holds the lock of the task, taking it when it is free.
+	// The lock is kept between runs, the task stays on this instance until the lock is released or lost.
+	// The returned context is cancelled once that happens, a run in progress stops with it.
+	Acquire(name string) (context.Context, bool, error)
+	// Release hands the task over to the other instances
+	Release(name string)
+	// ReleaseAll hands every task held by this instance over to the other instances
+	ReleaseAll()
+}
+
+func newTaskLocker() (TaskLocker, error) {
+	cfg := config.GetConfig()
+	if !cfg.GetTaskLockEnabled() {
+		return localTaskLocker{}, nil
+	}
+	sqlDB, err := database.GetDB().DB()
+	if err != nil {
+		return nil, err
+	}
+	return NewPostgresTaskLocker(sqlDB, cfg.GetTaskLockTTL()), nil
+}
+
+// localTaskLocker runs every task on this instance, for deployments with a single instance
+type localTaskLocker struct{}
+
+func (localTaskLocker) Acquire(string) (context.Context, bool, error) {
+	return context.Background(), true, nil
+}
+
+func (localTaskLocker) Release(string) {}
+
+func (localTaskLocker) ReleaseAll() {}
+
+// PostgresTaskLocker holds a session level advisory lock per task on a dedicated connection. Postgres releases the
+// lock when that connection closes, so the tasks of a stopped or crashed instance are taken over by the next instance
+// that tries. The connection is sent a heartbeat and closed by the server through idle_session_timeout once the
+// heartbeats stop for longer than the TTL, so a hung instance hands its tasks over as well.
+type PostgresTaskLocker struct {
+	db   *sql.DB
+	ttl  time.Duration
+	mu   sync.Mutex
+	held map[string]*taskLease
+}
+
+type taskLease struct {
+	conn *sql.Conn
+	// ctx is cancelled when the lock is lost or released
+	ctx  context.Context
+	stop context.CancelFunc
+	done chan struct{}
+	lost atomic.Bool
+}
+
+func NewPostgresTaskLocker(db *sql.DB, ttl time.Duration) *PostgresTaskLocker {
+	return &PostgresTaskLocker{
+		db:   db,
+		ttl:  ttl,
+		held: make(map[string]*taskLease),
+	}
+}
+
+// taskLockKey maps a task name to its advisory lock key, prefixed to stay clear of other advisory lock users
+func taskLockKey(name string) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte("degov-square/task/" + name))
+	return int64(hash.Sum64())
+}
+
+func (l *PostgresTaskLocker) Acquire(name string) (context.Context, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if lease, ok := l.held[name]; ok {
+		if !lease.lost.Load() {
+			return lease.ctx, true, nil
+		}
+		<-lease.done
+		delete(l.held, name)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), taskLockQueryTimeout)
+	defer cancel()
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to open task lock connection: %w", err)
+	}
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", taskLockKey(name)).Scan(&locked); err != nil {
+		discardConn(conn)
+		return nil, false, fmt.Errorf("failed to try task lock: %w", err)
+	}
+	if !locked {
+		_ = conn.Close()
+		return nil, false, nil
+	}
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("SET idle_session_timeout = %d", l.ttl.Milliseconds())); err != nil {
+		// postgres before 14 has no idle_session_timeout, the lock is then only released when the connection drops
+		slog.Warn("Task lock is held without a ttl", "task", name, "error", err)
+	}
+
+	leaseCtx, stop := context.WithCancel(context.Background())
+	lease := &taskLease{conn: conn, ctx: leaseCtx, stop: stop, done: make(chan struct{})}
+	l.held[name] = lease
+	go l.heartbeat(name, lease)
+
+	slog.Info("Acquired task lock", "task", name)
+	return leaseCtx, true, nil
+}
+
+func (l *PostgresTaskLocker) heartbeat(name string, lease *taskLease) {
+	defer close(lease.done)
+	ctx := lease.ctx
+	ticker := time.NewTicker(max(l.ttl/3, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pingCtx, cancel := context.WithTimeout(ctx, taskLockQueryTimeout)
+			_, err := lease.conn.ExecContext(pingCtx, "SELECT 1")
+			cancel()
+			if err != nil && ctx.Err() == nil {
+				// the run in progress is stopped, the lock is tried again before the next one
+				slog.Error("Lost task lock, another instance may take the task over", "task", name, "error", err)
+				lease.lost.Store(true)
+				lease.stop()
+				discardConn(lease.conn)
+				return
+			}
+		}
+	}
+}
+
+func (l *PostgresTaskLocker) Release(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if lease, ok := l.held[name]; ok {
+		l.release(name, lease)
+	}
+}
+
+func (l *PostgresTaskLocker) ReleaseAll() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for name, lease := range l.held {
+		l.release(name, lease)
+	}
+}
+
+func (l *PostgresTaskLocker) release(name string, lease *taskLease) {
+	lease.stop()
+	<-lease.done
+	discardConn(lease.conn)
+	delete(l.held, name)
+	slog.Info("Released task lock", "task", name)
+}
+
+// discardConn closes the connection instead of returning it to the pool, the session and its locks end with it
+func discardConn(conn *sql.Conn) {
+	_ = conn.Raw(func(any) error {
+		return driver.ErrBadConn
+	})
+}
@@ -0,0 +1,91 @@
+package tasks
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func openTaskLockTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TASK_LOCK_TEST_DSN")
+	if dsn == "" {
+		t.Skip("set TASK_LOCK_TEST_DSN to a postgres dsn to run the task lock tests")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open postgres: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("open postgres: %v", err)
+	}
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	return sqlDB
+}
+
+func TestPostgresTaskLockersCompeteForTheSameTask(t *testing.T) {
+	// two pools stand in for two instances
+	first := NewPostgresTaskLocker(openTaskLockTestDB(t), 3*time.Second)
+	second := NewPostgresTaskLocker(openTaskLockTestDB(t), 3*time.Second)
+	defer first.ReleaseAll()
+	defer second.ReleaseAll()
+	name := "test-" + t.Name()
+
+	lease, held, err := first.Acquire(name)
+	if err != nil || !held {
+		t.Fatalf("expected the first locker to take the task, got %v %v", held, err)
+	}
+	if _, held, err := second.Acquire(name); err != nil || held {
+		t.Fatalf("expected the second locker to be refused, got %v %v", held, err)
+	}
+	if _, held, err := first.Acquire(name); err != nil || !held {
+		t.Fatalf("expected the first locker to keep the task between runs, got %v %v", held, err)
+	}
+
+	first.Release(name)
+	if lease.Err() == nil {
+		t.Fatal("expected the released lease to be cancelled")
+	}
+	if _, held, err := second.Acquire(name); err != nil || !held {
+		t.Fatalf("expected the second locker to take the released task, got %v %v", held, err)
+	}
+}
+
+func TestPostgresTaskLockerAcquiresLostLeaseAgain(t *testing.T) {
+	db := openTaskLockTestDB(t)
+	locker := NewPostgresTaskLocker(db, 3*time.Second)
+	defer locker.ReleaseAll()
+	name := "test-" + t.Name()
+
+	lease, held, err := locker.Acquire(name)
+	if err != nil || !held {
+		t.Fatalf("acquire: %v %v", held, err)
+	}
+	// drop the session holding the lock, as a network failure would
+	key := taskLockKey(name)
+	if _, err := db.Exec(
+		`SELECT pg_terminate_backend(pid) FROM pg_locks
+		WHERE locktype = 'advisory' AND granted AND classid = $1 AND objid = $2`,
+		uint32(uint64(key)>>32), uint32(uint64(key)),
+	); err != nil {
+		t.Fatalf("terminate lock session: %v", err)
+	}
+
+	select {
+	case <-lease.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the heartbeat to notice the lost lease")
+	}
+	renewed, held, err := locker.Acquire(name)
+	if err != nil || !held || renewed.Err() != nil {
+		t.Fatalf("expected the lost lease to be acquired again, got %v %v", held, err)
+	}
+	if renewed == lease {
+		t.Fatal("expected a new lease")
+	}
+}
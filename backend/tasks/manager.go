@@ -2,22 +2,30 @@ package tasks
 
 import (
 	"context"
+	"errors"
 	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/go-co-op/gocron/v2"
+
+	"github.com/ringecosystem/degov-square/internal/config"
 )
 
 type TaskManager struct {
 	scheduler        gocron.Scheduler
 	tasks            []Task
 	metricsCollector *MetricsCollector
+	locker           TaskLocker
+	maxRunTime       time.Duration
+	initialRuns      sync.WaitGroup
 }
 
 // Task interface for all background tasks
 type Task interface {
 	Name() string
-	Execute() error
+	// Execute runs the task once, it should return soon after ctx is done
+	Execute(ctx context.Context) error
 }
 
 // NewTaskManager creates a new task manager with gocron scheduler
@@ -26,11 +34,17 @@ func NewTaskManager() (*TaskManager, error) {
 	if err != nil {
 		return nil, err
 	}
+	locker, err := newTaskLocker()
+	if err != nil {
+		return nil, err
+	}
 
 	return &TaskManager{
 		scheduler:        scheduler,
 		tasks:            make([]Task, 0),
 		metricsCollector: NewMetricsCollector(),
+		locker:           locker,
+		maxRunTime:       config.GetConfig().GetTaskMaxRunTime(),
 	}, nil
 }
 
@@ -42,20 +56,7 @@ func (tm *TaskManager) RegisterTask(task Task, interval time.Duration) error {
 		gocron.DurationJob(interval),
 		gocron.NewTask(
 			func() {
-				slog.Info("Executing scheduled task", "task", task.Name())
-
-				startTime := time.Now()
-				err := task.Execute()
-				duration := time.Since(startTime)
-
-				// Track metrics
-				tm.metricsCollector.TrackExecution(task.Name(), duration, err)
-
-				if err != nil {
-					slog.Error("Task execution failed", "task", task.Name(), "error", err)
-				} else {
-					slog.Debug("Task execution completed", "task", task.Name(), "duration", duration.String())
-				}
+				tm.runTask(task, false)
 			},
 		),
 		gocron.WithName(task.Name()),
@@ -76,19 +77,10 @@ func (tm *TaskManager) Start(ctx context.Context) {
 
 	// Execute all tasks immediately on startup
 	for _, task := range tm.tasks {
+		tm.initialRuns.Add(1)
 		go func(t Task) {
-			slog.Info("Running initial execution", "task", t.Name())
-
-			startTime := time.Now()
-			err := t.Execute()
-			duration := time.Since(startTime)
-
-			// Track metrics for initial execution
-			tm.metricsCollector.TrackExecution(t.Name(), duration, err)
-
-			if err != nil {
-				slog.Error("Initial task execution failed", "task", t.Name(), "error", err)
-			}
+			defer tm.initialRuns.Done()
+			tm.runTask(t, true)
 		}(task)
 	}
 
@@ -99,13 +91,79 @@ func (tm *TaskManager) Start(ctx context.Context) {
 	<-ctx.Done()
 	slog.Info("Stopping task manager")
 
-	// Log final metrics summary
-	tm.metricsCollector.LogSummary()
-
 	// Shutdown the scheduler gracefully
 	if err := tm.scheduler.Shutdown(); err != nil {
 		slog.Error("Error shutting down scheduler", "error", err)
 	}
+
+	// hand the tasks over once nothing runs on this instance anymore
+	tm.initialRuns.Wait()
+	tm.locker.ReleaseAll()
+
+	// Log final metrics summary
+	tm.metricsCollector.LogSummary()
+}
+
+// runTask executes the task when this instance holds its lock. The run is stopped when the lock is lost, and a run
+// exceeding maxRunTime is asked to stop and hands its lock over once it returns. The lock is never released while the
+// run is still going, a run that ignores ctx keeps it until it returns or the session of the lock ends.
+func (tm *TaskManager) runTask(task Task, initial bool) {
+	lease, held := tm.holdsLock(task)
+	if !held {
+		return
+	}
+	if initial {
+		slog.Info("Running initial execution", "task", task.Name())
+	} else {
+		slog.Info("Executing scheduled task", "task", task.Name())
+	}
+
+	ctx, cancel := lease, context.CancelFunc(func() {})
+	if tm.maxRunTime > 0 {
+		ctx, cancel = context.WithTimeout(lease, tm.maxRunTime)
+	}
+	defer cancel()
+	stopWatching := context.AfterFunc(ctx, func() {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			slog.Error("Task exceeded its max run time, asking it to stop", "task", task.Name(), "max_run_time", tm.maxRunTime.String())
+		}
+	})
+	defer stopWatching()
+
+	startTime := time.Now()
+	err := task.Execute(ctx)
+	duration := time.Since(startTime)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) && lease.Err() == nil {
+		// hand the task to another instance, this one was too slow to keep it
+		slog.Warn("Releasing the lock of a task that exceeded its max run time", "task", task.Name(), "duration", duration.String())
+		tm.locker.Release(task.Name())
+	}
+
+	// Track metrics
+	tm.metricsCollector.TrackExecution(task.Name(), duration, err)
+
+	switch {
+	case err != nil && initial:
+		slog.Error("Initial task execution failed", "task", task.Name(), "error", err)
+	case err != nil:
+		slog.Error("Task execution failed", "task", task.Name(), "error", err)
+	default:
+		slog.Debug("Task execution completed", "task", task.Name(), "duration", duration.String())
+	}
+}
+
+// holdsLock reports whether this instance executes the task, each task runs on one instance at a time.
+// The returned context is cancelled when the lock is lost.
+func (tm *TaskManager) holdsLock(task Task) (context.Context, bool) {
+	lease, held, err := tm.locker.Acquire(task.Name())
+	if err != nil {
+		slog.Error("Failed to acquire task lock", "task", task.Name(), "error", err)
+		return nil, false
+	}
+	if !held {
+		slog.Debug("Skip task, another instance holds its lock", "task", task.Name())
+	}
+	return lease, held
 }
 
 // GetTaskCount returns the number of registered tasks
@@ -0,0 +1,213 @@
+package tasks
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+)
+
+// fakeTaskLocker hands out leases that tests can take away again, as a lost database connection would
+type fakeTaskLocker struct {
+	mu       sync.Mutex
+	free     map[string]bool
+	leases   map[string]context.CancelFunc
+	acquired map[string]int
+	released []string
+	// releasedAll is closed by ReleaseAll
+	releasedAll chan struct{}
+}
+
+func newFakeTaskLocker(free ...string) *fakeTaskLocker {
+	locker := &fakeTaskLocker{
+		free:        make(map[string]bool),
+		leases:      make(map[string]context.CancelFunc),
+		acquired:    make(map[string]int),
+		releasedAll: make(chan struct{}),
+	}
+	for _, name := range free {
+		locker.free[name] = true
+	}
+	return locker
+}
+
+func (l *fakeTaskLocker) Acquire(name string) (context.Context, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.free[name] {
+		return nil, false, nil
+	}
+	l.acquired[name]++
+	ctx, cancel := context.WithCancel(context.Background())
+	l.leases[name] = cancel
+	return ctx, true, nil
+}
+
+// lose cancels the current lease of the task, the next Acquire hands out a new one
+func (l *fakeTaskLocker) lose(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.leases[name]()
+}
+
+func (l *fakeTaskLocker) Release(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if cancel, ok := l.leases[name]; ok {
+		cancel()
+	}
+	l.released = append(l.released, name)
+}
+
+func (l *fakeTaskLocker) ReleaseAll() {
+	close(l.releasedAll)
+}
+
+type fakeTask struct {
+	name    string
+	execute func(ctx context.Context) error
+	runs    int
+}
+
+func (t *fakeTask) Name() string {
+	return t.name
+}
+
+func (t *fakeTask) Execute(ctx context.Context) error {
+	t.runs++
+	if t.execute != nil {
+		return t.execute(ctx)
+	}
+	return nil
+}
+
+func newTestTaskManager(t *testing.T, locker TaskLocker) *TaskManager {
+	t.Helper()
+	scheduler, err := gocron.NewScheduler()
+	if err != nil {
+		t.Fatalf("new scheduler: %v", err)
+	}
+	return &TaskManager{
+		scheduler:        scheduler,
+		metricsCollector: NewMetricsCollector(),
+		locker:           locker,
+		maxRunTime:       time.Minute,
+	}
+}
+
+func TestRunTaskSkipsTasksHeldElsewhere(t *testing.T) {
+	locker := newFakeTaskLocker("held")
+	tm := newTestTaskManager(t, locker)
+	held := &fakeTask{name: "held"}
+	elsewhere := &fakeTask{name: "elsewhere"}
+
+	tm.runTask(held, false)
+	tm.runTask(elsewhere, false)
+
+	if held.runs != 1 || elsewhere.runs != 0 {
+		t.Fatalf("expected only the held task to run, got %d and %d runs", held.runs, elsewhere.runs)
+	}
+}
+
+func TestRunTaskStopsOnLostLeaseAndAcquiresAgain(t *testing.T) {
+	locker := newFakeTaskLocker("dispatcher")
+	tm := newTestTaskManager(t, locker)
+	var sent int
+	task := &fakeTask{name: "dispatcher"}
+	task.execute = func(ctx context.Context) error {
+		for batch := 0; batch < 3; batch++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			sent++
+			if task.runs == 1 && batch == 0 {
+				locker.lose("dispatcher")
+			}
+		}
+		return nil
+	}
+
+	tm.runTask(task, false)
+	if sent != 1 {
+		t.Fatalf("expected the run to stop after the lease was lost, sent %d batches", sent)
+	}
+	tm.runTask(task, false)
+	if sent != 4 || locker.acquired["dispatcher"] != 2 {
+		t.Fatalf("expected the next run to acquire a new lease and finish, sent %d batches after %d acquires", sent, locker.acquired["dispatcher"])
+	}
+}
+
+func TestRunTaskKeepsLockUntilOverrunningRunReturns(t *testing.T) {
+	locker := newFakeTaskLocker("stuck", "quick")
+	tm := newTestTaskManager(t, locker)
+	tm.maxRunTime = 20 * time.Millisecond
+	var releasedWhileRunning bool
+	stuck := &fakeTask{name: "stuck", execute: func(ctx context.Context) error {
+		// a run that ignores ctx, another instance must not take the task while it is still going
+		<-ctx.Done()
+		time.Sleep(30 * time.Millisecond)
+		locker.mu.Lock()
+		releasedWhileRunning = len(locker.released) > 0
+		locker.mu.Unlock()
+		return nil
+	}}
+	quick := &fakeTask{name: "quick"}
+
+	tm.runTask(stuck, false)
+	tm.runTask(quick, false)
+
+	if releasedWhileRunning {
+		t.Fatal("expected the lock to be kept while the run was still going")
+	}
+	locker.mu.Lock()
+	defer locker.mu.Unlock()
+	if len(locker.released) != 1 || locker.released[0] != "stuck" {
+		t.Fatalf("expected only the overrunning task to be released once it returned, got %v", locker.released)
+	}
+}
+
+func TestStartReleasesLocksAfterInitialRuns(t *testing.T) {
+	locker := newFakeTaskLocker("slow")
+	tm := newTestTaskManager(t, locker)
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	finished := make(chan struct{})
+	slow := &fakeTask{name: "slow", execute: func(context.Context) error {
+		close(started)
+		<-finish
+		close(finished)
+		return nil
+	}}
+	if err := tm.RegisterTask(slow, time.Hour); err != nil {
+		t.Fatalf("register task: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		tm.Start(ctx)
+		close(stopped)
+	}()
+	<-started
+	cancel()
+
+	select {
+	case <-locker.releasedAll:
+		t.Fatal("locks were released while the initial run was still going")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(finish)
+	<-stopped
+	select {
+	case <-finished:
+	default:
+		t.Fatal("expected the initial run to finish before the locks were released")
+	}
+	select {
+	case <-locker.releasedAll:
+	default:
+		t.Fatal("expected the locks to be released on stop")
+	}
+}
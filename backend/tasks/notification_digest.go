@@ -0,0 +1,192 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	"github.com/ringecosystem/degov-square/services"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+// maxDigestRecords caps a single digest, anything left over is sent with the next run
+const maxDigestRecords = 200
+
+type NotificationDigestTask struct {
+	notificationService    *services.NotificationService
+	templateService        *services.TemplateService
+	notifierService        *services.NotifierService
+	userInteractionService *services.UserInteractionService
+	preferenceService      *services.NotificationPreferenceService
+}
+
+func NewNotificationDigestTask() *NotificationDigestTask {
+	return &NotificationDigestTask{
+		notificationService:    services.NewNotificationService(),
+		templateService:        services.NewTemplateService(),
+		notifierService:        services.NewNotifierService(),
+		userInteractionService: services.NewUserInteractionService(),
+		preferenceService:      services.NewNotificationPreferenceService(),
+	}
+}
+
+func (t *NotificationDigestTask) Name() string {
+	return "notification-digest"
+}
+
+func (t *NotificationDigestTask) Execute(ctx context.Context) error {
+	groups, err := t.notificationService.ListDueDigests(100)
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := t.dispatchDigest(ctx, group); err != nil {
+			slog.Error("Failed to dispatch notification digest", "user_id", group.UserID, "digest", group.Digest, "error", err)
+		}
+	}
+	return nil
+}
+
+func (t *NotificationDigestTask) dispatchDigest(ctx context.Context, group types.NotificationDigestGroup) error {
+	records, err := t.notificationService.ListDigestRecords(types.ListDigestRecordsInput{
+		UserID: group.UserID,
+		Digest: group.Digest,
+		Limit:  maxDigestRecords,
+	})
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	// digests are never urgent, a window that closes during quiet hours is sent when they end
+	until, deferred, err := t.preferenceService.DeferUntil(group.UserID, "")
+	if err != nil {
+		return err
+	}
+	if deferred {
+		ids := make([]string, 0, len(records))
+		for _, record := range records {
+			ids = append(ids, record.ID)
+		}
+		return t.notificationService.DeferRecords(ids, until)
+	}
+
+	verified := true
+	channels, err := t.userInteractionService.ListChannel(types.BasicInput[types.ListChannelInput]{
+		User: &types.UserSessInfo{
+			Id: group.UserID,
+		},
+		Input: types.ListChannelInput{
+			Verified: &verified,
+		},
+	})
+	if err != nil {
+		t.retryRecords(records, fmt.Errorf("failed to list user channels: %w", err))
+		return err
+	}
+
+	channelByID := make(map[string]dbmodels.NotificationChannel, len(channels))
+	for _, channel := range channels {
+		channelByID[channel.ID] = channel
+	}
+
+	// collect, per channel, the records that still have to be delivered on it
+	now := time.Now()
+	recordsByChannel := make(map[string][]dbmodels.NotificationRecord)
+	deliveriesByChannel := make(map[string][]dbmodels.NotificationDelivery)
+	for i := range records {
+		// only the channels opted into the feature receive the record
+		routed := services.ChannelsForFeature(channels, records[i].Type)
+		routedIDs := make(map[string]bool, len(routed))
+		for _, channel := range routed {
+			routedIDs[channel.ID] = true
+		}
+		deliveries, err := t.notificationService.EnsureDeliveries(&records[i], routed)
+		if err != nil {
+			t.retryRecords(records, fmt.Errorf("failed to create deliveries: %w", err))
+			return err
+		}
+		for _, delivery := range deliveries {
+			if delivery.State != dbmodels.NotificationDeliveryStatePending || delivery.TimeNextExecute.After(now) {
+				continue
+			}
+			if !routedIDs[delivery.ChannelID] {
+				// the channel was removed, unverified or opted out of the feature after the delivery was created
+				if err := t.notificationService.UpdateDeliveryState(types.UpdateDeliveryStateInput{
+					ID:    delivery.ID,
+					State: dbmodels.NotificationDeliveryStateCancelled,
+				}); err != nil {
+					slog.Error("Failed to update delivery state", "delivery_id", delivery.ID, "error", err)
+				}
+				continue
+			}
+			recordsByChannel[delivery.ChannelID] = append(recordsByChannel[delivery.ChannelID], records[i])
+			deliveriesByChannel[delivery.ChannelID] = append(deliveriesByChannel[delivery.ChannelID], delivery)
+		}
+	}
+
+	for channelID, channelRecords := range recordsByChannel {
+		if ctx.Err() != nil {
+			// the task lock was lost, the pending deliveries are sent by the instance that holds it now
+			break
+		}
+		channel := channelByID[channelID]
+		templateOutput, err := t.templateService.GenerateDigestTemplate(types.GenerateDigestTemplateInput{
+			Digest:      group.Digest,
+			UserAddress: channelRecords[0].UserAddress,
+			Records:     channelRecords,
+		})
+		var output *types.NotifyOutput
+		if err == nil {
+			output, err = t.notifierService.Notify(types.NotifyInput{
+				Type:     channel.ChannelType,
+				To:       channel.ChannelValue,
+				Template: templateOutput,
+				Payload:  channel.Payload,
+			})
+		}
+		if err != nil {
+			slog.Warn(
+				"Failed to notify digest",
+				"user_id", group.UserID,
+				"digest", group.Digest,
+				"channel_type", channel.ChannelType,
+				"error", err,
+			)
+		}
+		for _, delivery := range deliveriesByChannel[channelID] {
+			updateDeliveryOutcome(t.notificationService, delivery, output, err)
+		}
+	}
+
+	for _, record := range records {
+		if _, err := t.notificationService.AggregateRecordState(record.ID); err != nil {
+			slog.Error("Failed to aggregate record state", "record_id", record.ID, "error", err)
+		}
+	}
+	return nil
+}
+
+func (t *NotificationDigestTask) retryRecords(records []dbmodels.NotificationRecord, cause error) {
+	for _, record := range records {
+		timesRetry := record.TimesRetry + 1
+		message := fmt.Sprintf("[%d] %s", timesRetry, cause.Error())
+		if record.Message != nil {
+			message = fmt.Sprintf("%s\n\n-------\n%s", *record.Message, message)
+		}
+		if err := t.notificationService.UpdateRecordRetryTimes(types.UpdateRecordRetryTimes{
+			ID:         record.ID,
+			TimesRetry: timesRetry,
+			Message:    message,
+		}); err != nil {
+			slog.Error("Failed to update record retry times", "record_id", record.ID, "error", err)
+		}
+	}
+}
@@ -1,8 +1,11 @@
 package tasks
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
 	dbmodels "github.com/ringecosystem/degov-square/database/models"
 	"github.com/ringecosystem/degov-square/services"
@@ -14,6 +17,7 @@ type NotificationDispatcherTask struct {
 	templateService        *services.TemplateService
 	notifierService        *services.NotifierService
 	userInteractionService *services.UserInteractionService
+	preferenceService      *services.NotificationPreferenceService
 }
 
 func NewNotificationDispatcherTask() *NotificationDispatcherTask {
@@ -22,6 +26,7 @@ func NewNotificationDispatcherTask() *NotificationDispatcherTask {
 		templateService:        services.NewTemplateService(),
 		notifierService:        services.NewNotifierService(),
 		userInteractionService: services.NewUserInteractionService(),
+		preferenceService:      services.NewNotificationPreferenceService(),
 	}
 }
 
@@ -29,22 +34,36 @@ func (t *NotificationDispatcherTask) Name() string {
 	return "notification-dispatcher"
 }
 
-func (t *NotificationDispatcherTask) Execute() error {
-	return t.dispatcherNotificationRecord()
+func (t *NotificationDispatcherTask) Execute(ctx context.Context) error {
+	return t.dispatcherNotificationRecord(ctx)
 }
 
-func (t *NotificationDispatcherTask) dispatcherNotificationRecord() error {
+func (t *NotificationDispatcherTask) dispatcherNotificationRecord(ctx context.Context) error {
 	states := []dbmodels.NotificationRecordState{
 		dbmodels.NotificationRecordStatePending,
 	}
 	records, err := t.notificationService.ListLimitRecords(types.ListLimitRecordsInput{
-		Limit:  100,
-		States: &states,
+		Limit:       100,
+		States:      &states,
+		InstantOnly: true,
 	})
 	if err != nil {
 		return err
 	}
 	for _, record := range records {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		until, deferred, err := t.preferenceService.DeferUntil(record.UserID, record.Type)
+		if err != nil {
+			slog.Warn("Failed to load notification preference", "user_id", record.UserID, "error", err)
+		} else if deferred {
+			if err := t.notificationService.DeferRecords([]string{record.ID}, until); err != nil {
+				slog.Error("Failed to defer record for quiet hours", "record_id", record.ID, "error", err)
+			}
+			continue
+		}
+
 		verified := true
 		channels, err := t.userInteractionService.ListChannel(types.BasicInput[types.ListChannelInput]{
 			User: &types.UserSessInfo{
@@ -74,7 +93,9 @@ func (t *NotificationDispatcherTask) dispatcherNotificationRecord() error {
 			continue
 		}
 
-		if err := t.dispatchNotificationRecordByRecord(&record, channels); err != nil {
+		// only the channels opted into the feature receive the record
+		channels = services.ChannelsForFeature(channels, record.Type)
+		if err := t.dispatchNotificationRecordByRecord(ctx, &record, channels); err != nil {
 			slog.Error("Failed to dispatch notification record", "record_id", record.ID, "error", err)
 
 			var message string
@@ -91,51 +112,124 @@ func (t *NotificationDispatcherTask) dispatcherNotificationRecord() error {
 			}); err != nil {
 				slog.Error("Failed to update record retry times", "record_id", record.ID, "error", err)
 			}
-
-			if timesRetry > 4 {
-				if err := t.notificationService.UpdateRecordState(types.UpdateRecordStateInput{
-					ID:    record.ID,
-					State: dbmodels.NotificationRecordStateSentFail,
-				}); err != nil {
-					slog.Error("Failed to update record state to failed", "record_id", record.ID, "error", err)
-				}
-			}
 			continue
 		}
 
-		if err := t.notificationService.UpdateRecordState(types.UpdateRecordStateInput{
-			ID:    record.ID,
-			State: dbmodels.NotificationRecordStateSentOk,
-		}); err != nil {
-			slog.Error("Failed to update record state to send_ok", "record_id", record.ID, "error", err)
+		if _, err := t.notificationService.AggregateRecordState(record.ID); err != nil {
+			slog.Error("Failed to aggregate record state", "record_id", record.ID, "error", err)
 			continue
 		}
 	}
 	return nil
 }
 
-func (t *NotificationDispatcherTask) dispatchNotificationRecordByRecord(record *dbmodels.NotificationRecord, channels []dbmodels.NotificationChannel) error {
+// dispatchNotificationRecordByRecord delivers the record to every channel that has not received it yet.
+// Channel failures are tracked on the delivery, only errors that affect all channels are returned.
+func (t *NotificationDispatcherTask) dispatchNotificationRecordByRecord(ctx context.Context, record *dbmodels.NotificationRecord, channels []dbmodels.NotificationChannel) error {
+	deliveries, err := t.notificationService.EnsureDeliveries(record, channels)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	dueDeliveries := make([]dbmodels.NotificationDelivery, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		if delivery.State == dbmodels.NotificationDeliveryStatePending && !delivery.TimeNextExecute.After(now) {
+			dueDeliveries = append(dueDeliveries, delivery)
+		}
+	}
+	if len(dueDeliveries) == 0 {
+		return nil
+	}
+
 	templateOutput, err := t.templateService.GenerateTemplateByNotificationRecord(record)
 	if err != nil {
 		return err
 	}
 	slog.Debug("Dispatch notification record", "record_id", record.ID, "template", templateOutput)
 
+	channelByID := make(map[string]dbmodels.NotificationChannel, len(channels))
 	for _, channel := range channels {
-		if err := t.notifierService.Notify(types.NotifyInput{
+		channelByID[channel.ID] = channel
+	}
+
+	for _, delivery := range dueDeliveries {
+		if ctx.Err() != nil {
+			// the task lock was lost, the pending deliveries are sent by the instance that holds it now
+			return nil
+		}
+		channel, ok := channelByID[delivery.ChannelID]
+		if !ok {
+			// the channel was removed, unverified or opted out of the feature after the delivery was created
+			if err := t.notificationService.UpdateDeliveryState(types.UpdateDeliveryStateInput{
+				ID:    delivery.ID,
+				State: dbmodels.NotificationDeliveryStateCancelled,
+			}); err != nil {
+				slog.Error("Failed to update delivery state", "delivery_id", delivery.ID, "error", err)
+			}
+			continue
+		}
+
+		output, err := t.notifierService.Notify(types.NotifyInput{
 			Type:     channel.ChannelType,
 			To:       channel.ChannelValue,
 			Template: templateOutput,
-		}); err != nil {
-			// todo: The best practice is to record the failure of a channel to avoid repeated pushes, but there will not be multiple channels for the time being
+			Record:   record,
+			Payload:  channel.Payload,
+		})
+		if err != nil {
 			slog.Warn(
 				"Failed to notify",
+				"record_id", record.ID,
 				"channel_type", channel.ChannelType,
 				"channel_to", channel.ChannelValue,
 				"error", err,
 			)
-			return err
 		}
+		updateDeliveryOutcome(t.notificationService, delivery, output, err)
 	}
 	return nil
 }
+
+// updateDeliveryOutcome stores the result of a notify attempt on the delivery, failures are scheduled for a retry
+func updateDeliveryOutcome(notificationService *services.NotificationService, delivery dbmodels.NotificationDelivery, output *types.NotifyOutput, notifyErr error) {
+	var responseCode *int
+	if output != nil && output.StatusCode != 0 {
+		responseCode = &output.StatusCode
+	}
+
+	if errors.Is(notifyErr, services.ErrNotificationChannelGone) {
+		// a retry cannot succeed, the channel is dropped and the record finishes on the remaining channels
+		if err := notificationService.RemoveGoneChannel(delivery.ChannelID); err != nil {
+			slog.Error("Failed to remove gone channel", "channel_id", delivery.ChannelID, "error", err)
+		}
+		if err := notificationService.UpdateDeliveryState(types.UpdateDeliveryStateInput{
+			ID:           delivery.ID,
+			State:        dbmodels.NotificationDeliveryStateCancelled,
+			ResponseCode: responseCode,
+		}); err != nil {
+			slog.Error("Failed to update delivery state", "delivery_id", delivery.ID, "error", err)
+		}
+		return
+	}
+
+	if notifyErr != nil {
+		if err := notificationService.UpdateDeliveryRetryTimes(types.UpdateDeliveryRetryTimes{
+			ID:           delivery.ID,
+			TimesRetry:   delivery.TimesRetry + 1,
+			LastError:    notifyErr.Error(),
+			ResponseCode: responseCode,
+		}); err != nil {
+			slog.Error("Failed to update delivery retry times", "delivery_id", delivery.ID, "error", err)
+		}
+		return
+	}
+
+	if err := notificationService.UpdateDeliveryState(types.UpdateDeliveryStateInput{
+		ID:           delivery.ID,
+		State:        dbmodels.NotificationDeliveryStateSentOk,
+		ResponseCode: responseCode,
+	}); err != nil {
+		slog.Error("Failed to update delivery state", "delivery_id", delivery.ID, "error", err)
+	}
+}
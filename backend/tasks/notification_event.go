@@ -1,19 +1,23 @@
 package tasks
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
-	"time"
 
 	dbmodels "github.com/ringecosystem/degov-square/database/models"
 	"github.com/ringecosystem/degov-square/services"
 	"github.com/ringecosystem/degov-square/types"
 )
 
+// inboxContentBatchSize bounds the records one update stores inbox content on
+const inboxContentBatchSize = 1000
+
 type NotificationEventTask struct {
 	daoService          *services.DaoService
 	notificationService *services.NotificationService
 	subscribeService    *services.SubscribeService
+	templateService     *services.TemplateService
 }
 
 func NewNotificationEventTask() *NotificationEventTask {
@@ -21,6 +25,7 @@ func NewNotificationEventTask() *NotificationEventTask {
 		daoService:          services.NewDaoService(),
 		notificationService: services.NewNotificationService(),
 		subscribeService:    services.NewSubscribeService(),
+		templateService:     services.NewTemplateService(),
 	}
 }
 
@@ -28,11 +33,11 @@ func (t *NotificationEventTask) Name() string {
 	return "notification-event"
 }
 
-func (t *NotificationEventTask) Execute() error {
-	return t.buildNotificationRecord()
+func (t *NotificationEventTask) Execute(ctx context.Context) error {
+	return t.buildNotificationRecord(ctx)
 }
 
-func (t *NotificationEventTask) buildNotificationRecord() error {
+func (t *NotificationEventTask) buildNotificationRecord(ctx context.Context) error {
 	states := []dbmodels.NotificationEventState{
 		dbmodels.NotificationEventStatePending,
 		dbmodels.NotificationEventStateProgress,
@@ -46,20 +51,36 @@ func (t *NotificationEventTask) buildNotificationRecord() error {
 	}
 
 	for _, event := range events {
-		if err := t.notificationService.UpdateEventState(types.UpdateEventStateInput{
-			ID:    event.ID,
-			State: dbmodels.NotificationEventStateProgress,
-		}); err != nil {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		claimed, err := t.notificationService.ClaimEvent(&event)
+		if err != nil {
 			slog.Error("Failed to update event state to progress", "event_id", event.ID, "error", err)
 			continue
 		}
+		if !claimed {
+			continue
+		}
+		// the claim counted an abandoned run on the event and leased it until this time
+		leaseUntil := event.TimeNextExecute
 
-		if err := t.buildNotificationRecordByEvent(&event); err != nil {
+		if err := t.buildNotificationRecordByEvent(ctx, &event); err != nil {
+			if ctx.Err() != nil {
+				// the task lock was lost, the event is picked up again once its lease expires
+				return ctx.Err()
+			}
 			slog.Error("Failed to build notification record", "event_id", event.ID, "error", err)
+			timesRetry := event.TimesRetry + 1
+			message := fmt.Sprintf("[%d] Failed to build notification record: %s", timesRetry, err.Error())
+			if event.Message != nil {
+				message = fmt.Sprintf("%s\n\n-------\n%s", *event.Message, message)
+			}
 			if err := t.notificationService.UpdateEventRetryTimes(types.UpdateEventRetryTimes{
 				ID:         event.ID,
-				TimesRetry: event.TimesRetry + 1,
-				Message:    fmt.Sprintf("%s \n\nFailed to build notification record: %s", *event.Message, err.Error()),
+				TimesRetry: timesRetry,
+				Message:    message,
+				LeaseUntil: &leaseUntil,
 			}); err != nil {
 				slog.Error("Failed to update event retry times", "event_id", event.ID, "error", err)
 			}
@@ -67,8 +88,9 @@ func (t *NotificationEventTask) buildNotificationRecord() error {
 		}
 
 		if err := t.notificationService.UpdateEventState(types.UpdateEventStateInput{
-			ID:    event.ID,
-			State: dbmodels.NotificationEventStateCompleted,
+			ID:         event.ID,
+			State:      dbmodels.NotificationEventStateCompleted,
+			LeaseUntil: &leaseUntil,
 		}); err != nil {
 			slog.Error("Failed to update event state to completed", "event_id", event.ID, "error", err)
 			continue
@@ -78,77 +100,69 @@ func (t *NotificationEventTask) buildNotificationRecord() error {
 	return nil
 }
 
-func (t *NotificationEventTask) buildNotificationRecordByEvent(event *dbmodels.NotificationEvent) error {
-	var (
-		offset     = 0
-		limit      = 100
-		recordsBuf = make([]dbmodels.NotificationRecord, 0, 256)
-		batchSize  = 200
-	)
-	for {
-		subscribedUsers, err := t.subscribeService.ListSubscribedUser(types.ListSubscribeUserInput{
-			Feature:    event.Type,
-			Strategies: t.allowStrategies(event.Type),
-			DaoCode:    event.DaoCode,
-			ProposalID: &event.ProposalID,
-			TimeEvent:  &event.TimeEvent,
-			Limit:      limit,
-			Offset:     offset,
-		})
-		if err != nil {
-			return err
-		}
-		for _, user := range subscribedUsers {
-			rec := dbmodels.NotificationRecord{
-				Code:        event.ID + "_" + user.UserID,
-				EventID:     event.ID,
-				ChainID:     event.ChainID,
-				DaoCode:     event.DaoCode,
-				Type:        event.Type,
-				ProposalID:  event.ProposalID,
-				VoteID:      event.VoteID,
-				Payload:     event.Payload,
-				UserID:      user.UserID,
-				UserAddress: user.UserAddress,
-				State:       dbmodels.NotificationRecordStatePending,
-				TimesRetry:  0,
-				CTime:       time.Now(),
-			}
-			recordsBuf = append(recordsBuf, rec)
-
-			if len(recordsBuf) >= batchSize {
-				if err := t.notificationService.StoreRecords(recordsBuf); err != nil {
-					return fmt.Errorf("failed to store notification records: %w", err)
-				}
-				recordsBuf = recordsBuf[:0] // reset buffer
-			}
-		}
-		if len(subscribedUsers) < limit {
-			break
-		}
-		offset += limit
+func (t *NotificationEventTask) buildNotificationRecordByEvent(ctx context.Context, event *dbmodels.NotificationEvent) error {
+	strategies, err := t.allowStrategies(event)
+	if err != nil {
+		return err
+	}
+	created, err := t.notificationService.FanOutEvent(event, strategies)
+	if err != nil {
+		return fmt.Errorf("failed to store notification records: %w", err)
 	}
+	slog.Debug("Fanned out notification event", "event_id", event.ID, "type", event.Type, "records", created)
+	return t.storeInboxContent(ctx, event)
+}
 
-	// flush remaining buffered records
-	if len(recordsBuf) > 0 {
-		if err := t.notificationService.StoreRecords(recordsBuf); err != nil {
-			return fmt.Errorf("failed to store notification records: %w", err)
+// storeInboxContent renders the inbox title and body of the records of the event, whether they are sent right away, in
+// a digest or to no channel at all. The body is rendered once per locale and timezone and stored in batches, a retry of
+// the event only stores it on the records still missing it.
+func (t *NotificationEventTask) storeInboxContent(ctx context.Context, event *dbmodels.NotificationEvent) error {
+	audiences, err := t.notificationService.ListInboxAudiences(event.ID)
+	if err != nil {
+		return fmt.Errorf("failed to list inbox audiences: %w", err)
+	}
+	if len(audiences) == 0 {
+		return nil
+	}
+	outputs, err := t.templateService.GenerateInboxByEvent(event, audiences)
+	if err != nil {
+		return fmt.Errorf("failed to render inbox content: %w", err)
+	}
+	for _, audience := range audiences {
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			stored, err := t.notificationService.StoreInboxContent(event.ID, audience, outputs[audience], inboxContentBatchSize)
+			if err != nil {
+				return fmt.Errorf("failed to store inbox content: %w", err)
+			}
+			if stored < inboxContentBatchSize {
+				break
+			}
 		}
 	}
 	return nil
 }
 
-func (t *NotificationEventTask) allowStrategies(feature dbmodels.SubscribeFeatureName) []string {
-	switch feature {
-	case dbmodels.SubscribeFeatureProposalNew:
-		return []string{"true"}
-	case dbmodels.SubscribeFeatureProposalStateChanged:
-		return []string{"true"}
-	case dbmodels.SubscribeFeatureVoteEmitted:
-		return []string{"true"}
-	case dbmodels.SubscribeFeatureVoteEnd:
-		return []string{"true"}
-	default:
-		return nil
+// allowStrategies returns the strategies whose subscribers are notified of the event.
+// Vote threshold strategies are evaluated against the vote here, only the ones the vote reaches are queried.
+func (t *NotificationEventTask) allowStrategies(event *dbmodels.NotificationEvent) ([]string, error) {
+	strategies := services.SubscribeStrategies(event.Type)
+	if event.Type == dbmodels.SubscribeFeatureVoteReminder {
+		// reminders target a single subscriber who was already matched against their lead time
+		leads, err := t.subscribeService.ListVoteReminderStrategies(event.DaoCode)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list vote reminder strategies: %w", err)
+		}
+		return append(strategies, leads...), nil
+	}
+	if event.Type != dbmodels.SubscribeFeatureVoteEmitted {
+		return strategies, nil
+	}
+	thresholds, err := t.subscribeService.ListVoteThresholdStrategies(event.DaoCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vote threshold strategies: %w", err)
 	}
+	return append(strategies, services.PassingVoteThresholds(thresholds, event.Payload)...), nil
 }
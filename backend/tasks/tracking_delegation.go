@@ -0,0 +1,175 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	"github.com/ringecosystem/degov-square/internal"
+	"github.com/ringecosystem/degov-square/services"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+const delegationQueryTimeout = 30 * time.Second
+
+type TrackingDelegationTask struct {
+	daoService          *services.DaoService
+	daoConfigService    *services.DaoConfigService
+	subscribeService    *services.SubscribeService
+	delegationService   *services.DelegationService
+	notificationService *services.NotificationService
+}
+
+func NewTrackingDelegationTask() *TrackingDelegationTask {
+	return &TrackingDelegationTask{
+		daoService:          services.NewDaoService(),
+		daoConfigService:    services.NewDaoConfigService(),
+		subscribeService:    services.NewSubscribeService(),
+		delegationService:   services.NewDelegationService(),
+		notificationService: services.NewNotificationService(),
+	}
+}
+
+// Name returns the task name
+func (t *TrackingDelegationTask) Name() string {
+	return "tracking-delegation"
+}
+
+// Execute diffs the delegators of every subscribed delegate and stores DELEGATION_CHANGED events
+func (t *TrackingDelegationTask) Execute(ctx context.Context) error {
+	return t.trackingDelegation(ctx)
+}
+
+type trackingDelegationInput struct {
+	indexer   *internal.DegovIndexer
+	scope     internal.ProposalScope
+	daoConfig *types.DaoConfig
+	delegate  string
+}
+
+func (t *TrackingDelegationTask) trackingDelegation(ctx context.Context) error {
+	daos, err := t.daoService.ListDaos(types.BasicInput[*types.ListDaosInput]{})
+	if err != nil {
+		slog.Error("Failed to list DAOs", "error", err)
+		return err
+	}
+
+	for _, dao := range daos {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		addresses, err := t.subscribeService.ListSubscribedAddresses(dbmodels.SubscribeFeatureDelegationChanged, dao.Code)
+		if err != nil {
+			slog.Error("Failed to list delegation subscribers", "dao_code", dao.Code, "error", err)
+			continue
+		}
+		if len(addresses) == 0 {
+			continue
+		}
+
+		daoConfig, err := t.daoConfigService.StandardConfig(dao.Code)
+		if err != nil {
+			slog.Error("Failed to get DAO config", "dao_code", dao.Code, "error", err)
+			continue
+		}
+		indexer := internal.NewDegovIndexer(daoConfig.Indexer.Endpoint)
+		scope := internal.ProposalScope{
+			ChainID:         daoConfig.Chain.ID,
+			DaoCode:         dao.Code,
+			GovernorAddress: daoConfig.Contracts.Governor,
+		}
+		for _, address := range addresses {
+			if err := t.trackingDelegationByDelegate(trackingDelegationInput{
+				indexer:   indexer,
+				scope:     scope,
+				daoConfig: daoConfig,
+				delegate:  address,
+			}); err != nil {
+				slog.Error("Failed to track delegation", "dao_code", dao.Code, "delegate", address, "error", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (t *TrackingDelegationTask) trackingDelegationByDelegate(input trackingDelegationInput) error {
+	ctx, cancel := context.WithTimeout(context.Background(), delegationQueryTimeout)
+	defer cancel()
+	current, err := input.indexer.QueryDelegatorsTo(ctx, input.scope, input.delegate)
+	if err != nil {
+		return err
+	}
+
+	changes, tracked, err := t.delegationService.Changes(input.scope.DaoCode, input.delegate, current)
+	if err != nil {
+		return fmt.Errorf("failed to diff delegations: %w", err)
+	}
+	if tracked && len(changes) > 0 {
+		// the event is stored before the snapshot, a failure in between notifies twice instead of never
+		if err := t.storeDelegationEvent(input, changes); err != nil {
+			return err
+		}
+		slog.Info("Delegation changed", "dao_code", input.scope.DaoCode, "delegate", input.delegate, "changes", len(changes))
+	}
+	if tracked && len(changes) == 0 {
+		return nil
+	}
+	return t.delegationService.SaveSnapshot(input.scope.DaoCode, input.delegate, current)
+}
+
+// tokenDecimals reads the decimals of the governor token from the indexer, as the proposal templates do. While the DAO
+// has no proposal yet they are read from the token contract.
+func (t *TrackingDelegationTask) tokenDecimals(input trackingDelegationInput) (int, error) {
+	token := input.daoConfig.Contracts.GovernorToken
+	if strings.EqualFold(token.Standard, "ERC721") {
+		return 0, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), delegationQueryTimeout)
+	defer cancel()
+
+	decimals, err := input.indexer.QueryTokenDecimals(ctx, input.scope)
+	if err != nil {
+		return 0, err
+	}
+	if decimals != nil {
+		return *decimals, nil
+	}
+	rpcURL := internal.GetRPCURL(input.daoConfig.Chain.RPCs, input.daoConfig.Chain.ID)
+	if rpcURL == "" || token.Address == "" {
+		return 0, fmt.Errorf("no proposal, rpc or token address to read the token decimals of %s", input.scope.DaoCode)
+	}
+	return internal.GetTokenDecimals(ctx, rpcURL, token.Address)
+}
+
+func (t *TrackingDelegationTask) storeDelegationEvent(input trackingDelegationInput, changes []types.DelegationChange) error {
+	gained, lost := services.DelegationPowerDelta(changes)
+	// powers are notified with the wrong magnitude otherwise, the changes are kept for the next run instead
+	decimals, err := t.tokenDecimals(input)
+	if err != nil {
+		return fmt.Errorf("failed to get token decimals: %w", err)
+	}
+	payload, err := json.Marshal(types.DelegationEventPayload{
+		Delegate:    input.delegate,
+		Changes:     changes,
+		PowerGained: gained.String(),
+		PowerLost:   lost.String(),
+		Decimals:    decimals,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode delegation payload: %w", err)
+	}
+	payloadStr := string(payload)
+	delegate := input.delegate
+	return t.notificationService.SaveEvent(dbmodels.NotificationEvent{
+		ChainID:       input.scope.ChainID,
+		DaoCode:       input.scope.DaoCode,
+		Type:          dbmodels.SubscribeFeatureDelegationChanged,
+		TargetAddress: &delegate,
+		TimeEvent:     time.Now(),
+		Payload:       &payloadStr,
+	})
+}
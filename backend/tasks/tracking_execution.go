@@ -0,0 +1,147 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	"github.com/ringecosystem/degov-square/internal"
+	"github.com/ringecosystem/degov-square/internal/config"
+	"github.com/ringecosystem/degov-square/services"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+type TrackingExecutionTask struct {
+	daoService          *services.DaoService
+	proposalService     *services.ProposalService
+	daoConfigService    *services.DaoConfigService
+	notificationService *services.NotificationService
+	expiringWindow      time.Duration
+}
+
+func NewTrackingExecutionTask() *TrackingExecutionTask {
+	return &TrackingExecutionTask{
+		daoService:          services.NewDaoService(),
+		proposalService:     services.NewProposalService(),
+		daoConfigService:    services.NewDaoConfigService(),
+		notificationService: services.NewNotificationService(),
+		expiringWindow:      config.GetConfig().GetTaskExecutionExpiringWindow(),
+	}
+}
+
+// Name returns the task name
+func (t *TrackingExecutionTask) Name() string {
+	return "tracking-execution"
+}
+
+// Execute stores EXECUTION_READY and EXECUTION_EXPIRING events for queued proposals
+func (t *TrackingExecutionTask) Execute(ctx context.Context) error {
+	return t.trackingExecution(ctx)
+}
+
+func (t *TrackingExecutionTask) trackingExecution(ctx context.Context) error {
+	daos, err := t.daoService.ListDaos(types.BasicInput[*types.ListDaosInput]{})
+	if err != nil {
+		slog.Error("Failed to list DAOs", "error", err)
+		return err
+	}
+
+	now := time.Now()
+	for _, dao := range daos {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		proposals, err := t.proposalService.ListProposals(types.ListProposalsInput{
+			DaoCode: dao.Code,
+			State:   dbmodels.ProposalStateQueued,
+		})
+		if err != nil {
+			slog.Error("Failed to list queued proposals", "dao_code", dao.Code, "error", err)
+			continue
+		}
+		if len(proposals) == 0 {
+			continue
+		}
+
+		daoConfig, err := t.daoConfigService.StandardConfig(dao.Code)
+		if err != nil {
+			slog.Error("Failed to get DAO config", "dao_code", dao.Code, "error", err)
+			continue
+		}
+		indexer := internal.NewDegovIndexer(daoConfig.Indexer.Endpoint)
+		scope := internal.ProposalScope{
+			ChainID:         daoConfig.Chain.ID,
+			DaoCode:         dao.Code,
+			GovernorAddress: daoConfig.Contracts.Governor,
+		}
+
+		notificationEvents := []dbmodels.NotificationEvent{}
+		for _, proposal := range proposals {
+			proposalIndexer, err := indexer.InspectProposal(scope, proposal.ProposalID)
+			if err != nil {
+				slog.Warn("Failed to inspect queued proposal", "dao_code", dao.Code, "proposal_id", proposal.ProposalID, "error", err)
+				continue
+			}
+			readyAt, expiresAt, err := proposalIndexer.ExecutionWindow()
+			if err != nil {
+				slog.Warn("Queued proposal has no execution window", "dao_code", dao.Code, "proposal_id", proposal.ProposalID, "error", err)
+				continue
+			}
+			payload, err := json.Marshal(types.ExecutionEventPayload{
+				ReadyAt:   strconv.FormatInt(readyAt.UnixMilli(), 10),
+				ExpiresAt: strconv.FormatInt(expiresAt.UnixMilli(), 10),
+			})
+			if err != nil {
+				return fmt.Errorf("failed to encode execution payload: %w", err)
+			}
+			payloadStr := string(payload)
+
+			for _, feature := range executionFeaturesDue(now, readyAt, expiresAt, t.expiringWindow) {
+				existingEvent, _ := t.notificationService.InspectEventWithProposal(types.InspectNotificationEventInput{
+					DaoCode:    dao.Code,
+					ProposalID: proposal.ProposalID,
+					Type:       feature,
+				})
+				if existingEvent != nil {
+					continue
+				}
+				timeEvent := readyAt
+				if feature == dbmodels.SubscribeFeatureExecutionExpiring {
+					timeEvent = expiresAt
+				}
+				slog.Info("Queued proposal execution reminder", "dao_code", dao.Code, "proposal_id", proposal.ProposalID, "feature", feature)
+				notificationEvents = append(notificationEvents, dbmodels.NotificationEvent{
+					ChainID:    int(dao.ChainID),
+					DaoCode:    dao.Code,
+					Type:       feature,
+					ProposalID: proposal.ProposalID,
+					TimeEvent:  timeEvent,
+					Payload:    &payloadStr,
+				})
+			}
+		}
+		if err := t.notificationService.SaveEvents(notificationEvents); err != nil {
+			slog.Warn("Failed to save notification events", "dao_code", dao.Code, "error", err)
+		}
+	}
+	return nil
+}
+
+// executionFeaturesDue returns the execution reminders a queued proposal is due for, none once the grace period is over.
+func executionFeaturesDue(now, readyAt, expiresAt time.Time, expiringWindow time.Duration) []dbmodels.SubscribeFeatureName {
+	if !now.Before(expiresAt) {
+		return nil
+	}
+	features := make([]dbmodels.SubscribeFeatureName, 0, 2)
+	if !now.Before(readyAt) {
+		features = append(features, dbmodels.SubscribeFeatureExecutionReady)
+	}
+	if !now.Before(expiresAt.Add(-expiringWindow)) {
+		features = append(features, dbmodels.SubscribeFeatureExecutionExpiring)
+	}
+	return features
+}
@@ -38,12 +38,12 @@ func (t *TrackingProposalTask) Name() string {
 }
 
 // Execute performs the DAO synchronization
-func (t *TrackingProposalTask) Execute() error {
-	return t.trackingProposal()
+func (t *TrackingProposalTask) Execute(ctx context.Context) error {
+	return t.trackingProposal(ctx)
 }
 
 // TrackingProposal tracks proposals for DAOs
-func (t *TrackingProposalTask) trackingProposal() error {
+func (t *TrackingProposalTask) trackingProposal(ctx context.Context) error {
 	// Get all DAOs from DaoService.ListDaos
 	daos, err := t.daoService.ListDaos(types.BasicInput[*types.ListDaosInput]{})
 	if err != nil {
@@ -55,6 +55,9 @@ func (t *TrackingProposalTask) trackingProposal() error {
 
 	// Iterate through each DAO and get its config from DaoConfigService
 	for _, dao := range daos {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		daoConfig, err := t.daoConfigService.StandardConfig(dao.Code)
 		if err != nil {
 			slog.Error("Failed to get DAO config", "dao_code", dao.Code, "error", err)
@@ -2,8 +2,10 @@ package tasks
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"time"
 
 	dbmodels "github.com/ringecosystem/degov-square/database/models"
@@ -36,8 +38,8 @@ func (t *TrackingVoteTask) Name() string {
 }
 
 // Execute performs the DAO synchronization
-func (t *TrackingVoteTask) Execute() error {
-	return t.trackingVote()
+func (t *TrackingVoteTask) Execute(ctx context.Context) error {
+	return t.trackingVote(ctx)
 }
 
 type trackingVoteInput struct {
@@ -47,7 +49,7 @@ type trackingVoteInput struct {
 	proposal  *dbmodels.ProposalTracking
 }
 
-func (t *TrackingVoteTask) trackingVote() error {
+func (t *TrackingVoteTask) trackingVote(ctx context.Context) error {
 	daos, err := t.daoService.ListDaos(types.BasicInput[*types.ListDaosInput]{})
 	if err != nil {
 		slog.Error("Failed to list DAOs", "error", err)
@@ -55,6 +57,9 @@ func (t *TrackingVoteTask) trackingVote() error {
 	}
 
 	for _, dao := range daos {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		// Get DAO config from DaoConfigService by DaoCode
 		daoConfig, err := t.daoConfigService.StandardConfig(dao.Code)
 		if err != nil {
@@ -104,7 +109,7 @@ func (t *TrackingVoteTask) trackingVoteByProposal(input trackingVoteInput) error
 	}
 
 	// 2. Page through subscribed users using the earliest time and generate notifications
-	return t.generateAndStoreNotificationEvents(input.proposal, processedVotes)
+	return t.generateAndStoreNotificationEvents(input, processedVotes)
 }
 
 func (t *TrackingVoteTask) fetchAllAndProcessVotes(input trackingVoteInput) ([]processedVote, error) {
@@ -149,9 +154,39 @@ func (t *TrackingVoteTask) fetchAllAndProcessVotes(input trackingVoteInput) ([]p
 	return processedVotes, nil
 }
 
-func (t *TrackingVoteTask) generateAndStoreNotificationEvents(proposal *dbmodels.ProposalTracking, processedVotes []processedVote) error {
+func (t *TrackingVoteTask) generateAndStoreNotificationEvents(input trackingVoteInput, processedVotes []processedVote) error {
+	proposal := input.proposal
+
+	// quorum and decimals let vote threshold strategies be evaluated when records are built
+	var (
+		quorum   string
+		decimals *int
+	)
+	proposalIndexer, err := input.indexer.InspectProposal(internal.ProposalScope{
+		ChainID:         input.daoConfig.Chain.ID,
+		DaoCode:         input.dao.Code,
+		GovernorAddress: input.daoConfig.Contracts.Governor,
+	}, proposal.ProposalID)
+	if err != nil {
+		slog.Warn("Failed to inspect proposal for vote events, vote thresholds will not match", "dao_code", proposal.DaoCode, "proposal_id", proposal.ProposalID, "error", err)
+	} else {
+		quorum = proposalIndexer.Quorum
+		if value, err := strconv.Atoi(proposalIndexer.Decimals); err == nil {
+			decimals = &value
+		}
+	}
+
 	notificationEvents := []dbmodels.NotificationEvent{}
 	for _, vote := range processedVotes {
+		payload, err := json.Marshal(types.VoteEventPayload{
+			Weight:   vote.Vote.Weight,
+			Quorum:   quorum,
+			Decimals: decimals,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to encode vote payload: %w", err)
+		}
+		payloadStr := string(payload)
 		ne := dbmodels.NotificationEvent{
 			ChainID:    proposal.ChainId,
 			DaoCode:    proposal.DaoCode,
@@ -159,6 +194,7 @@ func (t *TrackingVoteTask) generateAndStoreNotificationEvents(proposal *dbmodels
 			ProposalID: proposal.ProposalID,
 			VoteID:     &vote.Vote.ID,
 			TimeEvent:  vote.Timestamp,
+			Payload:    &payloadStr,
 		}
 		notificationEvents = append(notificationEvents, ne)
 	}
@@ -1,6 +1,7 @@
 package tasks
 
 import (
+	"context"
 	"log/slog"
 
 	dbmodels "github.com/ringecosystem/degov-square/database/models"
@@ -30,11 +31,11 @@ func (t *TrackingVoteEndTask) Name() string {
 }
 
 // Execute performs the DAO synchronization
-func (t *TrackingVoteEndTask) Execute() error {
-	return t.trackingVoteEnd()
+func (t *TrackingVoteEndTask) Execute(ctx context.Context) error {
+	return t.trackingVoteEnd(ctx)
 }
 
-func (t *TrackingVoteEndTask) trackingVoteEnd() error {
+func (t *TrackingVoteEndTask) trackingVoteEnd(ctx context.Context) error {
 	daos, err := t.daoService.ListDaos(types.BasicInput[*types.ListDaosInput]{})
 	if err != nil {
 		slog.Error("Failed to list DAOs", "error", err)
@@ -42,6 +43,9 @@ func (t *TrackingVoteEndTask) trackingVoteEnd() error {
 	}
 
 	for _, dao := range daos {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		daoConfig, err := t.daoConfigService.StandardConfig(dao.Code)
 		if err != nil {
 			slog.Error("Failed to get DAO config", "dao_code", dao.Code, "error", err)
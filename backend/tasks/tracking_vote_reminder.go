@@ -0,0 +1,194 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"time"
+
+	dbmodels "github.com/ringecosystem/degov-square/database/models"
+	"github.com/ringecosystem/degov-square/internal"
+	"github.com/ringecosystem/degov-square/internal/utils"
+	"github.com/ringecosystem/degov-square/services"
+	"github.com/ringecosystem/degov-square/types"
+)
+
+type TrackingVoteReminderTask struct {
+	daoService          *services.DaoService
+	proposalService     *services.ProposalService
+	daoConfigService    *services.DaoConfigService
+	subscribeService    *services.SubscribeService
+	notificationService *services.NotificationService
+}
+
+func NewTrackingVoteReminderTask() *TrackingVoteReminderTask {
+	return &TrackingVoteReminderTask{
+		daoService:          services.NewDaoService(),
+		proposalService:     services.NewProposalService(),
+		daoConfigService:    services.NewDaoConfigService(),
+		subscribeService:    services.NewSubscribeService(),
+		notificationService: services.NewNotificationService(),
+	}
+}
+
+// Name returns the task name
+func (t *TrackingVoteReminderTask) Name() string {
+	return "tracking-vote-reminder"
+}
+
+// Execute stores VOTE_REMINDER events for subscribers who have not voted on active proposals yet
+func (t *TrackingVoteReminderTask) Execute(ctx context.Context) error {
+	return t.trackingVoteReminder(ctx)
+}
+
+type trackingVoteReminderInput struct {
+	indexer  *internal.DegovIndexer
+	scope    internal.ProposalScope
+	proposal *dbmodels.ProposalTracking
+}
+
+func (t *TrackingVoteReminderTask) trackingVoteReminder(ctx context.Context) error {
+	daos, err := t.daoService.ListDaos(types.BasicInput[*types.ListDaosInput]{})
+	if err != nil {
+		slog.Error("Failed to list DAOs", "error", err)
+		return err
+	}
+
+	for _, dao := range daos {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		proposals, err := t.proposalService.ListProposals(types.ListProposalsInput{
+			DaoCode: dao.Code,
+			State:   dbmodels.ProposalStateActive,
+		})
+		if err != nil {
+			slog.Error("Failed to list active proposals", "dao_code", dao.Code, "error", err)
+			continue
+		}
+		if len(proposals) == 0 {
+			continue
+		}
+
+		daoConfig, err := t.daoConfigService.StandardConfig(dao.Code)
+		if err != nil {
+			slog.Error("Failed to get DAO config", "dao_code", dao.Code, "error", err)
+			continue
+		}
+		indexer := internal.NewDegovIndexer(daoConfig.Indexer.Endpoint)
+		scope := internal.ProposalScope{
+			ChainID:         daoConfig.Chain.ID,
+			DaoCode:         dao.Code,
+			GovernorAddress: daoConfig.Contracts.Governor,
+		}
+		for _, proposal := range proposals {
+			if err := t.trackingVoteReminderByProposal(trackingVoteReminderInput{
+				indexer:  indexer,
+				scope:    scope,
+				proposal: proposal,
+			}); err != nil {
+				slog.Error("Failed to track vote reminders", "dao_code", dao.Code, "proposal_id", proposal.ProposalID, "error", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (t *TrackingVoteReminderTask) trackingVoteReminderByProposal(input trackingVoteReminderInput) error {
+	proposal := input.proposal
+	subscribers, err := t.subscribeService.ListVoteReminderSubscribers(proposal.DaoCode, proposal.ProposalID)
+	if err != nil {
+		return fmt.Errorf("failed to list vote reminder subscribers: %w", err)
+	}
+	if len(subscribers) == 0 {
+		return nil
+	}
+
+	proposalIndexer, err := input.indexer.InspectProposal(input.scope, proposal.ProposalID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect proposal: %w", err)
+	}
+	voteEnd, err := utils.ParseTimestamp(proposalIndexer.VoteEndTimestamp)
+	if err != nil {
+		return fmt.Errorf("failed to parse vote end timestamp %q: %w", proposalIndexer.VoteEndTimestamp, err)
+	}
+
+	now := time.Now()
+	notificationEvents := []dbmodels.NotificationEvent{}
+	for _, subscriber := range subscribers {
+		lead, err := services.ParseVoteReminderLead(subscriber.Strategy)
+		if err != nil {
+			slog.Warn("Skip invalid vote reminder strategy", "user_address", subscriber.UserAddress, "strategy", subscriber.Strategy)
+			continue
+		}
+		if now.Before(voteEnd.Add(-lead)) || !now.Before(voteEnd) {
+			continue
+		}
+
+		address := subscriber.UserAddress
+		existingEvent, _ := t.notificationService.InspectEventWithProposal(types.InspectNotificationEventInput{
+			DaoCode:       proposal.DaoCode,
+			ProposalID:    proposal.ProposalID,
+			TargetAddress: &address,
+			Type:          dbmodels.SubscribeFeatureVoteReminder,
+		})
+		if existingEvent != nil {
+			continue
+		}
+
+		power, due, err := t.reminderDue(input, address)
+		if err != nil {
+			slog.Warn("Failed to check vote reminder", "dao_code", proposal.DaoCode, "proposal_id", proposal.ProposalID, "user_address", address, "error", err)
+			continue
+		}
+		if !due {
+			continue
+		}
+
+		payload, err := json.Marshal(types.VoteReminderEventPayload{Power: power})
+		if err != nil {
+			return fmt.Errorf("failed to encode vote reminder payload: %w", err)
+		}
+		payloadStr := string(payload)
+		notificationEvents = append(notificationEvents, dbmodels.NotificationEvent{
+			ChainID:       proposal.ChainId,
+			DaoCode:       proposal.DaoCode,
+			Type:          dbmodels.SubscribeFeatureVoteReminder,
+			ProposalID:    proposal.ProposalID,
+			TargetAddress: &address,
+			TimeEvent:     voteEnd,
+			Payload:       &payloadStr,
+		})
+	}
+	return t.notificationService.SaveEvents(notificationEvents)
+}
+
+// reminderDue reports whether the address has voting power and has not voted on the proposal yet.
+// The current voting power is used, the indexer does not expose the power at the proposal snapshot.
+func (t *TrackingVoteReminderTask) reminderDue(input trackingVoteReminderInput, address string) (string, bool, error) {
+	_, err := input.indexer.QueryVoteByVoter(input.scope, input.proposal.ProposalID, address)
+	if err == nil {
+		return "", false, nil
+	}
+	if !errors.Is(err, internal.ErrVoteNotFound) {
+		return "", false, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	contributor, err := input.indexer.QueryContributor(ctx, input.scope, address)
+	if errors.Is(err, internal.ErrContributorNotFound) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	power, ok := new(big.Int).SetString(contributor.Power, 10)
+	if !ok || power.Sign() <= 0 {
+		return "", false, nil
+	}
+	return power.String(), true, nil
+}
@@ -60,6 +60,16 @@ type DegovSiteConfig struct {
 	Square                          string
 	Docs                            string
 	Socials                         []DegovSiteConfigSocial
+	// Colors, Footer and SenderName are only set by the branding of a DAO
+	Colors     DegovSiteConfigColors
+	Footer     string
+	SenderName string
+}
+
+type DegovSiteConfigColors struct {
+	Primary     string
+	PrimaryText string
+	Link        string
 }
 
 type DegovSiteConfigSocial struct {
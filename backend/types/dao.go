@@ -87,4 +87,31 @@ type DaoConfig struct {
 		ChainID int    `yaml:"chainId"`
 		Link    string `yaml:"link"`
 	} `yaml:"safes"`
+	Branding *DaoBranding `yaml:"branding"`
+}
+
+// DaoBranding is the optional email branding of a DAO, unset fields keep the DeGov.AI site config
+type DaoBranding struct {
+	Name       string `yaml:"name"`
+	SenderName string `yaml:"senderName"`
+	Logo       string `yaml:"logo"`
+	LogoLight  string `yaml:"logoLight"`
+	LogoDark   string `yaml:"logoDark"`
+	Home       string `yaml:"home"`
+	Theme      string `yaml:"theme"`
+	Colors     struct {
+		Primary     string `yaml:"primary"`
+		PrimaryText string `yaml:"primaryText"`
+		Link        string `yaml:"link"`
+	} `yaml:"colors"`
+	Socials []DaoBrandingSocial `yaml:"socials"`
+	Footer  string              `yaml:"footer"`
+}
+
+type DaoBrandingSocial struct {
+	Name      string `yaml:"name"`
+	Link      string `yaml:"link"`
+	Icon      string `yaml:"icon"`
+	IconLight string `yaml:"iconLight"`
+	IconDark  string `yaml:"iconDark"`
 }
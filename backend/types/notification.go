@@ -1,6 +1,8 @@
 package types
 
 import (
+	"time"
+
 	dbmodels "github.com/ringecosystem/degov-square/database/models"
 )
 
@@ -8,8 +10,10 @@ type InspectNotificationEventInput struct {
 	DaoCode    string
 	ProposalID string
 	VoteID     *string
-	Type       dbmodels.SubscribeFeatureName
-	States     *[]dbmodels.NotificationEventState
+	// TargetAddress matches events sent to a single subscriber
+	TargetAddress *string
+	Type          dbmodels.SubscribeFeatureName
+	States        *[]dbmodels.NotificationEventState
 }
 
 type ListLimitEventsInput struct {
@@ -20,17 +24,43 @@ type ListLimitEventsInput struct {
 type ListLimitRecordsInput struct {
 	Limit  int
 	States *[]dbmodels.NotificationRecordState
+	// InstantOnly skips records held for a digest
+	InstantOnly bool
+}
+
+// NotificationDeadLetterFilter selects failed events or records, every set field narrows the selection
+type NotificationDeadLetterFilter struct {
+	IDs     []string
+	DaoCode string
+	Types   []dbmodels.SubscribeFeatureName
+	// Since and Until bound the time of the last attempt
+	Since *time.Time
+	Until *time.Time
+}
+
+type NotificationDigestGroup struct {
+	UserID string
+	Digest string
+}
+
+type ListDigestRecordsInput struct {
+	UserID string
+	Digest string
+	Limit  int
 }
 
 type UpdateEventStateInput struct {
 	ID    string
 	State dbmodels.NotificationEventState
+	// LeaseUntil is the lease taken by ClaimEvent, when set the event is only updated while the lease is still held
+	LeaseUntil *time.Time
 }
 
 type UpdateEventRetryTimes struct {
 	ID         string
 	TimesRetry int
 	Message    string
+	LeaseUntil *time.Time
 }
 
 type UpdateRecordStateInput struct {
@@ -44,6 +74,19 @@ type UpdateRecordRetryTimes struct {
 	Message    string
 }
 
+type UpdateDeliveryStateInput struct {
+	ID           string
+	State        dbmodels.NotificationDeliveryState
+	ResponseCode *int
+}
+
+type UpdateDeliveryRetryTimes struct {
+	ID           string
+	TimesRetry   int
+	LastError    string
+	ResponseCode *int
+}
+
 type ListChannelInput struct {
 	Verified *bool
 }
@@ -52,4 +95,58 @@ type NotifyInput struct {
 	Type     dbmodels.NotificationChannelType
 	To       string
 	Template *TemplateOutput
+	// Record is set when delivering a notification record, channels with structured payloads use it
+	Record *dbmodels.NotificationRecord
+	// Payload is the channel payload, e.g. the webhook signing secret
+	Payload *string
+}
+
+type NotifyOutput struct {
+	// StatusCode is the provider response code, zero when the channel has none
+	StatusCode int
+}
+
+// VoteEventPayload is stored on VOTE_EMITTED events so subscription strategies can be evaluated without the indexer
+type VoteEventPayload struct {
+	// Weight is the raw vote weight, in the smallest token unit
+	Weight string `json:"weight"`
+	// Quorum and Decimals are empty when the proposal could not be loaded from the indexer
+	Quorum   string `json:"quorum,omitempty"`
+	Decimals *int   `json:"decimals,omitempty"`
+}
+
+// ExecutionEventPayload is stored on EXECUTION_READY and EXECUTION_EXPIRING events, times are unix milliseconds
+type ExecutionEventPayload struct {
+	ReadyAt   string `json:"ready_at"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// VoteReminderEventPayload is stored on VOTE_REMINDER events, power is the raw voting power of the reminded subscriber
+type VoteReminderEventPayload struct {
+	Power string `json:"power"`
+}
+
+// CommentEventPayload is stored on COMMENT_REPLY and COMMENT_MENTION events, the excerpt is the start of the comment body
+type CommentEventPayload struct {
+	CommentID string  `json:"comment_id"`
+	ReplyToID *string `json:"reply_to_id,omitempty"`
+	Author    string  `json:"author"`
+	Excerpt   string  `json:"excerpt"`
+}
+
+// DelegationEventPayload is stored on DELEGATION_CHANGED events, powers are raw values in the smallest token unit
+type DelegationEventPayload struct {
+	Delegate    string             `json:"delegate"`
+	Changes     []DelegationChange `json:"changes"`
+	PowerGained string             `json:"power_gained"`
+	PowerLost   string             `json:"power_lost"`
+	Decimals    int                `json:"decimals"`
+}
+
+// DelegationChange is one delegator whose delegation to the delegate was added, changed or removed
+type DelegationChange struct {
+	Delegator string `json:"delegator"`
+	// PreviousPower is "0" for a new delegation, Power is "0" for a removed one
+	PreviousPower string `json:"previous_power"`
+	Power         string `json:"power"`
 }
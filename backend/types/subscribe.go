@@ -14,19 +14,17 @@ type ListSubscribeUserInput struct {
 	// TimeEvent is the timestamp of the event; only users who subscribed
 	// before or at this time should be returned.
 	TimeEvent *time.Time
-	Limit     int
-	Offset    int
-}
-
-type ListSubscribedUserOutput struct {
-	UserID      string
-	UserAddress string
-	ChainID     int
-	DaoCode     string
-	CTime       time.Time `gorm:"column:ctime"`
+	// TargetAddress restricts the users to the ones subscribed with this address
+	TargetAddress *string
 }
 
 type ListFeaturesInput struct {
 	DaoCode    string
 	ProposalID *string
 }
+
+type VoteReminderSubscriber struct {
+	UserAddress string
+	// Strategy is the reminder lead time, a proposal level setting wins over the DAO level one
+	Strategy string
+}
@@ -1,16 +1,59 @@
 package types
 
+import dbmodels "github.com/ringecosystem/degov-square/database/models"
+
 type GenerateTemplateOTPInput struct {
 	DegovSiteConfig DegovSiteConfig `json:"degov_site_config"`
-	EmailStyle      *EmailStyle      `json:"email_style"`
+	EmailStyle      *EmailStyle     `json:"email_style"`
 	OTP             string          `json:"otp"`
 	Expiration      int             `json:"expiration"`
 	UserAddress     string          `json:"user_address"`
 	EnsName         *string         `json:"ens_name"`
 }
 
+type GenerateDigestTemplateInput struct {
+	Digest      string
+	UserAddress string
+	Records     []dbmodels.NotificationRecord
+}
+
+// InboxAudience is a locale and timezone the inbox content of an event is rendered in, once for all of its users
+type InboxAudience struct {
+	Locale   string `json:"locale"`
+	Timezone string `json:"timezone"`
+}
+
 type TemplateOutput struct {
 	Title            string `json:"title"`
 	RichTextContent  string `json:"rich_text_content"`
 	PlainTextContent string `json:"plain_text_content"`
+	// Summary is only set for notification records, chat channels render it as a native card
+	Summary *TemplateSummary `json:"summary,omitempty"`
+	// UnsubscribeURL is the one-click unsubscribe link, sent as the List-Unsubscribe header of emails
+	UnsubscribeURL string `json:"unsubscribe_url,omitempty"`
+	// SenderName replaces the configured from name of emails, set by the branding of the DAO
+	SenderName string `json:"sender_name,omitempty"`
+}
+
+type TemplateSummary struct {
+	DaoName       string             `json:"dao_name"`
+	DaoLogo       string             `json:"dao_logo,omitempty"`
+	ProposalTitle string             `json:"proposal_title"`
+	ProposalLink  string             `json:"proposal_link"`
+	ProposalState string             `json:"proposal_state"`
+	Headline      string             `json:"headline"`
+	Votes         *TemplateVoteTally `json:"votes,omitempty"`
+}
+
+// TemplateVoteTally holds vote weights already formatted for display.
+type TemplateVoteTally struct {
+	For            string  `json:"for"`
+	Against        string  `json:"against"`
+	Abstain        string  `json:"abstain"`
+	Total          string  `json:"total"`
+	Quorum         string  `json:"quorum"`
+	PercentFor     float64 `json:"percent_for"`
+	PercentAgainst float64 `json:"percent_against"`
+	PercentAbstain float64 `json:"percent_abstain"`
+	PercentQuorum  float64 `json:"percent_quorum"`
 }